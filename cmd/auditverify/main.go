@@ -0,0 +1,93 @@
+// auditverify 离线校验认证中心导出的审计日志（GET /api/v1/system/audit/export）
+//
+// 用法:
+//
+//	go run ./cmd/auditverify -input audit.jsonl -key-id 3f2a9c0d1e4b5a67
+//	go run ./cmd/auditverify -input audit.csv -pubkey <base64 公钥>
+//	go run ./cmd/auditverify -genkey ./config/audit_signing.key
+//
+// 逐条重算记录哈希，检查序号连续性、前后链接以及检查点签名；发现问题时以状态码 1 退出。
+// 未指定 -pubkey 时使用导出文件中携带的公钥，此时应通过 -key-id 与服务端公钥比对，否则只能证明文件内部一致。
+// -genkey 生成服务端的检查点签名私钥，多副本部署时将同一密钥分发到所有副本
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"auth-center/utils"
+)
+
+func main() {
+	input := flag.String("input", "", "导出的审计日志文件")
+	format := flag.String("format", "", "文件格式：jsonl 或 csv（默认按扩展名判断）")
+	pubkey := flag.String("pubkey", "", "可信的检查点签名公钥（Ed25519，base64）")
+	keyID := flag.String("key-id", "", "期望的签名公钥ID")
+	genkey := flag.String("genkey", "", "生成检查点签名私钥并写入该文件")
+	flag.Parse()
+
+	if *genkey != "" {
+		key, err := utils.GenerateEd25519KeyFile(*genkey)
+		if err != nil {
+			log.Fatalf("生成签名密钥失败: %v", err)
+		}
+		pub := key.Public().(ed25519.PublicKey)
+		fmt.Printf("已生成签名密钥 %s\n公钥 %s\n公钥ID %s\n", *genkey, base64.StdEncoding.EncodeToString(pub), utils.AuditKeyID(pub))
+		return
+	}
+
+	if *input == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *format == "" {
+		*format = utils.AuditExportJSONL
+		if strings.EqualFold(filepath.Ext(*input), ".csv") {
+			*format = utils.AuditExportCSV
+		}
+	}
+
+	var trusted ed25519.PublicKey
+	if *pubkey != "" {
+		raw, err := base64.StdEncoding.DecodeString(*pubkey)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			log.Fatalf("公钥格式错误")
+		}
+		trusted = ed25519.PublicKey(raw)
+	}
+
+	file, err := os.Open(*input)
+	if err != nil {
+		log.Fatalf("打开文件失败: %v", err)
+	}
+	defer file.Close()
+
+	verifier, fileKeyID, err := utils.VerifyAuditExport(file, *format, trusted)
+	if err != nil {
+		log.Fatalf("校验失败: %v", err)
+	}
+
+	issues := verifier.Issues
+	if *keyID != "" && fileKeyID != *keyID {
+		issues = append(issues, utils.AuditIssue{Type: utils.AuditIssueBadSignature, Message: fmt.Sprintf("签名公钥ID为 %s，期望 %s", fileKeyID, *keyID)})
+	}
+
+	fmt.Printf("已校验 %d 条记录，签名公钥ID %s\n", verifier.Checked, fileKeyID)
+	if len(issues) == 0 {
+		fmt.Println("校验通过")
+		return
+	}
+
+	for _, issue := range issues {
+		fmt.Printf("[%s] seq=%d %s\n", issue.Type, issue.Seq, issue.Message)
+	}
+	fmt.Printf("发现 %d 个问题\n", len(issues))
+	os.Exit(1)
+}
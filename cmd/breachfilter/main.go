@@ -0,0 +1,112 @@
+// breachfilter 由泄露密码列表构建离线布隆过滤器文件，供认证中心在注册、修改密码和登录时检测泄露密码
+//
+// 用法:
+//
+//	go run ./cmd/breachfilter -input passwords.txt -output config/breached.bloom
+//	go run ./cmd/breachfilter -input pwned-passwords-sha1.txt -sha1 -output config/breached.bloom
+//
+// 明文模式下每行一个密码；-sha1 模式下每行为 40 位十六进制 SHA-1（兼容 "HASH:次数" 格式）
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"auth-center/utils"
+)
+
+func main() {
+	input := flag.String("input", "", "泄露密码列表文件")
+	output := flag.String("output", "breached.bloom", "输出的布隆过滤器文件")
+	fpRate := flag.Float64("fp", 0.001, "期望误判率")
+	isSHA1 := flag.Bool("sha1", false, "输入文件每行为 SHA-1 十六进制哈希")
+	flag.Parse()
+
+	if *input == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// 第一遍统计条数，用于确定过滤器大小
+	total, err := countEntries(*input)
+	if err != nil {
+		log.Fatalf("读取输入文件失败: %v", err)
+	}
+
+	filter := utils.NewBloomFilter(total, *fpRate)
+	skipped := 0
+	err = eachEntry(*input, func(line string) {
+		if !*isSHA1 {
+			filter.Add(line)
+			return
+		}
+
+		digest, ok := parseSHA1(line)
+		if !ok {
+			skipped++
+			return
+		}
+		filter.AddSHA1(digest)
+	})
+	if err != nil {
+		log.Fatalf("读取输入文件失败: %v", err)
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		log.Fatalf("创建输出文件失败: %v", err)
+	}
+	defer file.Close()
+
+	size, err := filter.WriteTo(file)
+	if err != nil {
+		log.Fatalf("写入布隆过滤器失败: %v", err)
+	}
+
+	fmt.Printf("已写入 %s：%d 条记录，跳过 %d 行，文件大小 %d 字节\n", *output, filter.Count(), skipped, size)
+}
+
+// countEntries 统计非空行数
+func countEntries(path string) (uint64, error) {
+	var n uint64
+	err := eachEntry(path, func(string) { n++ })
+	return n, err
+}
+
+// eachEntry 逐行读取非空行
+func eachEntry(path string, fn func(line string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		fn(line)
+	}
+	return scanner.Err()
+}
+
+// parseSHA1 解析 "HASH" 或 "HASH:次数" 格式的 SHA-1 行
+func parseSHA1(line string) ([sha1.Size]byte, bool) {
+	var digest [sha1.Size]byte
+	hash, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+	raw, err := hex.DecodeString(hash)
+	if err != nil || len(raw) != sha1.Size {
+		return digest, false
+	}
+	copy(digest[:], raw)
+	return digest, true
+}
@@ -2,9 +2,14 @@ package config
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"log"
+	"net"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"auth-center/models"
@@ -17,10 +22,20 @@ import (
 
 // Config 全局配置结构
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	JWT      JWTConfig
+	Server     ServerConfig
+	Database   DatabaseConfig
+	Redis      RedisConfig
+	JWT        JWTConfig
+	RateLimit  RateLimitConfig
+	Breach     BreachConfig
+	Password   PasswordHashConfig
+	Signature  SignatureConfig
+	TLS        TLSConfig
+	DPoP       DPoPConfig
+	Audit      AuditConfig
+	LoginRisk  LoginRiskConfig
+	Permission PermissionConfig
+	Relation   RelationConfig
 }
 
 // ServerConfig 服务器配置
@@ -55,6 +70,88 @@ type JWTConfig struct {
 	RefreshTTL       int64
 }
 
+// RateLimitConfig 限流配置
+type RateLimitConfig struct {
+	Enabled bool
+	Groups  map[string]RateLimitGroupConfig // 键为路由组名称，如 auth、permission
+}
+
+// RateLimitGroupConfig 路由组限流配置
+// IP、App、User 为各维度在窗口内允许的请求数，0 表示该维度不限流
+type RateLimitGroupConfig struct {
+	Window int64 // 滑动窗口长度（秒）
+	IP     int64
+	App    int64
+	User   int64
+}
+
+// BreachConfig 泄露密码检测配置
+type BreachConfig struct {
+	Enabled      bool
+	FilterFile   string // 由 cmd/breachfilter 生成的布隆过滤器文件
+	CheckOnLogin bool   // 登录时检测当前密码是否已泄露并标记用户
+}
+
+// PasswordHashConfig 密码哈希配置
+// 已存储的哈希与当前算法或参数不一致时，会在下次登录成功后自动升级
+type PasswordHashConfig struct {
+	Algorithm         string // bcrypt 或 argon2id
+	BcryptCost        int
+	Argon2Memory      uint32 // 内存开销（KiB）
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+}
+
+// SignatureConfig 请求签名配置
+type SignatureConfig struct {
+	MaxSkew       int64  // 允许的客户端时钟偏差（秒），同时决定 nonce 的保留时长
+	EncryptionKey string // 加密存储签名密钥的服务端密钥，为空时由 JWT 密钥派生；不能存放在数据库中
+}
+
+// TLSConfig HTTPS 与客户端证书（mTLS）配置
+type TLSConfig struct {
+	Enabled      bool
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string // 客户端证书 CA，配置后校验客户端证书链，并允许按证书主题识别应用
+	// ClientAuth 客户端证书要求：request（默认，可选出示，按指纹识别）、verify_if_given（出示则校验证书链）、require（必须出示并校验证书链）
+	ClientAuth string
+}
+
+// DPoPConfig DPoP（RFC 9449）配置
+type DPoPConfig struct {
+	MaxAge       int64 // DPoP 证明 iat 允许的最大偏差（秒），同时决定 jti 的保留时长
+	RequireNonce bool  // 是否要求证明携带服务端下发的 nonce
+	NonceTTL     int64 // 服务端 nonce 有效期（秒）
+	// 可信反向代理（IP 或 CIDR），只有来自这些地址的请求才按 X-Forwarded-Proto、X-Forwarded-Host 计算 htu
+	TrustedProxies []string
+}
+
+// AuditConfig 审计日志配置
+type AuditConfig struct {
+	SigningKey         string // 检查点签名私钥内容（Ed25519，PKCS#8 PEM 或其 DER 的 base64），优先于 SigningKeyFile
+	SigningKeyFile     string // 检查点签名私钥文件（Ed25519，PKCS#8 PEM），多副本部署时所有副本须使用同一密钥
+	CheckpointInterval int64  // 生成签名检查点的间隔（秒），0 表示不自动生成
+}
+
+// LoginRiskConfig 异常登录检测配置
+type LoginRiskConfig struct {
+	GeoIPFile string // 本地 GeoIP 数据库（MaxMind DB 格式，如 GeoLite2-City.mmdb），为空时不做地理位置判断
+	MFATTL    int64  // 异常登录二次验证的有效期（秒）
+}
+
+// PermissionConfig 权限判定配置
+type PermissionConfig struct {
+	SnapshotCacheSize       int64 // 进程内最多缓存多少个用户的权限快照，0 表示不缓存
+	AssignmentCheckInterval int64 // 检查分配有效期（删除到期的分配、撤销令牌、发出事件）的间隔（秒），0 表示不检查
+}
+
+// RelationConfig 关系授权配置
+type RelationConfig struct {
+	TupleGCInterval int64 // 清理已删除元组的间隔（秒），0 表示不清理
+	TupleGCWindow   int64 // 已删除元组的保留时间（秒），超过后被清理，早于清理版本的 at_exact_snapshot 令牌不再可用
+}
+
 var (
 	GlobalConfig *Config
 	DB           *gorm.DB
@@ -103,6 +200,89 @@ func loadConfig() {
 			RefreshSecretKey: cfg.Section("jwt").Key("refresh_secret_key").MustString("your-refresh-secret-key"),
 			RefreshTTL:       cfg.Section("jwt").Key("refresh_ttl").MustInt64(7200),
 		},
+		RateLimit: loadRateLimitConfig(cfg),
+		Breach: BreachConfig{
+			Enabled:      cfg.Section("breach").Key("enabled").MustBool(false),
+			FilterFile:   cfg.Section("breach").Key("filter_file").MustString("./config/breached.bloom"),
+			CheckOnLogin: cfg.Section("breach").Key("check_on_login").MustBool(false),
+		},
+		Password: PasswordHashConfig{
+			Algorithm:         cfg.Section("password_hash").Key("algorithm").MustString("bcrypt"),
+			BcryptCost:        cfg.Section("password_hash").Key("bcrypt_cost").MustInt(10),
+			Argon2Memory:      uint32(cfg.Section("password_hash").Key("argon2_memory").MustUint(64 * 1024)),
+			Argon2Iterations:  uint32(cfg.Section("password_hash").Key("argon2_iterations").MustUint(3)),
+			Argon2Parallelism: uint8(cfg.Section("password_hash").Key("argon2_parallelism").MustUint(2)),
+		},
+		Signature: SignatureConfig{
+			MaxSkew:       cfg.Section("signature").Key("max_skew").MustInt64(300),
+			EncryptionKey: cfg.Section("signature").Key("encryption_key").MustString(""),
+		},
+		TLS: TLSConfig{
+			Enabled:      cfg.Section("tls").Key("enabled").MustBool(false),
+			CertFile:     cfg.Section("tls").Key("cert_file").MustString(""),
+			KeyFile:      cfg.Section("tls").Key("key_file").MustString(""),
+			ClientCAFile: cfg.Section("tls").Key("client_ca_file").MustString(""),
+			ClientAuth:   cfg.Section("tls").Key("client_auth").MustString("request"),
+		},
+		DPoP: DPoPConfig{
+			MaxAge:         cfg.Section("dpop").Key("max_age").MustInt64(300),
+			RequireNonce:   cfg.Section("dpop").Key("require_nonce").MustBool(false),
+			NonceTTL:       cfg.Section("dpop").Key("nonce_ttl").MustInt64(300),
+			TrustedProxies: cfg.Section("dpop").Key("trusted_proxies").Strings(","),
+		},
+		Audit: AuditConfig{
+			SigningKey:         cfg.Section("audit").Key("signing_key").MustString(""),
+			SigningKeyFile:     cfg.Section("audit").Key("signing_key_file").MustString("./config/audit_signing.key"),
+			CheckpointInterval: cfg.Section("audit").Key("checkpoint_interval").MustInt64(3600),
+		},
+		LoginRisk: LoginRiskConfig{
+			GeoIPFile: cfg.Section("login_risk").Key("geoip_file").MustString(""),
+			MFATTL:    cfg.Section("login_risk").Key("mfa_ttl").MustInt64(300),
+		},
+		Permission: PermissionConfig{
+			SnapshotCacheSize:       cfg.Section("permission").Key("snapshot_cache_size").MustInt64(10000),
+			AssignmentCheckInterval: cfg.Section("permission").Key("assignment_check_interval").MustInt64(60),
+		},
+		Relation: RelationConfig{
+			TupleGCInterval: cfg.Section("relation").Key("tuple_gc_interval").MustInt64(3600),
+			TupleGCWindow:   cfg.Section("relation").Key("tuple_gc_window").MustInt64(86400),
+		},
+	}
+}
+
+// loadRateLimitConfig 读取限流配置
+// [rate_limit] 为总开关，[rate_limit.<group>] 为各路由组的窗口与各维度配额，未配置的组使用默认值
+func loadRateLimitConfig(cfg *ini.File) RateLimitConfig {
+	rl := RateLimitConfig{
+		Enabled: cfg.Section("rate_limit").Key("enabled").MustBool(true),
+		Groups:  defaultRateLimitGroups(),
+	}
+
+	for _, section := range cfg.Sections() {
+		name := section.Name()
+		if !strings.HasPrefix(name, "rate_limit.") {
+			continue
+		}
+		group := strings.TrimPrefix(name, "rate_limit.")
+		def := rl.Groups[group]
+		rl.Groups[group] = RateLimitGroupConfig{
+			Window: section.Key("window").MustInt64(orDefault(def.Window, 60)),
+			IP:     section.Key("ip").MustInt64(def.IP),
+			App:    section.Key("app").MustInt64(def.App),
+			User:   section.Key("user").MustInt64(def.User),
+		}
+	}
+
+	return rl
+}
+
+// defaultRateLimitGroups 默认的路由组限流配额
+func defaultRateLimitGroups() map[string]RateLimitGroupConfig {
+	return map[string]RateLimitGroupConfig{
+		// 登录、注册、刷新令牌：主要防止撞库和批量注册
+		"auth": {Window: 60, IP: 30, App: 600, User: 0},
+		// 权限校验：调用量大，按应用和用户限制
+		"permission": {Window: 60, IP: 0, App: 6000, User: 600},
 	}
 }
 
@@ -133,6 +313,56 @@ func getDefaultConfig() *Config {
 			RefreshSecretKey: getEnv("JWT_REFRESH_SECRET_KEY", "your-refresh-secret-key"),
 			RefreshTTL:       getEnvInt64("JWT_REFRESH_TTL", 72000),
 		},
+		RateLimit: RateLimitConfig{
+			Enabled: getEnvBool("RATE_LIMIT_ENABLED", true),
+			Groups:  defaultRateLimitGroups(),
+		},
+		Breach: BreachConfig{
+			Enabled:      getEnvBool("BREACH_CHECK_ENABLED", false),
+			FilterFile:   getEnv("BREACH_FILTER_FILE", "./config/breached.bloom"),
+			CheckOnLogin: getEnvBool("BREACH_CHECK_ON_LOGIN", false),
+		},
+		Password: PasswordHashConfig{
+			Algorithm:         getEnv("PASSWORD_HASH_ALGORITHM", "bcrypt"),
+			BcryptCost:        getEnvInt("PASSWORD_BCRYPT_COST", 10),
+			Argon2Memory:      uint32(getEnvInt("PASSWORD_ARGON2_MEMORY", 64*1024)),
+			Argon2Iterations:  uint32(getEnvInt("PASSWORD_ARGON2_ITERATIONS", 3)),
+			Argon2Parallelism: uint8(getEnvInt("PASSWORD_ARGON2_PARALLELISM", 2)),
+		},
+		Signature: SignatureConfig{
+			MaxSkew:       getEnvInt64("SIGNATURE_MAX_SKEW", 300),
+			EncryptionKey: getEnv("SIGNATURE_ENCRYPTION_KEY", ""),
+		},
+		TLS: TLSConfig{
+			Enabled:      getEnvBool("TLS_ENABLED", false),
+			CertFile:     getEnv("TLS_CERT_FILE", ""),
+			KeyFile:      getEnv("TLS_KEY_FILE", ""),
+			ClientCAFile: getEnv("TLS_CLIENT_CA_FILE", ""),
+			ClientAuth:   getEnv("TLS_CLIENT_AUTH", "request"),
+		},
+		DPoP: DPoPConfig{
+			MaxAge:         getEnvInt64("DPOP_MAX_AGE", 300),
+			RequireNonce:   getEnvBool("DPOP_REQUIRE_NONCE", false),
+			NonceTTL:       getEnvInt64("DPOP_NONCE_TTL", 300),
+			TrustedProxies: getEnvList("DPOP_TRUSTED_PROXIES"),
+		},
+		Audit: AuditConfig{
+			SigningKey:         getEnv("AUDIT_SIGNING_KEY", ""),
+			SigningKeyFile:     getEnv("AUDIT_SIGNING_KEY_FILE", "./config/audit_signing.key"),
+			CheckpointInterval: getEnvInt64("AUDIT_CHECKPOINT_INTERVAL", 3600),
+		},
+		LoginRisk: LoginRiskConfig{
+			GeoIPFile: getEnv("LOGIN_RISK_GEOIP_FILE", ""),
+			MFATTL:    getEnvInt64("LOGIN_RISK_MFA_TTL", 300),
+		},
+		Permission: PermissionConfig{
+			SnapshotCacheSize:       getEnvInt64("PERMISSION_SNAPSHOT_CACHE_SIZE", 10000),
+			AssignmentCheckInterval: getEnvInt64("PERMISSION_ASSIGNMENT_CHECK_INTERVAL", 60),
+		},
+		Relation: RelationConfig{
+			TupleGCInterval: getEnvInt64("RELATION_TUPLE_GC_INTERVAL", 3600),
+			TupleGCWindow:   getEnvInt64("RELATION_TUPLE_GC_WINDOW", 86400),
+		},
 	}
 }
 
@@ -171,6 +401,29 @@ func initDatabase() {
 		&models.RolePermission{},
 		&models.Token{},
 		&models.Provider{},
+		&models.RateLimitQuota{},
+		&models.PasswordPolicy{},
+		&models.PasswordHistory{},
+		&models.AppSecret{},
+		&models.AppCertificate{},
+		&models.AuditLog{},
+		&models.AuditCheckpoint{},
+		&models.AuditChainHead{},
+		&models.LoginHistory{},
+		&models.LoginRiskPolicy{},
+		&models.RoleInheritance{},
+		&models.UserPermission{},
+		&models.RelationSchema{},
+		&models.RelationTuple{},
+		&models.RoleApprover{},
+		&models.AccessRequest{},
+		&models.Group{},
+		&models.GroupMember{},
+		&models.GroupInheritance{},
+		&models.GroupRole{},
+		&models.Organization{},
+		&models.OrganizationMember{},
+		&models.OrganizationMemberRole{},
 	)
 	if err != nil {
 		log.Fatalf("数据库迁移失败: %v", err)
@@ -207,6 +460,70 @@ func buildDSN() string {
 		GlobalConfig.Database.Charset + "&parseTime=True&loc=Local"
 }
 
+// ServerTLSConfig 构建 HTTPS 服务的 TLS 配置
+// 未配置客户端 CA 时仅请求客户端证书而不校验证书链，由应用证书登记表按指纹识别应用
+func (c TLSConfig) ServerTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: tls.RequestClientCert,
+	}
+
+	if c.ClientCAFile != "" {
+		pemData, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, errors.New("客户端 CA 文件中没有有效的证书")
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	switch c.ClientAuth {
+	case "", "request":
+	case "verify_if_given":
+		if tlsConfig.ClientCAs == nil {
+			return nil, errors.New("client_auth = verify_if_given 需要配置 client_ca_file")
+		}
+	case "require":
+		if tlsConfig.ClientCAs == nil {
+			return nil, errors.New("client_auth = require 需要配置 client_ca_file")
+		}
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, errors.New("无效的 client_auth: " + c.ClientAuth)
+	}
+
+	return tlsConfig, nil
+}
+
+// TrustedProxyNetworks 解析可信反向代理，单个 IP 视为只包含该地址的网段
+func (c DPoPConfig) TrustedProxyNetworks() ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(c.TrustedProxies))
+	for _, proxy := range c.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, errors.New("无效的可信代理地址: " + proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, errors.New("无效的可信代理网段: " + proxy)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
 // GetConfig 获取全局配置
 func GetConfig() *Config {
 	return GlobalConfig
@@ -237,3 +554,30 @@ func getEnvInt64(key string, defaultValue int64) int64 {
 	}
 	return defaultValue
 }
+
+// getEnvList 读取逗号分隔的环境变量，忽略空项
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func orDefault(value, defaultValue int64) int64 {
+	if value == 0 {
+		return defaultValue
+	}
+	return value
+}
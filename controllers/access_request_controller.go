@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"auth-center/config"
+	"auth-center/middleware"
+	"auth-center/models"
+	"auth-center/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessRequestController 临时提权申请控制器
+// 用户接口（/api/v1/access-requests）供申请人和审批人使用，管理接口（/api/v1/app）用于设置角色的审批人和查询全部申请
+type AccessRequestController struct{}
+
+// writeAccessRequestError 按错误类型写入响应
+func writeAccessRequestError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrAccessRequestNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrAccessRequestNotApprover), errors.Is(err, service.ErrAccessRequestSelfApproval):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrAccessRequestDuplicate), errors.Is(err, service.ErrAccessRequestAlreadyGranted),
+		errors.Is(err, service.ErrAccessRequestNotPending):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrAccessRequestInvalid), errors.Is(err, service.ErrAccessRequestNotRequestable):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "处理申请失败"})
+	}
+}
+
+// parseAccessRequestID 解析路径中的申请ID
+func parseAccessRequestID(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的申请ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// CreateAccessRequest 申请在限定时间内获得角色
+// duration 为申请的时长（秒），不传时按角色允许的最长时长
+func (c *AccessRequestController) CreateAccessRequest(ctx *gin.Context) {
+	var req struct {
+		RoleID        uint   `json:"role_id" binding:"required"`
+		Justification string `json:"justification" binding:"required"`
+		Duration      int64  `json:"duration"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	accessRequestService := &service.AccessRequestService{}
+	request, err := accessRequestService.CreateRequest(ctx.GetString("app_id"), ctx.GetUint("user_id"), req.RoleID, req.Justification, req.Duration)
+	if err != nil {
+		writeAccessRequestError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"data": request})
+}
+
+// ListMyAccessRequests 查询自己的申请，可按 status 过滤
+func (c *AccessRequestController) ListMyAccessRequests(ctx *gin.Context) {
+	accessRequestService := &service.AccessRequestService{}
+	requests, err := accessRequestService.ListRequests(ctx.GetString("app_id"), ctx.GetUint("user_id"), ctx.Query("status"))
+	if err != nil {
+		writeAccessRequestError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": requests})
+}
+
+// ListPendingApprovals 查询自己可以审批的待审批申请
+func (c *AccessRequestController) ListPendingApprovals(ctx *gin.Context) {
+	accessRequestService := &service.AccessRequestService{}
+	requests, err := accessRequestService.ListPendingForApprover(ctx.GetString("app_id"), ctx.GetUint("user_id"))
+	if err != nil {
+		writeAccessRequestError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": requests})
+}
+
+// ApproveAccessRequest 批准申请，申请人立即获得有有效期的角色
+func (c *AccessRequestController) ApproveAccessRequest(ctx *gin.Context) {
+	c.review(ctx, true)
+}
+
+// DenyAccessRequest 拒绝申请
+func (c *AccessRequestController) DenyAccessRequest(ctx *gin.Context) {
+	c.review(ctx, false)
+}
+
+// review 审批申请，comment 为可选的审批意见
+func (c *AccessRequestController) review(ctx *gin.Context, approve bool) {
+	requestID, ok := parseAccessRequestID(ctx)
+	if !ok {
+		return
+	}
+	var req struct {
+		Comment string `json:"comment"`
+	}
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	accessRequestService := &service.AccessRequestService{}
+	request, err := accessRequestService.ReviewRequest(ctx.GetString("app_id"), ctx.GetUint("user_id"), requestID, approve, req.Comment)
+	if err != nil {
+		writeAccessRequestError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": request})
+}
+
+// CancelAccessRequest 撤回自己的待审批申请
+func (c *AccessRequestController) CancelAccessRequest(ctx *gin.Context) {
+	requestID, ok := parseAccessRequestID(ctx)
+	if !ok {
+		return
+	}
+
+	accessRequestService := &service.AccessRequestService{}
+	request, err := accessRequestService.CancelRequest(ctx.GetString("app_id"), ctx.GetUint("user_id"), requestID)
+	if err != nil {
+		writeAccessRequestError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": request})
+}
+
+// GetRoleAccessPolicy 获取角色的临时提权设置（管理接口）
+func (c *AccessRequestController) GetRoleAccessPolicy(ctx *gin.Context) {
+	appID := middleware.GetTargetAppID(ctx)
+
+	var role models.Role
+	if err := config.DB.Where("id = ? AND app_id = ?", ctx.Param("id"), appID).First(&role).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return
+	}
+
+	accessRequestService := &service.AccessRequestService{}
+	policy, err := accessRequestService.GetRoleAccessPolicy(appID, &role)
+	if err != nil {
+		writeAccessRequestError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": policy})
+}
+
+// SetRoleAccessPolicy 设置角色的临时提权设置（管理接口）：max_duration 为单次申请的最长时长（秒，0 表示不可申请），approver_ids 为审批人
+func (c *AccessRequestController) SetRoleAccessPolicy(ctx *gin.Context) {
+	appID := middleware.GetTargetAppID(ctx)
+
+	var req service.RoleAccessPolicy
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var role models.Role
+	if err := config.DB.Where("id = ? AND app_id = ?", ctx.Param("id"), appID).First(&role).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return
+	}
+
+	accessRequestService := &service.AccessRequestService{}
+	previous, _ := accessRequestService.GetRoleAccessPolicy(appID, &role)
+	if err := accessRequestService.SetRoleAccessPolicy(appID, &role, &req); err != nil {
+		writeAccessRequestError(ctx, err)
+		return
+	}
+	middleware.SetAuditChange(ctx, previous, req)
+
+	policy, _ := accessRequestService.GetRoleAccessPolicy(appID, &role)
+	ctx.JSON(http.StatusOK, gin.H{"data": policy})
+}
+
+// ListAccessRequests 查询应用内的申请（管理接口），可按 user_id 和 status 过滤
+func (c *AccessRequestController) ListAccessRequests(ctx *gin.Context) {
+	var userID uint
+	if value := ctx.Query("user_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+			return
+		}
+		userID = uint(id)
+	}
+
+	accessRequestService := &service.AccessRequestService{}
+	requests, err := accessRequestService.ListRequests(middleware.GetTargetAppID(ctx), userID, ctx.Query("status"))
+	if err != nil {
+		writeAccessRequestError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": requests})
+}
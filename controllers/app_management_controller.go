@@ -84,6 +84,10 @@ func (c *AppManagementController) CreateApp(ctx *gin.Context) {
 		return
 	}
 
+	middleware.SetAuditAppID(ctx, response.AppID)
+	middleware.SetAuditTarget(ctx, "app", response.AppID)
+	middleware.SetAuditChange(ctx, nil, response)
+
 	ctx.JSON(http.StatusCreated, gin.H{
 		"message": "应用创建成功",
 		"data":    response,
@@ -123,10 +127,12 @@ func (c *AppManagementController) UpdateApp(ctx *gin.Context) {
 		updates["status"] = req.Status
 	}
 
+	before := app
 	if err := config.DB.Model(&app).Updates(updates).Error; err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "更新应用失败"})
 		return
 	}
+	middleware.SetAuditChange(ctx, before, updates)
 
 	ctx.JSON(http.StatusOK, gin.H{"data": app})
 }
@@ -153,11 +159,13 @@ func (c *AppManagementController) DeleteApp(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "删除应用失败"})
 		return
 	}
+	middleware.SetAuditChange(ctx, app, nil)
 
 	ctx.JSON(http.StatusOK, gin.H{"message": "应用删除成功"})
 }
 
-// RegenerateAppSecret 重新生成应用密钥（仅系统级超级管理员）
+// RegenerateAppSecret 轮换应用密钥（仅系统级超级管理员）
+// 新密钥明文仅在响应中返回一次，旧密钥在宽限期（默认 24 小时）内仍然有效
 func (c *AppManagementController) RegenerateAppSecret(ctx *gin.Context) {
 	appID := ctx.Param("app_id")
 
@@ -167,21 +175,121 @@ func (c *AppManagementController) RegenerateAppSecret(ctx *gin.Context) {
 		return
 	}
 
-	// 生成新的应用密钥
-	newSecret, err := service.GenerateAppSecret()
+	var req service.RotateAppSecretRequest
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	gracePeriod := service.DefaultSecretGracePeriod
+	if req.GracePeriod != nil {
+		gracePeriod = *req.GracePeriod
+	}
+
+	secretService := &service.AppSecretService{}
+	result, err := secretService.RotateSecret(appID, gracePeriod)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "生成应用密钥失败"})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "更新应用密钥失败"})
 		return
 	}
+	middleware.SetAuditChange(ctx, nil, gin.H{"grace_period": gracePeriod})
 
-	// 更新应用密钥
-	if err := config.DB.Model(&app).Update("app_secret", newSecret).Error; err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "更新应用密钥失败"})
+	ctx.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// ListAppSecrets 获取应用当前有效的密钥（仅返回前缀、过期时间和最近使用时间）
+func (c *AppManagementController) ListAppSecrets(ctx *gin.Context) {
+	appID := ctx.Param("app_id")
+
+	secretService := &service.AppSecretService{}
+	secrets, err := secretService.ListSecrets(appID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取应用密钥失败"})
 		return
 	}
 
-	app.AppSecret = newSecret
-	ctx.JSON(http.StatusOK, gin.H{"data": app})
+	ctx.JSON(http.StatusOK, gin.H{"data": secrets})
+}
+
+// RevokeAppSecret 立即吊销应用密钥
+func (c *AppManagementController) RevokeAppSecret(ctx *gin.Context) {
+	appID := ctx.Param("app_id")
+	secretID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的密钥ID"})
+		return
+	}
+
+	secretService := &service.AppSecretService{}
+	if err := secretService.RevokeSecret(appID, uint(secretID)); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "密钥已吊销"})
+}
+
+// ListAppCertificates 获取应用登记的客户端证书
+func (c *AppManagementController) ListAppCertificates(ctx *gin.Context) {
+	appID := ctx.Param("app_id")
+
+	certService := &service.AppCertificateService{}
+	certs, err := certService.ListCertificates(appID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取应用证书失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": certs})
+}
+
+// AddAppCertificate 登记应用客户端证书（mTLS 认证）
+func (c *AppManagementController) AddAppCertificate(ctx *gin.Context) {
+	appID := ctx.Param("app_id")
+
+	var app models.Application
+	if err := config.DB.Where("app_id = ?", appID).First(&app).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "应用不存在"})
+		return
+	}
+
+	var req service.AddAppCertificateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	certService := &service.AppCertificateService{}
+	cert, err := certService.AddCertificate(appID, &req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	middleware.SetAuditTarget(ctx, "certificate", strconv.FormatUint(uint64(cert.ID), 10))
+	middleware.SetAuditChange(ctx, nil, cert)
+
+	ctx.JSON(http.StatusCreated, gin.H{"data": cert})
+}
+
+// DeleteAppCertificate 删除应用客户端证书
+func (c *AppManagementController) DeleteAppCertificate(ctx *gin.Context) {
+	appID := ctx.Param("app_id")
+	certID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的证书ID"})
+		return
+	}
+
+	certService := &service.AppCertificateService{}
+	if err := certService.DeleteCertificate(appID, uint(certID)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "证书删除成功"})
 }
 
 // ListAppUsers 获取应用用户列表
@@ -226,3 +334,169 @@ func (c *AppManagementController) ListAppUsers(ctx *gin.Context) {
 		},
 	})
 }
+
+// ListRateLimitQuotas 获取应用的限流配额覆盖（仅系统级超级管理员）
+func (c *AppManagementController) ListRateLimitQuotas(ctx *gin.Context) {
+	appID := ctx.Param("app_id")
+
+	var app models.Application
+	if err := config.DB.Where("app_id = ?", appID).First(&app).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "应用不存在"})
+		return
+	}
+
+	rateLimitService := &service.RateLimitService{}
+	quotas, err := rateLimitService.ListQuotas(appID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取限流配额失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data":     quotas,
+		"defaults": config.GetConfig().RateLimit.Groups,
+	})
+}
+
+// SetRateLimitQuota 设置应用的限流配额覆盖（仅系统级超级管理员）
+func (c *AppManagementController) SetRateLimitQuota(ctx *gin.Context) {
+	appID := ctx.Param("app_id")
+
+	var req service.SetRateLimitQuotaRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var app models.Application
+	if err := config.DB.Where("app_id = ?", appID).First(&app).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "应用不存在"})
+		return
+	}
+
+	rateLimitService := &service.RateLimitService{}
+	quota, err := rateLimitService.SetQuota(appID, &req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	middleware.SetAuditChange(ctx, nil, quota)
+
+	ctx.JSON(http.StatusOK, gin.H{"data": quota})
+}
+
+// DeleteRateLimitQuota 删除应用的限流配额覆盖（仅系统级超级管理员）
+func (c *AppManagementController) DeleteRateLimitQuota(ctx *gin.Context) {
+	appID := ctx.Param("app_id")
+	quotaID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的配额ID"})
+		return
+	}
+
+	rateLimitService := &service.RateLimitService{}
+	if err := rateLimitService.DeleteQuota(appID, uint(quotaID)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "限流配额删除成功"})
+}
+
+// GetPasswordPolicy 获取应用密码策略（仅系统级超级管理员）
+// 系统级管理员账号的策略通过 /system-admins/password-policy 管理
+func (c *AppManagementController) GetPasswordPolicy(ctx *gin.Context) {
+	appID := ctx.Param("app_id")
+
+	var app models.Application
+	if err := config.DB.Where("app_id = ?", appID).First(&app).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "应用不存在"})
+		return
+	}
+
+	policyService := &service.PasswordPolicyService{}
+	policy, err := policyService.GetPolicy(appID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取密码策略失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": policy})
+}
+
+// UpdatePasswordPolicy 更新应用密码策略（仅系统级超级管理员）
+func (c *AppManagementController) UpdatePasswordPolicy(ctx *gin.Context) {
+	appID := ctx.Param("app_id")
+
+	var req service.UpdatePasswordPolicyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var app models.Application
+	if err := config.DB.Where("app_id = ?", appID).First(&app).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "应用不存在"})
+		return
+	}
+
+	policyService := &service.PasswordPolicyService{}
+	before, _ := policyService.GetPolicy(appID)
+	policy, err := policyService.UpdatePolicy(appID, &req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	middleware.SetAuditChange(ctx, before, policy)
+
+	ctx.JSON(http.StatusOK, gin.H{"data": policy})
+}
+
+// GetLoginRiskPolicy 获取应用异常登录策略（仅系统级超级管理员）
+func (c *AppManagementController) GetLoginRiskPolicy(ctx *gin.Context) {
+	appID := ctx.Param("app_id")
+
+	var app models.Application
+	if err := config.DB.Where("app_id = ?", appID).First(&app).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "应用不存在"})
+		return
+	}
+
+	riskService := &service.LoginRiskService{}
+	policy, err := riskService.GetPolicy(appID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取异常登录策略失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": policy})
+}
+
+// UpdateLoginRiskPolicy 更新应用异常登录策略（仅系统级超级管理员）
+func (c *AppManagementController) UpdateLoginRiskPolicy(ctx *gin.Context) {
+	appID := ctx.Param("app_id")
+
+	var req service.UpdateLoginRiskPolicyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var app models.Application
+	if err := config.DB.Where("app_id = ?", appID).First(&app).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "应用不存在"})
+		return
+	}
+
+	riskService := &service.LoginRiskService{}
+	before, _ := riskService.GetPolicy(appID)
+	policy, err := riskService.UpdatePolicy(appID, &req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	middleware.SetAuditChange(ctx, before, policy)
+
+	ctx.JSON(http.StatusOK, gin.H{"data": policy})
+}
@@ -1,15 +1,22 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"auth-center/config"
 	"auth-center/middleware"
 	"auth-center/models"
+	"auth-center/service"
 	"auth-center/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // AppResourceController 应用内资源管理控制器
@@ -150,6 +157,8 @@ func (c *AppResourceController) CreateRole(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "创建角色失败"})
 		return
 	}
+	middleware.SetAuditTarget(ctx, "role", strconv.FormatUint(uint64(role.ID), 10))
+	middleware.SetAuditChange(ctx, nil, role)
 
 	ctx.JSON(http.StatusCreated, gin.H{"data": role})
 }
@@ -189,10 +198,12 @@ func (c *AppResourceController) UpdateRole(ctx *gin.Context) {
 		updates["status"] = req.Status
 	}
 
+	before := role
 	if err := config.DB.Model(&role).Updates(updates).Error; err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "更新角色失败"})
 		return
 	}
+	middleware.SetAuditChange(ctx, before, updates)
 
 	ctx.JSON(http.StatusOK, gin.H{"data": role})
 }
@@ -210,10 +221,12 @@ func (c *AppResourceController) DeleteRole(ctx *gin.Context) {
 	}
 
 	// 删除角色（级联删除相关数据）
-	if err := config.DB.Where("id = ? AND app_id = ?", roleID, appID).Delete(&models.Role{}).Error; err != nil {
+	permissionService := &service.PermissionService{}
+	if err := permissionService.DeleteRole(&role); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "删除角色失败"})
 		return
 	}
+	middleware.SetAuditChange(ctx, role, nil)
 
 	ctx.JSON(http.StatusOK, gin.H{"message": "角色删除成功"})
 }
@@ -294,6 +307,13 @@ func (c *AppResourceController) CreatePermission(ctx *gin.Context) {
 		return
 	}
 
+	// 路径支持参数（:id、{id}）、通配（*、*.csv）和前缀通配（/**），方法支持 ANY
+	if err := utils.ValidateRoutePattern(req.Path); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "API路径格式错误: " + err.Error()})
+		return
+	}
+	req.Method = strings.ToUpper(strings.TrimSpace(req.Method))
+
 	// 自动生成编码（如果未提供）
 	code := req.Code
 	if code == "" {
@@ -347,6 +367,9 @@ func (c *AppResourceController) CreatePermission(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "创建API失败"})
 		return
 	}
+	service.InvalidateAPIMatcher(appID)
+	middleware.SetAuditTarget(ctx, "permission", strconv.FormatUint(uint64(permission.ID), 10))
+	middleware.SetAuditChange(ctx, nil, gin.H{"permission": permission, "api": api})
 
 	ctx.JSON(http.StatusCreated, gin.H{"data": permission})
 }
@@ -424,10 +447,13 @@ func (c *AppResourceController) UpdatePermission(ctx *gin.Context) {
 		updates["status"] = req.Status
 	}
 
-	if err := config.DB.Model(&permission).Updates(updates).Error; err != nil {
+	before := permission
+	permissionService := &service.PermissionService{}
+	if err := permissionService.UpdatePermission(&permission, updates); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "更新权限失败"})
 		return
 	}
+	middleware.SetAuditChange(ctx, before, updates)
 
 	ctx.JSON(http.StatusOK, gin.H{"data": permission})
 }
@@ -445,21 +471,32 @@ func (c *AppResourceController) DeletePermission(ctx *gin.Context) {
 	}
 
 	// 删除权限（级联删除相关数据）
-	if err := config.DB.Where("id = ? AND app_id = ?", permissionID, appID).Delete(&models.Permission{}).Error; err != nil {
+	permissionService := &service.PermissionService{}
+	if err := permissionService.DeletePermission(&permission); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "删除权限失败"})
 		return
 	}
+	middleware.SetAuditChange(ctx, permission, nil)
 
 	ctx.JSON(http.StatusOK, gin.H{"message": "权限删除成功"})
 }
 
 // AssignRolePermissions 为角色分配权限
+// permission_ids 为授予的权限，deny_permission_ids 为明确拒绝的权限（优先于任何授予）；
+// conditions 为权限ID到生效条件（CEL 表达式）的映射，可用于授予和拒绝的权限；
+// validity 为权限ID到有效期（valid_from、valid_until）的映射，有效期外的分配不生效，到期后自动删除；
+// data_scopes 为授予的权限ID到数据范围（all、own、department 或 custom）的映射，未设置时为全部数据。
+// 未提供 deny_permission_ids、conditions、validity 或 data_scopes 时保留角色现有的拒绝权限、条件、有效期或数据范围
 func (c *AppResourceController) AssignRolePermissions(ctx *gin.Context) {
 	appID := c.getTargetAppID(ctx)
 	roleID := ctx.Param("id")
 
 	var req struct {
-		PermissionIDs []uint `json:"permission_ids" binding:"required"`
+		PermissionIDs     []uint                               `json:"permission_ids" binding:"required"`
+		DenyPermissionIDs *[]uint                              `json:"deny_permission_ids"`
+		Conditions        *map[uint]string                     `json:"conditions"`
+		Validity          *map[uint]service.AssignmentValidity `json:"validity"`
+		DataScopes        *map[uint]service.DataScope          `json:"data_scopes"`
 	}
 
 	if err := ctx.ShouldBindJSON(&req); err != nil {
@@ -474,39 +511,202 @@ func (c *AppResourceController) AssignRolePermissions(ctx *gin.Context) {
 		return
 	}
 
-	// 删除现有权限分配
-	config.DB.Where("role_id = ? AND app_id = ?", roleID, appID).Delete(&models.RolePermission{})
+	var previous []models.RolePermission
+	config.DB.Where("role_id = ? AND app_id = ?", roleID, appID).Find(&previous)
+	previousIDs := make([]uint, 0, len(previous))
+	previousDenyIDs := make([]uint, 0)
+	previousConditions := make(map[uint]string)
+	previousValidity := make(map[uint]service.AssignmentValidity)
+	previousScopes := make(map[uint]service.DataScope)
+	for _, rp := range previous {
+		var scope service.DataScope
+		if rp.DataScope != "" && json.Unmarshal([]byte(rp.DataScope), &scope) == nil {
+			previousScopes[rp.PermissionID] = scope
+		}
+		if rp.Condition != "" {
+			previousConditions[rp.PermissionID] = rp.Condition
+		}
+		if rp.ValidFrom != nil || rp.ValidUntil != nil {
+			previousValidity[rp.PermissionID] = service.AssignmentValidity{ValidFrom: rp.ValidFrom, ValidUntil: rp.ValidUntil}
+		}
+		if rp.Effect == service.PermissionEffectDeny {
+			previousDenyIDs = append(previousDenyIDs, rp.PermissionID)
+		} else {
+			previousIDs = append(previousIDs, rp.PermissionID)
+		}
+	}
 
-	// 添加新的权限分配
+	denyIDs := previousDenyIDs
+	if req.DenyPermissionIDs != nil {
+		denyIDs = *req.DenyPermissionIDs
+	}
+	assigned := make(map[uint]bool, len(req.PermissionIDs)+len(denyIDs))
 	for _, permissionID := range req.PermissionIDs {
-		rolePermission := models.RolePermission{
-			RoleID:       role.ID,
-			PermissionID: permissionID,
-			AppID:        appID,
+		assigned[permissionID] = true
+	}
+	for _, denyID := range denyIDs {
+		if assigned[denyID] {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("权限 %d 不能同时授予和拒绝", denyID)})
+			return
+		}
+		assigned[denyID] = true
+	}
+
+	conditions := make(map[uint]string)
+	if req.Conditions != nil {
+		for permissionID, condition := range *req.Conditions {
+			condition = strings.TrimSpace(condition)
+			if condition == "" {
+				continue
+			}
+			if !assigned[permissionID] {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("权限 %d 未分配，不能设置条件", permissionID)})
+				return
+			}
+			if err := service.ValidatePermissionCondition(condition); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("权限 %d 的%s", permissionID, err.Error())})
+				return
+			}
+			conditions[permissionID] = condition
+		}
+	} else {
+		for permissionID, condition := range previousConditions {
+			if assigned[permissionID] {
+				conditions[permissionID] = condition
+			}
+		}
+	}
+
+	validity := make(map[uint]service.AssignmentValidity)
+	if req.Validity != nil {
+		for permissionID, v := range *req.Validity {
+			if v.IsZero() {
+				continue
+			}
+			if !assigned[permissionID] {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("权限 %d 未分配，不能设置有效期", permissionID)})
+				return
+			}
+			if err := v.Validate(); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("权限 %d 的%s", permissionID, err.Error())})
+				return
+			}
+			validity[permissionID] = v
+		}
+	} else {
+		for permissionID, v := range previousValidity {
+			if assigned[permissionID] {
+				validity[permissionID] = v
+			}
 		}
-		config.DB.Create(&rolePermission)
 	}
 
+	// 数据范围只能设置在授予的权限上
+	scopes := make(map[uint]service.DataScope)
+	if req.DataScopes != nil {
+		var err error
+		if scopes, err = service.ValidateDataScopes(*req.DataScopes, req.PermissionIDs); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		for permissionID, scope := range previousScopes {
+			if assigned[permissionID] {
+				scopes[permissionID] = scope
+			}
+		}
+	}
+
+	// 替换现有权限分配
+	permissionService := &service.PermissionService{}
+	if err := permissionService.AssignRolePermissions(appID, role.ID, req.PermissionIDs, denyIDs, conditions, validity, scopes); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "分配权限失败"})
+		return
+	}
+	middleware.SetAuditChange(ctx,
+		gin.H{"permission_ids": previousIDs, "deny_permission_ids": previousDenyIDs, "conditions": previousConditions, "validity": previousValidity, "data_scopes": previousScopes},
+		gin.H{"permission_ids": req.PermissionIDs, "deny_permission_ids": denyIDs, "conditions": conditions, "validity": validity, "data_scopes": scopes})
+
 	ctx.JSON(http.StatusOK, gin.H{"message": "权限分配成功"})
 }
 
 // GetRolePermissions 获取角色权限
+// permission_ids 为直接分配的权限，effective_permission_ids 包含从父角色继承的权限（已排除被拒绝的权限），
+// deny_permission_ids 和 effective_deny_permission_ids 为直接设置和包含继承的拒绝权限
 func (c *AppResourceController) GetRolePermissions(ctx *gin.Context) {
 	appID := c.getTargetAppID(ctx)
 	roleID := ctx.Param("id")
 
-	var rolePermissions []models.RolePermission
-	if err := config.DB.Where("role_id = ? AND app_id = ?", roleID, appID).Find(&rolePermissions).Error; err != nil {
+	var role models.Role
+	if err := config.DB.Where("id = ? AND app_id = ?", roleID, appID).First(&role).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return
+	}
+
+	permissionService := &service.PermissionService{}
+	set, err := permissionService.GetRolePermissionSet(role.ID, appID)
+	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取角色权限失败"})
 		return
 	}
 
-	var permissionIDs []uint
-	for _, rp := range rolePermissions {
-		permissionIDs = append(permissionIDs, rp.PermissionID)
+	ctx.JSON(http.StatusOK, set)
+}
+
+// GetRoleParents 获取角色继承的父角色
+func (c *AppResourceController) GetRoleParents(ctx *gin.Context) {
+	appID := c.getTargetAppID(ctx)
+	roleID := ctx.Param("id")
+
+	var role models.Role
+	if err := config.DB.Where("id = ? AND app_id = ?", roleID, appID).First(&role).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"permission_ids": permissionIDs})
+	hierarchyService := &service.RoleHierarchyService{}
+	parentIDs, err := hierarchyService.GetRoleParents(appID, role.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取父角色失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"parent_ids": parentIDs})
+}
+
+// SetRoleParents 设置角色继承的父角色（替换原有父角色），角色获得父角色及其祖先的全部权限
+func (c *AppResourceController) SetRoleParents(ctx *gin.Context) {
+	appID := c.getTargetAppID(ctx)
+	roleID := ctx.Param("id")
+
+	var req struct {
+		ParentIDs []uint `json:"parent_ids"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var role models.Role
+	if err := config.DB.Where("id = ? AND app_id = ?", roleID, appID).First(&role).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return
+	}
+
+	hierarchyService := &service.RoleHierarchyService{}
+	previousIDs, _ := hierarchyService.GetRoleParents(appID, role.ID)
+	if err := hierarchyService.SetRoleParents(appID, role.ID, req.ParentIDs); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrRoleInheritanceCycle) {
+			status = http.StatusConflict
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	middleware.SetAuditChange(ctx, gin.H{"parent_ids": previousIDs}, gin.H{"parent_ids": req.ParentIDs})
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "父角色设置成功"})
 }
 
 // ListUsers 获取应用用户列表
@@ -548,7 +748,7 @@ func (c *AppResourceController) CreateUser(ctx *gin.Context) {
 		Username string `json:"username" binding:"required"`
 		Email    string `json:"email"`
 		Phone    string `json:"phone"`
-		Password string `json:"password" binding:"required,min=6"`
+		Password string `json:"password" binding:"required"`
 		Status   int    `json:"status"`
 	}
 
@@ -572,6 +772,15 @@ func (c *AppResourceController) CreateUser(ctx *gin.Context) {
 		}
 	}
 
+	// 校验密码策略
+	policyService := &service.PasswordPolicyService{}
+	if err := policyService.CheckPassword(appID, service.AccountTypeUser, 0, req.Username, req.Password); err != nil {
+		if !writePasswordPolicyError(ctx, err) {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取密码策略失败"})
+		}
+		return
+	}
+
 	// 哈希密码
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
@@ -580,19 +789,29 @@ func (c *AppResourceController) CreateUser(ctx *gin.Context) {
 	}
 
 	// 创建用户
+	now := time.Now()
 	user := models.User{
-		AppID:    appID,
-		Username: req.Username,
-		Email:    req.Email,
-		Phone:    req.Phone,
-		Password: hashedPassword,
-		Status:   req.Status,
-	}
-
-	if err := config.DB.Create(&user).Error; err != nil {
+		AppID:             appID,
+		Username:          req.Username,
+		Email:             req.Email,
+		Phone:             req.Phone,
+		Password:          hashedPassword,
+		Status:            req.Status,
+		PasswordChangedAt: &now,
+	}
+
+	// 用户与密码历史在同一事务中写入
+	if err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return policyService.RecordPasswordHistory(tx, appID, service.AccountTypeUser, user.ID, hashedPassword)
+	}); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "创建用户失败"})
 		return
 	}
+	middleware.SetAuditTarget(ctx, "user", strconv.FormatUint(uint64(user.ID), 10))
+	middleware.SetAuditChange(ctx, nil, user)
 
 	ctx.JSON(http.StatusCreated, gin.H{"data": user})
 }
@@ -632,23 +851,26 @@ func (c *AppResourceController) UpdateUser(ctx *gin.Context) {
 	if req.Phone != "" {
 		updates["phone"] = req.Phone
 	}
-	if req.Password != "" {
-		// 哈希新密码
-		hashedPassword, err := utils.HashPassword(req.Password)
-		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
-			return
-		}
-		updates["password"] = hashedPassword
-	}
 	if req.Status >= 0 {
 		updates["status"] = req.Status
 	}
 
-	if err := config.DB.Model(&user).Updates(updates).Error; err != nil {
+	before := user
+	if req.Password != "" {
+		// 修改密码需经过密码策略校验，校验通过后资料和密码在同一事务中更新
+		authService := &service.AuthService{}
+		if err := authService.UpdateUserWithPassword(&user, updates, req.Password); err != nil {
+			if !writePasswordPolicyError(ctx, err) {
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "更新用户失败"})
+			}
+			return
+		}
+		updates["password"] = req.Password // 审计日志中会被脱敏
+	} else if err := config.DB.Model(&user).Updates(updates).Error; err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "更新用户失败"})
 		return
 	}
+	middleware.SetAuditChange(ctx, before, updates)
 
 	ctx.JSON(http.StatusOK, gin.H{"data": user})
 }
@@ -671,22 +893,80 @@ func (c *AppResourceController) DeleteUser(ctx *gin.Context) {
 		return
 	}
 
-	// 删除用户（级联删除相关数据）
-	if err := config.DB.Where("id = ? AND app_id = ?", userID, appID).Delete(&models.User{}).Error; err != nil {
+	// 删除用户及其角色分配、成员身份等相关数据
+	permissionService := &service.PermissionService{}
+	if err := permissionService.DeleteUser(&user); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "删除用户失败"})
 		return
 	}
+	middleware.SetAuditChange(ctx, user, nil)
 
 	ctx.JSON(http.StatusOK, gin.H{"message": "用户删除成功"})
 }
 
-// AssignUserRoles 为用户分配角色
+// ResetUserPassword 重置用户密码
+func (c *AppResourceController) ResetUserPassword(ctx *gin.Context) {
+	appID := c.getTargetAppID(ctx)
+	userID := ctx.Param("id")
+
+	var req struct {
+		NewPassword string `json:"new_password" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.Where("id = ? AND app_id = ?", userID, appID).First(&user).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	authService := &service.AuthService{}
+	if err := authService.SetUserPassword(&user, req.NewPassword); err != nil {
+		if !writePasswordPolicyError(ctx, err) {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "重置密码失败"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "密码重置成功"})
+}
+
+// ImportUsers 批量导入用户（保留旧系统的密码哈希，首次登录后自动升级）
+func (c *AppResourceController) ImportUsers(ctx *gin.Context) {
+	appID := c.getTargetAppID(ctx)
+
+	var req service.ImportUsersRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashService := &service.PasswordHashService{}
+	result, err := hashService.ImportUsers(appID, &req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	middleware.SetAuditChange(ctx, nil, gin.H{"imported": result.Imported, "failed": len(result.Failed)})
+
+	ctx.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// AssignUserRoles 为用户分配角色（替换原有分配）
+// validity 为角色ID到有效期（valid_from、valid_until）的映射，有效期外的角色不生效，到期后自动删除并撤销用户的令牌；
+// 未提供 validity 时保留现有分配的有效期
 func (c *AppResourceController) AssignUserRoles(ctx *gin.Context) {
 	appID := c.getTargetAppID(ctx)
 	userID := ctx.Param("id")
 
 	var req struct {
-		RoleIDs []uint `json:"role_ids" binding:"required"`
+		RoleIDs  []uint                               `json:"role_ids" binding:"required"`
+		Validity *map[uint]service.AssignmentValidity `json:"validity"`
 	}
 
 	if err := ctx.ShouldBindJSON(&req); err != nil {
@@ -701,37 +981,261 @@ func (c *AppResourceController) AssignUserRoles(ctx *gin.Context) {
 		return
 	}
 
-	// 删除现有角色分配
-	config.DB.Where("user_id = ? AND app_id = ?", userID, appID).Delete(&models.UserRole{})
+	var previous []models.UserRole
+	config.DB.Where("user_id = ? AND app_id = ?", userID, appID).Find(&previous)
+	previousIDs := make([]uint, 0, len(previous))
+	previousValidity := make(map[uint]service.AssignmentValidity)
+	for _, ur := range previous {
+		previousIDs = append(previousIDs, ur.RoleID)
+		if ur.ValidFrom != nil || ur.ValidUntil != nil {
+			previousValidity[ur.RoleID] = service.AssignmentValidity{ValidFrom: ur.ValidFrom, ValidUntil: ur.ValidUntil}
+		}
+	}
 
-	// 添加新的角色分配
+	assigned := make(map[uint]bool, len(req.RoleIDs))
 	for _, roleID := range req.RoleIDs {
-		userRole := models.UserRole{
-			UserID: user.ID,
-			RoleID: roleID,
-			AppID:  appID,
+		assigned[roleID] = true
+	}
+	validity := make(map[uint]service.AssignmentValidity)
+	if req.Validity != nil {
+		for roleID, v := range *req.Validity {
+			if v.IsZero() {
+				continue
+			}
+			if !assigned[roleID] {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("角色 %d 未分配，不能设置有效期", roleID)})
+				return
+			}
+			if err := v.Validate(); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("角色 %d 的%s", roleID, err.Error())})
+				return
+			}
+			validity[roleID] = v
 		}
-		config.DB.Create(&userRole)
+	} else {
+		for roleID, v := range previousValidity {
+			if assigned[roleID] {
+				validity[roleID] = v
+			}
+		}
+	}
+
+	// 替换现有角色分配
+	permissionService := &service.PermissionService{}
+	if err := permissionService.AssignUserRoles(appID, user.ID, req.RoleIDs, validity); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "分配角色失败"})
+		return
 	}
+	middleware.SetAuditChange(ctx, gin.H{"role_ids": previousIDs, "validity": previousValidity}, gin.H{"role_ids": req.RoleIDs, "validity": validity})
 
 	ctx.JSON(http.StatusOK, gin.H{"message": "角色分配成功"})
 }
 
 // GetUserRoles 获取用户角色
+// role_ids 为当前生效的角色，assignments 为全部角色分配及其有效期（含尚未生效的分配），
+// group_ids 为用户直接所在的分组，all_group_ids 还包括上级分组（用户获得这些分组的角色）
 func (c *AppResourceController) GetUserRoles(ctx *gin.Context) {
 	appID := c.getTargetAppID(ctx)
 	userID := ctx.Param("id")
 
 	var userRoles []models.UserRole
-	if err := config.DB.Where("user_id = ? AND app_id = ?", userID, appID).Find(&userRoles).Error; err != nil {
+	if err := config.DB.Where("user_id = ? AND app_id = ?", userID, appID).Order("id").Find(&userRoles).Error; err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户角色失败"})
 		return
 	}
 
-	var roleIDs []uint
+	now := time.Now()
+	roleIDs := []uint{}
 	for _, ur := range userRoles {
-		roleIDs = append(roleIDs, ur.RoleID)
+		if (ur.ValidFrom == nil || !ur.ValidFrom.After(now)) && (ur.ValidUntil == nil || ur.ValidUntil.After(now)) {
+			roleIDs = append(roleIDs, ur.RoleID)
+		}
+	}
+
+	id, _ := strconv.ParseUint(userID, 10, 32)
+	groupService := &service.GroupService{}
+	groupIDs, allGroupIDs, err := groupService.GetUserGroups(appID, uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户分组失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"role_ids": roleIDs, "assignments": userRoles, "group_ids": groupIDs, "all_group_ids": allGroupIDs})
+}
+
+// GetUserPermissions 获取直接分配给用户的授予和拒绝权限
+func (c *AppResourceController) GetUserPermissions(ctx *gin.Context) {
+	appID := c.getTargetAppID(ctx)
+
+	var user models.User
+	if err := config.DB.Where("id = ? AND app_id = ?", ctx.Param("id"), appID).First(&user).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	permissionService := &service.PermissionService{}
+	permissions, err := permissionService.GetUserDirectPermissions(user.ID, appID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户权限失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, permissions)
+}
+
+// SetUserPermissions 设置直接分配给用户的授予和拒绝权限（替换原有分配）
+func (c *AppResourceController) SetUserPermissions(ctx *gin.Context) {
+	appID := c.getTargetAppID(ctx)
+
+	var req service.UserDirectPermissions
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.Where("id = ? AND app_id = ?", ctx.Param("id"), appID).First(&user).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	permissionService := &service.PermissionService{}
+	previous, _ := permissionService.GetUserDirectPermissions(user.ID, appID)
+	if err := permissionService.SetUserDirectPermissions(user.ID, appID, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	middleware.SetAuditChange(ctx, previous, req)
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "权限设置成功"})
+}
+
+// ExplainUserPermission 说明用户对某个权限或API的判定结果及决定结果的规则
+// 按权限判定时传 permission，按API判定时传 path 和 method；可选 ip 用于求值附带条件的规则，可选 org_id 按用户在该组织内判定
+func (c *AppResourceController) ExplainUserPermission(ctx *gin.Context) {
+	appID := c.getTargetAppID(ctx)
+
+	var user models.User
+	if err := config.DB.Where("id = ? AND app_id = ?", ctx.Param("id"), appID).First(&user).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	orgID, ok := parseOrgIDQuery(ctx)
+	if !ok {
+		return
+	}
+
+	// 附带条件的规则按当前时间和 ip 参数（可选）求值
+	pctx := &service.PermissionContext{IP: ctx.Query("ip"), Time: time.Now()}
+	permissionService := &service.PermissionService{OrgID: orgID}
+	var decision *service.PermissionDecision
+	var err error
+	if code := ctx.Query("permission"); code != "" {
+		decision, err = permissionService.ExplainUserPermission(user.ID, appID, code, pctx)
+	} else if path, method := ctx.Query("path"), ctx.Query("method"); path != "" && method != "" {
+		pctx.Path, pctx.Method = path, method
+		decision, err = permissionService.ExplainAPIPermission(user.ID, appID, path, method, pctx)
+	} else {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "需要提供权限代码，或API路径和方法"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "权限判定失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": decision})
+}
+
+// GetUserDataScope 获取用户对资源类型可访问的数据范围
+// resource 为权限的资源类型，action 为操作（可选，不传时包含该资源类型的全部操作）；可选 ip 用于求值附带条件的规则，可选 org_id 按用户在该组织内解析
+func (c *AppResourceController) GetUserDataScope(ctx *gin.Context) {
+	appID := c.getTargetAppID(ctx)
+
+	resource := ctx.Query("resource")
+	if resource == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "需要提供资源类型"})
+		return
+	}
+	orgID, ok := parseOrgIDQuery(ctx)
+	if !ok {
+		return
+	}
+
+	var user models.User
+	if err := config.DB.Where("id = ? AND app_id = ?", ctx.Param("id"), appID).First(&user).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	pctx := &service.PermissionContext{IP: ctx.Query("ip"), Time: time.Now()}
+	permissionService := &service.PermissionService{OrgID: orgID}
+	scope, err := permissionService.ResolveDataScope(user.ID, appID, resource, ctx.Query("action"), pctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取数据范围失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": scope})
+}
+
+// parseOrgIDQuery 解析可选的 org_id 查询参数，无效时写入 400
+func parseOrgIDQuery(ctx *gin.Context) (uint, bool) {
+	value := ctx.Query("org_id")
+	if value == "" {
+		return 0, true
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的组织ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// SimulatePermission 模拟权限判定：按用户或假设的一组角色判定权限或API访问，返回完整的判定过程
+func (c *AppResourceController) SimulatePermission(ctx *gin.Context) {
+	var req service.PermissionSimulationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.UserID == 0 && req.RoleIDs == nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "需要提供用户ID或角色ID列表"})
+		return
+	}
+	if req.Permission == "" && (req.Path == "" || req.Method == "") {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "需要提供权限代码，或API路径和方法"})
+		return
+	}
+
+	permissionService := &service.PermissionService{OrgID: req.OrgID}
+	result, err := permissionService.SimulatePermission(c.getTargetAppID(ctx), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrSimulationUserNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrSimulationRoleNotFound):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "权限判定失败"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// GetUserLoginHistory 获取用户的登录历史
+func (c *AppResourceController) GetUserLoginHistory(ctx *gin.Context) {
+	appID := c.getTargetAppID(ctx)
+
+	var user models.User
+	if err := config.DB.Where("id = ? AND app_id = ?", ctx.Param("id"), appID).First(&user).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"role_ids": roleIDs})
+	respondLoginHistory(ctx, appID, user.ID)
 }
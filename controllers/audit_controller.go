@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"auth-center/middleware"
+	"auth-center/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditController 审计日志控制器
+type AuditController struct{}
+
+// ListAuditLogs 查询审计日志
+// 系统级管理员可查询全部日志（可通过 app_id 过滤），应用级管理员只能查询自己应用的日志
+// @Summary 查询审计日志
+// @Tags 系统管理
+// @Produce json
+// @Param app_id query string false "应用ID"
+// @Param actor_type query string false "操作者类型：system_admin, user, app, anonymous"
+// @Param actor_id query int false "操作者ID"
+// @Param action query string false "操作名称"
+// @Param target_type query string false "操作对象类型"
+// @Param target_id query string false "操作对象ID"
+// @Param result query string false "结果：success, failure"
+// @Param ip query string false "客户端IP"
+// @Param start_time query string false "开始时间（RFC 3339）"
+// @Param end_time query string false "结束时间（RFC 3339）"
+// @Param page query int false "页码"
+// @Param size query int false "每页条数（最大 100）"
+// @Router /system/audit [get]
+func (c *AuditController) ListAuditLogs(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(ctx.DefaultQuery("size", "20"))
+
+	query := &service.AuditQuery{
+		AppID:      middleware.GetTargetAppID(ctx),
+		ActorType:  ctx.Query("actor_type"),
+		Action:     ctx.Query("action"),
+		TargetType: ctx.Query("target_type"),
+		TargetID:   ctx.Query("target_id"),
+		Result:     ctx.Query("result"),
+		IP:         ctx.Query("ip"),
+		Page:       page,
+		PageSize:   size,
+	}
+
+	if actorID := ctx.Query("actor_id"); actorID != "" {
+		id, err := strconv.ParseUint(actorID, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "actor_id 格式错误"})
+			return
+		}
+		query.ActorID = uint(id)
+	}
+
+	auditRange, ok := parseAuditRange(ctx)
+	if !ok {
+		return
+	}
+	query.StartTime, query.EndTime = auditRange.StartTime, auditRange.EndTime
+
+	auditService := &service.AuditService{}
+	logs, total, err := auditService.ListAuditLogs(query)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "查询审计日志失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": logs,
+		"pagination": gin.H{
+			"page":      query.Page,
+			"page_size": query.PageSize,
+			"total":     total,
+		},
+	})
+}
+
+// parseAuditTime 解析 RFC 3339 格式的时间查询参数，格式错误时写入 400 响应并返回 false
+func parseAuditTime(ctx *gin.Context, name string) (*time.Time, bool) {
+	value := ctx.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": name + " 格式错误，应为 RFC 3339 格式"})
+		return nil, false
+	}
+	return &t, true
+}
+
+// VerifyAuditLogs 校验审计日志哈希链和检查点签名（仅系统级管理员）
+// @Summary 校验审计日志
+// @Tags 系统管理
+// @Produce json
+// @Param start_time query string false "开始时间（RFC 3339）"
+// @Param end_time query string false "结束时间（RFC 3339）"
+// @Success 200 {object} service.AuditVerifyResult "校验结果"
+// @Router /system/audit/verify [get]
+func (c *AuditController) VerifyAuditLogs(ctx *gin.Context) {
+	auditRange, ok := parseAuditRange(ctx)
+	if !ok {
+		return
+	}
+
+	auditService := &service.AuditService{}
+	result, err := auditService.VerifyAuditChain(auditRange)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "校验审计日志失败: " + err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// ExportAuditLogs 导出审计日志（仅系统级管理员）
+// 导出内容包含签名公钥、范围内的检查点以及每条记录的序号和哈希，可使用 cmd/auditverify 离线校验
+// @Summary 导出审计日志
+// @Tags 系统管理
+// @Produce plain
+// @Param format query string false "导出格式：jsonl（默认）或 csv"
+// @Param start_time query string false "开始时间（RFC 3339）"
+// @Param end_time query string false "结束时间（RFC 3339）"
+// @Router /system/audit/export [get]
+func (c *AuditController) ExportAuditLogs(ctx *gin.Context) {
+	format := ctx.DefaultQuery("format", "jsonl")
+	if err := service.ValidAuditExportFormat(format); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	auditRange, ok := parseAuditRange(ctx)
+	if !ok {
+		return
+	}
+
+	contentType := "application/x-ndjson"
+	if format == "csv" {
+		contentType = "text/csv; charset=utf-8"
+	}
+	filename := "audit-" + time.Now().Format("20060102150405") + "." + format
+	ctx.Header("Content-Type", contentType)
+	ctx.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	ctx.Status(http.StatusOK)
+
+	auditService := &service.AuditService{}
+	if err := auditService.ExportAuditLogs(ctx.Writer, format, auditRange); err != nil {
+		// 响应头已发送，只能中断输出
+		ctx.Error(err)
+		ctx.Abort()
+	}
+}
+
+// parseAuditRange 解析 start_time、end_time 查询参数
+func parseAuditRange(ctx *gin.Context) (*service.AuditRange, bool) {
+	auditRange := &service.AuditRange{}
+	var ok bool
+	if auditRange.StartTime, ok = parseAuditTime(ctx, "start_time"); !ok {
+		return nil, false
+	}
+	if auditRange.EndTime, ok = parseAuditTime(ctx, "end_time"); !ok {
+		return nil, false
+	}
+	return auditRange, true
+}
@@ -1,10 +1,13 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"auth-center/middleware"
 	"auth-center/service"
+	"auth-center/utils"
 )
 
 // AuthController 认证控制器
@@ -28,10 +31,91 @@ func (c *AuthController) Login(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !bindSignedAppID(ctx, &req.AppID, &req.SignatureVerified) || !bindDPoPProof(ctx, &req.DPoPJkt) {
+		return
+	}
+	req.ClientCert = middleware.GetClientCert(ctx)
+	req.ClientIP = ctx.ClientIP()
+	req.UserAgent = ctx.Request.UserAgent()
+	req.AppVerified = appRateLimitHook(ctx)
 
 	authService := &service.AuthService{}
 	response, err := authService.Login(&req)
+	if errors.Is(err, service.ErrRateLimited) {
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// LoginMFA 异常登录二次验证
+// @Summary 异常登录二次验证
+// @Description 登录被判定为异常且应用策略要求二次验证时，使用登录返回的 mfa_token 和发送到绑定手机号的短信验证码完成登录
+// @Tags 认证
+// @Accept json
+// @Produce json
+// @Param request body service.LoginMFARequest true "二次验证请求"
+// @Success 200 {object} service.LoginResponse "登录成功"
+// @Failure 400 {object} map[string]string "请求参数错误"
+// @Failure 401 {object} map[string]string "验证失败"
+// @Router /auth/login/mfa [post]
+func (c *AuthController) LoginMFA(ctx *gin.Context) {
+	var req service.LoginMFARequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !bindSignedAppID(ctx, &req.AppID, &req.SignatureVerified) || !bindDPoPProof(ctx, &req.DPoPJkt) {
+		return
+	}
+	req.ClientCert = middleware.GetClientCert(ctx)
+	req.ClientIP = ctx.ClientIP()
+	req.UserAgent = ctx.Request.UserAgent()
+
+	authService := &service.AuthService{}
+	response, err := authService.LoginMFA(&req)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// ChangeExpiredPassword 密码过期时设置新密码并完成登录
+// @Summary 修改过期密码
+// @Description 登录返回 password_expired 时，使用 password_change_token 设置新密码并获取令牌
+// @Tags 认证
+// @Accept json
+// @Produce json
+// @Param request body service.ChangeExpiredPasswordRequest true "修改过期密码请求"
+// @Success 200 {object} service.LoginResponse "登录成功"
+// @Failure 400 {object} map[string]string "新密码不符合密码策略"
+// @Failure 401 {object} map[string]string "令牌无效"
+// @Router /auth/password/expired [post]
+func (c *AuthController) ChangeExpiredPassword(ctx *gin.Context) {
+	var req service.ChangeExpiredPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !bindSignedAppID(ctx, &req.AppID, &req.SignatureVerified) || !bindDPoPProof(ctx, &req.DPoPJkt) {
+		return
+	}
+	req.ClientCert = middleware.GetClientCert(ctx)
+	req.ClientIP = ctx.ClientIP()
+	req.UserAgent = ctx.Request.UserAgent()
+
+	authService := &service.AuthService{}
+	response, err := authService.ChangeExpiredPassword(&req)
 	if err != nil {
+		if writePasswordPolicyError(ctx, err) {
+			return
+		}
 		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
@@ -57,9 +141,24 @@ func (c *AuthController) Register(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !bindSignedAppID(ctx, &req.AppID, &req.SignatureVerified) {
+		return
+	}
+	req.ClientCert = middleware.GetClientCert(ctx)
+	req.AppVerified = appRateLimitHook(ctx)
 
 	authService := &service.AuthService{}
 	if err := authService.Register(&req); err != nil {
+		if errors.Is(err, service.ErrRateLimited) {
+			return
+		}
+		if writePasswordPolicyError(ctx, err) {
+			return
+		}
+		if errors.Is(err, service.ErrRegisterFailed) {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		return
 	}
@@ -85,6 +184,10 @@ func (c *AuthController) RefreshToken(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !bindDPoPProof(ctx, &req.DPoPJkt) {
+		return
+	}
+	req.ClientCert = middleware.GetClientCert(ctx)
 
 	authService := &service.AuthService{}
 	response, err := authService.RefreshToken(&req)
@@ -124,6 +227,81 @@ func (c *AuthController) Logout(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"message": "登出成功"})
 }
 
+// Introspect 令牌内省
+// @Summary 令牌内省
+// @Description 资源服务器查询令牌状态（RFC 7662），DPoP 绑定令牌须转发客户端的 DPoP 证明
+// @Tags 认证
+// @Accept json
+// @Produce json
+// @Param request body service.IntrospectRequest true "内省请求"
+// @Success 200 {object} service.IntrospectResponse "令牌状态"
+// @Failure 400 {object} map[string]string "请求参数错误"
+// @Failure 401 {object} map[string]string "应用认证失败"
+// @Router /auth/introspect [post]
+func (c *AuthController) Introspect(ctx *gin.Context) {
+	var req service.IntrospectRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !bindSignedAppID(ctx, &req.AppID, &req.SignatureVerified) {
+		return
+	}
+	req.ClientCert = middleware.GetClientCert(ctx)
+
+	authService := &service.AuthService{}
+	response, err := authService.Introspect(&req)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// ChangePassword 修改密码
+// @Summary 修改密码
+// @Description 当前登录用户修改自己的密码，新密码需符合应用密码策略
+// @Tags 认证
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body service.ChangePasswordRequest true "修改密码请求"
+// @Success 200 {object} map[string]string "修改成功"
+// @Failure 400 {object} map[string]interface{} "密码不符合策略"
+// @Failure 401 {object} map[string]string "未认证或原密码错误"
+// @Router /auth/password [post]
+func (c *AuthController) ChangePassword(ctx *gin.Context) {
+	var req service.ChangePasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	appID, exists := ctx.Get("app_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "应用未认证"})
+		return
+	}
+
+	authService := &service.AuthService{}
+	if err := authService.ChangePassword(userID.(uint), appID.(string), &req); err != nil {
+		if writePasswordPolicyError(ctx, err) {
+			return
+		}
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "密码修改成功"})
+}
+
 // GetUserInfo 获取用户信息
 // @Summary 获取用户信息
 // @Description 获取当前登录用户的详细信息
@@ -149,7 +327,7 @@ func (c *AuthController) GetUserInfo(ctx *gin.Context) {
 	}
 
 	authService := &service.AuthService{}
-	userInfo, err := authService.GetUserInfo(userID.(uint), appID.(string))
+	userInfo, err := authService.GetUserInfo(userID.(uint), appID.(string), ctx.GetUint("org_id"))
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -157,3 +335,93 @@ func (c *AuthController) GetUserInfo(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, userInfo)
 }
+
+// GetLoginHistory 获取当前用户的登录历史
+// @Summary 获取登录历史
+// @Description 按时间倒序返回当前登录用户的登录记录，包括IP、设备、位置和命中的异常规则
+// @Tags 认证
+// @Produce json
+// @Security BearerAuth
+// @Param success query bool false "按成功或失败过滤"
+// @Param risky query bool false "只返回异常登录"
+// @Param page query int false "页码"
+// @Param size query int false "每页条数（最大 100）"
+// @Router /auth/login-history [get]
+func (c *AuthController) GetLoginHistory(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	appID, exists := ctx.Get("app_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "应用未认证"})
+		return
+	}
+
+	respondLoginHistory(ctx, appID.(string), userID.(uint))
+}
+
+// ListOrganizations 获取当前用户所属的组织
+// @Summary 获取所属组织
+// @Description 返回当前登录用户所属的已启用组织，可用于登录时选择或切换当前组织
+// @Tags 认证
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "组织列表"
+// @Failure 401 {object} map[string]string "未认证"
+// @Router /auth/organizations [get]
+func (c *AuthController) ListOrganizations(ctx *gin.Context) {
+	organizationService := &service.OrganizationService{}
+	organizations, err := organizationService.ListUserOrganizations(ctx.GetString("app_id"), ctx.GetUint("user_id"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取组织列表失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": organizations, "current_org_id": ctx.GetUint("org_id")})
+}
+
+// SwitchOrganization 切换当前组织
+// @Summary 切换当前组织
+// @Description 以指定组织为当前组织重新签发访问令牌和刷新令牌（org_id 为 0 表示离开组织）。须提交当前会话的刷新令牌，新令牌沿用原令牌的证书或 DPoP 绑定和刷新令牌的过期时间，原令牌作废
+// @Tags 认证
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body service.SwitchOrganizationRequest true "目标组织和当前刷新令牌"
+// @Success 200 {object} service.LoginResponse "新令牌"
+// @Failure 401 {object} map[string]string "刷新令牌无效"
+// @Failure 403 {object} map[string]string "不是该组织的成员"
+// @Failure 404 {object} map[string]string "组织不存在或已停用"
+// @Router /auth/switch-organization [post]
+func (c *AuthController) SwitchOrganization(ctx *gin.Context) {
+	var req service.SwitchOrganizationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	value, _ := ctx.Get("cnf")
+	req.Cnf, _ = value.(*utils.Confirmation)
+	req.UserID = ctx.GetUint("user_id")
+	req.AppID = ctx.GetString("app_id")
+	req.AccessJTI = ctx.GetString("jti")
+
+	authService := &service.AuthService{}
+	response, err := authService.SwitchOrganization(&req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrOrganizationNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrOrganizationNotMember):
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
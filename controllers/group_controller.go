@@ -0,0 +1,286 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"auth-center/config"
+	"auth-center/middleware"
+	"auth-center/models"
+	"auth-center/service"
+	"auth-center/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GroupController 用户分组管理控制器（系统级和应用级超级管理员）
+type GroupController struct{}
+
+// findGroup 按路径参数查找目标应用内的分组，不存在时写入 404
+func (c *GroupController) findGroup(ctx *gin.Context) (*models.Group, bool) {
+	var group models.Group
+	if err := config.DB.Where("id = ? AND app_id = ?", ctx.Param("id"), middleware.GetTargetAppID(ctx)).First(&group).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "分组不存在"})
+		return nil, false
+	}
+	return &group, true
+}
+
+// ListGroups 获取应用分组列表
+func (c *GroupController) ListGroups(ctx *gin.Context) {
+	appID := middleware.GetTargetAppID(ctx)
+
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(ctx.DefaultQuery("size", "10"))
+	offset := (page - 1) * size
+
+	tx := config.DB.Model(&models.Group{}).Where("app_id = ?", appID)
+	if name := ctx.Query("name"); name != "" {
+		tx = tx.Where("name LIKE ?", "%"+name+"%")
+	}
+	var total int64
+	tx.Count(&total)
+
+	var groups []models.Group
+	if err := tx.Order("id").Offset(offset).Limit(size).Find(&groups).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取分组列表失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": groups,
+		"pagination": gin.H{
+			"page":      page,
+			"page_size": size,
+			"total":     total,
+		},
+	})
+}
+
+// CreateGroup 创建分组
+func (c *GroupController) CreateGroup(ctx *gin.Context) {
+	appID := middleware.GetTargetAppID(ctx)
+
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		Code        string `json:"code"`
+		Description string `json:"description"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 自动生成编码（如果未提供）
+	code := req.Code
+	if code == "" {
+		for i := 0; i < 5; i++ {
+			candidate := utils.GenerateShortCode(10)
+			var exists models.Group
+			if err := config.DB.Where("app_id = ? AND code = ?", appID, candidate).First(&exists).Error; err != nil {
+				code = candidate
+				break
+			}
+		}
+		if code == "" {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "生成分组编码失败"})
+			return
+		}
+	}
+
+	var existing models.Group
+	if err := config.DB.Where("app_id = ? AND code = ?", appID, code).First(&existing).Error; err == nil {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "分组编码已存在"})
+		return
+	}
+
+	group := models.Group{
+		AppID:       appID,
+		Name:        req.Name,
+		Code:        code,
+		Description: req.Description,
+	}
+	if err := config.DB.Create(&group).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "创建分组失败"})
+		return
+	}
+	middleware.SetAuditTarget(ctx, "group", strconv.FormatUint(uint64(group.ID), 10))
+	middleware.SetAuditChange(ctx, nil, group)
+
+	ctx.JSON(http.StatusCreated, gin.H{"data": group})
+}
+
+// GetGroup 获取分组详情
+func (c *GroupController) GetGroup(ctx *gin.Context) {
+	group, ok := c.findGroup(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": group})
+}
+
+// UpdateGroup 更新分组（编码不允许修改）
+func (c *GroupController) UpdateGroup(ctx *gin.Context) {
+	var req struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	group, ok := c.findGroup(ctx)
+	if !ok {
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != "" {
+		updates["name"] = req.Name
+	}
+	if req.Description != "" {
+		updates["description"] = req.Description
+	}
+
+	before := *group
+	if err := config.DB.Model(group).Updates(updates).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "更新分组失败"})
+		return
+	}
+	middleware.SetAuditChange(ctx, before, updates)
+
+	ctx.JSON(http.StatusOK, gin.H{"data": group})
+}
+
+// DeleteGroup 删除分组，成员失去该分组（及其上级分组）的角色，下级分组保留
+func (c *GroupController) DeleteGroup(ctx *gin.Context) {
+	group, ok := c.findGroup(ctx)
+	if !ok {
+		return
+	}
+
+	groupService := &service.GroupService{}
+	if err := groupService.DeleteGroup(group); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "删除分组失败"})
+		return
+	}
+	middleware.SetAuditChange(ctx, group, nil)
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "分组删除成功"})
+}
+
+// GetGroupMembers 获取分组的直接成员（用户和下级分组）
+func (c *GroupController) GetGroupMembers(ctx *gin.Context) {
+	group, ok := c.findGroup(ctx)
+	if !ok {
+		return
+	}
+
+	groupService := &service.GroupService{}
+	members, err := groupService.GetMembers(group.AppID, group.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取分组成员失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": members})
+}
+
+// AddGroupMembers 向分组加入用户和下级分组
+func (c *GroupController) AddGroupMembers(ctx *gin.Context) {
+	var req service.GroupMembers
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	group, ok := c.findGroup(ctx)
+	if !ok {
+		return
+	}
+
+	groupService := &service.GroupService{}
+	if err := groupService.AddMembers(group.AppID, group.ID, &req); err != nil {
+		switch {
+		case errors.Is(err, service.ErrGroupInheritanceCycle):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrGroupMemberInvalid):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "加入分组成员失败"})
+		}
+		return
+	}
+	middleware.SetAuditChange(ctx, nil, req)
+
+	c.GetGroupMembers(ctx)
+}
+
+// RemoveGroupMembers 从分组移除用户和下级分组
+func (c *GroupController) RemoveGroupMembers(ctx *gin.Context) {
+	var req service.GroupMembers
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	group, ok := c.findGroup(ctx)
+	if !ok {
+		return
+	}
+
+	groupService := &service.GroupService{}
+	if err := groupService.RemoveMembers(group.AppID, group.ID, &req); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "移除分组成员失败"})
+		return
+	}
+	middleware.SetAuditChange(ctx, req, nil)
+
+	c.GetGroupMembers(ctx)
+}
+
+// GetGroupRoles 获取分配给分组的角色
+func (c *GroupController) GetGroupRoles(ctx *gin.Context) {
+	group, ok := c.findGroup(ctx)
+	if !ok {
+		return
+	}
+
+	groupService := &service.GroupService{}
+	roleIDs, err := groupService.GetGroupRoles(group.AppID, group.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取分组角色失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"role_ids": roleIDs})
+}
+
+// SetGroupRoles 设置分组的角色（替换原有角色），分组及其下级分组的成员获得这些角色
+func (c *GroupController) SetGroupRoles(ctx *gin.Context) {
+	var req struct {
+		RoleIDs []uint `json:"role_ids"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	group, ok := c.findGroup(ctx)
+	if !ok {
+		return
+	}
+
+	groupService := &service.GroupService{}
+	previousIDs, _ := groupService.GetGroupRoles(group.AppID, group.ID)
+	if err := groupService.SetGroupRoles(group.AppID, group.ID, req.RoleIDs); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	middleware.SetAuditChange(ctx, gin.H{"role_ids": previousIDs}, gin.H{"role_ids": req.RoleIDs})
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "分组角色设置成功"})
+}
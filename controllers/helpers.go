@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"auth-center/middleware"
+	"auth-center/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// writePasswordPolicyError 密码不符合策略时返回结构化的违规项，err 不是密码策略错误时返回 false
+func writePasswordPolicyError(ctx *gin.Context, err error) bool {
+	var policyErr *service.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	ctx.JSON(http.StatusBadRequest, gin.H{
+		"error":      policyErr.Error(),
+		"violations": policyErr.Violations,
+	})
+	return true
+}
+
+// bindSignedAppID 处理请求签名与请求体中的 app_id
+// 请求已通过签名校验时，app_id 缺省取签名应用并标记无需校验应用密钥；两者不一致或缺少 app_id 时写入错误响应并返回 false
+func bindSignedAppID(ctx *gin.Context, appID *string, signatureVerified *bool) bool {
+	signedAppID, signed := middleware.GetSignedAppID(ctx)
+	if !signed {
+		if *appID == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "app_id 不能为空"})
+			return false
+		}
+		return true
+	}
+
+	if *appID == "" {
+		*appID = signedAppID
+	} else if *appID != signedAppID {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "app_id 与签名应用不一致"})
+		return false
+	}
+	*signatureVerified = true
+	return true
+}
+
+// appRateLimitHook 应用密钥校验通过后按应用维度限流：未签名的请求在中间件中只能按 IP 限流
+// 超限时已写入 429 响应，返回 service.ErrRateLimited
+func appRateLimitHook(ctx *gin.Context) func(appID string) error {
+	return func(appID string) error {
+		if !middleware.AllowVerifiedApp(ctx, appID) {
+			return service.ErrRateLimited
+		}
+		return nil
+	}
+}
+
+// bindDPoPProof 校验令牌请求中的 DPoP 证明，通过时写入公钥指纹；校验失败时写入错误响应并返回 false
+func bindDPoPProof(ctx *gin.Context, jkt *string) bool {
+	proof, err := middleware.VerifyDPoPHeader(ctx, "")
+	if err != nil {
+		middleware.WriteDPoPError(ctx, err, false)
+		return false
+	}
+	if proof != nil {
+		*jkt = proof.Thumbprint
+		middleware.SetDPoPNonce(ctx)
+	}
+	return true
+}
+
+// respondLoginHistory 按查询参数 success、risky、page、size 分页返回用户的登录历史
+func respondLoginHistory(ctx *gin.Context, appID string, userID uint) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(ctx.DefaultQuery("size", "20"))
+	query := &service.LoginHistoryQuery{
+		AppID:    appID,
+		UserID:   userID,
+		Risky:    ctx.Query("risky") == "true",
+		Page:     page,
+		PageSize: size,
+	}
+	if success := ctx.Query("success"); success != "" {
+		value := success == "true"
+		query.Success = &value
+	}
+
+	riskService := &service.LoginRiskService{}
+	histories, total, err := riskService.ListLoginHistory(query)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取登录历史失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": histories,
+		"pagination": gin.H{
+			"page":      query.Page,
+			"page_size": query.PageSize,
+			"total":     total,
+		},
+	})
+}
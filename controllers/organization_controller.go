@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"auth-center/config"
+	"auth-center/middleware"
+	"auth-center/models"
+	"auth-center/service"
+	"auth-center/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrganizationController 组织管理控制器（系统级和应用级超级管理员）
+type OrganizationController struct{}
+
+// findOrganization 按路径参数查找目标应用内的组织，不存在时写入 404
+func (c *OrganizationController) findOrganization(ctx *gin.Context) (*models.Organization, bool) {
+	var org models.Organization
+	if err := config.DB.Where("id = ? AND app_id = ?", ctx.Param("id"), middleware.GetTargetAppID(ctx)).First(&org).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "组织不存在"})
+		return nil, false
+	}
+	return &org, true
+}
+
+// parseMemberUserID 解析路径中的成员用户ID
+func parseMemberUserID(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("user_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// ListOrganizations 获取应用组织列表
+func (c *OrganizationController) ListOrganizations(ctx *gin.Context) {
+	appID := middleware.GetTargetAppID(ctx)
+
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(ctx.DefaultQuery("size", "10"))
+	offset := (page - 1) * size
+
+	tx := config.DB.Model(&models.Organization{}).Where("app_id = ?", appID)
+	if name := ctx.Query("name"); name != "" {
+		tx = tx.Where("name LIKE ?", "%"+name+"%")
+	}
+	var total int64
+	tx.Count(&total)
+
+	var organizations []models.Organization
+	if err := tx.Order("id").Offset(offset).Limit(size).Find(&organizations).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取组织列表失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": organizations,
+		"pagination": gin.H{
+			"page":      page,
+			"page_size": size,
+			"total":     total,
+		},
+	})
+}
+
+// CreateOrganization 创建组织
+func (c *OrganizationController) CreateOrganization(ctx *gin.Context) {
+	appID := middleware.GetTargetAppID(ctx)
+
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		Code        string `json:"code"`
+		Description string `json:"description"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 自动生成编码（如果未提供）
+	code := req.Code
+	if code == "" {
+		for i := 0; i < 5; i++ {
+			candidate := utils.GenerateShortCode(10)
+			var exists models.Organization
+			if err := config.DB.Where("app_id = ? AND code = ?", appID, candidate).First(&exists).Error; err != nil {
+				code = candidate
+				break
+			}
+		}
+		if code == "" {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "生成组织编码失败"})
+			return
+		}
+	}
+
+	var existing models.Organization
+	if err := config.DB.Where("app_id = ? AND code = ?", appID, code).First(&existing).Error; err == nil {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "组织编码已存在"})
+		return
+	}
+
+	org := models.Organization{
+		AppID:       appID,
+		Name:        req.Name,
+		Code:        code,
+		Description: req.Description,
+		Status:      1,
+	}
+	if err := config.DB.Create(&org).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "创建组织失败"})
+		return
+	}
+	middleware.SetAuditTarget(ctx, "organization", strconv.FormatUint(uint64(org.ID), 10))
+	middleware.SetAuditChange(ctx, nil, org)
+
+	ctx.JSON(http.StatusCreated, gin.H{"data": org})
+}
+
+// GetOrganization 获取组织详情
+func (c *OrganizationController) GetOrganization(ctx *gin.Context) {
+	org, ok := c.findOrganization(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": org})
+}
+
+// UpdateOrganization 更新组织（编码不允许修改），停用后组织内的角色不再生效，成员也不能再切换到该组织
+func (c *OrganizationController) UpdateOrganization(ctx *gin.Context) {
+	var req struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Status      *int   `json:"status"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Status != nil && *req.Status != 0 && *req.Status != 1 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "状态只能为 0 或 1"})
+		return
+	}
+
+	org, ok := c.findOrganization(ctx)
+	if !ok {
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != "" {
+		updates["name"] = req.Name
+	}
+	if req.Description != "" {
+		updates["description"] = req.Description
+	}
+	if req.Status != nil {
+		updates["status"] = *req.Status
+	}
+
+	before := *org
+	organizationService := &service.OrganizationService{}
+	if err := organizationService.UpdateOrganization(org, updates); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "更新组织失败"})
+		return
+	}
+	middleware.SetAuditChange(ctx, before, updates)
+
+	ctx.JSON(http.StatusOK, gin.H{"data": org})
+}
+
+// DeleteOrganization 删除组织及其成员，成员失去在组织内的角色
+func (c *OrganizationController) DeleteOrganization(ctx *gin.Context) {
+	org, ok := c.findOrganization(ctx)
+	if !ok {
+		return
+	}
+
+	organizationService := &service.OrganizationService{}
+	if err := organizationService.DeleteOrganization(org); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "删除组织失败"})
+		return
+	}
+	middleware.SetAuditChange(ctx, org, nil)
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "组织删除成功"})
+}
+
+// GetOrganizationMembers 获取组织成员及其在组织内的角色
+func (c *OrganizationController) GetOrganizationMembers(ctx *gin.Context) {
+	org, ok := c.findOrganization(ctx)
+	if !ok {
+		return
+	}
+
+	organizationService := &service.OrganizationService{}
+	members, err := organizationService.GetMembers(org.AppID, org.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取组织成员失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": members})
+}
+
+// SetOrganizationMember 将用户加入组织并设置其在组织内的角色（替换原有角色）
+func (c *OrganizationController) SetOrganizationMember(ctx *gin.Context) {
+	var req struct {
+		RoleIDs []uint `json:"role_ids"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := parseMemberUserID(ctx)
+	if !ok {
+		return
+	}
+	org, ok := c.findOrganization(ctx)
+	if !ok {
+		return
+	}
+
+	organizationService := &service.OrganizationService{}
+	if err := organizationService.SetMember(org.AppID, org.ID, userID, req.RoleIDs); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	middleware.SetAuditChange(ctx, nil, gin.H{"user_id": userID, "role_ids": req.RoleIDs})
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "组织成员设置成功"})
+}
+
+// RemoveOrganizationMember 将用户移出组织
+func (c *OrganizationController) RemoveOrganizationMember(ctx *gin.Context) {
+	userID, ok := parseMemberUserID(ctx)
+	if !ok {
+		return
+	}
+	org, ok := c.findOrganization(ctx)
+	if !ok {
+		return
+	}
+
+	organizationService := &service.OrganizationService{}
+	if err := organizationService.RemoveMember(org.AppID, org.ID, userID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "移除组织成员失败"})
+		return
+	}
+	middleware.SetAuditChange(ctx, gin.H{"user_id": userID}, nil)
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "组织成员移除成功"})
+}
@@ -1,7 +1,9 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
 	"auth-center/service"
 
@@ -42,7 +44,7 @@ func (c *PermissionController) CheckPermission(ctx *gin.Context) {
 		return
 	}
 
-	permissionService := &service.PermissionService{}
+	permissionService := &service.PermissionService{OrgID: ctx.GetUint("org_id")}
 	hasPermission, err := permissionService.CheckUserPermission(userID.(uint), appID.(string), permission)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -86,7 +88,7 @@ func (c *PermissionController) CheckAPIPermission(ctx *gin.Context) {
 		return
 	}
 
-	permissionService := &service.PermissionService{}
+	permissionService := &service.PermissionService{OrgID: ctx.GetUint("org_id")}
 	hasPermission, err := permissionService.CheckAPIPermission(userID.(uint), appID.(string), path, method)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -96,6 +98,184 @@ func (c *PermissionController) CheckAPIPermission(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"has_permission": hasPermission})
 }
 
+// permissionBatchMaxItems 单次批量检查的最大条目数（权限代码与API合计）
+const permissionBatchMaxItems = 200
+
+// CheckPermissionBatch 批量检查权限
+// @Summary 批量检查权限
+// @Description 一次检查多个权限代码和API，用户的有效权限只计算一次
+// @Tags 权限管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body object true "permissions 为权限代码列表，apis 为 path 和 method 列表"
+// @Success 200 {object} service.BatchPermissionResult "权限检查结果"
+// @Failure 400 {object} map[string]string "请求参数错误"
+// @Failure 401 {object} map[string]string "未认证"
+// @Failure 500 {object} map[string]string "服务器错误"
+// @Router /permissions/check-batch [post]
+func (c *PermissionController) CheckPermissionBatch(ctx *gin.Context) {
+	var req struct {
+		Permissions []string                     `json:"permissions"`
+		APIs        []service.APIPermissionQuery `json:"apis"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	total := len(req.Permissions) + len(req.APIs)
+	if total == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "需要提供权限代码或API"})
+		return
+	}
+	if total > permissionBatchMaxItems {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("单次最多检查 %d 项", permissionBatchMaxItems)})
+		return
+	}
+	for _, permission := range req.Permissions {
+		if permission == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "权限代码不能为空"})
+			return
+		}
+	}
+	for _, api := range req.APIs {
+		if api.Path == "" || api.Method == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "API路径和方法不能为空"})
+			return
+		}
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	appID, exists := ctx.Get("app_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "应用未认证"})
+		return
+	}
+
+	permissionService := &service.PermissionService{OrgID: ctx.GetUint("org_id")}
+	result, err := permissionService.CheckPermissionsBatch(userID.(uint), appID.(string), req.Permissions, req.APIs)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// EvaluatePermission 按请求上下文检查权限
+// @Summary 按请求上下文检查权限
+// @Description 检查用户是否具有指定权限或API访问权限，附带条件的权限按请求上下文（来源IP、当前时间和调用方传入的资源属性）求值
+// @Tags 权限管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body object true "permission，或 path 和 method；resource 为资源属性"
+// @Success 200 {object} map[string]interface{} "权限检查结果"
+// @Failure 400 {object} map[string]string "请求参数错误"
+// @Failure 401 {object} map[string]string "未认证"
+// @Failure 500 {object} map[string]string "服务器错误"
+// @Router /permissions/evaluate [post]
+func (c *PermissionController) EvaluatePermission(ctx *gin.Context) {
+	var req struct {
+		Permission string                 `json:"permission"`
+		Path       string                 `json:"path"`
+		Method     string                 `json:"method"`
+		Resource   map[string]interface{} `json:"resource"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Permission == "" && (req.Path == "" || req.Method == "") {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "需要提供权限代码，或API路径和方法"})
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	appID, exists := ctx.Get("app_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "应用未认证"})
+		return
+	}
+
+	// 来源IP和时间由服务端确定，调用方不能指定
+	pctx := &service.PermissionContext{
+		IP:       ctx.ClientIP(),
+		Time:     time.Now(),
+		Path:     req.Path,
+		Method:   req.Method,
+		Resource: req.Resource,
+	}
+
+	permissionService := &service.PermissionService{OrgID: ctx.GetUint("org_id")}
+	var decision *service.PermissionDecision
+	var err error
+	if req.Permission != "" {
+		decision, err = permissionService.ExplainUserPermission(userID.(uint), appID.(string), req.Permission, pctx)
+	} else {
+		decision, err = permissionService.ExplainAPIPermission(userID.(uint), appID.(string), req.Path, req.Method, pctx)
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"has_permission": decision.Allowed, "decision": decision.Decision})
+}
+
+// GetDataScope 获取数据范围
+// @Summary 获取数据范围
+// @Description 返回当前用户对资源类型（及操作）可访问的数据范围（行级权限），下游服务据此构造查询条件；附带条件的权限按来源IP和当前时间求值
+// @Tags 权限管理
+// @Produce json
+// @Security BearerAuth
+// @Param resource query string true "资源类型"
+// @Param action query string false "操作，不传时包含该资源类型的全部操作"
+// @Success 200 {object} service.ResolvedDataScope "数据范围"
+// @Failure 400 {object} map[string]string "请求参数错误"
+// @Failure 401 {object} map[string]string "未认证"
+// @Failure 500 {object} map[string]string "服务器错误"
+// @Router /permissions/data-scope [get]
+func (c *PermissionController) GetDataScope(ctx *gin.Context) {
+	resource := ctx.Query("resource")
+	if resource == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "需要提供资源类型"})
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	appID, exists := ctx.Get("app_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "应用未认证"})
+		return
+	}
+
+	pctx := &service.PermissionContext{IP: ctx.ClientIP(), Time: time.Now()}
+	permissionService := &service.PermissionService{OrgID: ctx.GetUint("org_id")}
+	scope, err := permissionService.ResolveDataScope(userID.(uint), appID.(string), resource, ctx.Query("action"), pctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, scope)
+}
+
 // GetUserPermissions 获取用户权限列表
 // @Summary 获取用户权限列表
 // @Description 获取当前用户的所有权限
@@ -119,7 +299,7 @@ func (c *PermissionController) GetUserPermissions(ctx *gin.Context) {
 		return
 	}
 
-	permissionService := &service.PermissionService{}
+	permissionService := &service.PermissionService{OrgID: ctx.GetUint("org_id")}
 	permissions, err := permissionService.GetUserPermissionsFromDB(userID.(uint), appID.(string))
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -153,7 +333,7 @@ func (c *PermissionController) GetUserRoles(ctx *gin.Context) {
 	}
 
 	authService := &service.AuthService{}
-	roles, err := authService.GetUserRoles(userID.(uint))
+	roles, err := authService.GetUserRoles(userID.(uint), ctx.GetUint("org_id"))
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
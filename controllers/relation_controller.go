@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"auth-center/middleware"
+	"auth-center/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RelationController 关系授权控制器
+// 同一组接口同时提供给管理员（/api/v1/app，按管理员的目标应用）和应用后端（/api/v1/admin，按应用凭据认证的应用）
+type RelationController struct{}
+
+// getAppID 管理员请求取目标应用ID，应用请求取认证的应用ID
+func (c *RelationController) getAppID(ctx *gin.Context) string {
+	if _, isAdmin := ctx.Get("admin_type"); isAdmin {
+		return middleware.GetTargetAppID(ctx)
+	}
+	return ctx.GetString("app_id")
+}
+
+// writeRelationError 按错误类型写入响应
+func writeRelationError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrRelationSchemaNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrRelationInvalid), errors.Is(err, service.ErrInvalidConsistencyToken),
+		errors.Is(err, service.ErrRelationDepthExceeded):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "关系授权处理失败"})
+	}
+}
+
+// GetSchema 获取关系模式
+func (c *RelationController) GetSchema(ctx *gin.Context) {
+	relationService := &service.RelationService{}
+	schema, token, err := relationService.GetSchema(c.getAppID(ctx))
+	if err != nil {
+		writeRelationError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": schema, "token": token})
+}
+
+// UpdateSchema 替换关系模式
+func (c *RelationController) UpdateSchema(ctx *gin.Context) {
+	var schema service.RelationSchema
+	if err := ctx.ShouldBindJSON(&schema); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	appID := c.getAppID(ctx)
+	relationService := &service.RelationService{}
+	before, _, _ := relationService.GetSchema(appID)
+	token, err := relationService.UpdateSchema(appID, &schema)
+	if err != nil {
+		writeRelationError(ctx, err)
+		return
+	}
+	middleware.SetAuditChange(ctx, before, schema)
+
+	ctx.JSON(http.StatusOK, gin.H{"data": schema, "token": token})
+}
+
+// ReadTuples 查询关系元组
+func (c *RelationController) ReadTuples(ctx *gin.Context) {
+	filter := &service.RelationTupleFilter{
+		Namespace: ctx.Query("namespace"),
+		ObjectID:  ctx.Query("object_id"),
+		Relation:  ctx.Query("relation"),
+		Subject:   ctx.Query("subject"),
+	}
+	consistency := &service.RelationConsistency{Mode: ctx.Query("consistency"), Token: ctx.Query("token")}
+
+	relationService := &service.RelationService{}
+	tuples, token, err := relationService.ReadTuples(c.getAppID(ctx), filter, consistency)
+	if err != nil {
+		writeRelationError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": tuples, "token": token})
+}
+
+// WriteTuples 写入或删除关系元组
+func (c *RelationController) WriteTuples(ctx *gin.Context) {
+	var req struct {
+		Writes []service.RelationTupleWrite `json:"writes" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	relationService := &service.RelationService{}
+	token, err := relationService.WriteTuples(c.getAppID(ctx), req.Writes)
+	if err != nil {
+		writeRelationError(ctx, err)
+		return
+	}
+	middleware.SetAuditChange(ctx, nil, req.Writes)
+
+	ctx.JSON(http.StatusOK, gin.H{"token": token})
+}
+
+// Check 判断主体是否拥有对象的关系
+func (c *RelationController) Check(ctx *gin.Context) {
+	var req struct {
+		Object      string                       `json:"object" binding:"required"`
+		Relation    string                       `json:"relation" binding:"required"`
+		Subject     string                       `json:"subject" binding:"required"`
+		Consistency *service.RelationConsistency `json:"consistency"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	relationService := &service.RelationService{}
+	allowed, token, err := relationService.Check(c.getAppID(ctx), req.Object, req.Relation, req.Subject, req.Consistency)
+	if err != nil {
+		writeRelationError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"allowed": allowed, "token": token})
+}
+
+// Expand 展开对象的关系
+func (c *RelationController) Expand(ctx *gin.Context) {
+	var req struct {
+		Object      string                       `json:"object" binding:"required"`
+		Relation    string                       `json:"relation" binding:"required"`
+		Consistency *service.RelationConsistency `json:"consistency"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	relationService := &service.RelationService{}
+	tree, token, err := relationService.Expand(c.getAppID(ctx), req.Object, req.Relation, req.Consistency)
+	if err != nil {
+		writeRelationError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": tree, "token": token})
+}
+
+// ListObjects 列出主体拥有关系的对象
+func (c *RelationController) ListObjects(ctx *gin.Context) {
+	var req struct {
+		Namespace   string                       `json:"namespace" binding:"required"`
+		Relation    string                       `json:"relation" binding:"required"`
+		Subject     string                       `json:"subject" binding:"required"`
+		Limit       int                          `json:"limit"`
+		Consistency *service.RelationConsistency `json:"consistency"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	relationService := &service.RelationService{}
+	ids, token, err := relationService.ListObjects(c.getAppID(ctx), req.Namespace, req.Relation, req.Subject, req.Limit, req.Consistency)
+	if err != nil {
+		writeRelationError(ctx, err)
+		return
+	}
+
+	objects := make([]string, 0, len(ids))
+	for _, id := range ids {
+		objects = append(objects, req.Namespace+":"+id)
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": objects, "token": token})
+}
@@ -28,6 +28,9 @@ func (c *SystemAdminController) SystemLogin(ctx *gin.Context) {
 		return
 	}
 
+	req.ClientIP = ctx.ClientIP()
+	req.UserAgent = ctx.Request.UserAgent()
+
 	adminService := &service.SystemAdminService{}
 	response, err := adminService.SystemLogin(&req)
 	if err != nil {
@@ -38,6 +41,40 @@ func (c *SystemAdminController) SystemLogin(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, response)
 }
 
+// SystemChangeExpiredPassword 系统管理员密码过期时设置新密码并完成登录
+// @Summary 系统管理员修改过期密码
+// @Description 登录返回 password_expired 时，使用 password_change_token 设置新密码并获取令牌
+// @Tags 系统管理
+// @Accept json
+// @Produce json
+// @Param request body service.SystemChangeExpiredPasswordRequest true "修改过期密码请求"
+// @Success 200 {object} service.SystemLoginResponse "登录成功"
+// @Failure 400 {object} map[string]string "新密码不符合密码策略"
+// @Failure 401 {object} map[string]string "令牌无效"
+// @Router /system/password/expired [post]
+func (c *SystemAdminController) SystemChangeExpiredPassword(ctx *gin.Context) {
+	var req service.SystemChangeExpiredPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.ClientIP = ctx.ClientIP()
+	req.UserAgent = ctx.Request.UserAgent()
+
+	adminService := &service.SystemAdminService{}
+	response, err := adminService.SystemChangeExpiredPassword(&req)
+	if err != nil {
+		if writePasswordPolicyError(ctx, err) {
+			return
+		}
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
 // SystemRegister 系统管理员注册
 // @Summary 系统管理员注册
 // @Description 注册新的系统管理员（仅系统级超级管理员可操作）
@@ -59,6 +96,9 @@ func (c *SystemAdminController) SystemRegister(ctx *gin.Context) {
 	adminService := &service.SystemAdminService{}
 	response, err := adminService.SystemRegister(&req)
 	if err != nil {
+		if writePasswordPolicyError(ctx, err) {
+			return
+		}
 		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		return
 	}
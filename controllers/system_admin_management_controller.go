@@ -2,12 +2,16 @@ package controllers
 
 import (
 	"auth-center/config"
+	"auth-center/middleware"
 	"auth-center/models"
+	"auth-center/service"
 	"auth-center/utils"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type SystemAdminManagementController struct{}
@@ -105,6 +109,16 @@ func (c *SystemAdminManagementController) CreateSystemAdmin(ctx *gin.Context) {
 		}
 	}
 
+	// 校验密码策略
+	policyAppID := service.AdminPolicyAppID(req.AdminType, req.AppID)
+	policyService := &service.PasswordPolicyService{}
+	if err := policyService.CheckPassword(policyAppID, service.AccountTypeSystemAdmin, 0, req.Username, req.Password); err != nil {
+		if !writePasswordPolicyError(ctx, err) {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取密码策略失败"})
+		}
+		return
+	}
+
 	// 加密密码
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
@@ -119,20 +133,31 @@ func (c *SystemAdminManagementController) CreateSystemAdmin(ctx *gin.Context) {
 	}
 
 	// 创建管理员
+	now := time.Now()
 	admin := models.SystemAdmin{
-		Username:  req.Username,
-		Email:     req.Email,
-		Phone:     req.Phone,
-		Password:  hashedPassword,
-		AdminType: req.AdminType,
-		AppID:     req.AppID,
-		IsActive:  isActive,
-	}
-
-	if err := config.DB.Create(&admin).Error; err != nil {
+		Username:          req.Username,
+		Email:             req.Email,
+		Phone:             req.Phone,
+		Password:          hashedPassword,
+		AdminType:         req.AdminType,
+		AppID:             req.AppID,
+		IsActive:          isActive,
+		PasswordChangedAt: &now,
+	}
+
+	// 管理员与密码历史在同一事务中写入
+	if err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&admin).Error; err != nil {
+			return err
+		}
+		return policyService.RecordPasswordHistory(tx, policyAppID, service.AccountTypeSystemAdmin, admin.ID, hashedPassword)
+	}); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "创建系统管理员失败"})
 		return
 	}
+	middleware.SetAuditAppID(ctx, admin.AppID)
+	middleware.SetAuditTarget(ctx, "system_admin", strconv.FormatUint(uint64(admin.ID), 10))
+	middleware.SetAuditChange(ctx, nil, admin)
 
 	ctx.JSON(http.StatusCreated, gin.H{
 		"message": "系统管理员创建成功",
@@ -167,6 +192,8 @@ func (c *SystemAdminManagementController) UpdateSystemAdmin(ctx *gin.Context) {
 		return
 	}
 
+	before := admin
+
 	// 更新字段
 	updates := make(map[string]interface{})
 	
@@ -185,12 +212,13 @@ func (c *SystemAdminManagementController) UpdateSystemAdmin(ctx *gin.Context) {
 	}
 	
 	if req.Password != nil {
-		hashedPassword, err := utils.HashPassword(*req.Password)
-		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
+		adminService := &service.SystemAdminService{}
+		if err := adminService.SetAdminPassword(&admin, *req.Password); err != nil {
+			if !writePasswordPolicyError(ctx, err) {
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "更新密码失败"})
+			}
 			return
 		}
-		updates["password"] = hashedPassword
 	}
 	
 	if req.IsActive != nil {
@@ -202,6 +230,11 @@ func (c *SystemAdminManagementController) UpdateSystemAdmin(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "更新系统管理员失败"})
 		return
 	}
+	if req.Password != nil {
+		updates["password"] = *req.Password // 审计日志中会被脱敏
+	}
+	middleware.SetAuditAppID(ctx, admin.AppID)
+	middleware.SetAuditChange(ctx, before, updates)
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": "系统管理员更新成功",
@@ -240,6 +273,8 @@ func (c *SystemAdminManagementController) DeleteSystemAdmin(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "删除系统管理员失败"})
 		return
 	}
+	middleware.SetAuditAppID(ctx, admin.AppID)
+	middleware.SetAuditChange(ctx, admin, nil)
 
 	ctx.JSON(http.StatusOK, gin.H{"message": "系统管理员删除成功"})
 }
@@ -253,7 +288,7 @@ func (c *SystemAdminManagementController) ResetSystemAdminPassword(ctx *gin.Cont
 	}
 
 	var req struct {
-		NewPassword string `json:"new_password" binding:"required,min=6"`
+		NewPassword string `json:"new_password" binding:"required"`
 	}
 
 	if err := ctx.ShouldBindJSON(&req); err != nil {
@@ -268,18 +303,47 @@ func (c *SystemAdminManagementController) ResetSystemAdminPassword(ctx *gin.Cont
 		return
 	}
 
-	// 加密新密码
-	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	// 按密码策略校验并更新密码
+	adminService := &service.SystemAdminService{}
+	if err := adminService.SetAdminPassword(&admin, req.NewPassword); err != nil {
+		if !writePasswordPolicyError(ctx, err) {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "重置密码失败"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "密码重置成功"})
+}
+
+// GetPasswordPolicy 获取系统级管理员的密码策略，应用级管理员使用所属应用的策略
+func (c *SystemAdminManagementController) GetPasswordPolicy(ctx *gin.Context) {
+	policyService := &service.PasswordPolicyService{}
+	policy, err := policyService.GetPolicy(service.SystemAdminPolicyAppID)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取密码策略失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": policy})
+}
+
+// UpdatePasswordPolicy 更新系统级管理员的密码策略
+func (c *SystemAdminManagementController) UpdatePasswordPolicy(ctx *gin.Context) {
+	var req service.UpdatePasswordPolicyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 更新密码
-	if err := config.DB.Model(&admin).Update("password", hashedPassword).Error; err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "重置密码失败"})
+	policyService := &service.PasswordPolicyService{}
+	before, _ := policyService.GetPolicy(service.SystemAdminPolicyAppID)
+	policy, err := policyService.UpdatePolicy(service.SystemAdminPolicyAppID, &req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	middleware.SetAuditTarget(ctx, "password_policy", "system_admin")
+	middleware.SetAuditChange(ctx, before, policy)
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "密码重置成功"})
+	ctx.JSON(http.StatusOK, gin.H{"data": policy})
 }
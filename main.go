@@ -2,10 +2,12 @@ package main
 
 import (
 	"log"
+	"net/http"
 	"time"
 
 	"auth-center/config"
 	"auth-center/routers"
+	"auth-center/service"
 	"auth-center/utils"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -25,6 +27,34 @@ func main() {
 	// 初始化配置
 	config.InitAll()
 
+	// 迁移明文存储的应用密钥
+	if err := service.MigrateLegacyAppSecrets(); err != nil {
+		log.Fatalf("应用密钥迁移失败: %v", err)
+	}
+
+	// 系统级管理员的密码策略不再借用 system-admin 应用的策略
+	if err := service.MigrateSystemAdminPasswordPolicy(); err != nil {
+		log.Fatalf("系统管理员密码策略迁移失败: %v", err)
+	}
+
+	// 为旧审计日志补齐哈希链，并定期生成签名检查点
+	if err := service.MigrateAuditChain(); err != nil {
+		log.Fatalf("审计日志哈希链迁移失败: %v", err)
+	}
+	if _, err := service.AuditSigningKey(); err != nil {
+		log.Fatalf("加载审计签名密钥失败: %v", err)
+	}
+	service.StartAuditCheckpointer()
+
+	// 接收其他副本的权限缓存失效通知
+	service.StartPermissionCacheSubscriber()
+
+	// 处理角色分配和角色权限分配的有效期
+	service.StartAssignmentScheduler()
+
+	// 清理超过保留时间的已删除关系元组
+	service.StartRelationTupleGC()
+
 	// 创建 Gin 实例
 	r := gin.Default()
 
@@ -32,8 +62,8 @@ func main() {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"}, // 允许所有来源，生产环境应该限制
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-App-Id", "X-App-Secret"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-App-Id", "X-App-Secret", "X-Timestamp", "X-Nonce", "X-Signature", "DPoP"},
+		ExposeHeaders:    []string{"Content-Length", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "DPoP-Nonce", "WWW-Authenticate"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -42,7 +72,7 @@ func main() {
 	r.OPTIONS("/*path", func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-App-Id, X-App-Secret")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-App-Id, X-App-Secret, X-Timestamp, X-Nonce, X-Signature, DPoP")
 		c.Header("Access-Control-Allow-Credentials", "true")
 		c.Status(200)
 	})
@@ -64,12 +94,33 @@ func main() {
 		log.Printf("Swagger 文档生成失败: %v\n", err)
 	}
 
+	// 计算 DPoP htu 时信任的反向代理
+	if _, err := config.GetConfig().DPoP.TrustedProxyNetworks(); err != nil {
+		log.Fatalf("DPoP 配置错误: %v", err)
+	}
+
 	// 启动服务
 	port := config.GetConfig().Server.Port
 	if port == "" {
 		port = "8080"
 	}
 
+	// 启用 TLS 时支持客户端证书（mTLS）认证
+	tlsCfg := config.GetConfig().TLS
+	if tlsCfg.Enabled {
+		tlsConfig, err := tlsCfg.ServerTLSConfig()
+		if err != nil {
+			log.Fatalf("TLS 配置错误: %v", err)
+		}
+		server := &http.Server{
+			Addr:      ":" + port,
+			Handler:   r,
+			TLSConfig: tlsConfig,
+		}
+		log.Printf("认证授权中心启动在端口: %s (HTTPS)", port)
+		log.Fatal(server.ListenAndServeTLS(tlsCfg.CertFile, tlsCfg.KeyFile))
+	}
+
 	log.Printf("认证授权中心启动在端口: %s", port)
 	r.Run(":" + port)
 }
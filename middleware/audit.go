@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"auth-center/models"
+	"auth-center/service"
+	"github.com/gin-gonic/gin"
+)
+
+// auditEntryKey 上下文中审计日志条目的键
+const auditEntryKey = "audit_entry"
+
+// auditMaxErrorBody 失败响应中最多读取的字节数（用于提取错误信息）
+const auditMaxErrorBody = 4096
+
+// auditResponseWriter 捕获失败响应的响应体，以便在审计日志中记录错误信息
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditResponseWriter) Write(data []byte) (int, error) {
+	if w.Status() >= http.StatusBadRequest && w.body.Len() < auditMaxErrorBody {
+		remaining := auditMaxErrorBody - w.body.Len()
+		if len(data) < remaining {
+			remaining = len(data)
+		}
+		w.body.Write(data[:remaining])
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// AuditMiddleware 审计中间件：记录写操作（POST、PUT、PATCH、DELETE）的操作者、目标、结果和客户端信息
+// 应放在认证中间件之前，认证失败的请求同样会被记录
+func AuditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		entry := &models.AuditLog{}
+		c.Set(auditEntryKey, entry)
+		writer := &auditResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		fillAuditEntry(c, entry, writer)
+		service.RecordAudit(entry)
+	}
+}
+
+// SetAuditAction 覆盖默认的操作名称（默认由处理函数名生成，如 CreateUser -> create_user）
+func SetAuditAction(c *gin.Context, action string) {
+	if entry := auditEntry(c); entry != nil {
+		entry.Action = action
+	}
+}
+
+// SetAuditTarget 设置审计日志的操作对象
+func SetAuditTarget(c *gin.Context, targetType, targetID string) {
+	if entry := auditEntry(c); entry != nil {
+		entry.TargetType = targetType
+		entry.TargetID = targetID
+	}
+}
+
+// SetAuditAppID 设置审计日志所属应用（默认取路径参数 app_id 或管理员的目标应用）
+func SetAuditAppID(c *gin.Context, appID string) {
+	if entry := auditEntry(c); entry != nil {
+		entry.AppID = appID
+	}
+}
+
+// SetAuditChange 记录变更前后的快照与字段差异
+// 创建时 before 为 nil，删除时 after 为 nil；after 可以只包含变更的字段
+func SetAuditChange(c *gin.Context, before, after interface{}) {
+	if entry := auditEntry(c); entry != nil {
+		entry.Before = service.AuditSnapshot(before)
+		entry.After = service.AuditSnapshot(after)
+		entry.Diff = service.AuditDiff(before, after)
+	}
+}
+
+// auditEntry 获取当前请求的审计日志条目，未启用审计时返回 nil
+func auditEntry(c *gin.Context) *models.AuditLog {
+	value, exists := c.Get(auditEntryKey)
+	if !exists {
+		return nil
+	}
+	entry, _ := value.(*models.AuditLog)
+	return entry
+}
+
+// fillAuditEntry 根据请求上下文补全审计日志中处理函数未设置的字段
+func fillAuditEntry(c *gin.Context, entry *models.AuditLog, writer *auditResponseWriter) {
+	if adminID, ok := GetSystemAdminID(c); ok {
+		entry.ActorType = service.AuditActorSystemAdmin
+		entry.ActorID = adminID
+		entry.ActorName = c.GetString("admin_username")
+	}
+
+	if entry.Action == "" {
+		entry.Action = AuditActionName(c.HandlerName())
+	}
+	if entry.AppID == "" {
+		entry.AppID = c.Param("app_id")
+	}
+	if entry.AppID == "" && entry.ActorType == service.AuditActorSystemAdmin {
+		entry.AppID = GetTargetAppID(c)
+	}
+	if entry.TargetID == "" {
+		entry.TargetType, entry.TargetID = auditDefaultTarget(c)
+	}
+
+	entry.Method = c.Request.Method
+	entry.Path = c.Request.URL.Path
+	entry.StatusCode = writer.Status()
+	entry.IP = c.ClientIP()
+	entry.UserAgent = c.Request.UserAgent()
+	entry.Result = service.AuditResultSuccess
+	if entry.StatusCode >= http.StatusBadRequest {
+		entry.Result = service.AuditResultFailure
+		entry.Error = auditErrorMessage(writer.body.Bytes())
+	}
+}
+
+// auditDefaultTarget 从路由参数推断操作对象：优先 :id，其次 :app_id
+// 类型取参数前一段路径的单数形式，如 /system-admins/:id -> system_admin
+func auditDefaultTarget(c *gin.Context) (string, string) {
+	segments := strings.Split(strings.Trim(c.FullPath(), "/"), "/")
+	for _, param := range []string{":id", ":app_id"} {
+		for i, segment := range segments {
+			if segment == param && i > 0 {
+				targetType := strings.ReplaceAll(strings.TrimSuffix(segments[i-1], "s"), "-", "_")
+				return targetType, c.Param(strings.TrimPrefix(param, ":"))
+			}
+		}
+	}
+	return "", ""
+}
+
+// auditErrorMessage 从失败响应体中提取错误信息
+func auditErrorMessage(body []byte) string {
+	var resp struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return ""
+	}
+	if resp.ErrorDescription != "" {
+		return resp.Error + ": " + resp.ErrorDescription
+	}
+	return resp.Error
+}
+
+// AuditActionName 由处理函数名生成操作名称
+// 例如 auth-center/controllers.(*AppResourceController).CreateUser-fm -> create_user
+func AuditActionName(handlerName string) string {
+	name := strings.TrimSuffix(handlerName, "-fm")
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// 在单词边界插入下划线（连续大写视为一个单词，如 APIKey -> api_key）
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
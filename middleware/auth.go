@@ -3,7 +3,10 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"time"
 
+	"auth-center/config"
+	"auth-center/models"
 	"auth-center/service"
 	"auth-center/utils"
 	"github.com/gin-gonic/gin"
@@ -20,9 +23,9 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// 检查Bearer前缀
+		// 检查Bearer（或DPoP）前缀
 		tokenParts := strings.Split(authHeader, " ")
-		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+		if len(tokenParts) != 2 || (tokenParts[0] != "Bearer" && tokenParts[0] != "DPoP") {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
 			c.Abort()
 			return
@@ -38,6 +41,18 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 证书绑定令牌（cnf.x5t#S256）只能在出示相同客户端证书的连接上使用
+		if !service.ConfirmationMatches(claims.Cnf, GetClientCert(c)) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token is bound to a different client certificate"})
+			c.Abort()
+			return
+		}
+
+		// DPoP 绑定令牌（cnf.jkt）须携带相同公钥签名的 DPoP 证明
+		if !checkDPoPBinding(c, tokenParts[0], token, claims) {
+			return
+		}
+
 		// 检查令牌是否在黑名单中
 		blacklistKey := utils.TokenBlacklistPrefix + claims.JTI
 		exists, err := utils.Exists(blacklistKey)
@@ -57,6 +72,8 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Set("app_id", claims.AppID)
 		c.Set("roles", claims.Roles)
 		c.Set("jti", claims.JTI)
+		c.Set("org_id", claims.OrgID)
+		c.Set("cnf", claims.Cnf)
 
 		c.Next()
 	}
@@ -65,22 +82,40 @@ func AuthMiddleware() gin.HandlerFunc {
 // AppAuthMiddleware 应用认证中间件
 func AppAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 从请求头获取应用ID和密钥
-		appID := c.GetHeader("X-App-Id")
-		appSecret := c.GetHeader("X-App-Secret")
-
-		if appID == "" || appSecret == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "App ID and Secret are required"})
-			c.Abort()
-			return
-		}
-
-		// 验证应用凭据
-		app, err := service.ValidateAppCredentials(appID, appSecret)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid app credentials"})
-			c.Abort()
-			return
+		var app *models.Application
+		if signedAppID, signed := GetSignedAppID(c); signed {
+			// 已通过请求签名校验（SignatureMiddleware）
+			app = &models.Application{}
+			if err := config.DB.Where("app_id = ?", signedAppID).First(app).Error; err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid app credentials"})
+				c.Abort()
+				return
+			}
+		} else {
+			// 从请求头获取应用ID和密钥
+			appID := c.GetHeader("X-App-Id")
+			appSecret := c.GetHeader("X-App-Secret")
+			clientCert := GetClientCert(c)
+
+			if appID == "" || (appSecret == "" && clientCert == nil) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "App ID and Secret are required"})
+				c.Abort()
+				return
+			}
+
+			// 验证应用凭据（登记的客户端证书或应用密钥）
+			var err error
+			if service.MatchAppCertificate(appID, clientCert) {
+				app = &models.Application{}
+				err = config.DB.Where("app_id = ?", appID).First(app).Error
+			} else {
+				app, err = service.ValidateAppCredentials(appID, appSecret)
+			}
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid app credentials"})
+				c.Abort()
+				return
+			}
 		}
 
 		// 检查应用状态
@@ -117,7 +152,7 @@ func PermissionMiddleware(permission string) gin.HandlerFunc {
 		}
 
 		// 检查用户权限
-		hasPermission, err := service.CheckUserPermission(userID.(uint), appID.(string), permission)
+		hasPermission, err := service.CheckUserPermission(userID.(uint), appID.(string), c.GetUint("org_id"), permission)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Permission check failed"})
 			c.Abort()
@@ -156,8 +191,9 @@ func APIPermissionMiddleware() gin.HandlerFunc {
 		apiPath := c.FullPath()
 		apiMethod := c.Request.Method
 
-		// 检查用户是否有权限访问该API
-		hasPermission, err := service.CheckAPIPermission(userID.(uint), appID.(string), apiPath, apiMethod)
+		// 检查用户是否有权限访问该API，附带条件的权限按本次请求的来源IP和时间求值
+		pctx := &service.PermissionContext{IP: c.ClientIP(), Time: time.Now(), Path: apiPath, Method: apiMethod}
+		hasPermission, err := service.CheckAPIPermissionWithContext(userID.(uint), appID.(string), c.GetUint("org_id"), apiPath, apiMethod, pctx)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "API permission check failed"})
 			c.Abort()
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"auth-center/config"
+	"auth-center/service"
+	"auth-center/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// dpopAlgs WWW-Authenticate 中声明支持的 DPoP 签名算法
+const dpopAlgs = "ES256 ES384 RS256 PS256 EdDSA"
+
+// RequestHTU 当前请求的 htu（协议 + 主机 + 路径，不含查询参数）
+// 只有直接来自可信反向代理（[dpop] trusted_proxies）的请求才读取 X-Forwarded-Proto、X-Forwarded-Host，
+// 否则客户端可以伪造这两个头，使为其他地址签发的证明通过校验
+func RequestHTU(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	host := c.Request.Host
+
+	if fromTrustedProxy(c) {
+		if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+			scheme = strings.TrimSpace(strings.Split(proto, ",")[0])
+		}
+		if forwarded := c.GetHeader("X-Forwarded-Host"); forwarded != "" {
+			host = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+
+	return scheme + "://" + host + c.Request.URL.EscapedPath()
+}
+
+// fromTrustedProxy 请求的直接来源是否为可信反向代理
+func fromTrustedProxy(c *gin.Context) bool {
+	networks, err := config.GetConfig().DPoP.TrustedProxyNetworks()
+	if err != nil || len(networks) == 0 {
+		return false
+	}
+	remote, _, err := net.SplitHostPort(strings.TrimSpace(c.Request.RemoteAddr))
+	if err != nil {
+		remote = strings.TrimSpace(c.Request.RemoteAddr)
+	}
+	ip := net.ParseIP(remote)
+	if ip == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyDPoPHeader 校验请求 DPoP 头中的证明，未携带时返回 nil, nil
+// accessToken 为空表示令牌端点（不校验 ath）
+func VerifyDPoPHeader(c *gin.Context, accessToken string) (*utils.DPoPProof, error) {
+	proof := c.GetHeader(utils.DPoPHeader)
+	if proof == "" {
+		return nil, nil
+	}
+	return service.VerifyDPoPProof(proof, c.Request.Method, RequestHTU(c), accessToken)
+}
+
+// SetDPoPNonce 启用 nonce 时在响应头中下发新的 DPoP nonce
+func SetDPoPNonce(c *gin.Context) {
+	if config.GetConfig().DPoP.RequireNonce {
+		c.Header(utils.DPoPNonceHeader, service.NewDPoPNonce())
+	}
+}
+
+// WriteDPoPError 写入 DPoP 错误响应
+// 令牌端点返回 400；资源访问返回 401 并携带 WWW-Authenticate；需要 nonce 时同时下发 DPoP-Nonce
+func WriteDPoPError(c *gin.Context, err error, resource bool) {
+	code := service.DPoPErrorInvalidProof
+	var dpopErr *service.DPoPError
+	if errors.As(err, &dpopErr) {
+		code = dpopErr.Code
+	}
+
+	if code == service.DPoPErrorUseNonce {
+		c.Header(utils.DPoPNonceHeader, service.NewDPoPNonce())
+	}
+
+	status := http.StatusBadRequest
+	if resource {
+		status = http.StatusUnauthorized
+		c.Header("WWW-Authenticate", `DPoP algs="`+dpopAlgs+`", error="`+code+`"`)
+	}
+	c.JSON(status, gin.H{"error": code, "error_description": err.Error()})
+	c.Abort()
+}
+
+// checkDPoPBinding 校验访问令牌的 DPoP 绑定：绑定令牌须使用 DPoP 方案并携带与 cnf.jkt 一致的有效证明
+func checkDPoPBinding(c *gin.Context, scheme, token string, claims *utils.JWTClaims) bool {
+	bound := claims.Cnf != nil && claims.Cnf.Jkt != ""
+	if !bound {
+		if scheme == "DPoP" {
+			WriteDPoPError(c, &service.DPoPError{Code: service.DPoPErrorInvalidToken, Message: "令牌未绑定 DPoP 公钥"}, true)
+			return false
+		}
+		return true
+	}
+
+	if scheme != "DPoP" {
+		WriteDPoPError(c, &service.DPoPError{Code: service.DPoPErrorInvalidToken, Message: "DPoP 绑定令牌须使用 DPoP 授权方案"}, true)
+		return false
+	}
+
+	proof, err := VerifyDPoPHeader(c, token)
+	if err == nil && proof == nil {
+		err = &service.DPoPError{Code: service.DPoPErrorInvalidProof, Message: "缺少 DPoP 证明"}
+	}
+	if err == nil && proof.Thumbprint != claims.Cnf.Jkt {
+		err = &service.DPoPError{Code: service.DPoPErrorInvalidProof, Message: "DPoP 证明公钥与令牌不匹配"}
+	}
+	if err != nil {
+		WriteDPoPError(c, err, true)
+		return false
+	}
+
+	SetDPoPNonce(c)
+	return true
+}
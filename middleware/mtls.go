@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"auth-center/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetClientCert 获取当前 TLS 连接中客户端出示的证书，未出示时返回 nil
+func GetClientCert(c *gin.Context) *utils.ClientCertInfo {
+	if cert, exists := c.Get("client_cert"); exists {
+		return cert.(*utils.ClientCertInfo)
+	}
+
+	cert := utils.ClientCertFromRequest(c.Request)
+	c.Set("client_cert", cert)
+	return cert
+}
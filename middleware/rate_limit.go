@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"auth-center/config"
+	"auth-center/service"
+	"auth-center/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimitCheckedKey 上下文中记录本次请求已检查过的限流维度，键为 路由组:维度
+const rateLimitCheckedKey = "rate_limit_checked"
+
+// rateLimitTightestKey 上下文中记录本次请求剩余次数最少的限流结果，用于输出响应头
+const rateLimitTightestKey = "rate_limit_tightest"
+
+// rateLimitGroupsKey 上下文中记录本次请求经过的限流路由组，供 AllowVerifiedApp 使用
+const rateLimitGroupsKey = "rate_limit_groups"
+
+// RateLimitMiddleware 限流中间件
+// 对指定路由组按 IP、应用（AppID）、用户三个维度分别做滑动窗口限流，任一维度超限即返回 429
+// 应用和用户维度只使用已验证的身份（请求签名或访问令牌），不读取客户端自报的 app_id，
+// 避免伪造的 app_id 消耗其他应用的配额或触发配额查询；因此需在签名校验、令牌认证之后再次挂载，
+// 同一请求的每个维度只计数一次。未签名的登录、注册请求在应用密钥校验通过后由 AllowVerifiedApp 补充应用维度。
+// 应用可通过应用管理接口覆盖默认配额；Redis 异常时放行，避免限流组件影响认证主流程
+func RateLimitMiddleware(group string) gin.HandlerFunc {
+	rateLimitService := &service.RateLimitService{}
+
+	return func(c *gin.Context) {
+		if !config.GetConfig().RateLimit.Enabled {
+			c.Next()
+			return
+		}
+
+		appID := rateLimitAppID(c)
+		subjects := map[string]string{
+			service.RateLimitDimensionIP: c.ClientIP(),
+		}
+		if appID != "" {
+			subjects[service.RateLimitDimensionApp] = appID
+		}
+		if userID, exists := GetUserID(c); exists && appID != "" {
+			subjects[service.RateLimitDimensionUser] = fmt.Sprintf("%s:%d", appID, userID)
+		}
+
+		groups, _ := c.Get(rateLimitGroupsKey)
+		if groupNames, _ := groups.([]string); !slices.Contains(groupNames, group) {
+			c.Set(rateLimitGroupsKey, append(groupNames, group))
+		}
+
+		if !checkRateLimit(c, rateLimitService, group, appID, subjects) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// AllowVerifiedApp 按应用维度限流已由业务层验证凭证（如 app_id/app_secret）的应用
+// 未签名的请求在中间件中只能按 IP 限流，需在应用密钥校验通过后调用；超限时写入 429 响应并返回 false
+func AllowVerifiedApp(c *gin.Context, appID string) bool {
+	if !config.GetConfig().RateLimit.Enabled || appID == "" {
+		return true
+	}
+	groups, _ := c.Get(rateLimitGroupsKey)
+	groupNames, _ := groups.([]string)
+	rateLimitService := &service.RateLimitService{}
+	for _, group := range groupNames {
+		if !checkRateLimit(c, rateLimitService, group, appID, map[string]string{service.RateLimitDimensionApp: appID}) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkRateLimit 按维度检查限流，同一请求的每个维度只计数一次；超限时写入 429 响应并返回 false
+func checkRateLimit(c *gin.Context, rateLimitService *service.RateLimitService, group, appID string, subjects map[string]string) bool {
+	checked, _ := c.Get(rateLimitCheckedKey)
+	checkedDimensions, _ := checked.(map[string]bool)
+	if checkedDimensions == nil {
+		checkedDimensions = make(map[string]bool)
+		c.Set(rateLimitCheckedKey, checkedDimensions)
+	}
+
+	// 记录剩余次数最少的维度，用于输出响应头
+	var tightest *utils.RateLimitResult
+	if previous, exists := c.Get(rateLimitTightestKey); exists {
+		tightest = previous.(*utils.RateLimitResult)
+	}
+	for _, dimension := range []string{service.RateLimitDimensionIP, service.RateLimitDimensionApp, service.RateLimitDimensionUser} {
+		subject, ok := subjects[dimension]
+		if !ok || checkedDimensions[group+":"+dimension] {
+			continue
+		}
+		checkedDimensions[group+":"+dimension] = true
+
+		rule := rateLimitService.ResolveRule(appID, group, dimension)
+		if rule == nil {
+			continue
+		}
+
+		key := utils.RateLimitPrefix + group + ":" + dimension + ":" + subject
+		result, err := utils.SlidingWindowAllow(key, rule.Limit, rule.Window)
+		if err != nil {
+			log.Printf("限流检查失败，已放行: %v", err)
+			continue
+		}
+
+		if !result.Allowed {
+			setRateLimitHeaders(c, result)
+			c.Header("Retry-After", strconv.FormatInt(ceilSeconds(result.ResetIn), 10))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "请求过于频繁，请稍后再试"})
+			c.Abort()
+			return false
+		}
+
+		if tightest == nil || result.Remaining < tightest.Remaining {
+			tightest = result
+		}
+	}
+
+	if tightest != nil {
+		c.Set(rateLimitTightestKey, tightest)
+		setRateLimitHeaders(c, tightest)
+	}
+	return true
+}
+
+// rateLimitAppID 获取限流使用的应用ID：已通过请求签名校验的应用，或访问令牌、应用认证中间件写入上下文的应用
+func rateLimitAppID(c *gin.Context) string {
+	if appID, signed := GetSignedAppID(c); signed && appID != "" {
+		return appID
+	}
+	if appID, exists := GetAppID(c); exists {
+		return appID
+	}
+	return ""
+}
+
+// setRateLimitHeaders 设置限流响应头
+func setRateLimitHeaders(c *gin.Context, result *utils.RateLimitResult) {
+	c.Header("X-RateLimit-Limit", strconv.FormatInt(result.Limit, 10))
+	c.Header("X-RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(result.ResetIn).Unix(), 10))
+}
+
+// ceilSeconds 向上取整为秒，至少为1秒
+func ceilSeconds(d time.Duration) int64 {
+	seconds := int64(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
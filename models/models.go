@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
@@ -11,7 +12,7 @@ type Application struct {
 	ID          uint           `json:"id" gorm:"primaryKey"`
 	Name        string         `json:"name" gorm:"type:varchar(191);not null;uniqueIndex:uk_app_name_deleted"`
 	AppID       string         `json:"app_id" gorm:"type:varchar(191);uniqueIndex;not null"`
-	AppSecret   string         `json:"app_secret" gorm:"not null"`
+	AppSecret   string         `json:"-" gorm:"not null;default:''"` // 已废弃：密钥仅以哈希形式存储在 app_secrets 表
 	Description string         `json:"description"`
 	Status      int            `json:"status" gorm:"default:1"` // 1:启用 0:禁用
 	CreatedAt   time.Time      `json:"created_at"`
@@ -21,30 +22,33 @@ type Application struct {
 
 // User 用户模型
 type User struct {
-	ID           uint           `json:"id" gorm:"primaryKey"`
-	AppID        string         `json:"app_id" gorm:"type:varchar(191);index;not null;uniqueIndex:uk_user_app_username_deleted,priority:1;uniqueIndex:uk_user_app_email_deleted,priority:1"`
-	Username     string         `json:"username" gorm:"type:varchar(191);not null;uniqueIndex:uk_user_app_username_deleted,priority:2"`
-	Email        string         `json:"email" gorm:"type:varchar(191);uniqueIndex:uk_user_app_email_deleted,priority:2"`
-	Phone        string         `json:"phone" gorm:"type:varchar(20);index"`
-	Password     string         `json:"-" gorm:"not null"`                   // 不返回给前端
-	IsSuperAdmin bool           `json:"is_super_admin" gorm:"default:false"` // 是否为超级管理员
-	Status       int            `json:"status" gorm:"default:1"`             // 1:启用 0:禁用
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"deleted_at" gorm:"index;uniqueIndex:uk_user_app_username_deleted,priority:3;uniqueIndex:uk_user_app_email_deleted,priority:3"`
+	ID                  uint           `json:"id" gorm:"primaryKey"`
+	AppID               string         `json:"app_id" gorm:"type:varchar(191);index;not null;uniqueIndex:uk_user_app_username_deleted,priority:1;uniqueIndex:uk_user_app_email_deleted,priority:1"`
+	Username            string         `json:"username" gorm:"type:varchar(191);not null;uniqueIndex:uk_user_app_username_deleted,priority:2"`
+	Email               string         `json:"email" gorm:"type:varchar(191);uniqueIndex:uk_user_app_email_deleted,priority:2"`
+	Phone               string         `json:"phone" gorm:"type:varchar(20);index"`
+	Password            string         `json:"-" gorm:"not null"`                         // 不返回给前端
+	IsSuperAdmin        bool           `json:"is_super_admin" gorm:"default:false"`       // 是否为超级管理员
+	Status              int            `json:"status" gorm:"default:1"`                   // 1:启用 0:禁用
+	PasswordChangedAt   *time.Time     `json:"password_changed_at"`                       // 最近一次修改密码的时间
+	PasswordCompromised bool           `json:"password_compromised" gorm:"default:false"` // 当前密码是否出现在泄露密码库中
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt `json:"deleted_at" gorm:"index;uniqueIndex:uk_user_app_username_deleted,priority:3;uniqueIndex:uk_user_app_email_deleted,priority:3"`
 }
 
 // Role 角色模型
 type Role struct {
-	ID          uint           `json:"id" gorm:"primaryKey"`
-	AppID       string         `json:"app_id" gorm:"type:varchar(191);index;not null;uniqueIndex:uk_role_app_code_deleted,priority:1"`
-	Name        string         `json:"name" gorm:"type:varchar(191);not null"`
-	Code        string         `json:"code" gorm:"type:varchar(191);not null;uniqueIndex:uk_role_app_code_deleted,priority:2"`
-	Description string         `json:"description"`
-	Status      int            `json:"status" gorm:"default:1"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index;uniqueIndex:uk_role_app_code_deleted,priority:3"`
+	ID                uint           `json:"id" gorm:"primaryKey"`
+	AppID             string         `json:"app_id" gorm:"type:varchar(191);index;not null;uniqueIndex:uk_role_app_code_deleted,priority:1"`
+	Name              string         `json:"name" gorm:"type:varchar(191);not null"`
+	Code              string         `json:"code" gorm:"type:varchar(191);not null;uniqueIndex:uk_role_app_code_deleted,priority:2"`
+	Description       string         `json:"description"`
+	Status            int            `json:"status" gorm:"default:1"`
+	AccessMaxDuration int64          `json:"access_max_duration" gorm:"not null;default:0"` // 临时提权单次申请的最长时长（秒），0 表示不可申请
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `json:"deleted_at" gorm:"index;uniqueIndex:uk_role_app_code_deleted,priority:3"`
 }
 
 // Permission 权限模型
@@ -77,18 +81,172 @@ type API struct {
 
 // UserRole 用户角色关联表
 type UserRole struct {
-	ID     uint   `json:"id" gorm:"primaryKey"`
-	UserID uint   `json:"user_id" gorm:"index"`
-	RoleID uint   `json:"role_id" gorm:"index"`
-	AppID  string `json:"app_id" gorm:"index"`
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"index"`
+	RoleID     uint       `json:"role_id" gorm:"index"`
+	AppID      string     `json:"app_id" gorm:"index"`
+	ValidFrom  *time.Time `json:"valid_from" gorm:"index"`  // 生效时间，为空表示立即生效
+	ValidUntil *time.Time `json:"valid_until" gorm:"index"` // 失效时间，为空表示长期有效；到期后由定时任务删除
 }
 
 // RolePermission 角色权限关联表
 type RolePermission struct {
-	ID           uint   `json:"id" gorm:"primaryKey"`
-	RoleID       uint   `json:"role_id" gorm:"index"`
-	PermissionID uint   `json:"permission_id" gorm:"index"`
-	AppID        string `json:"app_id" gorm:"index"`
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	RoleID       uint       `json:"role_id" gorm:"index"`
+	PermissionID uint       `json:"permission_id" gorm:"index"`
+	AppID        string     `json:"app_id" gorm:"index"`
+	Effect       string     `json:"effect" gorm:"type:varchar(16);not null;default:allow"`                         // allow：授予，deny：明确拒绝（优先于任何授予）
+	Condition    string     `json:"condition" gorm:"column:condition_expr;type:varchar(2048);not null;default:''"` // 生效条件（CEL 表达式），为空表示无条件
+	ValidFrom    *time.Time `json:"valid_from" gorm:"index"`                                                       // 生效时间，为空表示立即生效
+	ValidUntil   *time.Time `json:"valid_until" gorm:"index"`                                                      // 失效时间，为空表示长期有效；到期后由定时任务删除
+	DataScope    string     `json:"data_scope" gorm:"type:varchar(4096);not null;default:''"`                      // 授予的数据范围（JSON），为空表示全部数据
+}
+
+// UserPermission 直接分配给用户的权限（授予或明确拒绝）
+type UserPermission struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	UserID       uint      `json:"user_id" gorm:"index"`
+	PermissionID uint      `json:"permission_id" gorm:"index"`
+	AppID        string    `json:"app_id" gorm:"type:varchar(191);index"`
+	Effect       string    `json:"effect" gorm:"type:varchar(16);not null;default:allow"`
+	DataScope    string    `json:"data_scope" gorm:"type:varchar(4096);not null;default:''"` // 授予的数据范围（JSON），为空表示全部数据
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// RelationSchema 应用的关系模式（命名空间与关系定义），Revision 为应用关系数据的当前版本，每次写入元组或修改模式时递增
+type RelationSchema struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	AppID      string    `json:"app_id" gorm:"type:varchar(191);uniqueIndex;not null"`
+	Definition string    `json:"definition" gorm:"type:text"` // JSON 格式的模式定义
+	Revision   uint64    `json:"revision" gorm:"not null;default:0"`
+	GCRevision uint64    `json:"gc_revision" gorm:"not null;default:0"` // 已清理的删除版本，早于该版本的快照不再完整
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// RelationTuple 关系元组 namespace:object_id#relation@subject_namespace:subject_id[#subject_relation]
+// 元组按版本保留：CreatedRevision 为写入时的版本，DeletedRevision 为删除时的版本（0 表示未删除），以支持按快照读取；
+// 已删除的元组超过保留时间后由定时任务清理
+type RelationTuple struct {
+	ID               uint       `json:"id" gorm:"primaryKey"`
+	AppID            string     `json:"app_id" gorm:"type:varchar(191);not null;index:idx_relation_tuple_object,priority:1;index:idx_relation_tuple_subject,priority:1"`
+	Namespace        string     `json:"namespace" gorm:"type:varchar(64);not null;index:idx_relation_tuple_object,priority:2"`
+	ObjectID         string     `json:"object_id" gorm:"type:varchar(191);not null;index:idx_relation_tuple_object,priority:3"`
+	Relation         string     `json:"relation" gorm:"type:varchar(64);not null;index:idx_relation_tuple_object,priority:4"`
+	SubjectNamespace string     `json:"subject_namespace" gorm:"type:varchar(64);not null;index:idx_relation_tuple_subject,priority:2"`
+	SubjectID        string     `json:"subject_id" gorm:"type:varchar(191);not null;index:idx_relation_tuple_subject,priority:3"`
+	SubjectRelation  string     `json:"subject_relation" gorm:"type:varchar(64);not null;default:''"`
+	CreatedRevision  uint64     `json:"created_revision" gorm:"not null;index"`
+	DeletedRevision  uint64     `json:"deleted_revision" gorm:"not null;default:0;index"`
+	RemovedAt        *time.Time `json:"removed_at" gorm:"index"` // 删除时间，用于清理已删除的元组
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// RoleApprover 角色的临时提权审批人（应用内的用户）
+type RoleApprover struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	AppID     string    `json:"app_id" gorm:"type:varchar(191);not null;index"`
+	RoleID    uint      `json:"role_id" gorm:"not null;uniqueIndex:uk_role_approver,priority:1"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:uk_role_approver,priority:2;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AccessRequest 临时提权申请：用户申请在限定时间内获得角色，审批通过后以有有效期的角色分配授予
+type AccessRequest struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	AppID         string     `json:"app_id" gorm:"type:varchar(191);not null;index"`
+	UserID        uint       `json:"user_id" gorm:"not null;index"`
+	RoleID        uint       `json:"role_id" gorm:"not null;index"`
+	Justification string     `json:"justification" gorm:"type:varchar(1024);not null"`
+	Duration      int64      `json:"duration" gorm:"not null"`                      // 申请的时长（秒）
+	Status        string     `json:"status" gorm:"type:varchar(16);not null;index"` // pending: 待审批, approved: 已批准, denied: 已拒绝, expired: 授权已到期, cancelled: 已撤回
+	ReviewerID    uint       `json:"reviewer_id"`
+	ReviewComment string     `json:"review_comment" gorm:"type:varchar(1024)"`
+	ReviewedAt    *time.Time `json:"reviewed_at"`
+	GrantedUntil  *time.Time `json:"granted_until" gorm:"index"` // 批准后角色分配的失效时间
+	UserRoleID    uint       `json:"user_role_id"`               // 批准后创建的角色分配
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// RoleInheritance 角色继承关系：角色继承父角色的全部权限（可多级、多个父角色，不允许成环）
+type RoleInheritance struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	AppID        string    `json:"app_id" gorm:"type:varchar(191);index;not null"`
+	RoleID       uint      `json:"role_id" gorm:"not null;uniqueIndex:uk_role_inheritance,priority:1"`
+	ParentRoleID uint      `json:"parent_role_id" gorm:"not null;index;uniqueIndex:uk_role_inheritance,priority:2"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Group 用户分组：分组的成员（用户和下级分组的成员）获得分配给分组的角色
+type Group struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	AppID       string         `json:"app_id" gorm:"type:varchar(191);index;not null;uniqueIndex:uk_group_app_code_deleted,priority:1"`
+	Name        string         `json:"name" gorm:"type:varchar(191);not null"`
+	Code        string         `json:"code" gorm:"type:varchar(191);not null;uniqueIndex:uk_group_app_code_deleted,priority:2"`
+	Description string         `json:"description"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index;uniqueIndex:uk_group_app_code_deleted,priority:3"`
+}
+
+// GroupMember 分组的用户成员
+type GroupMember struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	AppID     string    `json:"app_id" gorm:"type:varchar(191);index;not null"`
+	GroupID   uint      `json:"group_id" gorm:"not null;uniqueIndex:uk_group_member,priority:1"`
+	UserID    uint      `json:"user_id" gorm:"not null;index;uniqueIndex:uk_group_member,priority:2"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GroupInheritance 分组嵌套关系：下级分组的成员同时是上级分组的成员（可多级、多个上级分组，不允许成环）
+type GroupInheritance struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	AppID         string    `json:"app_id" gorm:"type:varchar(191);index;not null"`
+	GroupID       uint      `json:"group_id" gorm:"not null;uniqueIndex:uk_group_inheritance,priority:1"`
+	ParentGroupID uint      `json:"parent_group_id" gorm:"not null;index;uniqueIndex:uk_group_inheritance,priority:2"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// GroupRole 分配给分组的角色
+type GroupRole struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	AppID     string    `json:"app_id" gorm:"type:varchar(191);index;not null"`
+	GroupID   uint      `json:"group_id" gorm:"not null;uniqueIndex:uk_group_role,priority:1"`
+	RoleID    uint      `json:"role_id" gorm:"not null;index;uniqueIndex:uk_group_role,priority:2"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Organization 组织：应用内的子租户（如 B2B 客户），用户可以属于多个组织，在每个组织中拥有不同的角色
+type Organization struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	AppID       string         `json:"app_id" gorm:"type:varchar(191);index;not null;uniqueIndex:uk_org_app_code_deleted,priority:1"`
+	Name        string         `json:"name" gorm:"type:varchar(191);not null"`
+	Code        string         `json:"code" gorm:"type:varchar(191);not null;uniqueIndex:uk_org_app_code_deleted,priority:2"`
+	Description string         `json:"description"`
+	Status      int            `json:"status" gorm:"default:1"` // 1: 启用，0: 停用（不能进入该组织，组织内的角色不生效）
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index;uniqueIndex:uk_org_app_code_deleted,priority:3"`
+}
+
+// OrganizationMember 组织成员
+type OrganizationMember struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	AppID     string    `json:"app_id" gorm:"type:varchar(191);index;not null"`
+	OrgID     uint      `json:"org_id" gorm:"not null;uniqueIndex:uk_org_member,priority:1"`
+	UserID    uint      `json:"user_id" gorm:"not null;index;uniqueIndex:uk_org_member,priority:2"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OrganizationMemberRole 组织成员在组织内的角色，仅在令牌的当前组织（org_id）为该组织时生效
+type OrganizationMemberRole struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	AppID     string    `json:"app_id" gorm:"type:varchar(191);index;not null"`
+	OrgID     uint      `json:"org_id" gorm:"not null;uniqueIndex:uk_org_member_role,priority:1"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:uk_org_member_role,priority:2"`
+	RoleID    uint      `json:"role_id" gorm:"not null;index;uniqueIndex:uk_org_member_role,priority:3"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // Token 令牌模型（用于令牌管理）
@@ -114,18 +272,193 @@ type Provider struct {
 
 // SystemAdmin 系统管理员模型
 type SystemAdmin struct {
-	ID          uint           `json:"id" gorm:"primaryKey"`
-	Username    string         `json:"username" gorm:"type:varchar(191);uniqueIndex;not null"`
-	Email       string         `json:"email" gorm:"type:varchar(191);uniqueIndex"`
-	Phone       string         `json:"phone" gorm:"type:varchar(20);index"`
-	Password    string         `json:"-" gorm:"not null"`                           // 不返回给前端
-	AdminType   string         `json:"admin_type" gorm:"type:varchar(50);not null"` // system: 系统级管理员, app: 应用级管理员
-	AppID       string         `json:"app_id" gorm:"type:varchar(191);index"`       // 应用级管理员关联的应用ID
-	IsActive    bool           `json:"is_active" gorm:"default:true"`               // 是否激活
-	LastLoginAt *time.Time     `json:"last_login_at"`                               // 最后登录时间
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+	ID                uint           `json:"id" gorm:"primaryKey"`
+	Username          string         `json:"username" gorm:"type:varchar(191);uniqueIndex;not null"`
+	Email             string         `json:"email" gorm:"type:varchar(191);uniqueIndex"`
+	Phone             string         `json:"phone" gorm:"type:varchar(20);index"`
+	Password          string         `json:"-" gorm:"not null"`                           // 不返回给前端
+	AdminType         string         `json:"admin_type" gorm:"type:varchar(50);not null"` // system: 系统级管理员, app: 应用级管理员
+	AppID             string         `json:"app_id" gorm:"type:varchar(191);index"`       // 应用级管理员关联的应用ID
+	IsActive          bool           `json:"is_active" gorm:"default:true"`               // 是否激活
+	LastLoginAt       *time.Time     `json:"last_login_at"`                               // 最后登录时间
+	PasswordChangedAt *time.Time     `json:"password_changed_at"`                         // 最近一次修改密码的时间
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+}
+
+// RateLimitQuota 应用级限流配额（覆盖配置文件中的路由组默认值）
+type RateLimitQuota struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	AppID     string    `json:"app_id" gorm:"type:varchar(191);not null;uniqueIndex:uk_quota_app_group_dimension,priority:1"`
+	Group     string    `json:"group" gorm:"column:route_group;type:varchar(64);not null;uniqueIndex:uk_quota_app_group_dimension,priority:2"` // 路由组：auth, permission 等
+	Dimension string    `json:"dimension" gorm:"type:varchar(16);not null;uniqueIndex:uk_quota_app_group_dimension,priority:3"`                // 限流维度：ip, app, user
+	Limit     int64     `json:"limit" gorm:"column:quota_limit;not null"`                                                                      // 窗口内允许的请求数，0 表示不限制
+	Window    int64     `json:"window" gorm:"not null"`                                                                                        // 窗口长度（秒）
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PasswordPolicy 应用密码策略
+type PasswordPolicy struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	AppID          string    `json:"app_id" gorm:"type:varchar(191);uniqueIndex;not null"` // 空字符串为系统级管理员的策略
+	MinLength      int       `json:"min_length" gorm:"not null;default:6"`
+	MaxLength      int       `json:"max_length" gorm:"not null;default:128"`
+	RequireUpper   bool      `json:"require_upper"`    // 必须包含大写字母
+	RequireLower   bool      `json:"require_lower"`    // 必须包含小写字母
+	RequireDigit   bool      `json:"require_digit"`    // 必须包含数字
+	RequireSymbol  bool      `json:"require_symbol"`   // 必须包含特殊字符
+	MinCharClasses int       `json:"min_char_classes"` // 至少包含的字符类别数（大写、小写、数字、特殊字符）
+	ForbidUsername bool      `json:"forbid_username"`  // 禁止密码中包含用户名
+	MaxAgeDays     int       `json:"max_age_days"`     // 密码最长使用天数，0 表示不过期
+	HistoryCount   int       `json:"history_count"`    // 禁止重复使用最近 N 个密码，0 表示不限制
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// PasswordHistory 密码历史（用于防止重复使用旧密码）
+type PasswordHistory struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	AccountType  string    `json:"account_type" gorm:"type:varchar(32);not null;index:idx_pwd_history_account,priority:1"` // user, system_admin
+	AccountID    uint      `json:"account_id" gorm:"not null;index:idx_pwd_history_account,priority:2"`
+	AppID        string    `json:"app_id" gorm:"type:varchar(191);index"`
+	PasswordHash string    `json:"-" gorm:"not null"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AppSecret 应用密钥（仅存储 SHA-256 哈希，明文只在创建时返回一次）
+// 轮换期间一个应用最多同时存在两个有效密钥，旧密钥在宽限期结束后失效
+// 请求签名密钥由明文派生，使用服务端密钥加密后保存，早于此功能创建的密钥没有签名密钥，需要轮换后才能签名
+type AppSecret struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	AppID         string     `json:"app_id" gorm:"type:varchar(191);index;not null"`
+	SecretHash    string     `json:"-" gorm:"type:char(64);uniqueIndex;not null"`
+	SigningKeyEnc string     `json:"-" gorm:"type:varchar(255);not null;default:''"` // 加密的请求签名密钥
+	Prefix        string     `json:"prefix" gorm:"type:varchar(16);not null"`        // 明文前缀，用于识别密钥
+	ExpiresAt     *time.Time `json:"expires_at"`                                     // 为空表示永不过期
+	LastUsedAt    *time.Time `json:"last_used_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// AppCertificate 应用可信客户端证书（mTLS 认证）
+// 按证书指纹匹配；配置了客户端 CA 时也可按证书主题匹配（仅对通过证书链校验的证书生效）
+type AppCertificate struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	AppID      string     `json:"app_id" gorm:"type:varchar(191);index;not null"`
+	Name       string     `json:"name" gorm:"type:varchar(191)"`
+	Thumbprint string     `json:"thumbprint" gorm:"type:varchar(64);index"` // SHA-256 指纹（base64url），即 x5t#S256
+	Subject    string     `json:"subject" gorm:"type:varchar(512)"`         // 证书主题（RFC 2253 格式）
+	NotAfter   *time.Time `json:"not_after"`                                // 证书到期时间，到期后不再接受
+	Status     int        `json:"status" gorm:"default:1"`                  // 1:启用 0:禁用
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// AuditLog 审计日志（只追加，不可修改或删除）
+type AuditLog struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	Seq        uint64    `json:"seq" gorm:"index"`                         // 连续递增的序号，用于检测缺失或重复的记录
+	PrevHash   string    `json:"prev_hash" gorm:"type:char(64)"`           // 上一条记录的哈希，第一条为 64 个 0
+	Hash       string    `json:"hash" gorm:"type:char(64)"`                // 本条记录（含 PrevHash）的 SHA-256
+	ActorType  string    `json:"actor_type" gorm:"type:varchar(32);index"` // system_admin: 系统管理员, user: 应用用户, app: 应用, anonymous: 匿名
+	ActorID    uint      `json:"actor_id" gorm:"index"`
+	ActorName  string    `json:"actor_name" gorm:"type:varchar(191)"`
+	AppID      string    `json:"app_id" gorm:"type:varchar(191);index"` // 操作所属应用，用于应用级管理员按应用查询
+	Action     string    `json:"action" gorm:"type:varchar(191);index"`
+	TargetType string    `json:"target_type" gorm:"type:varchar(64);index"`
+	TargetID   string    `json:"target_id" gorm:"type:varchar(191);index"`
+	Before     string    `json:"before" gorm:"type:text"` // 变更前快照（JSON）
+	After      string    `json:"after" gorm:"type:text"`  // 变更后快照（JSON）
+	Diff       string    `json:"diff" gorm:"type:text"`   // 字段级差异（JSON）：{"字段": {"before": 旧值, "after": 新值}}
+	Method     string    `json:"method" gorm:"type:varchar(16)"`
+	Path       string    `json:"path" gorm:"type:varchar(512)"`
+	StatusCode int       `json:"status_code"`
+	Result     string    `json:"result" gorm:"type:varchar(16);index"` // success / failure
+	Error      string    `json:"error" gorm:"type:varchar(1024)"`
+	IP         string    `json:"ip" gorm:"type:varchar(64)"`
+	UserAgent  string    `json:"user_agent" gorm:"type:varchar(512)"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+// BeforeUpdate 审计日志不允许修改
+func (AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// BeforeDelete 审计日志不允许删除
+func (AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// AuditChainHead 审计日志哈希链的链尾（只有一行）。追加审计日志时在事务中锁定该行分配序号，
+// 多个副本同时写入时依次接在链尾，不会分配相同的序号而使链分叉
+type AuditChainHead struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Seq       uint64    `json:"seq" gorm:"not null"`                // 最后一条审计日志的序号，没有记录时为 0
+	Hash      string    `json:"hash" gorm:"type:char(64);not null"` // 最后一条审计日志的哈希，没有记录时为初始值
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AuditCheckpoint 审计日志检查点：使用服务端 Ed25519 私钥对某一序号的链哈希签名
+type AuditCheckpoint struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Seq       uint64    `json:"seq" gorm:"index"`                     // 检查点覆盖的最后一条审计日志序号
+	Hash      string    `json:"hash" gorm:"type:char(64)"`            // 该序号审计日志的哈希
+	KeyID     string    `json:"key_id" gorm:"type:varchar(64);index"` // 签名公钥ID
+	Signature string    `json:"signature" gorm:"type:varchar(128)"`   // Ed25519 签名（base64）
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BeforeUpdate 检查点不允许修改
+func (AuditCheckpoint) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// BeforeDelete 检查点不允许删除
+func (AuditCheckpoint) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// ErrAuditLogImmutable 尝试修改或删除审计日志
+var ErrAuditLogImmutable = errors.New("审计日志只允许追加，不能修改或删除")
+
+// LoginHistory 用户登录历史（成功与失败）
+type LoginHistory struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	AppID      string    `json:"app_id" gorm:"type:varchar(191);not null;index:idx_login_history_user,priority:1;index:idx_login_history_account,priority:1"`
+	UserID     uint      `json:"user_id" gorm:"index:idx_login_history_user,priority:2"`                      // 未匹配到用户时为 0
+	Account    string    `json:"account" gorm:"type:varchar(191);index:idx_login_history_account,priority:2"` // 登录使用的用户名或手机号
+	Method     string    `json:"method" gorm:"type:varchar(32)"`                                              // password, sms
+	Success    bool      `json:"success"`
+	Reason     string    `json:"reason" gorm:"type:varchar(255)"` // 失败原因
+	IP         string    `json:"ip" gorm:"type:varchar(64)"`
+	UserAgent  string    `json:"user_agent" gorm:"type:varchar(512)"`
+	DeviceID   string    `json:"device_id" gorm:"type:varchar(128)"` // 客户端上报的设备标识，未上报时为 User-Agent 摘要
+	Country    string    `json:"country" gorm:"type:varchar(8)"`
+	City       string    `json:"city" gorm:"type:varchar(128)"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	RiskFlags  string    `json:"risk_flags" gorm:"type:varchar(255)"` // 命中的异常规则，逗号分隔
+	RiskAction string    `json:"risk_action" gorm:"type:varchar(32)"` // 采取的处置：notify, mfa, revoke
+	CreatedAt  time.Time `json:"created_at" gorm:"index:idx_login_history_user,priority:3;index:idx_login_history_account,priority:3"`
+}
+
+// LoginRiskPolicy 应用异常登录策略
+// 各规则的处置：none 仅记录，notify 通知，mfa 要求短信二次验证，revoke 撤销用户全部会话并要求短信二次验证
+type LoginRiskPolicy struct {
+	ID                     uint      `json:"id" gorm:"primaryKey"`
+	AppID                  string    `json:"app_id" gorm:"type:varchar(191);uniqueIndex;not null"`
+	Enabled                bool      `json:"enabled"`
+	NewDeviceAction        string    `json:"new_device_action" gorm:"type:varchar(32);default:notify"`
+	ImpossibleTravelAction string    `json:"impossible_travel_action" gorm:"type:varchar(32);default:mfa"`
+	FailureBurstAction     string    `json:"failure_burst_action" gorm:"type:varchar(32);default:notify"`
+	MaxTravelSpeed         int       `json:"max_travel_speed" gorm:"not null;default:900"` // 两次登录之间允许的最大移动速度（公里/小时）
+	FailureThreshold       int       `json:"failure_threshold" gorm:"not null;default:5"`  // 时间窗口内的失败次数阈值
+	FailureWindow          int       `json:"failure_window" gorm:"not null;default:600"`   // 失败次数统计窗口（秒）
+	NotifyWebhook          string    `json:"notify_webhook" gorm:"type:varchar(512)"`      // 通知地址，为空时只写日志
+	CreatedAt              time.Time `json:"created_at"`
+	UpdatedAt              time.Time `json:"updated_at"`
 }
 
 // TableName 方法用于指定表名
@@ -168,3 +501,95 @@ func (Provider) TableName() string {
 func (SystemAdmin) TableName() string {
 	return "system_admins"
 }
+
+func (RateLimitQuota) TableName() string {
+	return "rate_limit_quotas"
+}
+
+func (PasswordPolicy) TableName() string {
+	return "password_policies"
+}
+
+func (PasswordHistory) TableName() string {
+	return "password_histories"
+}
+
+func (AppSecret) TableName() string {
+	return "app_secrets"
+}
+
+func (AppCertificate) TableName() string {
+	return "app_certificates"
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+func (AuditCheckpoint) TableName() string {
+	return "audit_checkpoints"
+}
+
+func (AuditChainHead) TableName() string {
+	return "audit_chain_heads"
+}
+
+func (LoginHistory) TableName() string {
+	return "login_histories"
+}
+
+func (LoginRiskPolicy) TableName() string {
+	return "login_risk_policies"
+}
+
+func (RoleInheritance) TableName() string {
+	return "role_inheritances"
+}
+
+func (UserPermission) TableName() string {
+	return "user_permissions"
+}
+
+func (RelationSchema) TableName() string {
+	return "relation_schemas"
+}
+
+func (RelationTuple) TableName() string {
+	return "relation_tuples"
+}
+
+func (RoleApprover) TableName() string {
+	return "role_approvers"
+}
+
+func (AccessRequest) TableName() string {
+	return "access_requests"
+}
+
+func (Group) TableName() string {
+	return "groups"
+}
+
+func (GroupMember) TableName() string {
+	return "group_members"
+}
+
+func (GroupInheritance) TableName() string {
+	return "group_inheritances"
+}
+
+func (GroupRole) TableName() string {
+	return "group_roles"
+}
+
+func (Organization) TableName() string {
+	return "organizations"
+}
+
+func (OrganizationMember) TableName() string {
+	return "organization_members"
+}
+
+func (OrganizationMemberRole) TableName() string {
+	return "organization_member_roles"
+}
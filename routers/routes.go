@@ -14,16 +14,25 @@ func InitRoutes(r *gin.Engine) {
 	{
 		// 应用认证路由（外部应用使用）
 		auth := v1.Group("/auth")
+		// 签名校验前只按 IP 限流，校验通过后再按签名应用限流
+		auth.Use(middleware.RateLimitMiddleware("auth"), middleware.SignatureMiddleware(), middleware.RateLimitMiddleware("auth"))
 		{
 			authController := &controllers.AuthController{}
 			auth.POST("/login", authController.Login)
+			auth.POST("/login/mfa", authController.LoginMFA)
+			auth.POST("/password/expired", authController.ChangeExpiredPassword)
 			auth.POST("/register", authController.Register)
 			auth.POST("/refresh", authController.RefreshToken)
 			auth.POST("/logout", authController.Logout)
+			auth.POST("/introspect", authController.Introspect)
 
 			// 需要认证的路由
-			auth.Use(middleware.AuthMiddleware())
+			auth.Use(middleware.AuthMiddleware(), middleware.RateLimitMiddleware("auth"))
 			auth.GET("/user", authController.GetUserInfo)
+			auth.POST("/password", authController.ChangePassword)
+			auth.GET("/login-history", authController.GetLoginHistory)
+			auth.GET("/organizations", authController.ListOrganizations)
+			auth.POST("/switch-organization", authController.SwitchOrganization)
 		}
 
 		// 系统管理路由（系统内部使用）
@@ -31,16 +40,23 @@ func InitRoutes(r *gin.Engine) {
 		{
 			systemAdminController := &controllers.SystemAdminController{}
 			system.POST("/login", systemAdminController.SystemLogin)
-			system.POST("/register", systemAdminController.SystemRegister)
+			system.POST("/password/expired", systemAdminController.SystemChangeExpiredPassword)
+			system.POST("/register", middleware.AuditMiddleware(), systemAdminController.SystemRegister)
 			system.POST("/refresh", systemAdminController.SystemRefreshToken)
 			system.POST("/logout", systemAdminController.SystemLogout)
 			system.GET("/admin/info", middleware.SystemAdminAuthMiddleware(), systemAdminController.GetSystemAdminInfo)
+
+			// 审计日志（应用级管理员只能查询自己应用的日志）
+			auditController := &controllers.AuditController{}
+			system.GET("/audit", middleware.SystemAdminAuthMiddleware(), middleware.FlexibleSystemAdminMiddleware(), auditController.ListAuditLogs)
+			system.GET("/audit/verify", middleware.SystemAdminAuthMiddleware(), middleware.SystemAdminOnlyMiddleware(), auditController.VerifyAuditLogs)
+			system.GET("/audit/export", middleware.SystemAdminAuthMiddleware(), middleware.SystemAdminOnlyMiddleware(), auditController.ExportAuditLogs)
 		}
 
 		// 系统级应用管理路由（仅系统级超级管理员）
 		appManagementController := &controllers.AppManagementController{}
 		apps := v1.Group("/apps")
-		apps.Use(middleware.SystemAdminAuthMiddleware(), middleware.SystemAdminOnlyMiddleware())
+		apps.Use(middleware.AuditMiddleware(), middleware.SystemAdminAuthMiddleware(), middleware.SystemAdminOnlyMiddleware())
 		{
 			apps.POST("", appManagementController.CreateApp)
 			apps.GET("", appManagementController.ListApps)
@@ -48,15 +64,29 @@ func InitRoutes(r *gin.Engine) {
 			apps.PUT("/:app_id", appManagementController.UpdateApp)
 			apps.DELETE("/:app_id", appManagementController.DeleteApp)
 			apps.POST("/:app_id/regenerate-secret", appManagementController.RegenerateAppSecret)
+			apps.GET("/:app_id/secrets", appManagementController.ListAppSecrets)
+			apps.DELETE("/:app_id/secrets/:id", appManagementController.RevokeAppSecret)
+			apps.GET("/:app_id/certificates", appManagementController.ListAppCertificates)
+			apps.POST("/:app_id/certificates", appManagementController.AddAppCertificate)
+			apps.DELETE("/:app_id/certificates/:id", appManagementController.DeleteAppCertificate)
 			apps.GET("/:app_id/users", appManagementController.ListAppUsers)
+			apps.GET("/:app_id/rate-limits", appManagementController.ListRateLimitQuotas)
+			apps.PUT("/:app_id/rate-limits", appManagementController.SetRateLimitQuota)
+			apps.DELETE("/:app_id/rate-limits/:id", appManagementController.DeleteRateLimitQuota)
+			apps.GET("/:app_id/password-policy", appManagementController.GetPasswordPolicy)
+			apps.PUT("/:app_id/password-policy", appManagementController.UpdatePasswordPolicy)
+			apps.GET("/:app_id/login-risk-policy", appManagementController.GetLoginRiskPolicy)
+			apps.PUT("/:app_id/login-risk-policy", appManagementController.UpdateLoginRiskPolicy)
 		}
 
 		// 系统管理员管理路由（仅系统级超级管理员）
 		systemAdminManagementController := &controllers.SystemAdminManagementController{}
 		systemAdmins := v1.Group("/system-admins")
-		systemAdmins.Use(middleware.SystemAdminAuthMiddleware(), middleware.SystemAdminOnlyMiddleware())
+		systemAdmins.Use(middleware.AuditMiddleware(), middleware.SystemAdminAuthMiddleware(), middleware.SystemAdminOnlyMiddleware())
 		{
 			systemAdmins.GET("", systemAdminManagementController.ListSystemAdmins)
+			systemAdmins.GET("/password-policy", systemAdminManagementController.GetPasswordPolicy)
+			systemAdmins.PUT("/password-policy", systemAdminManagementController.UpdatePasswordPolicy)
 			systemAdmins.POST("", systemAdminManagementController.CreateSystemAdmin)
 			systemAdmins.PUT("/:id", systemAdminManagementController.UpdateSystemAdmin)
 			systemAdmins.DELETE("/:id", systemAdminManagementController.DeleteSystemAdmin)
@@ -65,8 +95,9 @@ func InitRoutes(r *gin.Engine) {
 
 		// 应用内资源管理路由（系统级和应用级超级管理员）
 		appResourceController := &controllers.AppResourceController{}
+		accessRequestController := &controllers.AccessRequestController{}
 		appResources := v1.Group("/app")
-		appResources.Use(middleware.SystemAdminAuthMiddleware(), middleware.FlexibleSystemAdminMiddleware())
+		appResources.Use(middleware.AuditMiddleware(), middleware.SystemAdminAuthMiddleware(), middleware.FlexibleSystemAdminMiddleware())
 		{
 			// 当前应用信息（仅应用级管理员可用）
 			appResources.GET("/self", appResourceController.GetSelfApp)
@@ -80,8 +111,15 @@ func InitRoutes(r *gin.Engine) {
 				roles.DELETE("/:id", appResourceController.DeleteRole)
 				roles.POST("/:id/permissions", appResourceController.AssignRolePermissions)
 				roles.GET("/:id/permissions", appResourceController.GetRolePermissions)
+				roles.GET("/:id/parents", appResourceController.GetRoleParents)
+				roles.PUT("/:id/parents", appResourceController.SetRoleParents)
+				roles.GET("/:id/access-policy", accessRequestController.GetRoleAccessPolicy)
+				roles.PUT("/:id/access-policy", accessRequestController.SetRoleAccessPolicy)
 			}
 
+			// 临时提权申请
+			appResources.GET("/access-requests", accessRequestController.ListAccessRequests)
+
 			// 权限管理
 			permissions := appResources.Group("/permissions")
 			{
@@ -90,6 +128,7 @@ func InitRoutes(r *gin.Engine) {
 				permissions.GET("/:id", appResourceController.GetPermissionDetail)
 				permissions.PUT("/:id", appResourceController.UpdatePermission)
 				permissions.DELETE("/:id", appResourceController.DeletePermission)
+				permissions.POST("/simulate", appResourceController.SimulatePermission)
 			}
 
 			// 用户管理
@@ -97,30 +136,107 @@ func InitRoutes(r *gin.Engine) {
 			{
 				users.GET("", appResourceController.ListUsers)
 				users.POST("", appResourceController.CreateUser)
+				users.POST("/import", appResourceController.ImportUsers)
 				users.PUT("/:id", appResourceController.UpdateUser)
 				users.DELETE("/:id", appResourceController.DeleteUser)
+				users.POST("/:id/reset-password", appResourceController.ResetUserPassword)
 				users.POST("/:id/roles", appResourceController.AssignUserRoles)
 				users.GET("/:id/roles", appResourceController.GetUserRoles)
+				users.GET("/:id/login-history", appResourceController.GetUserLoginHistory)
+				users.GET("/:id/permissions", appResourceController.GetUserPermissions)
+				users.PUT("/:id/permissions", appResourceController.SetUserPermissions)
+				users.GET("/:id/explain", appResourceController.ExplainUserPermission)
+				users.GET("/:id/data-scope", appResourceController.GetUserDataScope)
+			}
+
+			// 用户分组：分组管理、成员（用户和下级分组）和分组的角色
+			groupController := &controllers.GroupController{}
+			groups := appResources.Group("/groups")
+			{
+				groups.GET("", groupController.ListGroups)
+				groups.POST("", groupController.CreateGroup)
+				groups.GET("/:id", groupController.GetGroup)
+				groups.PUT("/:id", groupController.UpdateGroup)
+				groups.DELETE("/:id", groupController.DeleteGroup)
+				groups.GET("/:id/members", groupController.GetGroupMembers)
+				groups.POST("/:id/members", groupController.AddGroupMembers)
+				groups.DELETE("/:id/members", groupController.RemoveGroupMembers)
+				groups.GET("/:id/roles", groupController.GetGroupRoles)
+				groups.PUT("/:id/roles", groupController.SetGroupRoles)
+			}
+
+			// 组织：组织管理、成员及成员在组织内的角色
+			organizationController := &controllers.OrganizationController{}
+			organizations := appResources.Group("/organizations")
+			{
+				organizations.GET("", organizationController.ListOrganizations)
+				organizations.POST("", organizationController.CreateOrganization)
+				organizations.GET("/:id", organizationController.GetOrganization)
+				organizations.PUT("/:id", organizationController.UpdateOrganization)
+				organizations.DELETE("/:id", organizationController.DeleteOrganization)
+				organizations.GET("/:id/members", organizationController.GetOrganizationMembers)
+				organizations.PUT("/:id/members/:user_id", organizationController.SetOrganizationMember)
+				organizations.DELETE("/:id/members/:user_id", organizationController.RemoveOrganizationMember)
+			}
+
+			// 关系授权：关系模式和元组管理
+			relationController := &controllers.RelationController{}
+			relations := appResources.Group("/relations")
+			{
+				relations.GET("/schema", relationController.GetSchema)
+				relations.PUT("/schema", relationController.UpdateSchema)
+				relations.GET("/tuples", relationController.ReadTuples)
+				relations.POST("/tuples", relationController.WriteTuples)
+				relations.POST("/check", relationController.Check)
+				relations.POST("/expand", relationController.Expand)
+				relations.POST("/list-objects", relationController.ListObjects)
 			}
 		}
 
 		// 权限管理路由
 		permissions := v1.Group("/permissions")
-		permissions.Use(middleware.AuthMiddleware())
+		permissions.Use(middleware.AuthMiddleware(), middleware.RateLimitMiddleware("permission"))
 		{
 			permissionController := &controllers.PermissionController{}
 			permissions.GET("/check", permissionController.CheckPermission)
 			permissions.GET("/check-api", permissionController.CheckAPIPermission)
+			permissions.POST("/check-batch", permissionController.CheckPermissionBatch)
+			permissions.POST("/evaluate", permissionController.EvaluatePermission)
 			permissions.GET("/user", permissionController.GetUserPermissions)
 			permissions.GET("/roles", permissionController.GetUserRoles)
+			permissions.GET("/data-scope", permissionController.GetDataScope)
+		}
+
+		// 临时提权申请路由：申请人提交和撤回申请，审批人审批
+		accessRequests := v1.Group("/access-requests")
+		accessRequests.Use(middleware.AuthMiddleware(), middleware.RateLimitMiddleware("permission"))
+		{
+			accessRequests.POST("", accessRequestController.CreateAccessRequest)
+			accessRequests.GET("", accessRequestController.ListMyAccessRequests)
+			accessRequests.GET("/pending", accessRequestController.ListPendingApprovals)
+			accessRequests.POST("/:id/approve", accessRequestController.ApproveAccessRequest)
+			accessRequests.POST("/:id/deny", accessRequestController.DenyAccessRequest)
+			accessRequests.POST("/:id/cancel", accessRequestController.CancelAccessRequest)
 		}
 
 		// 管理后台路由（需要应用认证）
 		admin := v1.Group("/admin")
-		admin.Use(middleware.AppAuthMiddleware())
+		admin.Use(middleware.SignatureMiddleware(), middleware.AppAuthMiddleware())
 		{
 			// 这里可以添加管理后台相关的路由
 			// 例如：用户管理、角色管理、权限管理等
+
+			// 关系授权：应用后端写入元组并查询
+			relationController := &controllers.RelationController{}
+			relations := admin.Group("/relations")
+			{
+				relations.GET("/schema", relationController.GetSchema)
+				relations.GET("/tuples", relationController.ReadTuples)
+				relations.POST("/tuples", relationController.WriteTuples)
+				relations.POST("/check", relationController.Check)
+				relations.POST("/expand", relationController.Expand)
+				relations.POST("/list-objects", relationController.ListObjects)
+			}
 		}
 	}
 }
@@ -2,10 +2,17 @@ package auth
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -33,6 +40,7 @@ func NewAuthClient(baseURL, appID, appSecret string) *AuthClient {
 type LoginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	DeviceID string `json:"device_id"` // 可选，客户端设备标识
 }
 
 // LoginResponse 登录响应
@@ -42,6 +50,9 @@ type LoginResponse struct {
 	ExpiresIn    int64  `json:"expires_in"`
 	TokenType    string `json:"token_type"`
 	User         UserInfo `json:"user"`
+	// MFARequired 检测到异常登录，需调用 LoginMFA 完成登录
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
 }
 
 // UserInfo 用户信息
@@ -90,8 +101,30 @@ func (c *AuthClient) Login(req *LoginRequest) (*LoginResponse, error) {
 		"username": req.Username,
 		"password": req.Password,
 	}
+	if req.DeviceID != "" {
+		reqBody["device_id"] = req.DeviceID
+	}
+
+	var response LoginResponse
+	if err := c.postJSON("/api/v1/auth/login", reqBody, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
 
-	return c.postJSON("/api/v1/auth/login", reqBody, &LoginResponse{})
+// LoginMFA 使用登录返回的 MFAToken 和短信验证码完成异常登录的二次验证
+func (c *AuthClient) LoginMFA(mfaToken, code string) (*LoginResponse, error) {
+	reqBody := map[string]interface{}{
+		"app_id":    c.AppID,
+		"mfa_token": mfaToken,
+		"code":      code,
+	}
+
+	var response LoginResponse
+	if err := c.postJSON("/api/v1/auth/login/mfa", reqBody, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
 }
 
 // Register 用户注册
@@ -103,13 +136,16 @@ func (c *AuthClient) Register(req *RegisterRequest) error {
 		"password": req.Password,
 	}
 
-	_, err := c.postJSON("/api/v1/auth/register", reqBody, &APIResponse{})
-	return err
+	return c.postJSON("/api/v1/auth/register", reqBody, &APIResponse{})
 }
 
 // RefreshToken 刷新令牌
 func (c *AuthClient) RefreshToken(req *RefreshTokenRequest) (*LoginResponse, error) {
-	return c.postJSON("/api/v1/auth/refresh", req, &LoginResponse{})
+	var response LoginResponse
+	if err := c.postJSON("/api/v1/auth/refresh", req, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
 }
 
 // Logout 用户登出
@@ -118,8 +154,7 @@ func (c *AuthClient) Logout(token string) error {
 		"token": token,
 	}
 
-	_, err := c.postJSON("/api/v1/auth/logout", reqBody, &APIResponse{})
-	return err
+	return c.postJSON("/api/v1/auth/logout", reqBody, &APIResponse{})
 }
 
 // GetUserInfo 获取用户信息
@@ -128,7 +163,11 @@ func (c *AuthClient) GetUserInfo(token string) (*UserInfo, error) {
 		"Authorization": "Bearer " + token,
 	}
 
-	return c.getJSON("/api/v1/auth/user", headers, &UserInfo{})
+	var response UserInfo
+	if err := c.getJSON("/api/v1/auth/user", headers, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
 }
 
 // CheckPermission 检查权限
@@ -137,9 +176,9 @@ func (c *AuthClient) CheckPermission(token, permission string) (bool, error) {
 		"Authorization": "Bearer " + token,
 	}
 
-	url := fmt.Sprintf("/api/v1/permissions/check?permission=%s", permission)
-	response, err := c.getJSON(url, headers, &PermissionCheckResponse{})
-	if err != nil {
+	path := "/api/v1/permissions/check?permission=" + url.QueryEscape(permission)
+	var response PermissionCheckResponse
+	if err := c.getJSON(path, headers, &response); err != nil {
 		return false, err
 	}
 
@@ -152,15 +191,66 @@ func (c *AuthClient) CheckAPIPermission(token, path, method string) (bool, error
 		"Authorization": "Bearer " + token,
 	}
 
-	url := fmt.Sprintf("/api/v1/permissions/check-api?path=%s&method=%s", path, method)
-	response, err := c.getJSON(url, headers, &PermissionCheckResponse{})
-	if err != nil {
+	query := url.Values{"path": {path}, "method": {method}}
+	var response PermissionCheckResponse
+	if err := c.getJSON("/api/v1/permissions/check-api?"+query.Encode(), headers, &response); err != nil {
 		return false, err
 	}
 
 	return response.HasPermission, nil
 }
 
+// APIPermissionQuery 批量检查中的一项API
+type APIPermissionQuery struct {
+	Path   string `json:"path"`
+	Method string `json:"method"`
+}
+
+// BatchPermissionCheckRequest 批量权限检查请求
+type BatchPermissionCheckRequest struct {
+	Permissions []string             `json:"permissions,omitempty"`
+	APIs        []APIPermissionQuery `json:"apis,omitempty"`
+}
+
+// BatchPermissionCheckResponse 批量权限检查响应
+type BatchPermissionCheckResponse struct {
+	Permissions map[string]bool `json:"permissions"` // 权限代码 -> 是否拥有
+	APIs        map[string]bool `json:"apis"`        // APIPermissionKey -> 是否可以访问
+}
+
+// HasPermission 是否拥有指定权限代码
+func (r *BatchPermissionCheckResponse) HasPermission(permission string) bool {
+	return r.Permissions[permission]
+}
+
+// CanAccessAPI 是否可以访问指定API
+func (r *BatchPermissionCheckResponse) CanAccessAPI(path, method string) bool {
+	return r.APIs[APIPermissionKey(path, method)]
+}
+
+// APIPermissionKey 批量检查结果中API的键：大写的方法、空格、路径，如 "GET /api/orders/42"
+func APIPermissionKey(path, method string) string {
+	return strings.ToUpper(strings.TrimSpace(method)) + " " + path
+}
+
+// CheckPermissions 批量检查权限代码和API，一次请求返回全部结果
+func (c *AuthClient) CheckPermissions(token string, req *BatchPermissionCheckRequest) (*BatchPermissionCheckResponse, error) {
+	headers := map[string]string{
+		"Authorization": "Bearer " + token,
+	}
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var response BatchPermissionCheckResponse
+	if err := c.doJSON("POST", "/api/v1/permissions/check-batch", jsonData, headers, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
 // GetUserPermissions 获取用户权限列表
 func (c *AuthClient) GetUserPermissions(token string) ([]string, error) {
 	headers := map[string]string{
@@ -171,8 +261,7 @@ func (c *AuthClient) GetUserPermissions(token string) ([]string, error) {
 		Permissions []string `json:"permissions"`
 	}
 
-	_, err := c.getJSON("/api/v1/permissions/user", headers, &response)
-	if err != nil {
+	if err := c.getJSON("/api/v1/permissions/user", headers, &response); err != nil {
 		return nil, err
 	}
 
@@ -189,96 +278,129 @@ func (c *AuthClient) GetUserRoles(token string) ([]RoleInfo, error) {
 		Roles []RoleInfo `json:"roles"`
 	}
 
-	_, err := c.getJSON("/api/v1/permissions/roles", headers, &response)
-	if err != nil {
+	if err := c.getJSON("/api/v1/permissions/roles", headers, &response); err != nil {
 		return nil, err
 	}
 
 	return response.Roles, nil
 }
 
-// postJSON 发送POST JSON请求
-func (c *AuthClient) postJSON(path string, reqBody interface{}, response interface{}) (interface{}, error) {
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, err
-	}
+// DataScope 用户对资源类型可访问的数据范围（行级权限）。
+// All 为 true 时不限制；否则可访问的数据为本人（UserID）、本部门（DepartmentIDs）和自定义取值（CustomValues）各条件的并集，
+// 均不满足时（包括 Allowed 为 false）不能访问任何数据
+type DataScope struct {
+	Resource        string   `json:"resource"`
+	Action          string   `json:"action"`
+	Allowed         bool     `json:"allowed"`
+	All             bool     `json:"all"`
+	Own             bool     `json:"own"`
+	UserID          uint     `json:"user_id"`
+	Department      bool     `json:"department"`
+	DepartmentIDs   []uint   `json:"department_ids"`
+	DepartmentCodes []string `json:"department_codes"`
+	CustomValues    []string `json:"custom_values"`
+	Permissions     []string `json:"permissions"`
+}
 
-	req, err := http.NewRequest("POST", c.BaseURL+path, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
+// GetDataScope 获取当前用户对资源类型（及操作，可为空）可访问的数据范围，用于构造查询条件
+func (c *AuthClient) GetDataScope(token, resource, action string) (*DataScope, error) {
+	headers := map[string]string{
+		"Authorization": "Bearer " + token,
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-App-Id", c.AppID)
-	req.Header.Set("X-App-Secret", c.AppSecret)
-
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, err
+	query := url.Values{"resource": {resource}}
+	if action != "" {
+		query.Set("action", action)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
+	var response DataScope
+	if err := c.getJSON("/api/v1/permissions/data-scope?"+query.Encode(), headers, &response); err != nil {
 		return nil, err
 	}
+	return &response, nil
+}
 
-	if resp.StatusCode >= 400 {
-		var errorResp APIResponse
-		if err := json.Unmarshal(body, &errorResp); err == nil {
-			return nil, fmt.Errorf("API错误: %s", errorResp.Error)
-		}
-		return nil, fmt.Errorf("HTTP错误: %d", resp.StatusCode)
+// postJSON 发送签名的POST JSON请求
+func (c *AuthClient) postJSON(path string, reqBody interface{}, response interface{}) error {
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return err
 	}
 
-	if response != nil {
-		if err := json.Unmarshal(body, response); err != nil {
-			return nil, err
-		}
-		return response, nil
-	}
+	return c.doJSON("POST", path, jsonData, nil, response)
+}
 
-	return nil, nil
+// getJSON 发送签名的GET JSON请求
+func (c *AuthClient) getJSON(path string, headers map[string]string, response interface{}) error {
+	return c.doJSON("GET", path, nil, headers, response)
 }
 
-// getJSON 发送GET JSON请求
-func (c *AuthClient) getJSON(path string, headers map[string]string, response interface{}) (interface{}, error) {
-	req, err := http.NewRequest("GET", c.BaseURL+path, nil)
+// doJSON 发送请求并解析JSON响应
+func (c *AuthClient) doJSON(method, path string, body []byte, headers map[string]string, response interface{}) error {
+	req, err := http.NewRequest(method, c.BaseURL+path, bytes.NewReader(body))
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	for key, value := range headers {
 		req.Header.Set(key, value)
 	}
+	if err := c.signRequest(req, body); err != nil {
+		return err
+	}
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	if resp.StatusCode >= 400 {
 		var errorResp APIResponse
-		if err := json.Unmarshal(body, &errorResp); err == nil {
-			return nil, fmt.Errorf("API错误: %s", errorResp.Error)
+		if err := json.Unmarshal(respBody, &errorResp); err == nil && errorResp.Error != "" {
+			return fmt.Errorf("API错误: %s", errorResp.Error)
 		}
-		return nil, fmt.Errorf("HTTP错误: %d", resp.StatusCode)
+		return fmt.Errorf("HTTP错误: %d", resp.StatusCode)
 	}
 
 	if response != nil {
-		if err := json.Unmarshal(body, response); err != nil {
-			return nil, err
-		}
-		return response, nil
+		return json.Unmarshal(respBody, response)
 	}
+	return nil
+}
+
+// signRequest 使用应用密钥为请求签名，应用密钥本身不会随请求发送
+// 签名内容：请求方法\n请求URI\n时间戳\nnonce\n请求体SHA-256，签名密钥为 HMAC-SHA256(应用密钥, "auth-center request signing")（十六进制）
+func (c *AuthClient) signRequest(req *http.Request, body []byte) error {
+	nonceBytes := make([]byte, 16)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return err
+	}
+	nonce := hex.EncodeToString(nonceBytes)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	bodyHash := sha256.Sum256(body)
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.RequestURI(),
+		timestamp,
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+
+	keyMAC := hmac.New(sha256.New, []byte(c.AppSecret))
+	keyMAC.Write([]byte("auth-center request signing"))
+	mac := hmac.New(sha256.New, []byte(hex.EncodeToString(keyMAC.Sum(nil))))
+	mac.Write([]byte(canonical))
 
-	return nil, nil
+	req.Header.Set("X-App-Id", c.AppID)
+	req.Header.Set("X-Timestamp", timestamp)
+	req.Header.Set("X-Nonce", nonce)
+	req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+	return nil
 }
@@ -62,6 +62,17 @@ func main() {
 		fmt.Printf("是否有API权限: %t\n", hasAPIPermission)
 	}
 
+	// 批量检查权限（一次请求检查页面上的全部按钮）
+	batch, err := client.CheckPermissions(loginResp.AccessToken, &auth.BatchPermissionCheckRequest{
+		Permissions: []string{"user:read", "user:write", "user:delete"},
+		APIs:        []auth.APIPermissionQuery{{Path: "/api/v1/users", Method: "GET"}},
+	})
+	if err != nil {
+		log.Printf("批量检查权限失败: %v", err)
+	} else {
+		fmt.Printf("是否有user:write权限: %t，是否有API权限: %t\n", batch.HasPermission("user:write"), batch.CanAccessAPI("/api/v1/users", "GET"))
+	}
+
 	// 获取用户权限列表
 	permissions, err := client.GetUserPermissions(loginResp.AccessToken)
 	if err != nil {
@@ -0,0 +1,360 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"auth-center/config"
+	"auth-center/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 临时提权：角色设置了最长时长和审批人后，用户可以附带理由申请在限定时间内获得该角色；
+// 审批人批准后以有有效期的角色分配授予，到期由分配有效期的定时任务删除并撤销令牌。每一步都写入审计日志
+
+// 申请状态
+const (
+	AccessRequestPending   = "pending"
+	AccessRequestApproved  = "approved"
+	AccessRequestDenied    = "denied"
+	AccessRequestExpired   = "expired"
+	AccessRequestCancelled = "cancelled"
+)
+
+// accessRequestJustificationMaxLength 申请理由和审批意见的最大长度
+const accessRequestJustificationMaxLength = 1024
+
+var (
+	ErrAccessRequestNotFound       = errors.New("申请不存在")
+	ErrAccessRequestNotRequestable = errors.New("该角色不可申请临时授权")
+	ErrAccessRequestInvalid        = errors.New("申请参数无效")
+	ErrAccessRequestDuplicate      = errors.New("已有该角色的待审批申请")
+	ErrAccessRequestAlreadyGranted = errors.New("已拥有该角色")
+	ErrAccessRequestNotPending     = errors.New("申请不是待审批状态")
+	ErrAccessRequestNotApprover    = errors.New("不是该角色的审批人")
+	ErrAccessRequestSelfApproval   = errors.New("不能审批自己的申请")
+)
+
+// AccessRequestService 临时提权申请服务
+type AccessRequestService struct{}
+
+// RoleAccessPolicy 角色的临时提权设置
+type RoleAccessPolicy struct {
+	MaxDuration int64  `json:"max_duration"` // 单次申请的最长时长（秒），0 表示不可申请
+	ApproverIDs []uint `json:"approver_ids"` // 审批人（应用内的用户）
+}
+
+// GetRoleAccessPolicy 获取角色的临时提权设置
+func (s *AccessRequestService) GetRoleAccessPolicy(appID string, role *models.Role) (*RoleAccessPolicy, error) {
+	policy := &RoleAccessPolicy{MaxDuration: role.AccessMaxDuration, ApproverIDs: []uint{}}
+	if err := config.DB.Model(&models.RoleApprover{}).Where("app_id = ? AND role_id = ?", appID, role.ID).
+		Order("user_id").Pluck("user_id", &policy.ApproverIDs).Error; err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// SetRoleAccessPolicy 设置角色的临时提权设置（替换原有审批人），可申请的角色至少需要一名审批人
+func (s *AccessRequestService) SetRoleAccessPolicy(appID string, role *models.Role, policy *RoleAccessPolicy) error {
+	if policy.MaxDuration < 0 {
+		return fmt.Errorf("%w：最长时长不能为负数", ErrAccessRequestInvalid)
+	}
+	approverIDs := uniqueIDs(policy.ApproverIDs)
+	if policy.MaxDuration > 0 && len(approverIDs) == 0 {
+		return fmt.Errorf("%w：可申请的角色至少需要一名审批人", ErrAccessRequestInvalid)
+	}
+	if len(approverIDs) > 0 {
+		var count int64
+		if err := config.DB.Model(&models.User{}).Where("app_id = ? AND id IN ?", appID, approverIDs).Count(&count).Error; err != nil {
+			return err
+		}
+		if count != int64(len(approverIDs)) {
+			return fmt.Errorf("%w：审批人不存在或不属于当前应用", ErrAccessRequestInvalid)
+		}
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(role).Update("access_max_duration", policy.MaxDuration).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("app_id = ? AND role_id = ?", appID, role.ID).Delete(&models.RoleApprover{}).Error; err != nil {
+			return err
+		}
+		for _, userID := range approverIDs {
+			if err := tx.Create(&models.RoleApprover{AppID: appID, RoleID: role.ID, UserID: userID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ResolveAccessDuration 按角色允许的最长时长 maxDuration 确定申请时长，requested 为 0 时取最长时长
+func ResolveAccessDuration(requested, maxDuration int64) (int64, error) {
+	if maxDuration <= 0 {
+		return 0, ErrAccessRequestNotRequestable
+	}
+	if requested == 0 {
+		return maxDuration, nil
+	}
+	if requested < 0 || requested > maxDuration {
+		return 0, fmt.Errorf("%w：时长须在 1 到 %d 秒之间", ErrAccessRequestInvalid, maxDuration)
+	}
+	return requested, nil
+}
+
+// ApprovedAccessDuration 批准时的授权时长：不超过角色当前允许的最长时长 maxDuration（申请后可能已缩短）
+func ApprovedAccessDuration(requested, maxDuration int64) (int64, error) {
+	if maxDuration <= 0 {
+		return 0, ErrAccessRequestNotRequestable
+	}
+	if requested > maxDuration {
+		return maxDuration, nil
+	}
+	return requested, nil
+}
+
+// CreateRequest 用户申请在 duration 秒内获得角色，duration 为 0 时按角色允许的最长时长
+func (s *AccessRequestService) CreateRequest(appID string, userID, roleID uint, justification string, duration int64) (*models.AccessRequest, error) {
+	justification = strings.TrimSpace(justification)
+	if justification == "" || len(justification) > accessRequestJustificationMaxLength {
+		return nil, fmt.Errorf("%w：申请理由不能为空且不能超过 %d 个字符", ErrAccessRequestInvalid, accessRequestJustificationMaxLength)
+	}
+
+	// 已直接分配或通过分组获得该角色时无需申请（组织内的角色只在组织内生效，不影响申请）
+	assignedIDs, err := userAssignedRoleIDs(userID, appID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var request *models.AccessRequest
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定角色，同一角色的申请串行检查重复申请，避免并发提交产生多个待审批申请
+		var role models.Role
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND app_id = ? AND status = 1", roleID, appID).First(&role).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrAccessRequestNotRequestable
+			}
+			return err
+		}
+		duration, err := ResolveAccessDuration(duration, role.AccessMaxDuration)
+		if err != nil {
+			return err
+		}
+		for _, assignedID := range assignedIDs {
+			if assignedID == roleID {
+				return ErrAccessRequestAlreadyGranted
+			}
+		}
+		var count int64
+		if err := tx.Model(&models.AccessRequest{}).
+			Where("user_id = ? AND app_id = ? AND role_id = ? AND status = ?", userID, appID, roleID, AccessRequestPending).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAccessRequestDuplicate
+		}
+
+		request = &models.AccessRequest{
+			AppID:         appID,
+			UserID:        userID,
+			RoleID:        roleID,
+			Justification: justification,
+			Duration:      duration,
+			Status:        AccessRequestPending,
+		}
+		return tx.Create(request).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	recordAccessRequestAudit("access_request.create", AuditActorUser, userID, nil, request)
+	return request, nil
+}
+
+// ListRequests 查询应用内的申请，userID 不为 0 时只查询该用户的申请，status 为空时不过滤
+func (s *AccessRequestService) ListRequests(appID string, userID uint, status string) ([]models.AccessRequest, error) {
+	db := config.DB.Where("app_id = ?", appID)
+	if userID != 0 {
+		db = db.Where("user_id = ?", userID)
+	}
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	requests := []models.AccessRequest{}
+	err := db.Order("id DESC").Limit(200).Find(&requests).Error
+	return requests, err
+}
+
+// ListPendingForApprover 查询审批人可以审批的待审批申请（不含自己的申请）
+func (s *AccessRequestService) ListPendingForApprover(appID string, approverID uint) ([]models.AccessRequest, error) {
+	requests := []models.AccessRequest{}
+	err := config.DB.Where("app_id = ? AND status = ? AND user_id <> ?", appID, AccessRequestPending, approverID).
+		Where("role_id IN (?)", config.DB.Model(&models.RoleApprover{}).Select("role_id").Where("app_id = ? AND user_id = ?", appID, approverID)).
+		Order("id").Find(&requests).Error
+	return requests, err
+}
+
+// CancelRequest 申请人撤回待审批的申请
+func (s *AccessRequestService) CancelRequest(appID string, userID, requestID uint) (*models.AccessRequest, error) {
+	var request models.AccessRequest
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND app_id = ? AND user_id = ?", requestID, appID, userID).First(&request).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrAccessRequestNotFound
+			}
+			return err
+		}
+		return transitionAccessRequest(tx, &request, map[string]interface{}{"status": AccessRequestCancelled})
+	})
+	if err != nil {
+		return nil, err
+	}
+	recordAccessRequestAudit("access_request.cancel", AuditActorUser, userID, nil, &request)
+	return &request, nil
+}
+
+// ReviewRequest 审批人批准或拒绝申请。批准时授予有效期为申请时长的角色分配，从批准时开始计算
+func (s *AccessRequestService) ReviewRequest(appID string, reviewerID, requestID uint, approve bool, comment string) (*models.AccessRequest, error) {
+	comment = strings.TrimSpace(comment)
+	if len(comment) > accessRequestJustificationMaxLength {
+		return nil, fmt.Errorf("%w：审批意见不能超过 %d 个字符", ErrAccessRequestInvalid, accessRequestJustificationMaxLength)
+	}
+
+	var request models.AccessRequest
+	var before models.AccessRequest
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND app_id = ?", requestID, appID).First(&request).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrAccessRequestNotFound
+			}
+			return err
+		}
+		before = request
+		if request.UserID == reviewerID {
+			return ErrAccessRequestSelfApproval
+		}
+		var count int64
+		if err := tx.Model(&models.RoleApprover{}).Where("app_id = ? AND role_id = ? AND user_id = ?", appID, request.RoleID, reviewerID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrAccessRequestNotApprover
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"status":         AccessRequestDenied,
+			"reviewer_id":    reviewerID,
+			"review_comment": comment,
+			"reviewed_at":    now,
+		}
+		if approve {
+			// 申请后角色可能已停用或不再可申请，或最长时长已缩短
+			var role models.Role
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("id = ? AND app_id = ? AND status = 1 AND access_max_duration > 0", request.RoleID, appID).First(&role).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrAccessRequestNotRequestable
+				}
+				return err
+			}
+			duration, err := ApprovedAccessDuration(request.Duration, role.AccessMaxDuration)
+			if err != nil {
+				return err
+			}
+			if duration != request.Duration {
+				updates["duration"] = duration
+				request.Duration = duration
+			}
+			until := now.Add(time.Duration(request.Duration) * time.Second)
+			userRole := models.UserRole{UserID: request.UserID, RoleID: request.RoleID, AppID: appID, ValidFrom: &now, ValidUntil: &until}
+			if err := tx.Create(&userRole).Error; err != nil {
+				return err
+			}
+			updates["status"] = AccessRequestApproved
+			updates["granted_until"] = until
+			updates["user_role_id"] = userRole.ID
+		}
+		return transitionAccessRequest(tx, &request, updates)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	action := "access_request.deny"
+	if approve {
+		action = "access_request.approve"
+		cacheService := &PermissionCacheService{}
+		cacheService.InvalidateUser(appID, request.UserID)
+	}
+	recordAccessRequestAudit(action, AuditActorUser, reviewerID, &before, &request)
+	return &request, nil
+}
+
+// transitionAccessRequest 更新待审批的申请，申请已被其他人处理时返回 ErrAccessRequestNotPending
+func transitionAccessRequest(tx *gorm.DB, request *models.AccessRequest, updates map[string]interface{}) error {
+	result := tx.Model(request).Where("status = ?", AccessRequestPending).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAccessRequestNotPending
+	}
+	return tx.First(request, request.ID).Error
+}
+
+// expireAccessRequests 将授权已到期的申请标记为 expired（角色分配由 expireUserRoles 删除）
+func expireAccessRequests(now time.Time) error {
+	var requests []models.AccessRequest
+	if err := config.DB.Where("status = ? AND granted_until <= ?", AccessRequestApproved, now).Find(&requests).Error; err != nil {
+		return err
+	}
+	for i := range requests {
+		before := requests[i]
+		result := config.DB.Model(&requests[i]).Where("status = ?", AccessRequestApproved).Update("status", AccessRequestExpired)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		recordAccessRequestAudit("access_request.expire", AuditActorSystem, 0, &before, &requests[i])
+	}
+	return nil
+}
+
+// recordAccessRequestAudit 记录申请的一个步骤
+func recordAccessRequestAudit(action, actorType string, actorID uint, before, after *models.AccessRequest) {
+	entry := &models.AuditLog{
+		ActorType:  actorType,
+		ActorID:    actorID,
+		AppID:      after.AppID,
+		Action:     action,
+		TargetType: "access_request",
+		TargetID:   strconv.FormatUint(uint64(after.ID), 10),
+		After:      AuditSnapshot(after),
+	}
+	if before != nil {
+		entry.Before = AuditSnapshot(before)
+		entry.Diff = AuditDiff(before, after)
+	}
+	if actorType == AuditActorUser {
+		var user models.User
+		if err := config.DB.Select("username").Where("id = ?", actorID).First(&user).Error; err == nil {
+			entry.ActorName = user.Username
+		} else {
+			log.Printf("读取审计日志操作者失败: %v", err)
+		}
+	}
+	RecordAudit(entry)
+}
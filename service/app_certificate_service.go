@@ -0,0 +1,132 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"auth-center/config"
+	"auth-center/models"
+	"auth-center/utils"
+)
+
+// AppCertificateService 应用客户端证书服务
+type AppCertificateService struct{}
+
+// AddAppCertificateRequest 登记应用证书请求
+// 提供 certificate（PEM）时自动计算指纹、主题和到期时间；也可以只登记 thumbprint 或 subject
+type AddAppCertificateRequest struct {
+	Name        string `json:"name"`
+	Certificate string `json:"certificate"`
+	Thumbprint  string `json:"thumbprint"`
+	Subject     string `json:"subject"`
+}
+
+// ListCertificates 获取应用登记的证书
+func (s *AppCertificateService) ListCertificates(appID string) ([]models.AppCertificate, error) {
+	var certs []models.AppCertificate
+	if err := config.DB.Where("app_id = ?", appID).Order("id DESC").Find(&certs).Error; err != nil {
+		return nil, err
+	}
+	return certs, nil
+}
+
+// AddCertificate 登记应用证书
+func (s *AppCertificateService) AddCertificate(appID string, req *AddAppCertificateRequest) (*models.AppCertificate, error) {
+	cert := &models.AppCertificate{
+		AppID:      appID,
+		Name:       req.Name,
+		Thumbprint: req.Thumbprint,
+		Subject:    req.Subject,
+		Status:     1,
+	}
+
+	if req.Certificate != "" {
+		parsed, err := utils.ParseCertificatePEM(req.Certificate)
+		if err != nil {
+			return nil, err
+		}
+		notAfter := parsed.NotAfter
+		cert.Thumbprint = utils.CertThumbprint(parsed)
+		cert.Subject = parsed.Subject.String()
+		cert.NotAfter = &notAfter
+		if cert.Name == "" {
+			cert.Name = parsed.Subject.CommonName
+		}
+	}
+
+	if cert.Thumbprint == "" && cert.Subject == "" {
+		return nil, errors.New("必须提供证书、指纹或证书主题")
+	}
+
+	if cert.Thumbprint != "" {
+		var count int64
+		config.DB.Model(&models.AppCertificate{}).Where("thumbprint = ?", cert.Thumbprint).Count(&count)
+		if count > 0 {
+			return nil, errors.New("该证书已被登记")
+		}
+	}
+
+	if err := config.DB.Create(cert).Error; err != nil {
+		return nil, fmt.Errorf("登记证书失败: %v", err)
+	}
+	return cert, nil
+}
+
+// DeleteCertificate 删除应用证书
+func (s *AppCertificateService) DeleteCertificate(appID string, certID uint) error {
+	result := config.DB.Where("id = ? AND app_id = ?", certID, appID).Delete(&models.AppCertificate{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("证书不存在")
+	}
+	return nil
+}
+
+// MatchAppCertificate 判断客户端证书是否为应用登记的可信证书
+// 指纹匹配任何出示的证书；主题匹配仅对通过客户端 CA 证书链校验的证书生效
+func MatchAppCertificate(appID string, cert *utils.ClientCertInfo) bool {
+	if appID == "" || cert == nil {
+		return false
+	}
+
+	query := config.DB.Model(&models.AppCertificate{}).
+		Where("app_id = ? AND status = 1 AND (not_after IS NULL OR not_after > ?)", appID, time.Now())
+	if cert.Verified {
+		query = query.Where("(thumbprint = ? OR (subject <> '' AND subject = ?))", cert.Thumbprint, cert.Subject)
+	} else {
+		query = query.Where("thumbprint = ?", cert.Thumbprint)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false
+	}
+	return count > 0
+}
+
+// CertificateConfirmation 根据客户端证书生成令牌绑定信息，未出示证书时返回 nil
+func CertificateConfirmation(cert *utils.ClientCertInfo) *utils.Confirmation {
+	if cert == nil {
+		return nil
+	}
+	return &utils.Confirmation{X5tS256: cert.Thumbprint}
+}
+
+// ConfirmationMatches 校验令牌绑定信息与当前连接的客户端证书是否一致，未绑定证书的令牌始终通过
+func ConfirmationMatches(cnf *utils.Confirmation, cert *utils.ClientCertInfo) bool {
+	if cnf == nil || cnf.X5tS256 == "" {
+		return true
+	}
+	return cert != nil && cert.Thumbprint == cnf.X5tS256
+}
+
+// authenticateApp 校验应用身份：请求签名、登记的客户端证书或应用密钥，任一通过即可
+func authenticateApp(appID, appSecret string, signatureVerified bool, cert *utils.ClientCertInfo) bool {
+	if signatureVerified || MatchAppCertificate(appID, cert) {
+		return true
+	}
+	return VerifyAppSecret(appID, appSecret)
+}
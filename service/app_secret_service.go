@@ -0,0 +1,249 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"auth-center/config"
+	"auth-center/models"
+	"auth-center/utils"
+
+	"gorm.io/gorm"
+)
+
+// ErrLastActiveAppSecret 吊销应用唯一的有效密钥
+var ErrLastActiveAppSecret = errors.New("不能吊销应用唯一的有效密钥，请先轮换密钥")
+
+// AppSecretService 应用密钥服务
+type AppSecretService struct{}
+
+// maxActiveAppSecrets 每个应用同时有效的密钥数量上限
+const maxActiveAppSecrets = 2
+
+// DefaultSecretGracePeriod 轮换密钥时旧密钥的默认宽限期（秒）
+const DefaultSecretGracePeriod = 24 * 3600
+
+// appSecretPrefixLength 记录的明文前缀长度（"app_" + 8 位十六进制）
+const appSecretPrefixLength = 12
+
+// secretLastUsedInterval 最近使用时间的最小更新间隔，避免每次请求都写库
+const secretLastUsedInterval = time.Minute
+
+// RotateAppSecretRequest 轮换应用密钥请求
+type RotateAppSecretRequest struct {
+	GracePeriod *int `json:"grace_period" binding:"omitempty,min=0,max=2592000"` // 旧密钥宽限期（秒），0 表示立即失效
+}
+
+// RotateAppSecretResponse 轮换应用密钥响应（明文密钥仅返回一次）
+type RotateAppSecretResponse struct {
+	AppSecret string             `json:"app_secret"`
+	Secret    *models.AppSecret  `json:"secret"`
+	Active    []models.AppSecret `json:"active_secrets"`
+}
+
+// CreateSecret 为应用生成新密钥并保存哈希，返回明文
+func (s *AppSecretService) CreateSecret(tx *gorm.DB, appID string, expiresAt *time.Time) (string, *models.AppSecret, error) {
+	plaintext, err := GenerateAppSecret()
+	if err != nil {
+		return "", nil, err
+	}
+
+	secretHash := utils.HashAppSecret(plaintext)
+	signingKeyEnc, err := utils.EncryptSigningKey(utils.DeriveSigningKey(plaintext), secretHash)
+	if err != nil {
+		return "", nil, fmt.Errorf("加密签名密钥失败: %v", err)
+	}
+	secret := &models.AppSecret{
+		AppID:         appID,
+		SecretHash:    secretHash,
+		SigningKeyEnc: signingKeyEnc,
+		Prefix:        plaintext[:appSecretPrefixLength],
+		ExpiresAt:     expiresAt,
+	}
+	if err := tx.Create(secret).Error; err != nil {
+		return "", nil, fmt.Errorf("保存应用密钥失败: %v", err)
+	}
+	return plaintext, secret, nil
+}
+
+// RotateSecret 轮换应用密钥
+// 生成新密钥，原有效密钥在宽限期后失效；若已有两个有效密钥，较早的一个立即失效
+func (s *AppSecretService) RotateSecret(appID string, gracePeriod int) (*RotateAppSecretResponse, error) {
+	var app models.Application
+	if err := config.DB.Where("app_id = ?", appID).First(&app).Error; err != nil {
+		return nil, errors.New("应用不存在")
+	}
+
+	var plaintext string
+	var secret *models.AppSecret
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var active []models.AppSecret
+		if err := activeSecrets(tx, appID, now).Order("id DESC").Find(&active).Error; err != nil {
+			return err
+		}
+
+		for id, expiresAt := range RotatedSecretExpiries(active, now, gracePeriod) {
+			if err := tx.Model(&models.AppSecret{}).Where("id = ?", id).Update("expires_at", expiresAt).Error; err != nil {
+				return err
+			}
+		}
+
+		var err error
+		plaintext, secret, err = s.CreateSecret(tx, appID, nil)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("轮换应用密钥失败: %v", err)
+	}
+
+	active, err := s.ListSecrets(appID)
+	if err != nil {
+		return nil, err
+	}
+	return &RotateAppSecretResponse{AppSecret: plaintext, Secret: secret, Active: active}, nil
+}
+
+// RotatedSecretExpiries 轮换时原有效密钥（按 id 倒序）的新失效时间，键为密钥ID
+// 旧密钥在宽限期后失效，新密钥加入后超出上限的立即失效；已会更早失效的密钥不延长
+func RotatedSecretExpiries(active []models.AppSecret, now time.Time, gracePeriod int) map[uint]time.Time {
+	graceEnd := now.Add(time.Duration(gracePeriod) * time.Second)
+	expiries := make(map[uint]time.Time)
+	for i, old := range active {
+		expiresAt := graceEnd
+		if i >= maxActiveAppSecrets-1 {
+			expiresAt = now
+		}
+		if old.ExpiresAt != nil && old.ExpiresAt.Before(expiresAt) {
+			continue
+		}
+		expiries[old.ID] = expiresAt
+	}
+	return expiries
+}
+
+// ListSecrets 获取应用当前有效的密钥（不含明文和哈希）
+func (s *AppSecretService) ListSecrets(appID string) ([]models.AppSecret, error) {
+	var secrets []models.AppSecret
+	if err := activeSecrets(config.DB, appID, time.Now()).Order("id DESC").Find(&secrets).Error; err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// RevokeSecret 立即吊销应用密钥，不允许吊销最后一个有效密钥
+func (s *AppSecretService) RevokeSecret(appID string, secretID uint) error {
+	now := time.Now()
+	var secret models.AppSecret
+	if err := activeSecrets(config.DB, appID, now).Where("id = ?", secretID).First(&secret).Error; err != nil {
+		return errors.New("密钥不存在或已失效")
+	}
+
+	var count int64
+	activeSecrets(config.DB, appID, now).Model(&models.AppSecret{}).Count(&count)
+	if count <= 1 {
+		return ErrLastActiveAppSecret
+	}
+
+	return config.DB.Model(&secret).Update("expires_at", now).Error
+}
+
+// VerifyAppSecret 验证应用密钥，并记录密钥最近使用时间
+func VerifyAppSecret(appID, plaintext string) bool {
+	if appID == "" || plaintext == "" {
+		return false
+	}
+
+	now := time.Now()
+	var secret models.AppSecret
+	if err := activeSecrets(config.DB, appID, now).
+		Where("secret_hash = ?", utils.HashAppSecret(plaintext)).First(&secret).Error; err != nil {
+		return false
+	}
+
+	touchAppSecret(&secret, now)
+	return true
+}
+
+// VerifyAppSignature 使用应用的有效密钥校验请求签名，并记录密钥最近使用时间
+// 签名密钥由应用密钥派生、加密保存，轮换期间新旧密钥签名均可通过；没有签名密钥的旧密钥不能用于签名
+func VerifyAppSignature(appID, signature, method, requestURI, timestamp, nonce string, body []byte) bool {
+	now := time.Now()
+	var secrets []models.AppSecret
+	if err := activeSecrets(config.DB, appID, now).Where("signing_key_enc <> ''").Find(&secrets).Error; err != nil {
+		return false
+	}
+
+	for i := range secrets {
+		signingKey, err := utils.DecryptSigningKey(secrets[i].SigningKeyEnc, secrets[i].SecretHash)
+		if err != nil {
+			log.Printf("应用 %s 的签名密钥 %d 解密失败: %v", appID, secrets[i].ID, err)
+			continue
+		}
+		if utils.VerifyRequestSignature(signingKey, signature, method, requestURI, timestamp, nonce, body) {
+			touchAppSecret(&secrets[i], now)
+			return true
+		}
+	}
+	return false
+}
+
+// AppSecretUseDue 是否需要更新密钥最近使用时间（距上次记录超过最小间隔）
+func AppSecretUseDue(lastUsedAt *time.Time, now time.Time) bool {
+	return lastUsedAt == nil || now.Sub(*lastUsedAt) >= secretLastUsedInterval
+}
+
+// touchAppSecret 更新密钥最近使用时间（按最小间隔节流）
+func touchAppSecret(secret *models.AppSecret, now time.Time) {
+	if !AppSecretUseDue(secret.LastUsedAt, now) {
+		return
+	}
+	if err := config.DB.Model(secret).Update("last_used_at", now).Error; err != nil {
+		log.Printf("更新密钥最近使用时间失败: %v", err)
+	}
+}
+
+// MigrateLegacyAppSecrets 将 applications 表中的明文密钥迁移为哈希和加密的签名密钥，并清空明文
+func MigrateLegacyAppSecrets() error {
+	var apps []models.Application
+	if err := config.DB.Unscoped().Where("app_secret <> ''").Find(&apps).Error; err != nil {
+		return err
+	}
+
+	for _, app := range apps {
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			prefix := app.AppSecret
+			if len(prefix) > appSecretPrefixLength {
+				prefix = prefix[:appSecretPrefixLength]
+			}
+			secretHash := utils.HashAppSecret(app.AppSecret)
+			signingKeyEnc, err := utils.EncryptSigningKey(utils.DeriveSigningKey(app.AppSecret), secretHash)
+			if err != nil {
+				return err
+			}
+			secret := models.AppSecret{
+				AppID:      app.AppID,
+				SecretHash: secretHash,
+				Prefix:     prefix,
+			}
+			if err := tx.Where("secret_hash = ?", secretHash).FirstOrCreate(&secret).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&secret).Update("signing_key_enc", signingKeyEnc).Error; err != nil {
+				return err
+			}
+			return tx.Model(&models.Application{}).Unscoped().Where("id = ?", app.ID).Update("app_secret", "").Error
+		})
+		if err != nil {
+			return fmt.Errorf("迁移应用 %s 的密钥失败: %v", app.AppID, err)
+		}
+	}
+	return nil
+}
+
+// activeSecrets 有效密钥查询条件
+func activeSecrets(db *gorm.DB, appID string, now time.Time) *gorm.DB {
+	return db.Where("app_id = ? AND (expires_at IS NULL OR expires_at > ?)", appID, now)
+}
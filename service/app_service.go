@@ -9,6 +9,8 @@ import (
 
 	"auth-center/config"
 	"auth-center/models"
+
+	"gorm.io/gorm"
 )
 
 // AppService 应用服务
@@ -71,22 +73,25 @@ func (s *AppService) CreateApp(req *CreateAppRequest) (*CreateAppResponse, error
 		appID = s.generateAppID()
 	}
 
-	// 生成应用密钥
-	appSecret, err := GenerateAppSecret()
-	if err != nil {
-		return nil, err
-	}
-
-	// 创建应用
+	// 创建应用，密钥仅保存哈希
 	app := &models.Application{
 		Name:        req.Name,
 		AppID:       appID,
-		AppSecret:   appSecret,
 		Description: req.Description,
 		Status:      1,
 	}
 
-	if err := config.DB.Create(app).Error; err != nil {
+	var appSecret string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(app).Error; err != nil {
+			return err
+		}
+		secretService := &AppSecretService{}
+		var err error
+		appSecret, _, err = secretService.CreateSecret(tx, appID, nil)
+		return err
+	})
+	if err != nil {
 		// 如果因为唯一索引冲突（软删除未排除），返回更友好的错误
 		if strings.Contains(err.Error(), "Duplicate entry") {
 			return nil, fmt.Errorf("应用名称已存在: %s", req.Name)
@@ -98,7 +103,7 @@ func (s *AppService) CreateApp(req *CreateAppRequest) (*CreateAppResponse, error
 		ID:          app.ID,
 		Name:        app.Name,
 		AppID:       app.AppID,
-		AppSecret:   app.AppSecret,
+		AppSecret:   appSecret,
 		Description: app.Description,
 		Status:      app.Status,
 		CreatedAt:   app.CreatedAt.Format("2006-01-02 15:04:05"),
@@ -197,25 +202,14 @@ func (s *AppService) ListApps(page, pageSize int) ([]AppListResponse, int64, err
 	return responses, total, nil
 }
 
-// RegenerateAppSecret 重新生成应用密钥
+// RegenerateAppSecret 重新生成应用密钥，旧密钥在默认宽限期后失效
 func (s *AppService) RegenerateAppSecret(appID string) (string, error) {
-	var app models.Application
-	if err := config.DB.Where("app_id = ?", appID).First(&app).Error; err != nil {
-		return "", fmt.Errorf("应用不存在")
-	}
-
-	// 生成新的密钥
-	newSecret, err := GenerateAppSecret()
+	secretService := &AppSecretService{}
+	result, err := secretService.RotateSecret(appID, DefaultSecretGracePeriod)
 	if err != nil {
 		return "", err
 	}
-
-	// 更新密钥
-	if err := config.DB.Model(&app).Update("app_secret", newSecret).Error; err != nil {
-		return "", fmt.Errorf("更新密钥失败: %v", err)
-	}
-
-	return newSecret, nil
+	return result.AppSecret, nil
 }
 
 // GenerateAppSecret 生成应用密钥
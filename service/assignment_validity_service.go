@@ -0,0 +1,268 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"auth-center/config"
+	"auth-center/models"
+	"auth-center/utils"
+
+	"gorm.io/gorm"
+)
+
+// 角色分配（UserRole）和角色的权限分配（RolePermission）可以设置有效期：
+// 有效期外的分配在权限判定和签发令牌时忽略；到期的分配由定时任务删除，
+// 用户的角色分配到期时撤销其令牌（令牌中带有角色列表），并发出分配事件
+
+// 分配事件类型
+const (
+	AssignmentEventUserRoleActivated       = "user_role.activated"
+	AssignmentEventUserRoleExpired         = "user_role.expired"
+	AssignmentEventRolePermissionActivated = "role_permission.activated"
+	AssignmentEventRolePermissionExpired   = "role_permission.expired"
+)
+
+// assignmentSchedulerLastRunKey 上次检查的时间，用于找出在两次检查之间生效的分配
+const assignmentSchedulerLastRunKey = "assignment:scheduler:last_run"
+
+// assignmentSchedulerLockKey 多副本部署时只有一个副本执行检查
+const assignmentSchedulerLockKey = "assignment:scheduler:lock"
+
+// AssignmentValidity 分配的有效期，为空表示不限
+type AssignmentValidity struct {
+	ValidFrom  *time.Time `json:"valid_from,omitempty"`
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+}
+
+// Validate 失效时间须晚于生效时间和当前时间
+func (v AssignmentValidity) Validate() error {
+	if v.ValidUntil == nil {
+		return nil
+	}
+	if v.ValidFrom != nil && !v.ValidUntil.After(*v.ValidFrom) {
+		return errors.New("失效时间必须晚于生效时间")
+	}
+	if !v.ValidUntil.After(time.Now()) {
+		return errors.New("失效时间必须晚于当前时间")
+	}
+	return nil
+}
+
+// IsZero 是否未设置有效期
+func (v AssignmentValidity) IsZero() bool {
+	return v.ValidFrom == nil && v.ValidUntil == nil
+}
+
+// activeAssignments 只保留在 now 时有效的分配，table 为 user_roles 或 role_permissions
+func activeAssignments(table string, now time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(fmt.Sprintf("(%[1]s.valid_from IS NULL OR %[1]s.valid_from <= ?) AND (%[1]s.valid_until IS NULL OR %[1]s.valid_until > ?)", table), now, now)
+	}
+}
+
+// nextAssignmentChange 用户的角色分配或角色（含继承的父角色）的权限分配在 now 之后最早一次生效或失效的时间，没有时为零值
+func nextAssignmentChange(userID uint, appID string, roleIDs []uint, now time.Time) (time.Time, error) {
+	var bounds []AssignmentValidity
+	if err := config.DB.Model(&models.UserRole{}).
+		Where("user_id = ? AND app_id = ? AND (valid_from > ? OR valid_until > ?)", userID, appID, now, now).
+		Select("valid_from", "valid_until").Find(&bounds).Error; err != nil {
+		return time.Time{}, err
+	}
+	if len(roleIDs) > 0 {
+		var roleBounds []AssignmentValidity
+		if err := config.DB.Model(&models.RolePermission{}).
+			Where("role_id IN ? AND app_id = ? AND (valid_from > ? OR valid_until > ?)", roleIDs, appID, now, now).
+			Select("valid_from", "valid_until").Find(&roleBounds).Error; err != nil {
+			return time.Time{}, err
+		}
+		bounds = append(bounds, roleBounds...)
+	}
+	return EarliestAssignmentChange(bounds, now), nil
+}
+
+// EarliestAssignmentChange 一组有效期中在 now 之后最早的生效或失效时间，没有时为零值
+func EarliestAssignmentChange(bounds []AssignmentValidity, now time.Time) time.Time {
+	var next time.Time
+	for _, bound := range bounds {
+		for _, t := range []*time.Time{bound.ValidFrom, bound.ValidUntil} {
+			if t != nil && t.After(now) && (next.IsZero() || t.Before(next)) {
+				next = *t
+			}
+		}
+	}
+	return next
+}
+
+// AssignmentEvent 分配生效或到期事件，发布到 Redis 频道 assignment:events 并写入审计日志
+type AssignmentEvent struct {
+	Type         string     `json:"type"`
+	AppID        string     `json:"app_id"`
+	UserID       uint       `json:"user_id,omitempty"`
+	RoleID       uint       `json:"role_id"`
+	PermissionID uint       `json:"permission_id,omitempty"`
+	ValidFrom    *time.Time `json:"valid_from,omitempty"`
+	ValidUntil   *time.Time `json:"valid_until,omitempty"`
+	Time         time.Time  `json:"time"`
+}
+
+// emitAssignmentEvent 发出分配事件
+func emitAssignmentEvent(event *AssignmentEvent) {
+	targetType, targetID := "role", strconv.FormatUint(uint64(event.RoleID), 10)
+	if event.UserID != 0 {
+		targetType, targetID = "user", strconv.FormatUint(uint64(event.UserID), 10)
+	}
+	RecordAudit(&models.AuditLog{
+		ActorType:  AuditActorSystem,
+		AppID:      event.AppID,
+		Action:     event.Type,
+		TargetType: targetType,
+		TargetID:   targetID,
+		After:      AuditSnapshot(event),
+	})
+
+	message, _ := json.Marshal(event)
+	if err := utils.Publish(utils.AssignmentEventChannel, message); err != nil {
+		log.Printf("发布分配事件失败: %v", err)
+	}
+}
+
+// StartAssignmentScheduler 在后台定期删除到期的分配、撤销受影响的令牌并发出分配事件
+func StartAssignmentScheduler() {
+	interval := config.GetConfig().Permission.AssignmentCheckInterval
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			// 锁在下次检查前过期
+			locked, err := utils.SetNX(assignmentSchedulerLockKey, permissionCacheInstanceID, time.Duration(interval)*time.Second/2)
+			if err != nil || !locked {
+				continue
+			}
+			if err := processAssignmentSchedule(time.Now()); err != nil {
+				log.Printf("处理分配有效期失败: %v", err)
+			}
+		}
+	}()
+}
+
+// processAssignmentSchedule 处理上次检查以来生效和到期的分配
+func processAssignmentSchedule(now time.Time) error {
+	since := now.Add(-time.Duration(config.GetConfig().Permission.AssignmentCheckInterval) * time.Second)
+	if value, err := utils.Get(assignmentSchedulerLastRunKey); err == nil {
+		if nanos, err := strconv.ParseInt(value, 10, 64); err == nil {
+			since = time.Unix(0, nanos)
+		}
+	}
+
+	if err := activateAssignments(since, now); err != nil {
+		return err
+	}
+	if err := expireUserRoles(now); err != nil {
+		return err
+	}
+	if err := expireAccessRequests(now); err != nil {
+		return err
+	}
+	if err := expireRolePermissions(now); err != nil {
+		return err
+	}
+	return utils.Set(assignmentSchedulerLastRunKey, now.UnixNano(), 0)
+}
+
+// activateAssignments 为在 (since, now] 内生效的分配发出事件，并使相关的权限缓存失效
+func activateAssignments(since, now time.Time) error {
+	cacheService := &PermissionCacheService{}
+
+	var userRoles []models.UserRole
+	if err := config.DB.Where("valid_from > ? AND valid_from <= ?", since, now).
+		Scopes(activeAssignments("user_roles", now)).Find(&userRoles).Error; err != nil {
+		return err
+	}
+	for _, ur := range userRoles {
+		cacheService.InvalidateUser(ur.AppID, ur.UserID)
+		emitAssignmentEvent(&AssignmentEvent{
+			Type: AssignmentEventUserRoleActivated, AppID: ur.AppID, UserID: ur.UserID, RoleID: ur.RoleID,
+			ValidFrom: ur.ValidFrom, ValidUntil: ur.ValidUntil, Time: now,
+		})
+	}
+
+	var rolePermissions []models.RolePermission
+	if err := config.DB.Where("valid_from > ? AND valid_from <= ?", since, now).
+		Scopes(activeAssignments("role_permissions", now)).Find(&rolePermissions).Error; err != nil {
+		return err
+	}
+	invalidated := make(map[string]bool)
+	for _, rp := range rolePermissions {
+		if key := fmt.Sprintf("%s:%d", rp.AppID, rp.RoleID); !invalidated[key] {
+			invalidated[key] = true
+			cacheService.InvalidateRole(rp.AppID, rp.RoleID)
+		}
+		emitAssignmentEvent(&AssignmentEvent{
+			Type: AssignmentEventRolePermissionActivated, AppID: rp.AppID, RoleID: rp.RoleID, PermissionID: rp.PermissionID,
+			ValidFrom: rp.ValidFrom, ValidUntil: rp.ValidUntil, Time: now,
+		})
+	}
+	return nil
+}
+
+// expireUserRoles 删除到期的角色分配，撤销用户的令牌（令牌中的角色列表已过时）
+func expireUserRoles(now time.Time) error {
+	var userRoles []models.UserRole
+	if err := config.DB.Where("valid_until <= ?", now).Find(&userRoles).Error; err != nil {
+		return err
+	}
+
+	cacheService := &PermissionCacheService{}
+	revoked := make(map[string]bool)
+	for _, ur := range userRoles {
+		if err := config.DB.Delete(&models.UserRole{}, ur.ID).Error; err != nil {
+			return err
+		}
+		if key := fmt.Sprintf("%s:%d", ur.AppID, ur.UserID); !revoked[key] {
+			revoked[key] = true
+			cacheService.InvalidateUser(ur.AppID, ur.UserID)
+			if err := RevokeUserSessions(ur.AppID, ur.UserID); err != nil {
+				log.Printf("撤销用户 %d 的令牌失败: %v", ur.UserID, err)
+			}
+		}
+		emitAssignmentEvent(&AssignmentEvent{
+			Type: AssignmentEventUserRoleExpired, AppID: ur.AppID, UserID: ur.UserID, RoleID: ur.RoleID,
+			ValidFrom: ur.ValidFrom, ValidUntil: ur.ValidUntil, Time: now,
+		})
+	}
+	return nil
+}
+
+// expireRolePermissions 删除到期的角色权限分配
+func expireRolePermissions(now time.Time) error {
+	var rolePermissions []models.RolePermission
+	if err := config.DB.Where("valid_until <= ?", now).Find(&rolePermissions).Error; err != nil {
+		return err
+	}
+
+	cacheService := &PermissionCacheService{}
+	invalidated := make(map[string]bool)
+	for _, rp := range rolePermissions {
+		if err := config.DB.Delete(&models.RolePermission{}, rp.ID).Error; err != nil {
+			return err
+		}
+		if key := fmt.Sprintf("%s:%d", rp.AppID, rp.RoleID); !invalidated[key] {
+			invalidated[key] = true
+			cacheService.InvalidateRole(rp.AppID, rp.RoleID)
+		}
+		emitAssignmentEvent(&AssignmentEvent{
+			Type: AssignmentEventRolePermissionExpired, AppID: rp.AppID, RoleID: rp.RoleID, PermissionID: rp.PermissionID,
+			ValidFrom: rp.ValidFrom, ValidUntil: rp.ValidUntil, Time: now,
+		})
+	}
+	return nil
+}
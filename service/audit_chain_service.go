@@ -0,0 +1,388 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"auth-center/config"
+	"auth-center/models"
+	"auth-center/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// auditBatchSize 校验与导出时每批读取的记录数
+const auditBatchSize = 1000
+
+// auditChainHeadID 链尾记录的主键
+const auditChainHeadID = 1
+
+var (
+	auditKeyOnce sync.Once
+	auditKey     ed25519.PrivateKey
+	auditKeyErr  error
+)
+
+// AuditRange 审计日志范围（按时间），空值表示不限
+type AuditRange struct {
+	StartTime *time.Time
+	EndTime   *time.Time
+}
+
+// AuditVerifyResult 审计链校验结果
+type AuditVerifyResult struct {
+	Valid       bool               `json:"valid"`
+	FirstSeq    uint64             `json:"first_seq"`
+	LastSeq     uint64             `json:"last_seq"`
+	Checked     int                `json:"checked"`
+	Checkpoints int                `json:"checkpoints"`
+	KeyID       string             `json:"key_id"`
+	PublicKey   string             `json:"public_key"` // 检查点签名公钥（base64），可用于离线校验导出文件
+	Issues      []utils.AuditIssue `json:"issues"`
+}
+
+// AuditSigningKey 读取检查点签名私钥（首次调用时加载）。优先使用配置的密钥内容，否则读取密钥文件；
+// 不会自动生成密钥，多副本部署时所有副本须配置同一密钥，检查点才能用同一公钥校验
+func AuditSigningKey() (ed25519.PrivateKey, error) {
+	auditKeyOnce.Do(func() {
+		cfg := config.GetConfig().Audit
+		if cfg.SigningKey != "" {
+			auditKey, auditKeyErr = utils.ParseEd25519Key([]byte(cfg.SigningKey))
+			return
+		}
+		auditKey, auditKeyErr = utils.LoadEd25519Key(cfg.SigningKeyFile)
+	})
+	return auditKey, auditKeyErr
+}
+
+// lockAuditChainHead 在事务中锁定链尾记录，其他事务（包括其他副本）须等待本事务结束才能追加。
+// 链尾记录不存在时以现有的最后一条审计日志初始化，并发初始化时只有一个插入生效
+func lockAuditChainHead(tx *gorm.DB) (*models.AuditChainHead, error) {
+	var head models.AuditChainHead
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", auditChainHeadID).Limit(1).Find(&head).Error; err != nil {
+		return nil, err
+	}
+	if head.ID != 0 {
+		return &head, nil
+	}
+
+	var last models.AuditLog
+	if err := tx.Where("hash <> ''").Order("seq DESC").Limit(1).Find(&last).Error; err != nil {
+		return nil, err
+	}
+	head = models.AuditChainHead{ID: auditChainHeadID, Seq: last.Seq, Hash: last.Hash}
+	if last.Seq == 0 {
+		head.Hash = utils.GenesisAuditHash
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&head).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", auditChainHeadID).First(&head).Error; err != nil {
+		return nil, err
+	}
+	return &head, nil
+}
+
+// appendAuditLog 将审计日志追加到哈希链末尾：锁定链尾记录，分配下一个序号并计算哈希，再推进链尾。
+// 行锁只在这个短事务内持有
+func appendAuditLog(entry *models.AuditLog) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		head, err := lockAuditChainHead(tx)
+		if err != nil {
+			return err
+		}
+
+		entry.Seq = head.Seq + 1
+		entry.PrevHash = head.Hash
+		entry.Hash = utils.AuditLogHash(entry)
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+		return tx.Model(head).Updates(map[string]interface{}{"seq": entry.Seq, "hash": entry.Hash}).Error
+	})
+}
+
+// MigrateAuditChain 为启用哈希链之前写入的审计日志补齐序号和哈希（按写入顺序接在链尾）
+func MigrateAuditChain() error {
+	var legacy []models.AuditLog
+	if err := config.DB.Where("hash = '' OR hash IS NULL").Order("id").Find(&legacy).Error; err != nil {
+		return err
+	}
+	if len(legacy) == 0 {
+		return nil
+	}
+
+	return config.DB.Session(&gorm.Session{SkipHooks: true}).Transaction(func(tx *gorm.DB) error {
+		head, err := lockAuditChainHead(tx)
+		if err != nil {
+			return err
+		}
+
+		prevSeq, prevHash := head.Seq, head.Hash
+		for i := range legacy {
+			entry := &legacy[i]
+			entry.CreatedAt = entry.CreatedAt.Truncate(time.Millisecond)
+			entry.Seq = prevSeq + 1
+			entry.PrevHash = prevHash
+			entry.Hash = utils.AuditLogHash(entry)
+			if err := tx.Model(entry).Updates(map[string]interface{}{
+				"seq":       entry.Seq,
+				"prev_hash": entry.PrevHash,
+				"hash":      entry.Hash,
+			}).Error; err != nil {
+				return err
+			}
+			prevSeq, prevHash = entry.Seq, entry.Hash
+		}
+		if err := tx.Model(head).Updates(map[string]interface{}{"seq": prevSeq, "hash": prevHash}).Error; err != nil {
+			return err
+		}
+		log.Printf("已为 %d 条审计日志补齐哈希链", len(legacy))
+		return nil
+	})
+}
+
+// CreateAuditCheckpoint 对最新一条审计日志的哈希签名生成检查点，自上次检查点以来没有新记录时返回 nil, nil
+func CreateAuditCheckpoint() (*models.AuditCheckpoint, error) {
+	key, err := AuditSigningKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var last models.AuditLog
+	if err := config.DB.Order("seq DESC").Limit(1).Find(&last).Error; err != nil {
+		return nil, err
+	}
+	if last.Seq == 0 {
+		return nil, nil
+	}
+
+	var latest models.AuditCheckpoint
+	if err := config.DB.Order("seq DESC").Limit(1).Find(&latest).Error; err != nil {
+		return nil, err
+	}
+	if latest.ID != 0 && latest.Seq >= last.Seq {
+		return nil, nil
+	}
+
+	checkpoint := &models.AuditCheckpoint{
+		Seq:       last.Seq,
+		Hash:      last.Hash,
+		CreatedAt: time.Now().Truncate(time.Millisecond),
+	}
+	utils.SignAuditCheckpoint(key, checkpoint)
+	if err := config.DB.Create(checkpoint).Error; err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+// StartAuditCheckpointer 按配置的间隔在后台定期生成签名检查点
+func StartAuditCheckpointer() {
+	interval := config.GetConfig().Audit.CheckpointInterval
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := CreateAuditCheckpoint(); err != nil {
+				log.Printf("生成审计检查点失败: %v", err)
+			}
+		}
+	}()
+}
+
+// auditSeqRange 将时间范围换算为序号范围，范围内没有记录时 ok 为 false
+func auditSeqRange(r *AuditRange) (first, last uint64, ok bool, err error) {
+	db := config.DB.Model(&models.AuditLog{}).Where("seq > 0")
+	if r.StartTime != nil {
+		db = db.Where("created_at >= ?", *r.StartTime)
+	}
+	if r.EndTime != nil {
+		db = db.Where("created_at <= ?", *r.EndTime)
+	}
+
+	var bounds struct {
+		First *uint64
+		Last  *uint64
+	}
+	if err := db.Select("MIN(seq) AS first, MAX(seq) AS last").Scan(&bounds).Error; err != nil {
+		return 0, 0, false, err
+	}
+	if bounds.First == nil || bounds.Last == nil {
+		return 0, 0, false, nil
+	}
+	return *bounds.First, *bounds.Last, true, nil
+}
+
+// eachAuditLog 按序号顺序分批遍历 [first, last] 范围内的审计日志（同一序号出现多次时全部返回）
+func eachAuditLog(first, last uint64, fn func(entry *models.AuditLog) error) error {
+	lastSeq, lastID := first, uint(0)
+	for {
+		var batch []models.AuditLog
+		err := config.DB.Where("seq <= ? AND (seq > ? OR (seq = ? AND id > ?))", last, lastSeq, lastSeq, lastID).
+			Order("seq, id").Limit(auditBatchSize).Find(&batch).Error
+		if err != nil {
+			return err
+		}
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+			lastSeq, lastID = batch[i].Seq, batch[i].ID
+		}
+		if len(batch) < auditBatchSize {
+			return nil
+		}
+	}
+}
+
+// auditCheckpoints 读取 [first, last] 范围内的检查点，last 为 0 表示不限上界
+func auditCheckpoints(first, last uint64) ([]models.AuditCheckpoint, error) {
+	db := config.DB.Where("seq >= ?", first)
+	if last != 0 {
+		db = db.Where("seq <= ?", last)
+	}
+	var checkpoints []models.AuditCheckpoint
+	if err := db.Order("seq, id").Find(&checkpoints).Error; err != nil {
+		return nil, err
+	}
+	return checkpoints, nil
+}
+
+// VerifyAuditChain 校验指定时间范围内的审计日志：逐条重算哈希、检查序号连续性和前后链接，并校验检查点签名
+// 不限结束时间时，同时校验之后的检查点，以发现链尾被截断的情况
+func (s *AuditService) VerifyAuditChain(r *AuditRange) (*AuditVerifyResult, error) {
+	key, err := AuditSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	pub := key.Public().(ed25519.PublicKey)
+	result := &AuditVerifyResult{
+		KeyID:     utils.AuditKeyID(pub),
+		PublicKey: base64.StdEncoding.EncodeToString(pub),
+		Issues:    []utils.AuditIssue{},
+	}
+
+	first, last, ok, err := auditSeqRange(r)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		result.Valid = true
+		if r.StartTime == nil && r.EndTime == nil {
+			// 没有记录但存在检查点，说明记录被整体删除
+			var count int64
+			config.DB.Model(&models.AuditCheckpoint{}).Count(&count)
+			if count > 0 {
+				result.Valid = false
+				result.Issues = append(result.Issues, utils.AuditIssue{Type: utils.AuditIssueMissingRecord, Message: "存在检查点但没有审计日志"})
+			}
+		}
+		return result, nil
+	}
+	result.FirstSeq, result.LastSeq = first, last
+
+	verifier := utils.NewAuditChainVerifier(pub)
+
+	// 以范围之前的一条记录为起点
+	if first > 1 {
+		var prev models.AuditLog
+		if err := config.DB.Where("seq = ?", first-1).Order("id").Limit(1).Find(&prev).Error; err != nil {
+			return nil, err
+		}
+		if prev.ID != 0 {
+			verifier.Anchor(prev.Seq, prev.Hash)
+		} else {
+			result.Issues = append(result.Issues, utils.AuditIssue{Seq: first - 1, Type: utils.AuditIssueGap, Message: "范围之前的记录不存在"})
+		}
+	}
+
+	checkpointLimit := last
+	if r.EndTime == nil {
+		checkpointLimit = 0
+	}
+	checkpoints, err := auditCheckpoints(first, checkpointLimit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range checkpoints {
+		verifier.AddCheckpoint(&checkpoints[i])
+	}
+	result.Checkpoints = len(checkpoints)
+
+	if err := eachAuditLog(first, last, func(entry *models.AuditLog) error {
+		verifier.Add(entry)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	result.Issues = append(result.Issues, verifier.Finish(checkpointLimit)...)
+	result.Checked = verifier.Checked
+	result.Valid = len(result.Issues) == 0
+	return result, nil
+}
+
+// ExportAuditLogs 导出指定时间范围内的审计日志（JSON Lines 或 CSV）
+// 依次写出签名公钥、范围内的检查点和审计日志（含序号、PrevHash、Hash），可使用 cmd/auditverify 离线校验
+func (s *AuditService) ExportAuditLogs(w io.Writer, format string, r *AuditRange) error {
+	key, err := AuditSigningKey()
+	if err != nil {
+		return err
+	}
+	pub := key.Public().(ed25519.PublicKey)
+
+	writer, err := utils.NewAuditExportWriter(w, format)
+	if err != nil {
+		return err
+	}
+	if err := writer.Write(&utils.AuditExportRecord{
+		Type:      utils.AuditRecordKey,
+		KeyID:     utils.AuditKeyID(pub),
+		PublicKey: base64.StdEncoding.EncodeToString(pub),
+	}); err != nil {
+		return err
+	}
+
+	first, last, ok, err := auditSeqRange(r)
+	if err != nil {
+		return err
+	}
+	if ok {
+		checkpoints, err := auditCheckpoints(first, last)
+		if err != nil {
+			return err
+		}
+		for i := range checkpoints {
+			if err := writer.Write(&utils.AuditExportRecord{Type: utils.AuditRecordCheckpoint, Checkpoint: &checkpoints[i]}); err != nil {
+				return err
+			}
+		}
+
+		if err := eachAuditLog(first, last, func(entry *models.AuditLog) error {
+			return writer.Write(&utils.AuditExportRecord{Type: utils.AuditRecordEntry, Entry: entry})
+		}); err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
+
+// ValidAuditExportFormat 检查导出格式
+func ValidAuditExportFormat(format string) error {
+	if format != utils.AuditExportJSONL && format != utils.AuditExportCSV {
+		return errors.New("format 只支持 jsonl 或 csv")
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"auth-center/config"
+	"auth-center/models"
+)
+
+// 审计日志操作者类型
+const (
+	AuditActorSystemAdmin = "system_admin"
+	AuditActorUser        = "user"
+	AuditActorApp         = "app"
+	AuditActorAnonymous   = "anonymous"
+	AuditActorSystem      = "system" // 定时任务等由系统发起的操作
+)
+
+// 审计结果
+const (
+	AuditResultSuccess = "success"
+	AuditResultFailure = "failure"
+)
+
+// auditMaxPageSize 审计日志查询单页最大条数
+const auditMaxPageSize = 100
+
+// auditRedactedFields 快照与差异中需要隐藏的字段（按字段名包含关系匹配，不区分大小写）
+var auditRedactedFields = []string{"password", "secret", "token"}
+
+// AuditService 审计日志服务
+type AuditService struct{}
+
+// AuditQuery 审计日志查询条件，空值表示不过滤
+type AuditQuery struct {
+	AppID      string
+	ActorType  string
+	ActorID    uint
+	Action     string
+	TargetType string
+	TargetID   string
+	Result     string
+	IP         string
+	StartTime  *time.Time
+	EndTime    *time.Time
+	Page       int
+	PageSize   int
+}
+
+// RecordAudit 追加一条审计日志（接入哈希链），写入失败只记录错误日志，不影响业务
+func RecordAudit(entry *models.AuditLog) {
+	if entry.ActorType == "" {
+		entry.ActorType = AuditActorAnonymous
+	}
+	if entry.Result == "" {
+		entry.Result = AuditResultSuccess
+	}
+	entry.ID = 0
+	// 数据库时间精度为毫秒，哈希按存储后的值计算
+	entry.CreatedAt = time.Now().Truncate(time.Millisecond)
+	entry.ActorName = truncate(entry.ActorName, 191)
+	entry.AppID = truncate(entry.AppID, 191)
+	entry.Action = truncate(entry.Action, 191)
+	entry.TargetType = truncate(entry.TargetType, 64)
+	entry.TargetID = truncate(entry.TargetID, 191)
+	entry.Error = truncate(entry.Error, 1024)
+	entry.UserAgent = truncate(entry.UserAgent, 512)
+	entry.Path = truncate(entry.Path, 512)
+
+	if config.DB == nil {
+		return
+	}
+	if err := appendAuditLog(entry); err != nil {
+		log.Printf("写入审计日志失败: action=%s, err=%v", entry.Action, err)
+	}
+}
+
+// recordLoginAudit 记录登录结果
+func recordLoginAudit(action, actorType string, actorID uint, actorName, appID, ip, userAgent string, err error) {
+	entry := &models.AuditLog{
+		ActorType:  actorType,
+		ActorID:    actorID,
+		ActorName:  actorName,
+		AppID:      appID,
+		Action:     action,
+		TargetType: actorType,
+		IP:         ip,
+		UserAgent:  userAgent,
+		Result:     AuditResultSuccess,
+	}
+	if actorID != 0 {
+		entry.TargetID = strconv.FormatUint(uint64(actorID), 10)
+	}
+	if err != nil {
+		entry.Result = AuditResultFailure
+		entry.Error = err.Error()
+	}
+	RecordAudit(entry)
+}
+
+// AuditSnapshot 将对象序列化为审计快照（JSON），敏感字段替换为 "***"，nil 返回空字符串
+func AuditSnapshot(v interface{}) string {
+	fields := auditFields(v)
+	if fields == nil {
+		return ""
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// AuditDiff 计算变更前后的字段级差异（JSON）
+// after 可以是完整快照，也可以是只包含变更字段的 map；仅比较 after 中出现的字段，无差异时返回空字符串
+func AuditDiff(before, after interface{}) string {
+	beforeFields := auditFields(before)
+	afterFields := auditFields(after)
+	if beforeFields == nil || afterFields == nil {
+		return ""
+	}
+
+	diff := make(map[string]map[string]interface{})
+	for key, newValue := range afterFields {
+		oldValue := beforeFields[key]
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		diff[key] = map[string]interface{}{"before": oldValue, "after": newValue}
+	}
+	if len(diff) == 0 {
+		return ""
+	}
+
+	data, err := json.Marshal(diff)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// auditFields 将对象转换为字段 map（经 JSON 往返以统一数值类型），非对象返回 nil
+func auditFields(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+
+	for key := range fields {
+		lower := strings.ToLower(key)
+		for _, redacted := range auditRedactedFields {
+			if strings.Contains(lower, redacted) {
+				fields[key] = "***"
+				break
+			}
+		}
+	}
+	return fields
+}
+
+// ListAuditLogs 按条件分页查询审计日志（按时间倒序）
+func (s *AuditService) ListAuditLogs(query *AuditQuery) ([]models.AuditLog, int64, error) {
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 {
+		query.PageSize = 20
+	}
+	if query.PageSize > auditMaxPageSize {
+		query.PageSize = auditMaxPageSize
+	}
+
+	db := config.DB.Model(&models.AuditLog{})
+	if query.AppID != "" {
+		db = db.Where("app_id = ?", query.AppID)
+	}
+	if query.ActorType != "" {
+		db = db.Where("actor_type = ?", query.ActorType)
+	}
+	if query.ActorID != 0 {
+		db = db.Where("actor_id = ?", query.ActorID)
+	}
+	if query.Action != "" {
+		db = db.Where("action = ?", query.Action)
+	}
+	if query.TargetType != "" {
+		db = db.Where("target_type = ?", query.TargetType)
+	}
+	if query.TargetID != "" {
+		db = db.Where("target_id = ?", query.TargetID)
+	}
+	if query.Result != "" {
+		db = db.Where("result = ?", query.Result)
+	}
+	if query.IP != "" {
+		db = db.Where("ip = ?", query.IP)
+	}
+	if query.StartTime != nil {
+		db = db.Where("created_at >= ?", *query.StartTime)
+	}
+	if query.EndTime != nil {
+		db = db.Where("created_at <= ?", *query.EndTime)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var logs []models.AuditLog
+	offset := (query.Page - 1) * query.PageSize
+	if err := db.Order("id DESC").Offset(offset).Limit(query.PageSize).Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
+
+// truncate 按字符截断字符串
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
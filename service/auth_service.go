@@ -1,7 +1,10 @@
 package service
 
 import (
+	"crypto/subtle"
 	"errors"
+	"log"
+	"strconv"
 	"time"
 
 	"auth-center/config"
@@ -14,23 +17,90 @@ import (
 // AuthService 认证服务
 type AuthService struct{}
 
+// ErrRegisterFailed 注册时写入数据库失败（非用户名、邮箱冲突）
+var ErrRegisterFailed = errors.New("注册失败，请稍后重试")
+
+// ErrRateLimited 应用请求过于频繁（由 AppVerified 返回，响应已写入）
+var ErrRateLimited = errors.New("请求过于频繁，请稍后再试")
+
 // LoginRequest 登录请求
 type LoginRequest struct {
-	AppID     string `json:"app_id" binding:"required"`
-	AppSecret string `json:"app_secret" binding:"required"`
+	AppID     string `json:"app_id"`
+	AppSecret string `json:"app_secret"` // 使用请求签名时可省略
 	Username  string `json:"username"`
 	Password  string `json:"password"`
 	Phone     string `json:"phone"`
 	Code      string `json:"code"`
+	// DeviceID 客户端设备标识（可选），用于识别新设备登录；未提供时使用 User-Agent 摘要
+	DeviceID string `json:"device_id"`
+	// OrgID 登录后的当前组织（可选），用户须为该组织成员
+	OrgID uint `json:"org_id"`
+	// SignatureVerified 请求已通过应用签名校验，无需再校验应用密钥
+	SignatureVerified bool `json:"-"`
+	// ClientCert TLS 连接中出示的客户端证书，登记过的证书可代替应用密钥，签发的令牌绑定到该证书
+	ClientCert *utils.ClientCertInfo `json:"-"`
+	// DPoPJkt 已校验的 DPoP 证明公钥指纹，签发的令牌绑定到该公钥
+	DPoPJkt string `json:"-"`
+	// ClientIP、UserAgent 客户端信息，用于审计日志和登录历史
+	ClientIP  string `json:"-"`
+	UserAgent string `json:"-"`
+	// AppVerified 应用凭证校验通过后调用（如按应用维度限流），返回错误时中止请求
+	AppVerified func(appID string) error `json:"-"`
+}
+
+// LoginMFARequest 异常登录二次验证请求
+type LoginMFARequest struct {
+	AppID     string `json:"app_id"`
+	AppSecret string `json:"app_secret"` // 使用请求签名时可省略
+	MFAToken  string `json:"mfa_token" binding:"required"`
+	Code      string `json:"code" binding:"required"` // 登录要求二次验证时发送到账号绑定手机号的短信验证码
+	DeviceID  string `json:"device_id"`
+	OrgID     uint   `json:"org_id"` // 登录后的当前组织（可选）
+	// SignatureVerified 请求已通过应用签名校验，无需再校验应用密钥
+	SignatureVerified bool `json:"-"`
+	// ClientCert TLS 连接中出示的客户端证书，签发的令牌绑定到该证书
+	ClientCert *utils.ClientCertInfo `json:"-"`
+	// DPoPJkt 已校验的 DPoP 证明公钥指纹，签发的令牌绑定到该公钥
+	DPoPJkt string `json:"-"`
+	// ClientIP、UserAgent 客户端信息，用于审计日志和登录历史
+	ClientIP  string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+// ChangeExpiredPasswordRequest 密码过期时设置新密码并完成登录的请求
+type ChangeExpiredPasswordRequest struct {
+	AppID               string `json:"app_id"`
+	AppSecret           string `json:"app_secret"` // 使用请求签名时可省略
+	PasswordChangeToken string `json:"password_change_token" binding:"required"`
+	NewPassword         string `json:"new_password" binding:"required"`
+	// SignatureVerified 请求已通过应用签名校验，无需再校验应用密钥
+	SignatureVerified bool `json:"-"`
+	// ClientCert TLS 连接中出示的客户端证书，签发的令牌绑定到该证书
+	ClientCert *utils.ClientCertInfo `json:"-"`
+	// DPoPJkt 已校验的 DPoP 证明公钥指纹，签发的令牌绑定到该公钥
+	DPoPJkt string `json:"-"`
+	// ClientIP、UserAgent 客户端信息，用于审计日志
+	ClientIP  string `json:"-"`
+	UserAgent string `json:"-"`
 }
 
 // LoginResponse 登录响应
 type LoginResponse struct {
-	AccessToken  string   `json:"access_token"`
-	RefreshToken string   `json:"refresh_token"`
-	ExpiresIn    int64    `json:"expires_in"`
-	TokenType    string   `json:"token_type"`
+	AccessToken  string   `json:"access_token,omitempty"`
+	RefreshToken string   `json:"refresh_token,omitempty"`
+	ExpiresIn    int64    `json:"expires_in,omitempty"`
+	TokenType    string   `json:"token_type,omitempty"`
+	OrgID        uint     `json:"org_id,omitempty"` // 令牌的当前组织
 	User         UserInfo `json:"user"`
+	// MFARequired 检测到异常登录，需使用 MFAToken 和短信验证码调用 /auth/login/mfa 完成登录，此时不返回令牌
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+	// PasswordExpired 密码已超过应用策略规定的最长使用期限，此时不返回令牌，
+	// 需使用 PasswordChangeToken 调用 /auth/password/expired 设置新密码后完成登录
+	PasswordExpired     bool   `json:"password_expired,omitempty"`
+	PasswordChangeToken string `json:"password_change_token,omitempty"`
+	// PasswordCompromised 当前密码出现在泄露密码库中，客户端应要求用户修改密码
+	PasswordCompromised bool `json:"password_compromised,omitempty"`
 }
 
 // UserInfo 用户信息
@@ -52,17 +122,63 @@ type RoleInfo struct {
 
 // RegisterRequest 注册请求
 type RegisterRequest struct {
-	AppID     string `json:"app_id" binding:"required"`
-	AppSecret string `json:"app_secret" binding:"required"`
+	AppID     string `json:"app_id"`
+	AppSecret string `json:"app_secret"` // 使用请求签名时可省略
 	Username  string `json:"username" binding:"required"`
 	Email     string `json:"email"`
 	Phone     string `json:"phone"`
-	Password  string `json:"password" binding:"required,min=6"`
+	Password  string `json:"password" binding:"required"`
+	// SignatureVerified 请求已通过应用签名校验，无需再校验应用密钥
+	SignatureVerified bool `json:"-"`
+	// ClientCert TLS 连接中出示的客户端证书，登记过的证书可代替应用密钥
+	ClientCert *utils.ClientCertInfo `json:"-"`
+	// AppVerified 应用凭证校验通过后调用（如按应用维度限流），返回错误时中止请求
+	AppVerified func(appID string) error `json:"-"`
+}
+
+// ChangePasswordRequest 修改密码请求
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
 }
 
 // RefreshTokenRequest 刷新令牌请求
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
+	// ClientCert TLS 连接中出示的客户端证书，证书绑定的刷新令牌须出示相同证书
+	ClientCert *utils.ClientCertInfo `json:"-"`
+	// DPoPJkt 已校验的 DPoP 证明公钥指纹，DPoP 绑定的刷新令牌须使用相同公钥
+	DPoPJkt string `json:"-"`
+}
+
+// IntrospectRequest 令牌内省请求（RFC 7662），由应用的资源服务器调用
+type IntrospectRequest struct {
+	AppID         string `json:"app_id"`
+	AppSecret     string `json:"app_secret"` // 使用请求签名或客户端证书时可省略
+	Token         string `json:"token" binding:"required"`
+	TokenTypeHint string `json:"token_type_hint"` // access_token（默认）或 refresh_token
+	// DPoP 绑定令牌须转发客户端请求中的 DPoP 证明及其请求方法、地址
+	DPoPProof string `json:"dpop_proof"`
+	HTM       string `json:"htm"`
+	HTU       string `json:"htu"`
+
+	SignatureVerified bool                  `json:"-"`
+	ClientCert        *utils.ClientCertInfo `json:"-"`
+}
+
+// IntrospectResponse 令牌内省响应，令牌无效时仅返回 active=false
+type IntrospectResponse struct {
+	Active    bool                `json:"active"`
+	ClientID  string              `json:"client_id,omitempty"`
+	Sub       string              `json:"sub,omitempty"`
+	UserID    uint                `json:"user_id,omitempty"`
+	Roles     []uint              `json:"roles,omitempty"`
+	OrgID     uint                `json:"org_id,omitempty"`
+	TokenType string              `json:"token_type,omitempty"`
+	Exp       int64               `json:"exp,omitempty"`
+	Iat       int64               `json:"iat,omitempty"`
+	Jti       string              `json:"jti,omitempty"`
+	Cnf       *utils.Confirmation `json:"cnf,omitempty"`
 }
 
 // LogoutRequest 登出请求
@@ -72,11 +188,33 @@ type LogoutRequest struct {
 
 // Login 用户登录
 func (s *AuthService) Login(req *LoginRequest) (*LoginResponse, error) {
+	account := req.Username
+	if account == "" {
+		account = req.Phone
+	}
+	history := newLoginHistory(req.AppID, account, req.ClientIP, req.UserAgent, req.DeviceID)
+	response, err := s.login(req, history)
+
+	// 记录登录审计日志和登录历史（成功与失败）
+	recordLoginAudit("login", AuditActorUser, history.UserID, history.Account, req.AppID, req.ClientIP, req.UserAgent, err)
+	recordLoginHistory(history, response, err)
+
+	return response, err
+}
+
+// login 校验应用与用户凭证，评估异常登录规则后签发令牌；用户、登录方式和命中的规则写入 history
+func (s *AuthService) login(req *LoginRequest, history *models.LoginHistory) (*LoginResponse, error) {
 	// 验证应用是否存在且密钥正确
 	var app models.Application
-	if err := config.DB.Where("app_id = ? AND app_secret = ? AND status = 1", req.AppID, req.AppSecret).First(&app).Error; err != nil {
+	if err := config.DB.Where("app_id = ? AND status = 1", req.AppID).First(&app).Error; err != nil ||
+		!authenticateApp(req.AppID, req.AppSecret, req.SignatureVerified, req.ClientCert) {
 		return nil, errors.New("应用不存在、密钥错误或已禁用")
 	}
+	if req.AppVerified != nil {
+		if err := req.AppVerified(req.AppID); err != nil {
+			return nil, err
+		}
+	}
 
 	// 读取应用登录方式
 	loginMethod, err := s.getLoginMethod(req.AppID)
@@ -88,23 +226,34 @@ func (s *AuthService) Login(req *LoginRequest) (*LoginResponse, error) {
 	var user models.User
 	switch loginMethod {
 	case 0: // 账号密码登录
+		history.Method = LoginMethodPassword
 		if req.Username == "" || req.Password == "" {
 			return nil, errors.New("用户名与密码必填")
 		}
 		if err := config.DB.Where("username = ? AND app_id = ? AND status = 1", req.Username, req.AppID).First(&user).Error; err != nil {
 			return nil, errors.New("用户不存在或已禁用")
 		}
+		history.UserID = user.ID
 		valid, verr := utils.VerifyPassword(req.Password, user.Password)
 		if verr != nil || !valid {
 			return nil, errors.New("用户名或密码错误")
 		}
+		// 哈希算法或参数已过时则自动升级
+		upgradePasswordHash(&user, req.Password, user.Password)
+		// 检测当前密码是否已泄露，命中则标记用户
+		if shouldCheckBreachOnLogin() && !user.PasswordCompromised && IsPasswordBreached(req.Password) {
+			config.DB.Model(&user).Update("password_compromised", true)
+			user.PasswordCompromised = true
+		}
 	case 1: // 手机验证码登录
+		history.Method = LoginMethodSMS
 		if req.Phone == "" || req.Code == "" {
 			return nil, errors.New("手机号与验证码必填")
 		}
 		if err := config.DB.Where("phone = ? AND app_id = ? AND status = 1", req.Phone, req.AppID).First(&user).Error; err != nil {
 			return nil, errors.New("用户不存在或已禁用")
 		}
+		history.UserID = user.ID
 		if ok := s.verifyOTP(req.AppID, req.Phone, req.Code); !ok {
 			return nil, errors.New("验证码错误或已过期")
 		}
@@ -112,19 +261,113 @@ func (s *AuthService) Login(req *LoginRequest) (*LoginResponse, error) {
 		return nil, errors.New("不支持的登录方式")
 	}
 
+	// 评估异常登录规则，按应用策略撤销会话或要求二次验证
+	action := assessLoginRisk(history)
+	if action == LoginRiskActionRevoke {
+		if err := RevokeUserSessions(req.AppID, user.ID); err != nil {
+			return nil, err
+		}
+		// 撤销已有会话后本次登录仍可通过二次验证完成，验证通过后设备即被记录为已知设备
+		if user.Phone == "" {
+			return nil, ErrLoginSessionsRevoked
+		}
+		action = LoginRiskActionMFA
+	}
+	if action == LoginRiskActionMFA {
+		if user.Phone == "" {
+			return nil, ErrLoginMFAUnavailable
+		}
+		mfaToken, err := createLoginMFAChallenge(req.AppID, &user, history.Account)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResponse{
+			User:        UserInfo{ID: user.ID, Username: user.Username},
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		}, nil
+	}
+
+	return s.issueLoginTokens(&user, req.AppID, req.OrgID, buildConfirmation(req.ClientCert, req.DPoPJkt))
+}
+
+// LoginMFA 使用登录时发送的短信验证码完成异常登录的二次验证并签发令牌
+func (s *AuthService) LoginMFA(req *LoginMFARequest) (*LoginResponse, error) {
+	var app models.Application
+	if err := config.DB.Where("app_id = ? AND status = 1", req.AppID).First(&app).Error; err != nil ||
+		!authenticateApp(req.AppID, req.AppSecret, req.SignatureVerified, req.ClientCert) {
+		return nil, errors.New("应用不存在、密钥错误或已禁用")
+	}
+
+	history := newLoginHistory(req.AppID, "", req.ClientIP, req.UserAgent, req.DeviceID)
+	history.Method = LoginMethodMFA
+
+	var user models.User
+	challenge, err := consumeLoginMFAChallenge(req.AppID, req.MFAToken, req.Code)
+	if challenge != nil {
+		history.UserID, history.Account = challenge.UserID, challenge.Account
+	}
+	if err == nil {
+		if dbErr := config.DB.Where("id = ? AND app_id = ? AND status = 1", challenge.UserID, req.AppID).First(&user).Error; dbErr != nil {
+			err = errors.New("用户不存在或已禁用")
+		}
+	}
+	var response *LoginResponse
+	if err == nil {
+		response, err = s.issueLoginTokens(&user, req.AppID, req.OrgID, buildConfirmation(req.ClientCert, req.DPoPJkt))
+	}
+
+	recordLoginAudit("login_mfa", AuditActorUser, history.UserID, history.Account, req.AppID, req.ClientIP, req.UserAgent, err)
+	recordLoginHistory(history, response, err)
+	return response, err
+}
+
+// issueLoginTokens 为通过认证的用户签发令牌，出示了客户端证书或 DPoP 证明时令牌绑定到证书或公钥；
+// orgID 不为 0 时以该组织为当前组织，用户须为其成员。
+// 密码已过期时不签发令牌，返回修改密码令牌
+func (s *AuthService) issueLoginTokens(user *models.User, appID string, orgID uint, cnf *utils.Confirmation) (*LoginResponse, error) {
+	if (&PasswordPolicyService{}).IsPasswordExpired(appID, user.PasswordChangedAt) {
+		token, err := createPasswordChangeChallenge(&passwordChangeChallenge{
+			AccountType: AccountTypeUser,
+			AppID:       appID,
+			AccountID:   user.ID,
+			OrgID:       orgID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResponse{
+			User:                UserInfo{ID: user.ID, Username: user.Username},
+			PasswordExpired:     true,
+			PasswordChangeToken: token,
+		}, nil
+	}
+	return s.issueTokens(user, appID, orgID, cnf, time.Now().Add(time.Duration(config.GetConfig().JWT.RefreshTTL)*time.Second))
+}
+
+// issueTokens 签发访问令牌和在 refreshExpiresAt 过期的刷新令牌，密码已过期时返回 ErrPasswordExpired
+func (s *AuthService) issueTokens(user *models.User, appID string, orgID uint, cnf *utils.Confirmation, refreshExpiresAt time.Time) (*LoginResponse, error) {
+	if (&PasswordPolicyService{}).IsPasswordExpired(appID, user.PasswordChangedAt) {
+		return nil, ErrPasswordExpired
+	}
+	if orgID != 0 {
+		if err := checkOrganizationMember(appID, orgID, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
 	// 获取用户角色
-	roles, err := s.GetUserRoles(user.ID)
+	roles, err := s.GetUserRoles(user.ID, orgID)
 	if err != nil {
 		return nil, err
 	}
 
-	// 生成令牌
-	accessToken, err := utils.GenerateAccessToken(user.ID, req.AppID, roles)
+	accessToken, err := utils.GenerateOrgAccessToken(user.ID, appID, orgID, roles, cnf)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := utils.GenerateRefreshToken(user.ID, req.AppID)
+	refreshToken, err := utils.GenerateOrgRefreshTokenUntil(user.ID, appID, orgID, cnf, refreshExpiresAt)
 	if err != nil {
 		return nil, err
 	}
@@ -136,10 +379,10 @@ func (s *AuthService) Login(req *LoginRequest) (*LoginResponse, error) {
 	}
 
 	// 保存令牌到数据库
-	if err := s.saveToken(user.ID, req.AppID, accessToken, "access"); err != nil {
+	if err := s.saveToken(user.ID, appID, accessToken, "access"); err != nil {
 		return nil, err
 	}
-	if err := s.saveToken(user.ID, req.AppID, refreshToken, "refresh"); err != nil {
+	if err := s.saveToken(user.ID, appID, refreshToken, "refresh"); err != nil {
 		return nil, err
 	}
 
@@ -147,7 +390,8 @@ func (s *AuthService) Login(req *LoginRequest) (*LoginResponse, error) {
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 		ExpiresIn:    config.GetConfig().JWT.TTL,
-		TokenType:    "Bearer",
+		TokenType:    tokenType(cnf),
+		OrgID:        orgID,
 		User: UserInfo{
 			ID:       user.ID,
 			Username: user.Username,
@@ -155,6 +399,7 @@ func (s *AuthService) Login(req *LoginRequest) (*LoginResponse, error) {
 			Phone:    user.Phone,
 			Roles:    roleInfos,
 		},
+		PasswordCompromised: user.PasswordCompromised,
 	}, nil
 }
 
@@ -162,9 +407,15 @@ func (s *AuthService) Login(req *LoginRequest) (*LoginResponse, error) {
 func (s *AuthService) Register(req *RegisterRequest) error {
 	// 验证应用是否存在且密钥正确
 	var app models.Application
-	if err := config.DB.Where("app_id = ? AND app_secret = ? AND status = 1", req.AppID, req.AppSecret).First(&app).Error; err != nil {
+	if err := config.DB.Where("app_id = ? AND status = 1", req.AppID).First(&app).Error; err != nil ||
+		!authenticateApp(req.AppID, req.AppSecret, req.SignatureVerified, req.ClientCert) {
 		return errors.New("应用不存在、密钥错误或已禁用")
 	}
+	if req.AppVerified != nil {
+		if err := req.AppVerified(req.AppID); err != nil {
+			return err
+		}
+	}
 
 	// 检查用户名是否已存在
 	var existingUser models.User
@@ -179,29 +430,137 @@ func (s *AuthService) Register(req *RegisterRequest) error {
 		}
 	}
 
+	// 校验密码策略
+	policyService := &PasswordPolicyService{}
+	if err := policyService.CheckPassword(req.AppID, AccountTypeUser, 0, req.Username, req.Password); err != nil {
+		return err
+	}
+
 	// 哈希密码
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
-		return err
+		return errors.New("密码加密失败")
 	}
 
 	// 创建用户
+	now := time.Now()
 	user := models.User{
-		AppID:    req.AppID,
-		Username: req.Username,
-		Email:    req.Email,
-		Phone:    req.Phone,
-		Password: hashedPassword,
-		Status:   1,
+		AppID:             req.AppID,
+		Username:          req.Username,
+		Email:             req.Email,
+		Phone:             req.Phone,
+		Password:          hashedPassword,
+		Status:            1,
+		PasswordChangedAt: &now,
+	}
+
+	// 用户与密码历史在同一事务中写入，任一失败都不会留下用户
+	if err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return policyService.RecordPasswordHistory(tx, req.AppID, AccountTypeUser, user.ID, hashedPassword)
+	}); err != nil {
+		log.Printf("注册用户失败: %v", err)
+		return ErrRegisterFailed
+	}
+	return nil
+}
+
+// ChangePassword 用户修改自己的密码（需校验旧密码）
+func (s *AuthService) ChangePassword(userID uint, appID string, req *ChangePasswordRequest) error {
+	var user models.User
+	if err := config.DB.Where("id = ? AND app_id = ? AND status = 1", userID, appID).First(&user).Error; err != nil {
+		return errors.New("用户不存在或已禁用")
+	}
+
+	valid, err := utils.VerifyPassword(req.OldPassword, user.Password)
+	if err != nil || !valid {
+		return errors.New("原密码错误")
+	}
+
+	return s.SetUserPassword(&user, req.NewPassword)
+}
+
+// SetUserPassword 按应用密码策略校验并更新用户密码（修改密码、管理员重置等场景共用）
+func (s *AuthService) SetUserPassword(user *models.User, newPassword string) error {
+	return s.UpdateUserWithPassword(user, nil, newPassword)
+}
+
+// UpdateUserWithPassword 更新用户资料并设置新密码：先按策略校验新密码（用户名取更新后的值），
+// 校验通过后资料、密码和密码历史在同一事务中写入，不符合策略时不修改任何字段
+func (s *AuthService) UpdateUserWithPassword(user *models.User, updates map[string]interface{}, newPassword string) error {
+	username := user.Username
+	if v, ok := updates["username"].(string); ok && v != "" {
+		username = v
 	}
 
-	if err := config.DB.Create(&user).Error; err != nil {
+	policyService := &PasswordPolicyService{}
+	if err := policyService.CheckPassword(user.AppID, AccountTypeUser, user.ID, username, newPassword); err != nil {
 		return err
 	}
 
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return errors.New("密码加密失败")
+	}
+
+	now := time.Now()
+	fields := make(map[string]interface{}, len(updates)+3)
+	for k, v := range updates {
+		fields[k] = v
+	}
+	fields["password"] = hashedPassword
+	fields["password_changed_at"] = now
+	fields["password_compromised"] = false
+
+	if err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(fields).Error; err != nil {
+			return err
+		}
+		return policyService.RecordPasswordHistory(tx, user.AppID, AccountTypeUser, user.ID, hashedPassword)
+	}); err != nil {
+		return err
+	}
+	user.Password = hashedPassword
+	user.PasswordChangedAt = &now
+	user.PasswordCompromised = false
 	return nil
 }
 
+// ChangeExpiredPassword 使用登录时返回的修改密码令牌设置新密码并签发令牌
+func (s *AuthService) ChangeExpiredPassword(req *ChangeExpiredPasswordRequest) (*LoginResponse, error) {
+	var user models.User
+	response, err := s.changeExpiredPassword(req, &user)
+	recordLoginAudit("login_password_change", AuditActorUser, user.ID, user.Username, req.AppID, req.ClientIP, req.UserAgent, err)
+	return response, err
+}
+
+// changeExpiredPassword 校验应用和修改密码令牌，设置新密码后签发令牌；令牌仅在密码设置成功后失效
+func (s *AuthService) changeExpiredPassword(req *ChangeExpiredPasswordRequest, user *models.User) (*LoginResponse, error) {
+	var app models.Application
+	if err := config.DB.Where("app_id = ? AND status = 1", req.AppID).First(&app).Error; err != nil ||
+		!authenticateApp(req.AppID, req.AppSecret, req.SignatureVerified, req.ClientCert) {
+		return nil, errors.New("应用不存在、密钥错误或已禁用")
+	}
+
+	challenge, err := loadPasswordChangeChallenge(AccountTypeUser, req.AppID, req.PasswordChangeToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.DB.Where("id = ? AND app_id = ? AND status = 1", challenge.AccountID, req.AppID).First(user).Error; err != nil {
+		return nil, errors.New("用户不存在或已禁用")
+	}
+
+	if err := s.SetUserPassword(user, req.NewPassword); err != nil {
+		return nil, err
+	}
+	if err := finishPasswordChangeChallenge(req.PasswordChangeToken); err != nil {
+		return nil, err
+	}
+	return s.issueLoginTokens(user, req.AppID, challenge.OrgID, buildConfirmation(req.ClientCert, req.DPoPJkt))
+}
+
 // RefreshToken 刷新令牌
 func (s *AuthService) RefreshToken(req *RefreshTokenRequest) (*LoginResponse, error) {
 	// 解析刷新令牌
@@ -210,26 +569,63 @@ func (s *AuthService) RefreshToken(req *RefreshTokenRequest) (*LoginResponse, er
 		return nil, errors.New("无效的刷新令牌")
 	}
 
+	// 已撤销的会话不能再刷新
+	if revoked, _ := utils.Exists(utils.TokenBlacklistPrefix + claims.JTI); revoked {
+		return nil, errors.New("刷新令牌已被撤销")
+	}
+
+	// 证书绑定的刷新令牌须在相同证书的连接上使用
+	if !ConfirmationMatches(claims.Cnf, req.ClientCert) {
+		return nil, errors.New("刷新令牌与客户端证书不匹配")
+	}
+
+	// DPoP 绑定的刷新令牌须使用相同公钥的证明；未绑定的刷新令牌携带证明时，新令牌绑定到该公钥
+	cnf := claims.Cnf
+	if cnf != nil && cnf.Jkt != "" {
+		if req.DPoPJkt != cnf.Jkt {
+			return nil, errors.New("刷新令牌与 DPoP 公钥不匹配")
+		}
+	} else if req.DPoPJkt != "" {
+		bound := utils.Confirmation{Jkt: req.DPoPJkt}
+		if cnf != nil {
+			bound.X5tS256 = cnf.X5tS256
+		}
+		cnf = &bound
+	}
+
 	// 查找用户
 	var user models.User
 	if err := config.DB.Where("id = ? AND app_id = ? AND status = 1", claims.UserID, claims.AppID).First(&user).Error; err != nil {
 		return nil, errors.New("用户不存在或已禁用")
 	}
 
+	// 密码过期后不能再刷新，须重新登录并修改密码
+	if (&PasswordPolicyService{}).IsPasswordExpired(claims.AppID, user.PasswordChangedAt) {
+		return nil, ErrPasswordExpired
+	}
+
+	// 沿用刷新令牌的当前组织，用户已被移出组织或组织已停用时不能再刷新
+	orgID := claims.OrgID
+	if orgID != 0 {
+		if err := checkOrganizationMember(claims.AppID, orgID, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
 	// 获取用户角色
-	roles, err := s.GetUserRoles(user.ID)
+	roles, err := s.GetUserRoles(user.ID, orgID)
 	if err != nil {
 		return nil, err
 	}
 
-	// 生成新的访问令牌
-	accessToken, err := utils.GenerateAccessToken(user.ID, claims.AppID, roles)
+	// 生成新的访问令牌（沿用刷新令牌的绑定信息）
+	accessToken, err := utils.GenerateOrgAccessToken(user.ID, claims.AppID, orgID, roles, cnf)
 	if err != nil {
 		return nil, err
 	}
 
 	// 生成新的刷新令牌
-	refreshToken, err := utils.GenerateRefreshToken(user.ID, claims.AppID)
+	refreshToken, err := utils.GenerateOrgRefreshToken(user.ID, claims.AppID, orgID, cnf)
 	if err != nil {
 		return nil, err
 	}
@@ -252,7 +648,8 @@ func (s *AuthService) RefreshToken(req *RefreshTokenRequest) (*LoginResponse, er
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 		ExpiresIn:    config.GetConfig().JWT.TTL,
-		TokenType:    "Bearer",
+		TokenType:    tokenType(cnf),
+		OrgID:        orgID,
 		User: UserInfo{
 			ID:       user.ID,
 			Username: user.Username,
@@ -263,6 +660,61 @@ func (s *AuthService) RefreshToken(req *RefreshTokenRequest) (*LoginResponse, er
 	}, nil
 }
 
+// SwitchOrganizationRequest 切换当前组织请求，须提交当前会话的刷新令牌
+type SwitchOrganizationRequest struct {
+	OrgID        uint   `json:"org_id"` // 0 表示离开组织
+	RefreshToken string `json:"refresh_token" binding:"required"`
+	// UserID、AppID、AccessJTI、Cnf 来自当前访问令牌
+	UserID    uint                `json:"-"`
+	AppID     string              `json:"-"`
+	AccessJTI string              `json:"-"`
+	Cnf       *utils.Confirmation `json:"-"`
+}
+
+// SwitchOrganization 以 orgID 为当前组织重新签发令牌，沿用原令牌的绑定信息。
+// 须提交与访问令牌属于同一用户和绑定的刷新令牌；新刷新令牌沿用原刷新令牌的过期时间，不延长会话，
+// 原访问令牌和刷新令牌加入黑名单
+func (s *AuthService) SwitchOrganization(req *SwitchOrganizationRequest) (*LoginResponse, error) {
+	claims, err := utils.ParseRefreshToken(req.RefreshToken)
+	if err != nil || claims.UserID != req.UserID || claims.AppID != req.AppID || !sameConfirmation(claims.Cnf, req.Cnf) {
+		return nil, errors.New("无效的刷新令牌")
+	}
+	if revoked, _ := utils.Exists(utils.TokenBlacklistPrefix + claims.JTI); revoked {
+		return nil, errors.New("刷新令牌已被撤销")
+	}
+
+	var user models.User
+	if err := config.DB.Where("id = ? AND app_id = ? AND status = 1", req.UserID, req.AppID).First(&user).Error; err != nil {
+		return nil, errors.New("用户不存在或已禁用")
+	}
+
+	response, err := s.issueTokens(&user, req.AppID, req.OrgID, req.Cnf, claims.ExpiresAt.Time)
+	if err != nil {
+		return nil, err
+	}
+
+	// 原令牌作废：访问令牌按最长有效期、刷新令牌按剩余有效期加入黑名单
+	if req.AccessJTI != "" {
+		if err := utils.Set(utils.TokenBlacklistPrefix+req.AccessJTI, "1", time.Duration(config.GetConfig().JWT.TTL)*time.Second); err != nil {
+			return nil, err
+		}
+	}
+	if ttl := time.Until(claims.ExpiresAt.Time); ttl > 0 {
+		if err := utils.Set(utils.TokenBlacklistPrefix+claims.JTI, "1", ttl); err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+// sameConfirmation 两个令牌的绑定信息是否一致
+func sameConfirmation(a, b *utils.Confirmation) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
 // Logout 用户登出
 func (s *AuthService) Logout(token string) error {
 	// 解析令牌
@@ -280,15 +732,75 @@ func (s *AuthService) Logout(token string) error {
 	return nil
 }
 
-// GetUserInfo 获取用户信息
-func (s *AuthService) GetUserInfo(userID uint, appID string) (*UserInfo, error) {
+// Introspect 令牌内省
+// 调用方须通过应用认证，且只能内省本应用签发的令牌；DPoP 绑定令牌须同时提供有效的 DPoP 证明
+func (s *AuthService) Introspect(req *IntrospectRequest) (*IntrospectResponse, error) {
+	if !authenticateApp(req.AppID, req.AppSecret, req.SignatureVerified, req.ClientCert) {
+		return nil, errors.New("应用不存在、密钥错误或已禁用")
+	}
+
+	inactive := &IntrospectResponse{Active: false}
+	isAccessToken := req.TokenTypeHint != "refresh_token"
+	claims, err := utils.ValidateToken(req.Token, isAccessToken)
+	if err != nil || claims.AppID != req.AppID {
+		return inactive, nil
+	}
+
+	// 已登出的令牌
+	if exists, err := utils.Exists(utils.TokenBlacklistPrefix + claims.JTI); err != nil || exists {
+		return inactive, nil
+	}
+
+	// DPoP 绑定令牌由授权中心代为校验证明
+	if claims.Cnf != nil && claims.Cnf.Jkt != "" {
+		if req.DPoPProof == "" {
+			return inactive, nil
+		}
+		accessToken := ""
+		if isAccessToken {
+			accessToken = req.Token
+		}
+		proof, err := VerifyDPoPProof(req.DPoPProof, req.HTM, req.HTU, accessToken)
+		if err != nil || proof.Thumbprint != claims.Cnf.Jkt {
+			return inactive, nil
+		}
+	}
+
+	var count int64
+	config.DB.Model(&models.User{}).Where("id = ? AND app_id = ? AND status = 1", claims.UserID, claims.AppID).Count(&count)
+	if count == 0 {
+		return inactive, nil
+	}
+
+	response := &IntrospectResponse{
+		Active:    true,
+		ClientID:  claims.AppID,
+		Sub:       strconv.FormatUint(uint64(claims.UserID), 10),
+		UserID:    claims.UserID,
+		Roles:     claims.Roles,
+		OrgID:     claims.OrgID,
+		TokenType: tokenType(claims.Cnf),
+		Jti:       claims.JTI,
+		Cnf:       claims.Cnf,
+	}
+	if claims.ExpiresAt != nil {
+		response.Exp = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		response.Iat = claims.IssuedAt.Unix()
+	}
+	return response, nil
+}
+
+// GetUserInfo 获取用户信息，orgID 为令牌的当前组织，角色包含组织内的角色
+func (s *AuthService) GetUserInfo(userID uint, appID string, orgID uint) (*UserInfo, error) {
 	var user models.User
 	if err := config.DB.Where("id = ? AND app_id = ? AND status = 1", userID, appID).First(&user).Error; err != nil {
 		return nil, errors.New("用户不存在或已禁用")
 	}
 
 	// 获取用户角色
-	roles, err := s.GetUserRoles(userID)
+	roles, err := s.GetUserRoles(userID, orgID)
 	if err != nil {
 		return nil, err
 	}
@@ -308,19 +820,14 @@ func (s *AuthService) GetUserInfo(userID uint, appID string) (*UserInfo, error)
 	}, nil
 }
 
-// getUserRoles 获取用户角色ID列表
-func (s *AuthService) GetUserRoles(userID uint) ([]uint, error) {
-	var userRoles []models.UserRole
-	if err := config.DB.Where("user_id = ?", userID).Find(&userRoles).Error; err != nil {
+// getUserRoles 获取用户当前有效的角色ID列表（直接分配、通过分组获得以及在当前组织 orgID 内的角色）
+func (s *AuthService) GetUserRoles(userID uint, orgID uint) ([]uint, error) {
+	var user models.User
+	if err := config.DB.Select("id", "app_id").Where("id = ?", userID).First(&user).Error; err != nil {
 		return nil, err
 	}
 
-	var roleIDs []uint
-	for _, ur := range userRoles {
-		roleIDs = append(roleIDs, ur.RoleID)
-	}
-
-	return roleIDs, nil
+	return userAssignedRoleIDs(userID, user.AppID, orgID)
 }
 
 // getRoleInfos 获取角色信息
@@ -378,12 +885,14 @@ func (s *AuthService) getLoginMethod(appID string) (int, error) {
 	return p.LoginMethod, nil
 }
 
-// verifyOTP 校验短信验证码（示例占位：从 Redis 读取）
+// verifyOTP 校验短信验证码（示例占位：从 Redis 读取），校验通过后验证码失效
 func (s *AuthService) verifyOTP(appID, phone, code string) bool {
 	key := "otp:" + appID + ":" + phone
 	val, err := utils.Get(key)
-	if err != nil {
+	if err != nil || code == "" || subtle.ConstantTimeCompare([]byte(val), []byte(code)) != 1 {
 		return false
 	}
-	return val == code
+	// 原子地取出并删除，并发提交同一验证码时只有一个请求通过
+	consumed, err := utils.GetDel(key)
+	return err == nil && consumed == val
 }
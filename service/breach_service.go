@@ -0,0 +1,46 @@
+package service
+
+import (
+	"log"
+	"sync"
+
+	"auth-center/config"
+	"auth-center/utils"
+)
+
+var (
+	breachFilter     *utils.BloomFilter
+	breachFilterOnce sync.Once
+)
+
+// getBreachFilter 按配置加载泄露密码布隆过滤器（仅加载一次），未启用或加载失败时返回 nil
+func getBreachFilter() *utils.BloomFilter {
+	breachFilterOnce.Do(func() {
+		cfg := config.GetConfig()
+		if cfg == nil || !cfg.Breach.Enabled {
+			return
+		}
+
+		filter, err := utils.LoadBloomFilter(cfg.Breach.FilterFile)
+		if err != nil {
+			log.Printf("加载泄露密码库失败，已跳过泄露密码检测: %v", err)
+			return
+		}
+		breachFilter = filter
+		log.Printf("泄露密码库加载成功，共 %d 条记录", filter.Count())
+	})
+	return breachFilter
+}
+
+// IsPasswordBreached 判断密码是否出现在离线泄露密码库中
+// 布隆过滤器存在极低的误判率，但不会漏判；未启用检测时始终返回 false
+func IsPasswordBreached(password string) bool {
+	filter := getBreachFilter()
+	return filter != nil && filter.Test(password)
+}
+
+// shouldCheckBreachOnLogin 登录时是否检测当前密码
+func shouldCheckBreachOnLogin() bool {
+	cfg := config.GetConfig()
+	return cfg != nil && cfg.Breach.Enabled && cfg.Breach.CheckOnLogin
+}
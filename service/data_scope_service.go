@@ -0,0 +1,313 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"auth-center/config"
+	"auth-center/models"
+)
+
+// 数据范围（行级权限）：授予权限时可以限定用户能访问该资源的哪些数据。
+// 数据范围设置在角色的权限分配和直接分配给用户的权限上，不影响是否拥有权限的判定；
+// 下游服务按资源类型查询用户的数据范围，并据此构造查询条件。
+// 用户通过多条规则获得同一资源类型的权限时取各规则数据范围的并集，未设置数据范围的规则等同于 all
+
+// 数据范围类型
+const (
+	DataScopeAll        = "all"        // 全部数据
+	DataScopeOwn        = "own"        // 本人的数据
+	DataScopeDepartment = "department" // 本部门的数据：用户所在分组及其下级分组
+	DataScopeCustom     = "custom"     // 自定义取值列表（如地区代码）内的数据
+)
+
+// dataScopeMaxValues 自定义数据范围的最大取值数
+const dataScopeMaxValues = 100
+
+// DataScope 一条规则的数据范围
+type DataScope struct {
+	Type   string   `json:"type"`
+	Values []string `json:"values,omitempty"` // 仅 custom 使用
+}
+
+// ResolvedDataScope 用户对资源类型（及操作）可访问的数据范围。
+// All 为 true 时不限制；否则可访问的数据为 Own、部门和自定义取值各条件的并集，均不满足时不能访问任何数据
+type ResolvedDataScope struct {
+	Resource        string   `json:"resource"`
+	Action          string   `json:"action,omitempty"`
+	Allowed         bool     `json:"allowed"`          // 拥有该资源类型（及操作）的任一权限
+	All             bool     `json:"all"`              // 可访问全部数据
+	Own             bool     `json:"own"`              // 可访问本人（user_id）的数据
+	UserID          uint     `json:"user_id"`          // 本人的用户ID
+	Department      bool     `json:"department"`       // 可访问本部门的数据
+	DepartmentIDs   []uint   `json:"department_ids"`   // 本部门：用户所在分组及其下级分组
+	DepartmentCodes []string `json:"department_codes"` // 本部门分组的编码
+	CustomValues    []string `json:"custom_values"`    // 可访问的自定义取值
+	Permissions     []string `json:"permissions"`      // 授予访问的权限代码
+}
+
+// ValidateDataScope 校验并规范化数据范围：自定义取值去除首尾空白、去重并排序，其他类型不能带取值
+func ValidateDataScope(scope *DataScope) error {
+	switch scope.Type {
+	case DataScopeAll, DataScopeOwn, DataScopeDepartment:
+		if len(scope.Values) > 0 {
+			return fmt.Errorf("数据范围 %s 不能设置取值", scope.Type)
+		}
+		scope.Values = nil
+	case DataScopeCustom:
+		values := make([]string, 0, len(scope.Values))
+		for _, value := range scope.Values {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		values = uniqueStrings(values)
+		if len(values) == 0 {
+			return errors.New("自定义数据范围需要至少一个取值")
+		}
+		if len(values) > dataScopeMaxValues {
+			return fmt.Errorf("自定义数据范围最多 %d 个取值", dataScopeMaxValues)
+		}
+		scope.Values = values
+	default:
+		return fmt.Errorf("不支持的数据范围类型 %q，应为 all、own、department 或 custom", scope.Type)
+	}
+	return nil
+}
+
+// encodeDataScope 数据范围的存储形式，all 存为空（与未设置相同）
+func encodeDataScope(scope DataScope) string {
+	if scope.Type == DataScopeAll || scope.Type == "" {
+		return ""
+	}
+	data, _ := json.Marshal(scope)
+	return string(data)
+}
+
+// decodeDataScope 解析存储的数据范围，空表示 all
+func decodeDataScope(raw string) (DataScope, error) {
+	if raw == "" {
+		return DataScope{Type: DataScopeAll}, nil
+	}
+	var scope DataScope
+	if err := json.Unmarshal([]byte(raw), &scope); err != nil {
+		return DataScope{}, err
+	}
+	return scope, ValidateDataScope(&scope)
+}
+
+// MergeDataScopes 合并多条规则的数据范围（取并集），任一为 all 时为全部数据；没有规则时不能访问任何数据
+func MergeDataScopes(scopes []DataScope) *ResolvedDataScope {
+	result := &ResolvedDataScope{
+		Allowed:         len(scopes) > 0,
+		DepartmentIDs:   []uint{},
+		DepartmentCodes: []string{},
+		CustomValues:    []string{},
+		Permissions:     []string{},
+	}
+	var values []string
+	for _, scope := range scopes {
+		switch scope.Type {
+		case DataScopeAll:
+			result.All = true
+		case DataScopeOwn:
+			result.Own = true
+		case DataScopeDepartment:
+			result.Department = true
+		case DataScopeCustom:
+			values = append(values, scope.Values...)
+		}
+	}
+	if result.All {
+		result.Own, result.Department = false, false
+		return result
+	}
+	if len(values) > 0 {
+		result.CustomValues = uniqueStrings(values)
+	}
+	return result
+}
+
+// ResolveDataScope 解析用户对资源类型可访问的数据范围，action 为空时包含该资源类型的全部操作。
+// 只计入判定为允许的权限上生效的授予规则（拒绝优先、附带条件的规则按 pctx 求值），与权限判定使用相同的角色（含当前组织内的角色）
+func (s *PermissionService) ResolveDataScope(userID uint, appID, resource, action string, pctx *PermissionContext) (*ResolvedDataScope, error) {
+	tx := config.DB.Where("app_id = ? AND resource = ? AND status = 1", appID, resource)
+	if action != "" {
+		tx = tx.Where("action = ?", action)
+	}
+	var permissions []models.Permission
+	if err := tx.Order("id").Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+
+	var applied []PermissionGrant
+	var codes []string
+	if len(permissions) > 0 {
+		grants, err := s.loadUserGrants(userID, appID)
+		if err != nil {
+			return nil, err
+		}
+		for i := range permissions {
+			permissionGrants := grants[permissions[i].ID]
+			if err := evaluateGrantConditions(userID, appID, s.OrgID, permissionGrants, pctx); err != nil {
+				return nil, err
+			}
+			if !decide(&permissions[i], permissionGrants).Allowed {
+				continue
+			}
+			codes = append(codes, permissions[i].Code)
+			for _, grant := range permissionGrants {
+				if grant.Effect == PermissionEffectAllow && grant.Applies() {
+					applied = append(applied, grant)
+				}
+			}
+		}
+	}
+
+	scopes, err := grantDataScopes(userID, appID, applied)
+	if err != nil {
+		return nil, err
+	}
+	result := MergeDataScopes(scopes)
+	result.Resource, result.Action, result.UserID = resource, action, userID
+	if codes != nil {
+		result.Allowed, result.Permissions = true, codes
+	}
+	if result.Department {
+		if result.DepartmentIDs, result.DepartmentCodes, err = userDepartments(userID, appID); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// grantDataScopes 读取授予规则上设置的数据范围。找不到规则或数据范围无法解析时忽略该规则，不扩大可访问的范围
+func grantDataScopes(userID uint, appID string, grants []PermissionGrant) ([]DataScope, error) {
+	if len(grants) == 0 {
+		return nil, nil
+	}
+	var roleIDs, permissionIDs []uint
+	for _, grant := range grants {
+		if grant.Source == PermissionSourceRole {
+			roleIDs = append(roleIDs, grant.RoleID)
+		}
+		permissionIDs = append(permissionIDs, grant.PermissionID)
+	}
+	permissionIDs = uniqueIDs(permissionIDs)
+
+	type ruleKey struct{ roleID, permissionID uint }
+	roleScopes := make(map[ruleKey]string)
+	if len(roleIDs) > 0 {
+		var rules []models.RolePermission
+		if err := config.DB.Scopes(activeAssignments("role_permissions", time.Now())).
+			Where("app_id = ? AND role_id IN ? AND permission_id IN ? AND effect <> ?", appID, uniqueIDs(roleIDs), permissionIDs, PermissionEffectDeny).
+			Find(&rules).Error; err != nil {
+			return nil, err
+		}
+		for _, rule := range rules {
+			roleScopes[ruleKey{rule.RoleID, rule.PermissionID}] = rule.DataScope
+		}
+	}
+	var userPermissions []models.UserPermission
+	if err := config.DB.Where("user_id = ? AND app_id = ? AND permission_id IN ? AND effect <> ?", userID, appID, permissionIDs, PermissionEffectDeny).
+		Find(&userPermissions).Error; err != nil {
+		return nil, err
+	}
+	userScopes := make(map[uint]string, len(userPermissions))
+	for _, up := range userPermissions {
+		userScopes[up.PermissionID] = up.DataScope
+	}
+
+	scopes := make([]DataScope, 0, len(grants))
+	for _, grant := range grants {
+		var raw string
+		var ok bool
+		if grant.Source == PermissionSourceUser {
+			raw, ok = userScopes[grant.PermissionID]
+		} else {
+			raw, ok = roleScopes[ruleKey{grant.RoleID, grant.PermissionID}]
+		}
+		if !ok {
+			continue
+		}
+		scope, err := decodeDataScope(raw)
+		if err != nil {
+			continue
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, nil
+}
+
+// userDepartments 用户的部门：直接所在的分组及其下级分组
+func userDepartments(userID uint, appID string) ([]uint, []string, error) {
+	var directGroupIDs []uint
+	if err := config.DB.Model(&models.GroupMember{}).Where("user_id = ? AND app_id = ?", userID, appID).
+		Pluck("group_id", &directGroupIDs).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(directGroupIDs) == 0 {
+		return []uint{}, []string{}, nil
+	}
+
+	graph, err := loadGroupGraph(config.DB, appID)
+	if err != nil {
+		return nil, nil, err
+	}
+	groupIDs := append([]uint(nil), directGroupIDs...)
+	for _, groupID := range directGroupIDs {
+		groupIDs = append(groupIDs, graph.Descendants(groupID)...)
+	}
+	groupIDs = uniqueIDs(groupIDs)
+
+	var groups []models.Group
+	if err := config.DB.Where("app_id = ? AND id IN ?", appID, groupIDs).Order("id").Find(&groups).Error; err != nil {
+		return nil, nil, err
+	}
+	ids := make([]uint, 0, len(groups))
+	codes := make([]string, 0, len(groups))
+	for _, group := range groups {
+		ids = append(ids, group.ID)
+		codes = append(codes, group.Code)
+	}
+	return ids, codes, nil
+}
+
+// uniqueStrings 去重并排序
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// ValidateDataScopes 校验授予的权限上的数据范围，权限须在 allowIDs 中，all 视为未设置
+func ValidateDataScopes(scopes map[uint]DataScope, allowIDs []uint) (map[uint]DataScope, error) {
+	allowed := make(map[uint]bool, len(allowIDs))
+	for _, id := range allowIDs {
+		allowed[id] = true
+	}
+	result := make(map[uint]DataScope, len(scopes))
+	for permissionID, scope := range scopes {
+		if !allowed[permissionID] {
+			return nil, fmt.Errorf("权限 %d 未授予，不能设置数据范围", permissionID)
+		}
+		if err := ValidateDataScope(&scope); err != nil {
+			return nil, fmt.Errorf("权限 %d 的%s", permissionID, err.Error())
+		}
+		if scope.Type != DataScopeAll {
+			result[permissionID] = scope
+		}
+	}
+	return result, nil
+}
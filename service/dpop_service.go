@@ -0,0 +1,119 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"log"
+	"time"
+
+	"auth-center/config"
+	"auth-center/utils"
+)
+
+// DPoP 错误码（RFC 9449）
+const (
+	DPoPErrorInvalidProof  = "invalid_dpop_proof"
+	DPoPErrorUseNonce      = "use_dpop_nonce"
+	DPoPErrorInvalidToken  = "invalid_token"
+	dpopNonceMACLength     = 16
+	dpopNonceTimestampSize = 8
+)
+
+// DPoPError DPoP 证明校验失败
+type DPoPError struct {
+	Code    string
+	Message string
+}
+
+func (e *DPoPError) Error() string {
+	return e.Message
+}
+
+// VerifyDPoPProof 校验 DPoP 证明：签名、htm/htu/ath、iat 时间窗口、服务端 nonce 以及 jti 重放
+// accessToken 为空表示令牌端点（不校验 ath）
+func VerifyDPoPProof(proof, method, htu, accessToken string) (*utils.DPoPProof, error) {
+	cfg := config.GetConfig().DPoP
+
+	parsed, err := utils.ParseDPoPProof(proof, method, htu, accessToken)
+	if err != nil {
+		return nil, &DPoPError{Code: DPoPErrorInvalidProof, Message: err.Error()}
+	}
+
+	maxAge := time.Duration(cfg.MaxAge) * time.Second
+	if age := time.Since(parsed.IssuedAt); age > maxAge || age < -maxAge {
+		return nil, &DPoPError{Code: DPoPErrorInvalidProof, Message: "DPoP 证明已过期"}
+	}
+
+	if cfg.RequireNonce && !validDPoPNonce(parsed.Nonce) {
+		return nil, &DPoPError{Code: DPoPErrorUseNonce, Message: "DPoP 证明须携带服务端下发的 nonce"}
+	}
+
+	// jti 在证明有效期内只能使用一次
+	ok, err := utils.SetNX(utils.DPoPJTIPrefix+parsed.Thumbprint+":"+parsed.JTI, 1, 2*maxAge)
+	if err != nil {
+		log.Printf("DPoP jti 校验失败: %v", err)
+		return nil, &DPoPError{Code: DPoPErrorInvalidProof, Message: "DPoP 证明校验暂不可用"}
+	}
+	if !ok {
+		return nil, &DPoPError{Code: DPoPErrorInvalidProof, Message: "DPoP 证明已被使用"}
+	}
+
+	return parsed, nil
+}
+
+// NewDPoPNonce 生成服务端 DPoP nonce：签发时间与 HMAC 拼接后 base64url 编码，无需服务端存储
+func NewDPoPNonce() string {
+	buf := make([]byte, dpopNonceTimestampSize, dpopNonceTimestampSize+dpopNonceMACLength)
+	binary.BigEndian.PutUint64(buf, uint64(time.Now().Unix()))
+	buf = append(buf, dpopNonceMAC(buf)...)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// validDPoPNonce 校验服务端 nonce 的 HMAC 和有效期
+func validDPoPNonce(nonce string) bool {
+	raw, err := base64.RawURLEncoding.DecodeString(nonce)
+	if err != nil || len(raw) != dpopNonceTimestampSize+dpopNonceMACLength {
+		return false
+	}
+
+	issued := raw[:dpopNonceTimestampSize]
+	if !hmac.Equal(raw[dpopNonceTimestampSize:], dpopNonceMAC(issued)) {
+		return false
+	}
+
+	age := time.Now().Unix() - int64(binary.BigEndian.Uint64(issued))
+	return age >= 0 && age <= config.GetConfig().DPoP.NonceTTL
+}
+
+// dpopNonceMAC 计算 nonce 的 HMAC（密钥由 JWT 密钥派生）
+func dpopNonceMAC(data []byte) []byte {
+	keyMAC := hmac.New(sha256.New, []byte(config.GetConfig().JWT.SecretKey))
+	keyMAC.Write([]byte("dpop-nonce"))
+
+	mac := hmac.New(sha256.New, keyMAC.Sum(nil))
+	mac.Write(data)
+	return mac.Sum(nil)[:dpopNonceMACLength]
+}
+
+// buildConfirmation 根据客户端证书和 DPoP 公钥指纹生成令牌绑定信息，均为空时返回 nil
+func buildConfirmation(cert *utils.ClientCertInfo, jkt string) *utils.Confirmation {
+	cnf := CertificateConfirmation(cert)
+	if jkt == "" {
+		return cnf
+	}
+	if cnf == nil {
+		cnf = &utils.Confirmation{}
+	}
+	cnf.Jkt = jkt
+	return cnf
+}
+
+// tokenType 令牌类型：DPoP 绑定令牌为 DPoP，其余为 Bearer
+func tokenType(cnf *utils.Confirmation) string {
+	if cnf != nil && cnf.Jkt != "" {
+		return "DPoP"
+	}
+	return "Bearer"
+}
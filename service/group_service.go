@@ -0,0 +1,335 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"auth-center/config"
+	"auth-center/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 用户分组：角色可以分配给分组，分组的成员获得这些角色。分组可以嵌套，
+// 下级分组的成员同时是上级分组（含多级上级）的成员，获得上级分组的角色。
+// 用户的角色由直接分配的角色、通过分组获得的角色和当前组织内的角色（见 organization_service.go）共同组成，再按角色继承关系展开
+
+// GroupService 用户分组服务
+type GroupService struct{}
+
+var (
+	// ErrGroupInheritanceCycle 加入下级分组后嵌套关系成环
+	ErrGroupInheritanceCycle = errors.New("分组嵌套关系不能成环")
+	// ErrGroupMemberInvalid 成员不存在或不属于当前应用
+	ErrGroupMemberInvalid = errors.New("成员不存在或不属于当前应用")
+)
+
+// GroupMembers 分组的直接成员
+type GroupMembers struct {
+	UserIDs  []uint `json:"user_ids"`  // 用户成员
+	GroupIDs []uint `json:"group_ids"` // 下级分组
+}
+
+// loadGroupGraph 读取应用内全部分组嵌套关系（分组ID -> 上级分组ID列表），结构与角色继承关系图相同
+func loadGroupGraph(db *gorm.DB, appID string) (RoleGraph, error) {
+	var edges []models.GroupInheritance
+	if err := db.Where("app_id = ?", appID).Find(&edges).Error; err != nil {
+		return nil, err
+	}
+	graph := make(RoleGraph, len(edges))
+	for _, edge := range edges {
+		graph[edge.GroupID] = append(graph[edge.GroupID], edge.ParentGroupID)
+	}
+	return graph, nil
+}
+
+// ResolveGroupRoles 按用户直接所在的分组、分组嵌套关系和分组的角色，计算用户通过分组获得的角色：角色ID -> 提供该角色的分组（按ID排序）
+func ResolveGroupRoles(graph RoleGraph, directGroupIDs []uint, groupRoles map[uint][]uint) map[uint][]uint {
+	result := make(map[uint][]uint)
+	for _, groupID := range graph.Ancestors(directGroupIDs...) {
+		for _, roleID := range groupRoles[groupID] {
+			result[roleID] = append(result[roleID], groupID)
+		}
+	}
+	for _, groupIDs := range result {
+		sort.Slice(groupIDs, func(i, j int) bool { return groupIDs[i] < groupIDs[j] })
+	}
+	return result
+}
+
+// userGroupRoles 用户当前通过分组获得的角色：角色ID -> 提供该角色的分组
+func userGroupRoles(userID uint, appID string) (map[uint][]uint, error) {
+	var directGroupIDs []uint
+	if err := config.DB.Model(&models.GroupMember{}).Where("user_id = ? AND app_id = ?", userID, appID).
+		Pluck("group_id", &directGroupIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(directGroupIDs) == 0 {
+		return nil, nil
+	}
+
+	graph, err := loadGroupGraph(config.DB, appID)
+	if err != nil {
+		return nil, err
+	}
+	var assignments []models.GroupRole
+	if err := config.DB.Where("app_id = ? AND group_id IN ?", appID, graph.Ancestors(directGroupIDs...)).
+		Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+	groupRoles := make(map[uint][]uint)
+	for _, assignment := range assignments {
+		groupRoles[assignment.GroupID] = append(groupRoles[assignment.GroupID], assignment.RoleID)
+	}
+	return ResolveGroupRoles(graph, directGroupIDs, groupRoles), nil
+}
+
+// userAssignedRoleIDs 用户当前直接分配的角色、通过分组获得的角色和在组织 orgID 内的角色（按ID排序，不含继承的父角色），
+// orgID 为 0 时不含组织内的角色
+func userAssignedRoleIDs(userID uint, appID string, orgID uint) ([]uint, error) {
+	var roleIDs []uint
+	if err := config.DB.Model(&models.UserRole{}).Scopes(activeAssignments("user_roles", time.Now())).
+		Where("user_id = ? AND app_id = ?", userID, appID).Pluck("role_id", &roleIDs).Error; err != nil {
+		return nil, err
+	}
+	groupRoles, err := userGroupRoles(userID, appID)
+	if err != nil {
+		return nil, err
+	}
+	for roleID := range groupRoles {
+		roleIDs = append(roleIDs, roleID)
+	}
+	if orgID != 0 {
+		orgRoleIDs, err := organizationRoleIDs(userID, appID, orgID)
+		if err != nil {
+			return nil, err
+		}
+		roleIDs = append(roleIDs, orgRoleIDs...)
+	}
+	return uniqueIDs(roleIDs), nil
+}
+
+// groupMemberUserIDs 分组及其全部下级分组的用户成员
+func groupMemberUserIDs(appID string, groupIDs []uint) ([]uint, error) {
+	if len(groupIDs) == 0 {
+		return nil, nil
+	}
+	graph, err := loadGroupGraph(config.DB, appID)
+	if err != nil {
+		return nil, err
+	}
+	expanded := append([]uint(nil), groupIDs...)
+	for _, groupID := range groupIDs {
+		expanded = append(expanded, graph.Descendants(groupID)...)
+	}
+
+	var userIDs []uint
+	err = config.DB.Model(&models.GroupMember{}).Where("app_id = ? AND group_id IN ?", appID, uniqueIDs(expanded)).
+		Distinct().Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
+
+// GetUserGroups 获取用户直接所在的分组和包含上级分组的全部分组
+func (s *GroupService) GetUserGroups(appID string, userID uint) (direct, all []uint, err error) {
+	if err = config.DB.Model(&models.GroupMember{}).Where("user_id = ? AND app_id = ?", userID, appID).
+		Order("group_id").Pluck("group_id", &direct).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(direct) == 0 {
+		return []uint{}, []uint{}, nil
+	}
+	graph, err := loadGroupGraph(config.DB, appID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return direct, graph.Ancestors(direct...), nil
+}
+
+// GetMembers 获取分组的直接成员
+func (s *GroupService) GetMembers(appID string, groupID uint) (*GroupMembers, error) {
+	members := &GroupMembers{UserIDs: []uint{}, GroupIDs: []uint{}}
+	if err := config.DB.Model(&models.GroupMember{}).Where("app_id = ? AND group_id = ?", appID, groupID).
+		Order("user_id").Pluck("user_id", &members.UserIDs).Error; err != nil {
+		return nil, err
+	}
+	if err := config.DB.Model(&models.GroupInheritance{}).Where("app_id = ? AND parent_group_id = ?", appID, groupID).
+		Order("group_id").Pluck("group_id", &members.GroupIDs).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// AddMembers 向分组加入用户和下级分组（已是成员的忽略），下级分组不能导致嵌套关系成环
+func (s *GroupService) AddMembers(appID string, groupID uint, members *GroupMembers) error {
+	userIDs, groupIDs := uniqueIDs(members.UserIDs), uniqueIDs(members.GroupIDs)
+	if err := validateGroupMembers(appID, userIDs, groupIDs); err != nil {
+		return err
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定应用记录，串行化同一应用内的嵌套关系修改，避免并发修改绕过成环检测
+		var app models.Application
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("app_id = ?", appID).First(&app).Error; err != nil {
+			return err
+		}
+		graph, err := loadGroupGraph(tx, appID)
+		if err != nil {
+			return err
+		}
+		for _, memberGroupID := range groupIDs {
+			parentIDs := append(append([]uint(nil), graph[memberGroupID]...), groupID)
+			if memberGroupID == groupID || graph.CreatesCycle(memberGroupID, parentIDs) {
+				return ErrGroupInheritanceCycle
+			}
+			graph[memberGroupID] = parentIDs
+
+			edge := models.GroupInheritance{AppID: appID, GroupID: memberGroupID, ParentGroupID: groupID}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&edge).Error; err != nil {
+				return fmt.Errorf("保存分组嵌套关系失败: %v", err)
+			}
+		}
+		for _, userID := range userIDs {
+			member := models.GroupMember{AppID: appID, GroupID: groupID, UserID: userID}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&member).Error; err != nil {
+				return fmt.Errorf("保存分组成员失败: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 新成员及下级分组（含其下级分组）的成员获得该分组的角色
+	cacheService := &PermissionCacheService{}
+	for _, userID := range userIDs {
+		cacheService.InvalidateUser(appID, userID)
+	}
+	for _, memberGroupID := range groupIDs {
+		cacheService.InvalidateGroup(appID, memberGroupID)
+	}
+	return nil
+}
+
+// RemoveMembers 从分组移除用户和下级分组
+func (s *GroupService) RemoveMembers(appID string, groupID uint, members *GroupMembers) error {
+	userIDs, groupIDs := uniqueIDs(members.UserIDs), uniqueIDs(members.GroupIDs)
+	// 移除前确定下级分组的成员，它们将失去该分组的角色
+	affectedUserIDs, lookupErr := groupMemberUserIDs(appID, groupIDs)
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if len(userIDs) > 0 {
+			if err := tx.Where("app_id = ? AND group_id = ? AND user_id IN ?", appID, groupID, userIDs).
+				Delete(&models.GroupMember{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(groupIDs) > 0 {
+			if err := tx.Where("app_id = ? AND parent_group_id = ? AND group_id IN ?", appID, groupID, groupIDs).
+				Delete(&models.GroupInheritance{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	cacheService := &PermissionCacheService{}
+	for _, userID := range userIDs {
+		cacheService.InvalidateUser(appID, userID)
+	}
+	if len(groupIDs) > 0 {
+		cacheService.invalidateGroupUsers(appID, groupID, affectedUserIDs, lookupErr)
+	}
+	return nil
+}
+
+// GetGroupRoles 获取分配给分组的角色
+func (s *GroupService) GetGroupRoles(appID string, groupID uint) ([]uint, error) {
+	roleIDs := []uint{}
+	err := config.DB.Model(&models.GroupRole{}).Where("app_id = ? AND group_id = ?", appID, groupID).
+		Order("role_id").Pluck("role_id", &roleIDs).Error
+	return roleIDs, err
+}
+
+// SetGroupRoles 替换分组的角色，角色须属于同一应用
+func (s *GroupService) SetGroupRoles(appID string, groupID uint, roleIDs []uint) error {
+	roleIDs = uniqueIDs(roleIDs)
+	if len(roleIDs) > 0 {
+		var count int64
+		if err := config.DB.Model(&models.Role{}).Where("app_id = ? AND id IN ?", appID, roleIDs).Count(&count).Error; err != nil {
+			return err
+		}
+		if count != int64(len(roleIDs)) {
+			return errors.New("角色不存在或不属于当前应用")
+		}
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("app_id = ? AND group_id = ?", appID, groupID).Delete(&models.GroupRole{}).Error; err != nil {
+			return err
+		}
+		for _, roleID := range roleIDs {
+			assignment := models.GroupRole{AppID: appID, GroupID: groupID, RoleID: roleID}
+			if err := tx.Create(&assignment).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	cacheService := &PermissionCacheService{}
+	cacheService.InvalidateGroup(appID, groupID)
+	return nil
+}
+
+// DeleteGroup 删除分组及其成员、角色分配和嵌套关系，下级分组不随之删除
+func (s *GroupService) DeleteGroup(group *models.Group) error {
+	// 删除成员前先确定受影响的用户
+	userIDs, lookupErr := groupMemberUserIDs(group.AppID, []uint{group.ID})
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND app_id = ?", group.ID, group.AppID).Delete(&models.Group{}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&models.GroupMember{}, &models.GroupRole{}} {
+			if err := tx.Where("group_id = ? AND app_id = ?", group.ID, group.AppID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("app_id = ? AND (group_id = ? OR parent_group_id = ?)", group.AppID, group.ID, group.ID).
+			Delete(&models.GroupInheritance{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	cacheService := &PermissionCacheService{}
+	cacheService.invalidateGroupUsers(group.AppID, group.ID, userIDs, lookupErr)
+	return nil
+}
+
+// validateGroupMembers 用户和分组须属于同一应用
+func validateGroupMembers(appID string, userIDs, groupIDs []uint) error {
+	for model, ids := range map[interface{}][]uint{&models.User{}: userIDs, &models.Group{}: groupIDs} {
+		if len(ids) == 0 {
+			continue
+		}
+		var count int64
+		if err := config.DB.Model(model).Where("app_id = ? AND id IN ?", appID, ids).Count(&count).Error; err != nil {
+			return err
+		}
+		if count != int64(len(ids)) {
+			return ErrGroupMemberInvalid
+		}
+	}
+	return nil
+}
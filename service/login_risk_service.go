@@ -0,0 +1,587 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"auth-center/config"
+	"auth-center/models"
+	"auth-center/utils"
+
+	"gorm.io/gorm"
+)
+
+// LoginRiskService 登录历史与异常登录策略服务
+type LoginRiskService struct{}
+
+// loginRiskWebhookClient 发送异常登录通知和二次验证码的客户端，拒绝连接非公网地址
+var loginRiskWebhookClient = utils.NewWebhookClient(5 * time.Second)
+
+// 异常登录规则
+const (
+	LoginRiskNewDevice        = "new_device"
+	LoginRiskImpossibleTravel = "impossible_travel"
+	LoginRiskFailureBurst     = "failure_burst"
+)
+
+// 异常登录处置，按严重程度递增
+const (
+	LoginRiskActionNone   = "none"
+	LoginRiskActionNotify = "notify"
+	LoginRiskActionMFA    = "mfa"
+	LoginRiskActionRevoke = "revoke"
+)
+
+// 登录方式名称
+const (
+	LoginMethodPassword = "password"
+	LoginMethodSMS      = "sms"
+	LoginMethodMFA      = "mfa" // 异常登录的短信二次验证
+)
+
+// loginReasonMFAPending 等待二次验证的登录记录的原因，不计入失败次数
+const loginReasonMFAPending = "等待二次验证"
+
+// loginReasonPasswordChangePending 密码已过期、等待设置新密码的登录记录的原因，不计入失败次数
+const loginReasonPasswordChangePending = "密码已过期，等待修改密码"
+
+// minTravelDistanceKm 低于该距离的位置变化视为 GeoIP 误差，不判定为异常移动
+const minTravelDistanceKm = 100
+
+// maxMFAAttempts 每个二次验证令牌允许的验证码错误次数
+const maxMFAAttempts = 5
+
+// loginMFACodeLength 二次验证码的位数
+const loginMFACodeLength = 6
+
+// loginMFAPrefix 异常登录二次验证令牌的缓存键前缀
+const loginMFAPrefix = "login:mfa:"
+
+var (
+	// ErrLoginSessionsRevoked 异常登录撤销了用户全部会话，且账号未绑定手机号无法二次验证，本次登录被拒绝
+	ErrLoginSessionsRevoked = errors.New("检测到异常登录，已拒绝并撤销该账号的全部会话")
+	// ErrLoginMFAUnavailable 异常登录需要二次验证，但用户未绑定手机号
+	ErrLoginMFAUnavailable = errors.New("检测到异常登录，需要二次验证，但账号未绑定手机号")
+	// ErrLoginMFAUndeliverable 异常登录需要二次验证，但验证码无法发送（应用未配置通知地址或发送失败）
+	ErrLoginMFAUndeliverable = errors.New("检测到异常登录，需要二次验证，但验证码发送失败")
+)
+
+// UpdateLoginRiskPolicyRequest 更新异常登录策略请求
+type UpdateLoginRiskPolicyRequest struct {
+	Enabled                bool   `json:"enabled"`
+	NewDeviceAction        string `json:"new_device_action" binding:"omitempty,oneof=none notify mfa revoke"`
+	ImpossibleTravelAction string `json:"impossible_travel_action" binding:"omitempty,oneof=none notify mfa revoke"`
+	FailureBurstAction     string `json:"failure_burst_action" binding:"omitempty,oneof=none notify mfa revoke"`
+	MaxTravelSpeed         int    `json:"max_travel_speed" binding:"min=0"`
+	FailureThreshold       int    `json:"failure_threshold" binding:"min=0"`
+	FailureWindow          int    `json:"failure_window" binding:"min=0"`
+	NotifyWebhook          string `json:"notify_webhook" binding:"omitempty,url"`
+}
+
+// LoginRiskContext 评估异常登录规则所需的信息
+type LoginRiskContext struct {
+	Current        *models.LoginHistory // 本次登录
+	LastSuccess    *models.LoginHistory // 上一次成功登录，没有时为 nil
+	KnownDevice    bool                 // 本次设备此前成功登录过
+	RecentFailures int64                // 统计窗口内的失败次数（不含本次）
+}
+
+// DefaultLoginRiskPolicy 未配置策略时使用的默认策略：只记录命中的规则，不做处置
+func DefaultLoginRiskPolicy(appID string) *models.LoginRiskPolicy {
+	return &models.LoginRiskPolicy{
+		AppID:                  appID,
+		NewDeviceAction:        LoginRiskActionNotify,
+		ImpossibleTravelAction: LoginRiskActionMFA,
+		FailureBurstAction:     LoginRiskActionNotify,
+		MaxTravelSpeed:         900,
+		FailureThreshold:       5,
+		FailureWindow:          600,
+	}
+}
+
+// GetPolicy 获取应用异常登录策略，未配置时返回默认策略
+func (s *LoginRiskService) GetPolicy(appID string) (*models.LoginRiskPolicy, error) {
+	var policy models.LoginRiskPolicy
+	if err := config.DB.Where("app_id = ?", appID).First(&policy).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return DefaultLoginRiskPolicy(appID), nil
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// UpdatePolicy 新增或更新应用异常登录策略，未填写的字段使用默认值；通知地址须为指向公网的 https 地址
+func (s *LoginRiskService) UpdatePolicy(appID string, req *UpdateLoginRiskPolicyRequest) (*models.LoginRiskPolicy, error) {
+	if req.NotifyWebhook != "" {
+		if err := utils.ValidateWebhookURL(req.NotifyWebhook); err != nil {
+			return nil, err
+		}
+	}
+
+	var policy models.LoginRiskPolicy
+	err := config.DB.Where("app_id = ?", appID).First(&policy).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	defaults := DefaultLoginRiskPolicy(appID)
+	orDefault := func(v, def string) string {
+		if v == "" {
+			return def
+		}
+		return v
+	}
+	orDefaultInt := func(v, def int) int {
+		if v == 0 {
+			return def
+		}
+		return v
+	}
+
+	policy.AppID = appID
+	policy.Enabled = req.Enabled
+	policy.NewDeviceAction = orDefault(req.NewDeviceAction, defaults.NewDeviceAction)
+	policy.ImpossibleTravelAction = orDefault(req.ImpossibleTravelAction, defaults.ImpossibleTravelAction)
+	policy.FailureBurstAction = orDefault(req.FailureBurstAction, defaults.FailureBurstAction)
+	policy.MaxTravelSpeed = orDefaultInt(req.MaxTravelSpeed, defaults.MaxTravelSpeed)
+	policy.FailureThreshold = orDefaultInt(req.FailureThreshold, defaults.FailureThreshold)
+	policy.FailureWindow = orDefaultInt(req.FailureWindow, defaults.FailureWindow)
+	policy.NotifyWebhook = req.NotifyWebhook
+
+	if err := config.DB.Save(&policy).Error; err != nil {
+		return nil, fmt.Errorf("保存异常登录策略失败: %v", err)
+	}
+	return &policy, nil
+}
+
+// LoginHistoryQuery 登录历史查询条件
+type LoginHistoryQuery struct {
+	AppID    string
+	UserID   uint
+	Success  *bool
+	Risky    bool // 只返回命中异常规则的记录
+	Page     int
+	PageSize int
+}
+
+// ListLoginHistory 按时间倒序分页查询登录历史
+func (s *LoginRiskService) ListLoginHistory(query *LoginHistoryQuery) ([]models.LoginHistory, int64, error) {
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 || query.PageSize > 100 {
+		query.PageSize = 20
+	}
+
+	db := config.DB.Model(&models.LoginHistory{}).Where("app_id = ? AND user_id = ?", query.AppID, query.UserID)
+	if query.Success != nil {
+		db = db.Where("success = ?", *query.Success)
+	}
+	if query.Risky {
+		db = db.Where("risk_flags <> ''")
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var histories []models.LoginHistory
+	err := db.Order("id DESC").Offset((query.Page - 1) * query.PageSize).Limit(query.PageSize).Find(&histories).Error
+	return histories, total, err
+}
+
+// EvaluateLoginRisk 评估成功登录命中的异常规则，返回命中的规则和应采取的处置
+// 策略未启用时仍返回命中的规则（用于记录），处置为 none
+func EvaluateLoginRisk(policy *models.LoginRiskPolicy, rc *LoginRiskContext) ([]string, string) {
+	var flags []string
+	action := LoginRiskActionNone
+	hit := func(flag, flagAction string) {
+		flags = append(flags, flag)
+		if policy.Enabled && loginRiskActionRank(flagAction) > loginRiskActionRank(action) {
+			action = flagAction
+		}
+	}
+
+	// 已有成功登录记录的账号使用未见过的设备
+	if rc.LastSuccess != nil && !rc.KnownDevice {
+		hit(LoginRiskNewDevice, policy.NewDeviceAction)
+	}
+
+	// 与上一次成功登录的位置相比，移动速度超过上限
+	if rc.LastSuccess != nil && hasGeoLocation(rc.LastSuccess) && hasGeoLocation(rc.Current) && policy.MaxTravelSpeed > 0 {
+		distance := utils.GeoDistanceKm(rc.LastSuccess.Latitude, rc.LastSuccess.Longitude, rc.Current.Latitude, rc.Current.Longitude)
+		hours := rc.Current.CreatedAt.Sub(rc.LastSuccess.CreatedAt).Hours()
+		if hours < 1.0/60 {
+			hours = 1.0 / 60
+		}
+		if distance >= minTravelDistanceKm && distance/hours > float64(policy.MaxTravelSpeed) {
+			hit(LoginRiskImpossibleTravel, policy.ImpossibleTravelAction)
+		}
+	}
+
+	// 登录成功前短时间内出现大量失败
+	if policy.FailureThreshold > 0 && rc.RecentFailures >= int64(policy.FailureThreshold) {
+		hit(LoginRiskFailureBurst, policy.FailureBurstAction)
+	}
+
+	return flags, action
+}
+
+// loginRiskActionRank 处置的严重程度
+func loginRiskActionRank(action string) int {
+	switch action {
+	case LoginRiskActionNotify:
+		return 1
+	case LoginRiskActionMFA:
+		return 2
+	case LoginRiskActionRevoke:
+		return 3
+	}
+	return 0
+}
+
+// hasGeoLocation 登录记录是否带有经纬度（GeoIP 未命中时经纬度均为 0）
+func hasGeoLocation(h *models.LoginHistory) bool {
+	return h.Latitude != 0 || h.Longitude != 0
+}
+
+// LoginDeviceID 计算设备标识：优先使用客户端上报的设备ID，否则使用 User-Agent 摘要
+func LoginDeviceID(deviceID, userAgent string) string {
+	if deviceID != "" {
+		return truncate(deviceID, 128)
+	}
+	if userAgent == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(userAgent))
+	return "ua:" + hex.EncodeToString(sum[:16])
+}
+
+var (
+	geoIPOnce   sync.Once
+	geoIPReader *utils.GeoIPReader
+)
+
+// LookupGeoIP 使用配置的本地 GeoIP 数据库查询IP位置，未配置或未命中时返回 nil
+func LookupGeoIP(ip string) *utils.GeoLocation {
+	geoIPOnce.Do(func() {
+		path := config.GetConfig().LoginRisk.GeoIPFile
+		if path == "" {
+			return
+		}
+		reader, err := utils.OpenGeoIP(path)
+		if err != nil {
+			log.Printf("加载 GeoIP 数据库失败: %v", err)
+			return
+		}
+		geoIPReader = reader
+	})
+
+	parsed := net.ParseIP(ip)
+	if geoIPReader == nil || parsed == nil {
+		return nil
+	}
+	loc, err := geoIPReader.Lookup(parsed)
+	if err != nil {
+		return nil
+	}
+	return loc
+}
+
+// newLoginHistory 创建登录历史记录（尚未保存），并按客户端IP查询地理位置
+func newLoginHistory(appID, account, clientIP, userAgent, deviceID string) *models.LoginHistory {
+	history := &models.LoginHistory{
+		AppID:     appID,
+		Account:   truncate(account, 191),
+		IP:        truncate(clientIP, 64),
+		UserAgent: truncate(userAgent, 512),
+		DeviceID:  LoginDeviceID(deviceID, userAgent),
+		CreatedAt: time.Now(),
+	}
+	if loc := LookupGeoIP(clientIP); loc != nil {
+		history.Country = truncate(loc.Country, 8)
+		history.City = truncate(loc.City, 128)
+		history.Latitude = loc.Latitude
+		history.Longitude = loc.Longitude
+	}
+	return history
+}
+
+// assessLoginRisk 凭证校验通过后评估异常登录规则，结果写入登录历史并返回处置
+func assessLoginRisk(history *models.LoginHistory) string {
+	policy, err := (&LoginRiskService{}).GetPolicy(history.AppID)
+	if err != nil {
+		log.Printf("读取异常登录策略失败: %v", err)
+		return LoginRiskActionNone
+	}
+
+	rc := &LoginRiskContext{Current: history}
+	var last models.LoginHistory
+	if err := config.DB.Where("app_id = ? AND user_id = ? AND success = ?", history.AppID, history.UserID, true).
+		Order("id DESC").First(&last).Error; err == nil {
+		rc.LastSuccess = &last
+		var known int64
+		config.DB.Model(&models.LoginHistory{}).
+			Where("app_id = ? AND user_id = ? AND success = ? AND device_id = ?", history.AppID, history.UserID, true, history.DeviceID).
+			Count(&known)
+		rc.KnownDevice = known > 0
+	}
+	rc.RecentFailures = countRecentLoginFailures(policy, history)
+
+	flags, action := EvaluateLoginRisk(policy, rc)
+	history.RiskFlags = strings.Join(flags, ",")
+	if action != LoginRiskActionNone {
+		history.RiskAction = action
+		notifyLoginRisk(policy, history)
+	}
+	return action
+}
+
+// countRecentLoginFailures 统计策略窗口内同一账号来自本次IP或设备的失败登录次数；
+// 只统计与本次登录同源的失败，避免他人对该账号的失败尝试影响正常用户的登录
+func countRecentLoginFailures(policy *models.LoginRiskPolicy, history *models.LoginHistory) int64 {
+	if policy.FailureThreshold <= 0 || policy.FailureWindow <= 0 || history.Account == "" {
+		return 0
+	}
+	if history.IP == "" && history.DeviceID == "" {
+		return 0
+	}
+	var count int64
+	since := time.Now().Add(-time.Duration(policy.FailureWindow) * time.Second)
+	config.DB.Model(&models.LoginHistory{}).
+		Where("app_id = ? AND account = ? AND success = ? AND reason NOT IN ? AND created_at >= ?",
+			history.AppID, history.Account, false, []string{loginReasonMFAPending, loginReasonPasswordChangePending}, since).
+		Where("(ip <> '' AND ip = ?) OR (device_id <> '' AND device_id = ?)", history.IP, history.DeviceID).
+		Count(&count)
+	return count
+}
+
+// recordLoginHistory 保存登录历史；失败登录达到阈值时标记并通知（失败时不撤销会话，避免被利用来踢出正常用户）
+func recordLoginHistory(history *models.LoginHistory, response *LoginResponse, loginErr error) {
+	if config.DB == nil {
+		return
+	}
+	switch {
+	case loginErr == nil && response != nil && response.MFARequired:
+		history.Reason = loginReasonMFAPending
+	case loginErr == nil && response != nil && response.PasswordExpired:
+		history.Reason = loginReasonPasswordChangePending
+	case loginErr == nil:
+		history.Success = true
+	default:
+		history.Success = false
+		history.Reason = truncate(loginErr.Error(), 255)
+		if history.AppID != "" && history.RiskAction == "" {
+			if policy, err := (&LoginRiskService{}).GetPolicy(history.AppID); err == nil &&
+				policy.FailureThreshold > 0 && countRecentLoginFailures(policy, history)+1 == int64(policy.FailureThreshold) {
+				history.RiskFlags = LoginRiskFailureBurst
+				if policy.Enabled && policy.FailureBurstAction != LoginRiskActionNone {
+					history.RiskAction = LoginRiskActionNotify
+					notifyLoginRisk(policy, history)
+				}
+			}
+		}
+	}
+
+	if err := config.DB.Create(history).Error; err != nil {
+		log.Printf("记录登录历史失败: %v", err)
+	}
+}
+
+// notifyLoginRisk 异步发送异常登录通知，未配置通知地址时只写日志
+func notifyLoginRisk(policy *models.LoginRiskPolicy, history *models.LoginHistory) {
+	log.Printf("异常登录: app=%s user=%d account=%s ip=%s flags=%s action=%s",
+		history.AppID, history.UserID, history.Account, history.IP, history.RiskFlags, history.RiskAction)
+	if policy.NotifyWebhook == "" {
+		return
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"event":      "suspicious_login",
+		"app_id":     history.AppID,
+		"user_id":    history.UserID,
+		"account":    history.Account,
+		"success":    history.Success,
+		"flags":      strings.Split(history.RiskFlags, ","),
+		"action":     history.RiskAction,
+		"ip":         history.IP,
+		"user_agent": history.UserAgent,
+		"country":    history.Country,
+		"city":       history.City,
+		"time":       history.CreatedAt.Format(time.RFC3339),
+	})
+	go func(url string) {
+		if err := utils.PostWebhook(loginRiskWebhookClient, url, payload); err != nil {
+			log.Printf("发送异常登录通知失败: %v", err)
+		}
+	}(policy.NotifyWebhook)
+}
+
+// RevokeUserSessions 撤销用户在应用内的全部会话：未过期的访问令牌和刷新令牌加入黑名单并删除令牌记录
+func RevokeUserSessions(appID string, userID uint) error {
+	var tokens []models.Token
+	if err := config.DB.Where("app_id = ? AND user_id = ? AND expires_at > ?", appID, userID, time.Now()).Find(&tokens).Error; err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		var claims *utils.JWTClaims
+		var err error
+		if token.Type == "refresh" {
+			claims, err = utils.ParseRefreshToken(token.Token)
+		} else {
+			claims, err = utils.ParseAccessToken(token.Token)
+		}
+		if err != nil {
+			continue
+		}
+		ttl := time.Until(token.ExpiresAt)
+		if ttl <= 0 {
+			continue
+		}
+		if err := utils.Set(utils.TokenBlacklistPrefix+claims.JTI, "1", ttl); err != nil {
+			return err
+		}
+	}
+
+	return config.DB.Where("app_id = ? AND user_id = ?", appID, userID).Delete(&models.Token{}).Error
+}
+
+// loginMFAChallenge 异常登录二次验证的待完成登录
+type loginMFAChallenge struct {
+	AppID    string `json:"app_id"`
+	UserID   uint   `json:"user_id"`
+	Account  string `json:"account"`   // 首次登录使用的用户名或手机号
+	CodeHash string `json:"code_hash"` // 发送给用户的验证码与令牌的摘要，验证码只对该令牌有效
+}
+
+// createLoginMFAChallenge 生成二次验证令牌和一次性验证码，并将验证码发送到用户绑定的手机号
+func createLoginMFAChallenge(appID string, user *models.User, account string) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+	code, err := generateLoginMFACode()
+	if err != nil {
+		return "", err
+	}
+
+	data, _ := json.Marshal(&loginMFAChallenge{AppID: appID, UserID: user.ID, Account: account, CodeHash: loginMFACodeHash(token, code)})
+	if err := utils.Set(loginMFAPrefix+token, string(data), loginMFATTL()); err != nil {
+		return "", err
+	}
+	if err := sendLoginMFACode(appID, user, code); err != nil {
+		utils.Del(loginMFAPrefix + token)
+		return "", err
+	}
+	return token, nil
+}
+
+// loginMFACodeHash 计算验证码摘要，与令牌绑定
+func loginMFACodeHash(token, code string) string {
+	sum := sha256.Sum256([]byte(token + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateLoginMFACode 生成 loginMFACodeLength 位数字验证码
+func generateLoginMFACode() (string, error) {
+	max := big.NewInt(int64(math.Pow10(loginMFACodeLength)))
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", loginMFACodeLength, n.Int64()), nil
+}
+
+// sendLoginMFACode 通过应用异常登录策略的通知地址发送验证码（事件 login_mfa_code），由应用负责下发短信
+func sendLoginMFACode(appID string, user *models.User, code string) error {
+	policy, err := (&LoginRiskService{}).GetPolicy(appID)
+	if err != nil {
+		return err
+	}
+	if policy.NotifyWebhook == "" {
+		return ErrLoginMFAUndeliverable
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"event":      "login_mfa_code",
+		"app_id":     appID,
+		"user_id":    user.ID,
+		"phone":      user.Phone,
+		"code":       code,
+		"expires_in": int64(loginMFATTL().Seconds()),
+	})
+	if err := utils.PostWebhook(loginRiskWebhookClient, policy.NotifyWebhook, payload); err != nil {
+		log.Printf("发送二次验证码失败: %v", err)
+		return ErrLoginMFAUndeliverable
+	}
+	return nil
+}
+
+// loginMFATTL 二次验证令牌有效期
+func loginMFATTL() time.Duration {
+	ttl := config.GetConfig().LoginRisk.MFATTL
+	if ttl <= 0 {
+		ttl = 300
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+// consumeLoginMFAChallenge 校验二次验证令牌和验证码，校验通过后令牌失效（只能使用一次）；
+// 错误次数以独立的计数键原子累加，达到 maxMFAAttempts 次后令牌同样失效，须重新登录。
+// 令牌有效时返回其内容（校验失败时也返回，用于记录登录历史）
+func consumeLoginMFAChallenge(appID, token, code string) (*loginMFAChallenge, error) {
+	key := loginMFAPrefix + token
+	attemptsKey := key + ":attempts"
+	data, err := utils.Get(key)
+	if err != nil {
+		return nil, errors.New("二次验证令牌无效或已过期")
+	}
+	var challenge loginMFAChallenge
+	if err := json.Unmarshal([]byte(data), &challenge); err != nil || challenge.AppID != appID {
+		return nil, errors.New("二次验证令牌无效或已过期")
+	}
+
+	counts, err := utils.IncrAll(attemptsKey)
+	if err != nil {
+		return &challenge, err
+	}
+	if counts[0] == 1 {
+		utils.Expire(attemptsKey, loginMFATTL())
+	}
+	if counts[0] > maxMFAAttempts {
+		utils.Del(key)
+		return &challenge, errors.New("验证码错误次数过多，请重新登录")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(loginMFACodeHash(token, code)), []byte(challenge.CodeHash)) != 1 {
+		if counts[0] >= maxMFAAttempts {
+			utils.Del(key)
+			utils.Del(attemptsKey)
+			return &challenge, errors.New("验证码错误次数过多，请重新登录")
+		}
+		return &challenge, errors.New("验证码错误或已过期")
+	}
+
+	// 原子地取出并删除令牌，并发提交同一验证码时只有一个请求能完成登录
+	if _, err := utils.GetDel(key); err != nil {
+		return &challenge, errors.New("二次验证令牌无效或已过期")
+	}
+	utils.Del(attemptsKey)
+	return &challenge, nil
+}
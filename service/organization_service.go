@@ -0,0 +1,231 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"auth-center/config"
+	"auth-center/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 组织：应用内的子租户。用户可以属于多个组织，在每个组织中分配不同的角色。
+// 令牌带有当前组织（org_id，登录时选择或通过切换组织接口重新签发），
+// 权限判定时用户的角色为应用级的角色（直接分配和通过分组获得）加上当前组织内的角色；
+// 不在组织内（org_id 为 0）时只有应用级的角色
+
+// OrganizationService 组织服务
+type OrganizationService struct{}
+
+var (
+	// ErrOrganizationNotFound 组织不存在或已停用
+	ErrOrganizationNotFound = errors.New("组织不存在或已停用")
+	// ErrOrganizationNotMember 用户不是组织成员
+	ErrOrganizationNotMember = errors.New("用户不是该组织的成员")
+)
+
+// OrganizationMemberInfo 组织成员及其在组织内的角色
+type OrganizationMemberInfo struct {
+	UserID  uint   `json:"user_id"`
+	RoleIDs []uint `json:"role_ids"`
+}
+
+// organizationRoleIDs 用户在组织内的角色，组织已停用或用户不是成员时为空
+func organizationRoleIDs(userID uint, appID string, orgID uint) ([]uint, error) {
+	if err := checkOrganizationMember(appID, orgID, userID); err != nil {
+		if errors.Is(err, ErrOrganizationNotFound) || errors.Is(err, ErrOrganizationNotMember) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var roleIDs []uint
+	err := config.DB.Model(&models.OrganizationMemberRole{}).Where("app_id = ? AND org_id = ? AND user_id = ?", appID, orgID, userID).
+		Pluck("role_id", &roleIDs).Error
+	return roleIDs, err
+}
+
+// checkOrganizationMember 组织须已启用且用户为其成员
+func checkOrganizationMember(appID string, orgID, userID uint) error {
+	var org models.Organization
+	if err := config.DB.Where("id = ? AND app_id = ? AND status = 1", orgID, appID).First(&org).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrOrganizationNotFound
+		}
+		return err
+	}
+	var count int64
+	if err := config.DB.Model(&models.OrganizationMember{}).Where("app_id = ? AND org_id = ? AND user_id = ?", appID, orgID, userID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrOrganizationNotMember
+	}
+	return nil
+}
+
+// organizationMemberIDs 组织的全部成员
+func organizationMemberIDs(appID string, orgID uint) ([]uint, error) {
+	var userIDs []uint
+	err := config.DB.Model(&models.OrganizationMember{}).Where("app_id = ? AND org_id = ?", appID, orgID).
+		Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
+
+// ListUserOrganizations 获取用户所属的已启用组织
+func (s *OrganizationService) ListUserOrganizations(appID string, userID uint) ([]models.Organization, error) {
+	organizations := []models.Organization{}
+	err := config.DB.Where("app_id = ? AND status = 1 AND id IN (?)", appID,
+		config.DB.Model(&models.OrganizationMember{}).Select("org_id").Where("app_id = ? AND user_id = ?", appID, userID)).
+		Order("id").Find(&organizations).Error
+	return organizations, err
+}
+
+// GetMembers 获取组织成员及其在组织内的角色
+func (s *OrganizationService) GetMembers(appID string, orgID uint) ([]OrganizationMemberInfo, error) {
+	userIDs, err := organizationMemberIDs(appID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	var assignments []models.OrganizationMemberRole
+	if err := config.DB.Where("app_id = ? AND org_id = ?", appID, orgID).Order("role_id").Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+	roles := make(map[uint][]uint)
+	for _, assignment := range assignments {
+		roles[assignment.UserID] = append(roles[assignment.UserID], assignment.RoleID)
+	}
+
+	members := make([]OrganizationMemberInfo, 0, len(userIDs))
+	for _, userID := range uniqueIDs(userIDs) {
+		roleIDs := roles[userID]
+		if roleIDs == nil {
+			roleIDs = []uint{}
+		}
+		members = append(members, OrganizationMemberInfo{UserID: userID, RoleIDs: roleIDs})
+	}
+	return members, nil
+}
+
+// SetMember 将用户加入组织（已是成员时保留）并替换其在组织内的角色，用户和角色须属于同一应用
+func (s *OrganizationService) SetMember(appID string, orgID, userID uint, roleIDs []uint) error {
+	roleIDs = uniqueIDs(roleIDs)
+	var count int64
+	if err := config.DB.Model(&models.User{}).Where("id = ? AND app_id = ?", userID, appID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("用户不存在或不属于当前应用")
+	}
+	if len(roleIDs) > 0 {
+		if err := config.DB.Model(&models.Role{}).Where("app_id = ? AND id IN ?", appID, roleIDs).Count(&count).Error; err != nil {
+			return err
+		}
+		if count != int64(len(roleIDs)) {
+			return errors.New("角色不存在或不属于当前应用")
+		}
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		member := models.OrganizationMember{AppID: appID, OrgID: orgID, UserID: userID}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&member).Error; err != nil {
+			return fmt.Errorf("保存组织成员失败: %v", err)
+		}
+		if err := tx.Where("app_id = ? AND org_id = ? AND user_id = ?", appID, orgID, userID).
+			Delete(&models.OrganizationMemberRole{}).Error; err != nil {
+			return err
+		}
+		for _, roleID := range roleIDs {
+			assignment := models.OrganizationMemberRole{AppID: appID, OrgID: orgID, UserID: userID, RoleID: roleID}
+			if err := tx.Create(&assignment).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	cacheService := &PermissionCacheService{}
+	cacheService.InvalidateUser(appID, userID)
+	return nil
+}
+
+// RemoveMember 将用户移出组织并删除其在组织内的角色。
+// 已签发的以该组织为当前组织的令牌中，组织内的角色随即不再生效，且不能再刷新
+func (s *OrganizationService) RemoveMember(appID string, orgID, userID uint) error {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&models.OrganizationMember{}, &models.OrganizationMemberRole{}} {
+			if err := tx.Where("app_id = ? AND org_id = ? AND user_id = ?", appID, orgID, userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	cacheService := &PermissionCacheService{}
+	cacheService.InvalidateUser(appID, userID)
+	return nil
+}
+
+// UpdateOrganization 更新组织，状态变更时组织内的角色随之生效或失效
+func (s *OrganizationService) UpdateOrganization(org *models.Organization, updates map[string]interface{}) error {
+	previousStatus := org.Status
+	if err := config.DB.Model(org).Updates(updates).Error; err != nil {
+		return err
+	}
+	if status, ok := updates["status"]; ok && status != previousStatus {
+		invalidateOrganizationMembers(org.AppID, org.ID)
+	}
+	return nil
+}
+
+// DeleteOrganization 删除组织及其成员和成员的角色
+func (s *OrganizationService) DeleteOrganization(org *models.Organization) error {
+	// 删除成员前先确定受影响的用户
+	userIDs, lookupErr := organizationMemberIDs(org.AppID, org.ID)
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND app_id = ?", org.ID, org.AppID).Delete(&models.Organization{}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&models.OrganizationMember{}, &models.OrganizationMemberRole{}} {
+			if err := tx.Where("org_id = ? AND app_id = ?", org.ID, org.AppID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	cacheService := &PermissionCacheService{}
+	if lookupErr != nil {
+		cacheService.InvalidateApp(org.AppID)
+		return nil
+	}
+	for _, userID := range userIDs {
+		cacheService.InvalidateUser(org.AppID, userID)
+	}
+	return nil
+}
+
+// invalidateOrganizationMembers 使组织全部成员的权限缓存失效，无法确定成员时整个应用失效
+func invalidateOrganizationMembers(appID string, orgID uint) {
+	cacheService := &PermissionCacheService{}
+	userIDs, err := organizationMemberIDs(appID, orgID)
+	if err != nil {
+		cacheService.InvalidateApp(appID)
+		return
+	}
+	for _, userID := range userIDs {
+		cacheService.InvalidateUser(appID, userID)
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"auth-center/config"
+	"auth-center/models"
+	"auth-center/utils"
+)
+
+// maxImportUsers 单次导入的最大用户数
+const maxImportUsers = 1000
+
+// PasswordHashService 密码哈希迁移服务
+type PasswordHashService struct{}
+
+// ImportUserItem 导入用户（携带旧系统的密码哈希）
+type ImportUserItem struct {
+	Username string           `json:"username" binding:"required"`
+	Email    string           `json:"email"`
+	Phone    string           `json:"phone"`
+	Status   *int             `json:"status"`
+	Password utils.LegacyHash `json:"password" binding:"required"`
+}
+
+// ImportUsersRequest 批量导入用户请求
+type ImportUsersRequest struct {
+	Users []ImportUserItem `json:"users" binding:"required,min=1,dive"`
+}
+
+// ImportUserFailure 导入失败项
+type ImportUserFailure struct {
+	Username string `json:"username"`
+	Error    string `json:"error"`
+}
+
+// ImportUsersResponse 批量导入用户结果
+type ImportUsersResponse struct {
+	Imported int                 `json:"imported"`
+	Failed   []ImportUserFailure `json:"failed"`
+}
+
+// ImportUsers 批量导入用户，保留旧系统的密码哈希
+// 旧式哈希（PBKDF2、加盐 SHA）仅用于验证，用户首次登录成功后自动升级为当前算法
+func (s *PasswordHashService) ImportUsers(appID string, req *ImportUsersRequest) (*ImportUsersResponse, error) {
+	if len(req.Users) > maxImportUsers {
+		return nil, fmt.Errorf("单次最多导入%d个用户", maxImportUsers)
+	}
+
+	result := &ImportUsersResponse{Failed: []ImportUserFailure{}}
+	for i := range req.Users {
+		item := &req.Users[i]
+		if err := s.importUser(appID, item); err != nil {
+			result.Failed = append(result.Failed, ImportUserFailure{Username: item.Username, Error: err.Error()})
+			continue
+		}
+		result.Imported++
+	}
+	return result, nil
+}
+
+// importUser 导入单个用户
+func (s *PasswordHashService) importUser(appID string, item *ImportUserItem) error {
+	encoded, err := utils.EncodeLegacyHash(&item.Password)
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	config.DB.Model(&models.User{}).Where("app_id = ? AND username = ?", appID, item.Username).Count(&count)
+	if count > 0 {
+		return errors.New("用户名已存在")
+	}
+	if item.Email != "" {
+		config.DB.Model(&models.User{}).Where("app_id = ? AND email = ?", appID, item.Email).Count(&count)
+		if count > 0 {
+			return errors.New("邮箱已存在")
+		}
+	}
+
+	status := 1
+	if item.Status != nil {
+		status = *item.Status
+	}
+
+	now := time.Now()
+	user := models.User{
+		AppID:             appID,
+		Username:          item.Username,
+		Email:             item.Email,
+		Phone:             item.Phone,
+		Password:          encoded,
+		Status:            status,
+		PasswordChangedAt: &now,
+	}
+	if err := config.DB.Create(&user).Error; err != nil {
+		return errors.New("创建用户失败")
+	}
+	return nil
+}
+
+// upgradePasswordHash 登录验证成功后，若存储的哈希算法或参数已过时，则使用当前算法重新哈希
+// 升级失败不影响登录
+func upgradePasswordHash(model interface{}, password, encodedHash string) {
+	if !utils.PasswordNeedsRehash(encodedHash) {
+		return
+	}
+
+	newHash, err := utils.HashPassword(password)
+	if err != nil {
+		log.Printf("密码哈希升级失败: %v", err)
+		return
+	}
+	if err := config.DB.Model(model).Update("password", newHash).Error; err != nil {
+		log.Printf("密码哈希升级失败: %v", err)
+	}
+}
@@ -0,0 +1,373 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"auth-center/config"
+	"auth-center/models"
+	"auth-center/utils"
+
+	"gorm.io/gorm"
+)
+
+// PasswordPolicyService 密码策略服务
+type PasswordPolicyService struct{}
+
+// 密码历史的账号类型
+const (
+	AccountTypeUser        = "user"
+	AccountTypeSystemAdmin = "system_admin"
+)
+
+// SystemAdminPolicyAppID 系统级管理员密码策略的 app_id。应用ID不能为空，该策略不会与任何应用的策略冲突，
+// 通过 /system-admins/password-policy 管理
+const SystemAdminPolicyAppID = ""
+
+// legacySystemAdminPolicyAppID 旧版本中系统级管理员借用的 system-admin 应用的策略
+const legacySystemAdminPolicyAppID = "system-admin"
+
+// maxPasswordHistory 每个账号最多保留的密码历史条数
+const maxPasswordHistory = 24
+
+// passwordChangePrefix 密码过期后待修改密码的登录的缓存键前缀
+const passwordChangePrefix = "password:change:"
+
+// passwordChangeTTL 修改过期密码令牌的有效期
+const passwordChangeTTL = 10 * time.Minute
+
+// ErrPasswordExpired 密码已超过最长使用期限，须重新登录并修改密码
+var ErrPasswordExpired = errors.New("密码已过期，请重新登录并修改密码")
+
+// UpdatePasswordPolicyRequest 更新密码策略请求
+type UpdatePasswordPolicyRequest struct {
+	MinLength      int  `json:"min_length" binding:"required,min=1,max=128"`
+	MaxLength      int  `json:"max_length" binding:"omitempty,min=1,max=1024"`
+	RequireUpper   bool `json:"require_upper"`
+	RequireLower   bool `json:"require_lower"`
+	RequireDigit   bool `json:"require_digit"`
+	RequireSymbol  bool `json:"require_symbol"`
+	MinCharClasses int  `json:"min_char_classes" binding:"min=0,max=4"`
+	ForbidUsername bool `json:"forbid_username"`
+	MaxAgeDays     int  `json:"max_age_days" binding:"min=0"`
+	HistoryCount   int  `json:"history_count" binding:"min=0,max=24"`
+}
+
+// PasswordViolation 密码策略违规项
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError 密码不符合策略
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Message)
+	}
+	return "密码不符合安全策略: " + strings.Join(messages, "；")
+}
+
+// DefaultPasswordPolicy 未配置策略时使用的默认密码策略
+func DefaultPasswordPolicy(appID string) *models.PasswordPolicy {
+	return &models.PasswordPolicy{
+		AppID:     appID,
+		MinLength: 6,
+		MaxLength: 128,
+	}
+}
+
+// GetPolicy 获取应用密码策略，未配置时返回默认策略
+func (s *PasswordPolicyService) GetPolicy(appID string) (*models.PasswordPolicy, error) {
+	var policy models.PasswordPolicy
+	if err := config.DB.Where("app_id = ?", appID).First(&policy).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return DefaultPasswordPolicy(appID), nil
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// UpdatePolicy 新增或更新应用密码策略
+func (s *PasswordPolicyService) UpdatePolicy(appID string, req *UpdatePasswordPolicyRequest) (*models.PasswordPolicy, error) {
+	maxLength := req.MaxLength
+	if maxLength == 0 {
+		maxLength = 128
+	}
+	if maxLength < req.MinLength {
+		return nil, errors.New("最大长度不能小于最小长度")
+	}
+
+	var policy models.PasswordPolicy
+	err := config.DB.Where("app_id = ?", appID).First(&policy).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	policy.AppID = appID
+	policy.MinLength = req.MinLength
+	policy.MaxLength = maxLength
+	policy.RequireUpper = req.RequireUpper
+	policy.RequireLower = req.RequireLower
+	policy.RequireDigit = req.RequireDigit
+	policy.RequireSymbol = req.RequireSymbol
+	policy.MinCharClasses = req.MinCharClasses
+	policy.ForbidUsername = req.ForbidUsername
+	policy.MaxAgeDays = req.MaxAgeDays
+	policy.HistoryCount = req.HistoryCount
+
+	if err := config.DB.Save(&policy).Error; err != nil {
+		return nil, fmt.Errorf("保存密码策略失败: %v", err)
+	}
+	return &policy, nil
+}
+
+// ValidatePassword 按策略校验密码强度（不含历史校验），返回全部违规项
+func ValidatePassword(policy *models.PasswordPolicy, username, password string) []PasswordViolation {
+	var violations []PasswordViolation
+
+	length := utf8.RuneCountInString(password)
+	if length < policy.MinLength {
+		violations = append(violations, PasswordViolation{
+			Code:    "min_length",
+			Message: fmt.Sprintf("密码长度不能少于%d位", policy.MinLength),
+		})
+	}
+	if policy.MaxLength > 0 && length > policy.MaxLength {
+		violations = append(violations, PasswordViolation{
+			Code:    "max_length",
+			Message: fmt.Sprintf("密码长度不能超过%d位", policy.MaxLength),
+		})
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case !unicode.IsLetter(r) && !unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	if policy.RequireUpper && !hasUpper {
+		violations = append(violations, PasswordViolation{Code: "require_upper", Message: "密码必须包含大写字母"})
+	}
+	if policy.RequireLower && !hasLower {
+		violations = append(violations, PasswordViolation{Code: "require_lower", Message: "密码必须包含小写字母"})
+	}
+	if policy.RequireDigit && !hasDigit {
+		violations = append(violations, PasswordViolation{Code: "require_digit", Message: "密码必须包含数字"})
+	}
+	if policy.RequireSymbol && !hasSymbol {
+		violations = append(violations, PasswordViolation{Code: "require_symbol", Message: "密码必须包含特殊字符"})
+	}
+
+	if policy.MinCharClasses > 0 {
+		classes := 0
+		for _, has := range []bool{hasUpper, hasLower, hasDigit, hasSymbol} {
+			if has {
+				classes++
+			}
+		}
+		if classes < policy.MinCharClasses {
+			violations = append(violations, PasswordViolation{
+				Code:    "min_char_classes",
+				Message: fmt.Sprintf("密码至少需要包含大写字母、小写字母、数字、特殊字符中的%d类", policy.MinCharClasses),
+			})
+		}
+	}
+
+	if policy.ForbidUsername && utf8.RuneCountInString(username) >= 3 &&
+		strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		violations = append(violations, PasswordViolation{Code: "contains_username", Message: "密码不能包含用户名"})
+	}
+
+	return violations
+}
+
+// CheckPassword 按应用策略校验新密码，accountID 非 0 时同时校验密码历史
+// 不符合策略时返回 *PasswordPolicyError
+func (s *PasswordPolicyService) CheckPassword(appID, accountType string, accountID uint, username, password string) error {
+	policy, err := s.GetPolicy(appID)
+	if err != nil {
+		return err
+	}
+
+	violations := ValidatePassword(policy, username, password)
+
+	if IsPasswordBreached(password) {
+		violations = append(violations, PasswordViolation{
+			Code:    "breached",
+			Message: "该密码已出现在公开泄露的密码库中，请更换",
+		})
+	}
+
+	if accountID != 0 && policy.HistoryCount > 0 {
+		reused, err := s.isRecentlyUsed(accountType, accountID, password, policy.HistoryCount)
+		if err != nil {
+			return err
+		}
+		if reused {
+			violations = append(violations, PasswordViolation{
+				Code:    "password_reused",
+				Message: fmt.Sprintf("不能使用最近%d次使用过的密码", policy.HistoryCount),
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// RecordPasswordHistory 在 tx 中记录密码历史，并清理超出保留条数的旧记录；
+// 与密码的写入使用同一事务，历史写入失败时密码修改一并回滚
+func (s *PasswordPolicyService) RecordPasswordHistory(tx *gorm.DB, appID, accountType string, accountID uint, passwordHash string) error {
+	history := models.PasswordHistory{
+		AccountType:  accountType,
+		AccountID:    accountID,
+		AppID:        appID,
+		PasswordHash: passwordHash,
+	}
+	if err := tx.Create(&history).Error; err != nil {
+		return err
+	}
+
+	var staleIDs []uint
+	if err := tx.Model(&models.PasswordHistory{}).
+		Where("account_type = ? AND account_id = ?", accountType, accountID).
+		Order("id DESC").Offset(maxPasswordHistory).Pluck("id", &staleIDs).Error; err != nil {
+		return err
+	}
+	if len(staleIDs) > 0 {
+		return tx.Where("id IN ?", staleIDs).Delete(&models.PasswordHistory{}).Error
+	}
+	return nil
+}
+
+// IsPasswordExpired 判断密码是否超过策略规定的最长使用期限
+func (s *PasswordPolicyService) IsPasswordExpired(appID string, changedAt *time.Time) bool {
+	if changedAt == nil {
+		return false
+	}
+	policy, err := s.GetPolicy(appID)
+	if err != nil || policy.MaxAgeDays <= 0 {
+		return false
+	}
+	return time.Since(*changedAt) > time.Duration(policy.MaxAgeDays)*24*time.Hour
+}
+
+// isRecentlyUsed 检查密码是否与最近 n 次使用过的密码相同
+func (s *PasswordPolicyService) isRecentlyUsed(accountType string, accountID uint, password string, n int) (bool, error) {
+	var histories []models.PasswordHistory
+	if err := config.DB.Where("account_type = ? AND account_id = ?", accountType, accountID).
+		Order("id DESC").Limit(n).Find(&histories).Error; err != nil {
+		return false, err
+	}
+
+	for _, h := range histories {
+		if ok, _ := utils.VerifyPassword(password, h.PasswordHash); ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AdminPolicyAppID 获取系统管理员适用的密码策略应用ID
+// 应用级管理员使用所属应用的策略，系统级管理员使用 SystemAdminPolicyAppID 策略
+func AdminPolicyAppID(adminType, appID string) string {
+	if adminType == "app" && appID != "" {
+		return appID
+	}
+	return SystemAdminPolicyAppID
+}
+
+// MigrateSystemAdminPasswordPolicy 旧版本中系统级管理员使用 system-admin 应用的策略，
+// 尚未配置系统管理员策略时复制一份，升级后系统管理员的密码要求保持不变
+func MigrateSystemAdminPasswordPolicy() error {
+	var count int64
+	if err := config.DB.Model(&models.PasswordPolicy{}).Where("app_id = ?", SystemAdminPolicyAppID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	var legacy models.PasswordPolicy
+	if err := config.DB.Where("app_id = ?", legacySystemAdminPolicyAppID).First(&legacy).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	legacy.ID = 0
+	legacy.AppID = SystemAdminPolicyAppID
+	return config.DB.Create(&legacy).Error
+}
+
+// passwordChangeChallenge 密码过期时待完成的登录：账号已通过认证，但只能在设置新密码后获得令牌
+type passwordChangeChallenge struct {
+	AccountType string `json:"account_type"`
+	AppID       string `json:"app_id"`
+	AccountID   uint   `json:"account_id"`
+	OrgID       uint   `json:"org_id,omitempty"` // 登录请求的当前组织
+}
+
+// createPasswordChangeChallenge 生成修改过期密码的一次性令牌
+func createPasswordChangeChallenge(challenge *passwordChangeChallenge) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+	data, _ := json.Marshal(challenge)
+	if err := utils.Set(passwordChangePrefix+token, string(data), passwordChangeTTL); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// loadPasswordChangeChallenge 读取修改过期密码令牌，账号类型或应用不一致时视为无效；
+// 新密码不符合策略时令牌仍然有效，可重新提交
+func loadPasswordChangeChallenge(accountType, appID, token string) (*passwordChangeChallenge, error) {
+	invalid := errors.New("修改密码令牌无效或已过期")
+	if token == "" {
+		return nil, invalid
+	}
+	data, err := utils.Get(passwordChangePrefix + token)
+	if err != nil {
+		return nil, invalid
+	}
+	var challenge passwordChangeChallenge
+	if err := json.Unmarshal([]byte(data), &challenge); err != nil ||
+		challenge.AccountType != accountType || challenge.AppID != appID {
+		return nil, invalid
+	}
+	return &challenge, nil
+}
+
+// finishPasswordChangeChallenge 原子地取出并删除令牌，并发提交时只有一个请求能完成登录
+func finishPasswordChangeChallenge(token string) error {
+	if _, err := utils.GetDel(passwordChangePrefix + token); err != nil {
+		return errors.New("修改密码令牌无效或已过期")
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"auth-center/config"
+	"auth-center/models"
+
+	"gorm.io/gorm"
+)
+
+// 权限数据的写操作。每个方法在写入成功后使受影响的权限缓存失效（包括其他副本的进程内缓存），
+// 修改角色、权限及其分配时应通过这些方法，而不是直接写数据库
+
+// AssignRolePermissions 替换角色的权限分配：allowIDs 为授予的权限，denyIDs 为明确拒绝的权限，
+// conditions 为权限ID到生效条件的映射，validity 为权限ID到有效期的映射，scopes 为授予的权限ID到数据范围的映射
+func (s *PermissionService) AssignRolePermissions(appID string, roleID uint, allowIDs, denyIDs []uint, conditions map[uint]string, validity map[uint]AssignmentValidity, scopes map[uint]DataScope) error {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ? AND app_id = ?", roleID, appID).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		for effect, ids := range map[string][]uint{PermissionEffectAllow: allowIDs, PermissionEffectDeny: denyIDs} {
+			for _, permissionID := range ids {
+				rolePermission := models.RolePermission{
+					RoleID:       roleID,
+					PermissionID: permissionID,
+					AppID:        appID,
+					Effect:       effect,
+					Condition:    conditions[permissionID],
+					ValidFrom:    validity[permissionID].ValidFrom,
+					ValidUntil:   validity[permissionID].ValidUntil,
+				}
+				if effect == PermissionEffectAllow {
+					rolePermission.DataScope = encodeDataScope(scopes[permissionID])
+				}
+				if err := tx.Create(&rolePermission).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	cacheService := &PermissionCacheService{}
+	cacheService.InvalidateRole(appID, roleID)
+	return nil
+}
+
+// AssignUserRoles 替换用户的角色分配，validity 为角色ID到有效期的映射
+func (s *PermissionService) AssignUserRoles(appID string, userID uint, roleIDs []uint, validity map[uint]AssignmentValidity) error {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND app_id = ?", userID, appID).Delete(&models.UserRole{}).Error; err != nil {
+			return err
+		}
+		for _, roleID := range roleIDs {
+			userRole := models.UserRole{
+				UserID:     userID,
+				RoleID:     roleID,
+				AppID:      appID,
+				ValidFrom:  validity[roleID].ValidFrom,
+				ValidUntil: validity[roleID].ValidUntil,
+			}
+			if err := tx.Create(&userRole).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	cacheService := &PermissionCacheService{}
+	cacheService.InvalidateUser(appID, userID)
+	return nil
+}
+
+// UpdatePermission 更新权限（名称、状态等），权限状态影响应用内所有拥有该权限的用户
+func (s *PermissionService) UpdatePermission(permission *models.Permission, updates map[string]interface{}) error {
+	if err := config.DB.Model(permission).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	cacheService := &PermissionCacheService{}
+	cacheService.InvalidateApp(permission.AppID)
+	return nil
+}
+
+// DeletePermission 删除权限及其 API 规则和分配
+func (s *PermissionService) DeletePermission(permission *models.Permission) error {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND app_id = ?", permission.ID, permission.AppID).Delete(&models.Permission{}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&models.API{}, &models.RolePermission{}, &models.UserPermission{}} {
+			if err := tx.Where("permission_id = ? AND app_id = ?", permission.ID, permission.AppID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	cacheService := &PermissionCacheService{}
+	cacheService.InvalidateApp(permission.AppID)
+	return nil
+}
+
+// DeleteRole 删除角色及其权限分配、用户、分组和组织成员的分配、审批人和继承关系
+func (s *PermissionService) DeleteRole(role *models.Role) error {
+	// 删除用户分配前先确定受影响的用户
+	userIDs, lookupErr := roleAffectedUserIDs(role.AppID, role.ID)
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND app_id = ?", role.ID, role.AppID).Delete(&models.Role{}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&models.RolePermission{}, &models.UserRole{}, &models.GroupRole{}, &models.OrganizationMemberRole{}, &models.RoleApprover{}} {
+			if err := tx.Where("role_id = ? AND app_id = ?", role.ID, role.AppID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("app_id = ? AND (role_id = ? OR parent_role_id = ?)", role.AppID, role.ID, role.ID).
+			Delete(&models.RoleInheritance{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	cacheService := &PermissionCacheService{}
+	cacheService.invalidateRoleUsers(role.AppID, role.ID, userIDs, lookupErr)
+	return nil
+}
+
+// DeleteUser 删除用户及其角色分配、直接权限、分组和组织的成员身份以及审批人身份
+func (s *PermissionService) DeleteUser(user *models.User) error {
+	appID, userID := user.AppID, user.ID
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND app_id = ?", userID, appID).Delete(&models.User{}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&models.UserRole{}, &models.UserPermission{}, &models.GroupMember{}, &models.OrganizationMember{}, &models.OrganizationMemberRole{}, &models.RoleApprover{}} {
+			if err := tx.Where("user_id = ? AND app_id = ?", userID, appID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	cacheService := &PermissionCacheService{}
+	cacheService.InvalidateUser(appID, userID)
+	return nil
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"auth-center/config"
+	"auth-center/models"
+	"auth-center/utils"
+)
+
+// 权限缓存按版本号组织：角色的权限集合缓存在带版本号的键下，用户的权限快照（见 permission_snapshot_service.go）记录构建时的版本号，
+// 权限数据变更时递增受影响的版本号，旧版本的缓存随之失效（到期后由 Redis 清除），无需逐个查找和删除。
+// 版本号有三级：应用（权限本身或角色继承关系变更）、角色（角色的权限分配变更）、用户（用户的角色或直接权限变更）。
+// 各副本在进程内缓存版本号，并通过 Redis 发布订阅接收其他副本的失效通知
+
+// 失效范围
+const (
+	PermissionScopeApp   = "app"   // 应用内全部权限缓存
+	PermissionScopeRole  = "role"  // 角色及拥有该角色（含继承）的用户
+	PermissionScopeUser  = "user"  // 单个用户
+	PermissionScopeGroup = "group" // 分组（含下级分组）的成员
+	PermissionScopeAPI   = "api"   // 仅 API 规则匹配器
+)
+
+// permissionCacheTTL 权限集合在 Redis 中的缓存时间
+const permissionCacheTTL = 12 * time.Hour
+
+// permissionVersionLocalTTL 进程内版本号的缓存时间，失效通知丢失（如 Redis 断线重连期间）时最多延迟这么久生效
+const permissionVersionLocalTTL = 5 * time.Second
+
+// PermissionInvalidation 权限缓存失效通知
+type PermissionInvalidation struct {
+	AppID string   `json:"app_id"`
+	Scope string   `json:"scope"`
+	ID    uint     `json:"id,omitempty"`   // 角色或用户ID
+	Keys  []string `json:"keys,omitempty"` // 已递增的版本号键
+
+	Origin string `json:"origin"` // 发出通知的副本
+}
+
+type localPermissionVersion struct {
+	value     string
+	expiresAt time.Time
+}
+
+var (
+	localPermissionVersions sync.Map // 版本号键 -> *localPermissionVersion
+
+	permissionCacheInstanceID = utils.GenerateShortCode(16) // 本副本的标识，用于忽略自己发出的通知
+
+	permissionCacheListenersMu sync.RWMutex
+	permissionCacheListeners   []func(*PermissionInvalidation)
+)
+
+// onPermissionInvalidate 注册进程内缓存的失效回调，本副本和其他副本的失效通知都会触发
+func onPermissionInvalidate(listener func(*PermissionInvalidation)) {
+	permissionCacheListenersMu.Lock()
+	defer permissionCacheListenersMu.Unlock()
+	permissionCacheListeners = append(permissionCacheListeners, listener)
+}
+
+func appPermissionVersionKey(appID string) string {
+	return utils.PermissionVersionPrefix + appID
+}
+
+func rolePermissionVersionKey(appID string, roleID uint) string {
+	return fmt.Sprintf("%s%s:role:%d", utils.PermissionVersionPrefix, appID, roleID)
+}
+
+func userPermissionVersionKey(appID string, userID uint) string {
+	return fmt.Sprintf("%s%s:user:%d", utils.PermissionVersionPrefix, appID, userID)
+}
+
+// permissionVersions 读取版本号（不存在时为 0），优先使用进程内缓存；读取失败时 ok 为 false
+func permissionVersions(keys ...string) ([]string, bool) {
+	values := make([]string, len(keys))
+	var missing []int
+	now := time.Now()
+	for i, key := range keys {
+		if cached, ok := localPermissionVersions.Load(key); ok && now.Before(cached.(*localPermissionVersion).expiresAt) {
+			values[i] = cached.(*localPermissionVersion).value
+		} else {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) == 0 {
+		return values, true
+	}
+
+	missingKeys := make([]string, len(missing))
+	for j, i := range missing {
+		missingKeys[j] = keys[i]
+	}
+	results, err := utils.MGet(missingKeys...)
+	if err != nil {
+		return nil, false
+	}
+	for j, i := range missing {
+		value := "0"
+		if s, ok := results[j].(string); ok {
+			value = s
+		}
+		values[i] = value
+		localPermissionVersions.Store(keys[i], &localPermissionVersion{value: value, expiresAt: now.Add(permissionVersionLocalTTL)})
+	}
+	return values, true
+}
+
+// LocalPermissionVersion 本副本进程内缓存的版本号，未缓存或已过期时 ok 为 false；用于排查失效通知是否送达
+func LocalPermissionVersion(key string) (string, bool) {
+	cached, ok := localPermissionVersions.Load(key)
+	if !ok || !time.Now().Before(cached.(*localPermissionVersion).expiresAt) {
+		return "", false
+	}
+	return cached.(*localPermissionVersion).value, true
+}
+
+// userPermissionVersion 用户权限快照的版本号（应用和用户两级），ok 为 false 时不应使用缓存
+func userPermissionVersion(userID uint, appID string) (string, bool) {
+	versions, ok := permissionVersions(appPermissionVersionKey(appID), userPermissionVersionKey(appID, userID))
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("v%s.%s", versions[0], versions[1]), true
+}
+
+// rolePermissionCacheKey 角色权限ID集合的缓存键，ok 为 false 时不应使用缓存
+func rolePermissionCacheKey(roleID uint, appID string) (string, bool) {
+	versions, ok := permissionVersions(appPermissionVersionKey(appID), rolePermissionVersionKey(appID, roleID))
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("%s%d:%s:v%s.%s", utils.RolePermissionPrefix, roleID, appID, versions[0], versions[1]), true
+}
+
+// PermissionCacheService 权限缓存失效服务，权限数据变更后由对应的服务方法调用
+type PermissionCacheService struct{}
+
+// InvalidateApp 使应用内全部权限缓存失效（权限本身或角色继承关系变更）
+func (s *PermissionCacheService) InvalidateApp(appID string) {
+	publishPermissionInvalidation(&PermissionInvalidation{AppID: appID, Scope: PermissionScopeApp}, appPermissionVersionKey(appID))
+}
+
+// InvalidateUser 使用户的权限缓存失效（用户的角色或直接权限变更）
+func (s *PermissionCacheService) InvalidateUser(appID string, userID uint) {
+	publishPermissionInvalidation(&PermissionInvalidation{AppID: appID, Scope: PermissionScopeUser, ID: userID}, userPermissionVersionKey(appID, userID))
+}
+
+// InvalidateRole 使角色及拥有该角色的用户的权限缓存失效（角色的权限分配或父角色变更）。
+// 拥有该角色的用户包括直接分配了该角色或继承该角色的角色的用户
+func (s *PermissionCacheService) InvalidateRole(appID string, roleID uint) {
+	userIDs, err := roleAffectedUserIDs(appID, roleID)
+	s.invalidateRoleUsers(appID, roleID, userIDs, err)
+}
+
+// invalidateRoleUsers 使角色和给定用户的权限缓存失效，lookupErr 不为空表示无法确定受影响的用户，此时整个应用失效
+func (s *PermissionCacheService) invalidateRoleUsers(appID string, roleID uint, userIDs []uint, lookupErr error) {
+	keys := []string{rolePermissionVersionKey(appID, roleID)}
+	if lookupErr != nil {
+		log.Printf("查询角色 %d 的用户失败，使应用 %s 的权限缓存全部失效: %v", roleID, appID, lookupErr)
+		keys = append(keys, appPermissionVersionKey(appID))
+	}
+	for _, userID := range userIDs {
+		keys = append(keys, userPermissionVersionKey(appID, userID))
+	}
+	publishPermissionInvalidation(&PermissionInvalidation{AppID: appID, Scope: PermissionScopeRole, ID: roleID}, keys...)
+}
+
+// InvalidateGroup 使分组（含下级分组）成员的权限缓存失效（分组的角色或嵌套关系变更）
+func (s *PermissionCacheService) InvalidateGroup(appID string, groupID uint) {
+	userIDs, err := groupMemberUserIDs(appID, []uint{groupID})
+	s.invalidateGroupUsers(appID, groupID, userIDs, err)
+}
+
+// invalidateGroupUsers 使给定用户的权限缓存失效，lookupErr 不为空表示无法确定受影响的用户，此时整个应用失效
+func (s *PermissionCacheService) invalidateGroupUsers(appID string, groupID uint, userIDs []uint, lookupErr error) {
+	var keys []string
+	if lookupErr != nil {
+		log.Printf("查询分组 %d 的成员失败，使应用 %s 的权限缓存全部失效: %v", groupID, appID, lookupErr)
+		keys = append(keys, appPermissionVersionKey(appID))
+	}
+	for _, userID := range userIDs {
+		keys = append(keys, userPermissionVersionKey(appID, userID))
+	}
+	publishPermissionInvalidation(&PermissionInvalidation{AppID: appID, Scope: PermissionScopeGroup, ID: groupID}, keys...)
+}
+
+// InvalidateAPIRules 使应用的 API 规则匹配器失效（API 规则变更）
+func (s *PermissionCacheService) InvalidateAPIRules(appID string) {
+	publishPermissionInvalidation(&PermissionInvalidation{AppID: appID, Scope: PermissionScopeAPI})
+}
+
+// roleAffectedUserIDs 拥有角色（直接分配、通过分组获得或在组织内分配，含通过继承）的用户
+func roleAffectedUserIDs(appID string, roleID uint) ([]uint, error) {
+	graph, err := loadRoleGraph(appID)
+	if err != nil {
+		return nil, err
+	}
+	roleIDs := append(graph.Descendants(roleID), roleID)
+
+	var userIDs []uint
+	if err := config.DB.Model(&models.UserRole{}).Where("app_id = ? AND role_id IN ?", appID, roleIDs).
+		Distinct().Pluck("user_id", &userIDs).Error; err != nil {
+		return nil, err
+	}
+	var orgUserIDs []uint
+	if err := config.DB.Model(&models.OrganizationMemberRole{}).Where("app_id = ? AND role_id IN ?", appID, roleIDs).
+		Distinct().Pluck("user_id", &orgUserIDs).Error; err != nil {
+		return nil, err
+	}
+	userIDs = append(userIDs, orgUserIDs...)
+	var groupIDs []uint
+	if err := config.DB.Model(&models.GroupRole{}).Where("app_id = ? AND role_id IN ?", appID, roleIDs).
+		Distinct().Pluck("group_id", &groupIDs).Error; err != nil {
+		return nil, err
+	}
+	groupUserIDs, err := groupMemberUserIDs(appID, groupIDs)
+	if err != nil {
+		return nil, err
+	}
+	return uniqueIDs(append(userIDs, groupUserIDs...)), nil
+}
+
+// publishPermissionInvalidation 递增版本号，在本副本立即生效，并通知其他副本
+func publishPermissionInvalidation(invalidation *PermissionInvalidation, keys ...string) {
+	if len(keys) > 0 {
+		values, err := utils.IncrAll(keys...)
+		if err != nil {
+			log.Printf("递增权限缓存版本号失败: %v", err)
+			for _, key := range keys {
+				localPermissionVersions.Delete(key)
+			}
+		} else {
+			now := time.Now()
+			for i, key := range keys {
+				localPermissionVersions.Store(key, &localPermissionVersion{
+					value:     strconv.FormatInt(values[i], 10),
+					expiresAt: now.Add(permissionVersionLocalTTL),
+				})
+			}
+		}
+		invalidation.Keys = keys
+	}
+	invalidation.Origin = permissionCacheInstanceID
+
+	applyPermissionInvalidation(invalidation, false)
+	message, _ := json.Marshal(invalidation)
+	if err := utils.Publish(utils.PermissionInvalidateChannel, message); err != nil {
+		log.Printf("发布权限缓存失效通知失败: %v", err)
+	}
+}
+
+// applyPermissionInvalidation 清除本副本的进程内缓存。remote 为 true 时表示来自其他副本，需丢弃本地缓存的版本号
+func applyPermissionInvalidation(invalidation *PermissionInvalidation, remote bool) {
+	if remote {
+		for _, key := range invalidation.Keys {
+			localPermissionVersions.Delete(key)
+		}
+	}
+	switch invalidation.Scope {
+	case PermissionScopeApp, PermissionScopeAPI:
+		apiMatchers.Delete(invalidation.AppID)
+	}
+
+	permissionCacheListenersMu.RLock()
+	listeners := permissionCacheListeners
+	permissionCacheListenersMu.RUnlock()
+	for _, listener := range listeners {
+		listener(invalidation)
+	}
+}
+
+// StartPermissionCacheSubscriber 在后台订阅其他副本发出的权限缓存失效通知
+func StartPermissionCacheSubscriber() {
+	pubsub, err := utils.Subscribe(utils.PermissionInvalidateChannel)
+	if err != nil {
+		log.Printf("订阅权限缓存失效通知失败: %v", err)
+		return
+	}
+
+	go func() {
+		for message := range pubsub.Channel() {
+			var invalidation PermissionInvalidation
+			if err := json.Unmarshal([]byte(message.Payload), &invalidation); err != nil {
+				log.Printf("忽略无效的权限缓存失效通知: %v", err)
+				continue
+			}
+			if invalidation.Origin != permissionCacheInstanceID {
+				applyPermissionInvalidation(&invalidation, true)
+			}
+		}
+	}()
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"auth-center/config"
+	"auth-center/models"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// permissionConditionCostLimit 单个条件表达式的求值开销上限，防止构造的表达式耗尽 CPU
+const permissionConditionCostLimit = 10000
+
+// permissionConditionMaxLength 条件表达式的最大长度
+const permissionConditionMaxLength = 2048
+
+// PermissionContext 权限判定的请求上下文
+//
+// 条件表达式（CEL）中可以使用以下变量：
+//   - user：id、username、email、phone、roles（角色代码列表）、org_id（当前组织，不在组织内时为 0），由服务端根据用户记录和令牌填充
+//   - request：ip、time（timestamp）、path、method
+//   - resource：调用方传入的资源属性，如 resource.owner_id
+//
+// 以及函数 ip_in_cidr(ip, cidr)，例如：
+//
+//	request.time.getHours("Asia/Shanghai") >= 9 && request.time.getHours("Asia/Shanghai") < 18
+//	ip_in_cidr(request.ip, "10.0.0.0/8")
+//	resource.owner_id == user.id
+type PermissionContext struct {
+	IP       string                 `json:"ip"`
+	Time     time.Time              `json:"time"`
+	Path     string                 `json:"path,omitempty"`
+	Method   string                 `json:"method,omitempty"`
+	Resource map[string]interface{} `json:"resource,omitempty"`
+}
+
+var (
+	conditionEnvOnce sync.Once
+	conditionEnv     *cel.Env
+	conditionEnvErr  error
+	conditionCache   sync.Map // 表达式 -> cel.Program
+)
+
+// permissionConditionEnv 条件表达式的 CEL 环境
+func permissionConditionEnv() (*cel.Env, error) {
+	conditionEnvOnce.Do(func() {
+		conditionEnv, conditionEnvErr = cel.NewEnv(
+			cel.Variable("user", cel.MapType(cel.StringType, cel.DynType)),
+			cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)),
+			cel.Variable("resource", cel.MapType(cel.StringType, cel.DynType)),
+			cel.CrossTypeNumericComparisons(true),
+			cel.Function("ip_in_cidr",
+				cel.Overload("ip_in_cidr_string_string", []*cel.Type{cel.StringType, cel.StringType}, cel.BoolType,
+					cel.BinaryBinding(ipInCIDR))),
+		)
+	})
+	return conditionEnv, conditionEnvErr
+}
+
+// ipInCIDR 判断 IP 是否属于网段
+func ipInCIDR(ipVal, cidrVal ref.Val) ref.Val {
+	ipStr, ok1 := ipVal.(types.String)
+	cidrStr, ok2 := cidrVal.(types.String)
+	if !ok1 || !ok2 {
+		return types.NewErr("ip_in_cidr 的参数必须为字符串")
+	}
+	_, network, err := net.ParseCIDR(string(cidrStr))
+	if err != nil {
+		return types.NewErr("无效的网段: %s", cidrStr)
+	}
+	ip := net.ParseIP(string(ipStr))
+	if ip == nil {
+		return types.False
+	}
+	return types.Bool(network.Contains(ip))
+}
+
+// compilePermissionCondition 编译条件表达式，结果按表达式缓存
+func compilePermissionCondition(expr string) (cel.Program, error) {
+	if cached, ok := conditionCache.Load(expr); ok {
+		return cached.(cel.Program), nil
+	}
+	if len(expr) > permissionConditionMaxLength {
+		return nil, fmt.Errorf("条件表达式不能超过 %d 个字符", permissionConditionMaxLength)
+	}
+
+	env, err := permissionConditionEnv()
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("条件表达式错误: %v", issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, errors.New("条件表达式的结果必须为布尔值")
+	}
+	program, err := env.Program(ast, cel.CostLimit(permissionConditionCostLimit))
+	if err != nil {
+		return nil, fmt.Errorf("条件表达式错误: %v", err)
+	}
+
+	conditionCache.Store(expr, program)
+	return program, nil
+}
+
+// ValidatePermissionCondition 校验条件表达式，空表达式表示无条件
+func ValidatePermissionCondition(expr string) error {
+	if expr == "" {
+		return nil
+	}
+	_, err := compilePermissionCondition(expr)
+	return err
+}
+
+// EvaluatePermissionCondition 按给定变量求值条件表达式，空表达式视为成立
+func EvaluatePermissionCondition(expr string, vars map[string]interface{}) (bool, error) {
+	if expr == "" {
+		return true, nil
+	}
+	program, err := compilePermissionCondition(expr)
+	if err != nil {
+		return false, err
+	}
+	for _, name := range []string{"user", "request", "resource"} {
+		if _, ok := vars[name]; !ok {
+			vars[name] = map[string]interface{}{}
+		}
+	}
+	out, _, err := program.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+	result, ok := out.Value().(bool)
+	if !ok {
+		return false, errors.New("条件表达式的结果必须为布尔值")
+	}
+	return result, nil
+}
+
+// conditionVars 构造用户和请求上下文对应的表达式变量
+func conditionVars(userID uint, appID string, orgID uint, pctx *PermissionContext) (map[string]interface{}, error) {
+	var user models.User
+	if err := config.DB.Where("id = ? AND app_id = ?", userID, appID).First(&user).Error; err != nil {
+		return nil, err
+	}
+	roleIDs, err := userAssignedRoleIDs(userID, appID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	var roleCodes []string
+	if len(roleIDs) > 0 {
+		if err := config.DB.Model(&models.Role{}).Where("id IN ? AND app_id = ?", roleIDs, appID).
+			Order("id").Pluck("code", &roleCodes).Error; err != nil {
+			return nil, err
+		}
+	}
+	return buildConditionVars(&user, orgID, roleCodes, pctx), nil
+}
+
+// buildConditionVars 由用户、当前组织、用户的角色代码和请求上下文构造表达式变量
+func buildConditionVars(user *models.User, orgID uint, roleCodes []string, pctx *PermissionContext) map[string]interface{} {
+	if roleCodes == nil {
+		roleCodes = []string{}
+	}
+
+	now := pctx.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	resource := pctx.Resource
+	if resource == nil {
+		resource = map[string]interface{}{}
+	}
+
+	return map[string]interface{}{
+		"user": map[string]interface{}{
+			"id":       int64(user.ID),
+			"username": user.Username,
+			"email":    user.Email,
+			"phone":    user.Phone,
+			"roles":    roleCodes,
+			"org_id":   int64(orgID),
+		},
+		"request": map[string]interface{}{
+			"ip":     pctx.IP,
+			"time":   now,
+			"path":   pctx.Path,
+			"method": pctx.Method,
+		},
+		"resource": resource,
+	}
+}
+
+// evaluateGrantConditions 按请求上下文求值规则上的条件，结果记录在规则上。
+// pctx 为 nil 时不求值，附带条件的授予不生效、附带条件的拒绝生效
+func evaluateGrantConditions(userID uint, appID string, orgID uint, grants []PermissionGrant, pctx *PermissionContext) error {
+	if pctx == nil {
+		return nil
+	}
+	var vars map[string]interface{}
+	return evaluateGrantConditionsWith(grants, func() (map[string]interface{}, error) {
+		if vars == nil {
+			var err error
+			if vars, err = conditionVars(userID, appID, orgID, pctx); err != nil {
+				return nil, err
+			}
+		}
+		return vars, nil
+	})
+}
+
+// evaluateGrantConditionsWith 用给定的变量求值规则的生效条件，变量在遇到第一个附带条件的规则时才读取
+func evaluateGrantConditionsWith(grants []PermissionGrant, loadVars func() (map[string]interface{}, error)) error {
+	for i := range grants {
+		if grants[i].Condition == "" {
+			continue
+		}
+		vars, err := loadVars()
+		if err != nil {
+			return err
+		}
+		met, err := EvaluatePermissionCondition(grants[i].Condition, vars)
+		if err != nil {
+			grants[i].ConditionError = err.Error()
+			continue
+		}
+		grants[i].ConditionMet = &met
+	}
+	return nil
+}
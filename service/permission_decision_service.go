@@ -0,0 +1,450 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"auth-center/config"
+	"auth-center/models"
+	"auth-center/utils"
+
+	"gorm.io/gorm"
+)
+
+// 权限规则效果
+const (
+	PermissionEffectAllow = "allow" // 授予
+	PermissionEffectDeny  = "deny"  // 明确拒绝，优先于任何授予
+)
+
+// 权限规则来源
+const (
+	PermissionSourceUser = "user" // 直接分配给用户
+	PermissionSourceRole = "role" // 通过角色（含继承的父角色）获得
+)
+
+// 权限判定结果
+const (
+	PermissionDecisionAllow              = "allow"                // 存在授予规则且没有拒绝规则
+	PermissionDecisionExplicitDeny       = "explicit_deny"        // 存在明确拒绝规则
+	PermissionDecisionNotGranted         = "not_granted"          // 没有任何授予规则
+	PermissionDecisionDisabled           = "permission_disabled"  // 权限已停用
+	PermissionDecisionPermissionNotFound = "permission_not_found" // 权限不存在
+	PermissionDecisionNoAPIRule          = "no_api_rule"          // 没有匹配的 API 规则
+	PermissionDecisionAppDisabled        = "app_disabled"         // 应用已禁用（仅模拟判定）
+	PermissionDecisionUserDisabled       = "user_disabled"        // 用户已禁用（仅模拟判定）
+)
+
+// PermissionGrant 一条授予或拒绝规则
+type PermissionGrant struct {
+	PermissionID uint   `json:"permission_id"`
+	Effect       string `json:"effect"`
+	Source       string `json:"source"`
+	RoleID       uint   `json:"role_id,omitempty"`   // Source 为 role 时提供该规则的角色
+	Condition    string `json:"condition,omitempty"` // 生效条件（CEL 表达式），为空表示无条件
+
+	ConditionMet   *bool  `json:"condition_met,omitempty"`   // 条件求值结果，未求值时为空
+	ConditionError string `json:"condition_error,omitempty"` // 条件求值出错的原因
+}
+
+// Applies 规则是否生效：无条件的规则总是生效，条件成立时生效；
+// 条件未求值（没有请求上下文）或求值出错时按拒绝处理，即授予不生效、拒绝生效
+func (g *PermissionGrant) Applies() bool {
+	if g.Condition == "" {
+		return true
+	}
+	if g.ConditionMet != nil {
+		return *g.ConditionMet
+	}
+	return g.Effect == PermissionEffectDeny
+}
+
+// PermissionDecision 权限判定结果及其依据
+type PermissionDecision struct {
+	Allowed    bool               `json:"allowed"`
+	Decision   string             `json:"decision"`
+	Reason     string             `json:"reason"`
+	Permission string             `json:"permission,omitempty"`
+	DecidedBy  *PermissionGrant   `json:"decided_by,omitempty"` // 决定结果的规则
+	Grants     []PermissionGrant  `json:"grants"`               // 参与判定的全部规则
+	APIRule    *PermissionAPIRule `json:"api_rule,omitempty"`   // 匹配到的 API 规则（仅 API 判定）
+}
+
+// PermissionAPIRule 判定时匹配到的 API 规则
+type PermissionAPIRule struct {
+	ID           uint   `json:"id"`
+	Path         string `json:"path"`
+	Method       string `json:"method"`
+	PermissionID uint   `json:"permission_id"`
+}
+
+func newPermissionAPIRule(rule *utils.RouteRule) *PermissionAPIRule {
+	return &PermissionAPIRule{ID: rule.ID, Path: rule.Path, Method: rule.Method, PermissionID: rule.PermissionID}
+}
+
+// ResolvePermissionGrants 按拒绝优先从同一权限的生效规则中选出决定结果的规则：
+// 存在生效的拒绝规则时由拒绝决定，否则由授予规则决定，没有生效的规则时返回 nil。
+// 同为拒绝或授予时，直接分配给用户的规则优先，其次按角色ID排序
+func ResolvePermissionGrants(grants []PermissionGrant) *PermissionGrant {
+	var decided *PermissionGrant
+	for i := range grants {
+		grant := &grants[i]
+		if !grant.Applies() {
+			continue
+		}
+		if decided == nil || permissionGrantBefore(grant, decided) {
+			decided = grant
+		}
+	}
+	if decided == nil {
+		return nil
+	}
+	result := *decided
+	return &result
+}
+
+// permissionGrantBefore a 是否比 b 更优先决定结果
+func permissionGrantBefore(a, b *PermissionGrant) bool {
+	if a.Effect != b.Effect {
+		return a.Effect == PermissionEffectDeny
+	}
+	if a.Source != b.Source {
+		return a.Source == PermissionSourceUser
+	}
+	return a.RoleID < b.RoleID
+}
+
+// loadUserGrants 读取用户在应用内的全部授予和拒绝规则：权限ID -> 规则
+func (s *PermissionService) loadUserGrants(userID uint, appID string) (map[uint][]PermissionGrant, error) {
+	roleIDs, err := s.getUserRoleIDs(userID, appID)
+	if err != nil {
+		return nil, err
+	}
+	return s.loadGrants(userID, appID, roleIDs)
+}
+
+// loadGrants 读取角色（已包含继承的父角色）上的规则和直接分配给用户的规则，userID 为 0 时不含用户的规则
+func (s *PermissionService) loadGrants(userID uint, appID string, roleIDs []uint) (map[uint][]PermissionGrant, error) {
+	grants := make(map[uint][]PermissionGrant)
+	for _, roleID := range roleIDs {
+		permissionIDs, err := s.getRolePermissions(roleID, appID)
+		if err != nil {
+			return nil, err
+		}
+		for _, permissionID := range permissionIDs {
+			grants[permissionID] = append(grants[permissionID], PermissionGrant{
+				PermissionID: permissionID, Effect: PermissionEffectAllow, Source: PermissionSourceRole, RoleID: roleID,
+			})
+		}
+	}
+
+	// 拒绝规则、附带条件的规则和有有效期的规则不走缓存，分配后立即生效
+	rules, err := getRoleUncachedRules(appID, roleIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, rp := range rules {
+		effect := PermissionEffectAllow
+		if rp.Effect == PermissionEffectDeny {
+			effect = PermissionEffectDeny
+		}
+		grants[rp.PermissionID] = append(grants[rp.PermissionID], PermissionGrant{
+			PermissionID: rp.PermissionID, Effect: effect, Source: PermissionSourceRole, RoleID: rp.RoleID, Condition: rp.Condition,
+		})
+	}
+
+	if userID == 0 {
+		return grants, nil
+	}
+	var userPermissions []models.UserPermission
+	if err := config.DB.Where("user_id = ? AND app_id = ?", userID, appID).Find(&userPermissions).Error; err != nil {
+		return nil, err
+	}
+	for _, up := range userPermissions {
+		effect := PermissionEffectAllow
+		if up.Effect == PermissionEffectDeny {
+			effect = PermissionEffectDeny
+		}
+		grants[up.PermissionID] = append(grants[up.PermissionID], PermissionGrant{
+			PermissionID: up.PermissionID, Effect: effect, Source: PermissionSourceUser,
+		})
+	}
+
+	return grants, nil
+}
+
+// getRoleUncachedRules 获取角色上的拒绝规则、附带条件的规则和当前有效的有有效期的规则（不在角色权限缓存中）
+func getRoleUncachedRules(appID string, roleIDs []uint) ([]models.RolePermission, error) {
+	if len(roleIDs) == 0 {
+		return nil, nil
+	}
+	var rules []models.RolePermission
+	err := config.DB.Scopes(activeAssignments("role_permissions", time.Now())).
+		Where("app_id = ? AND role_id IN ? AND (effect = ? OR condition_expr <> '' OR valid_from IS NOT NULL OR valid_until IS NOT NULL)",
+			appID, roleIDs, PermissionEffectDeny).
+		Find(&rules).Error
+	return rules, err
+}
+
+// decide 根据权限的规则和状态生成判定结果
+func decide(permission *models.Permission, grants []PermissionGrant) *PermissionDecision {
+	decision := &PermissionDecision{
+		Permission: permission.Code,
+		Grants:     grants,
+		DecidedBy:  ResolvePermissionGrants(grants),
+	}
+	if decision.Grants == nil {
+		decision.Grants = []PermissionGrant{}
+	}
+
+	switch {
+	case decision.DecidedBy == nil:
+		decision.Decision = PermissionDecisionNotGranted
+		decision.Reason = fmt.Sprintf("用户未被授予权限 %s", permission.Code)
+		for _, grant := range grants {
+			if grant.Effect == PermissionEffectAllow && grant.Condition != "" {
+				decision.Reason = fmt.Sprintf("用户被授予的权限 %s 附带的条件不成立", permission.Code)
+				break
+			}
+		}
+	case decision.DecidedBy.Effect == PermissionEffectDeny:
+		decision.Decision = PermissionDecisionExplicitDeny
+		decision.Reason = fmt.Sprintf("权限 %s 被%s明确拒绝", permission.Code, describeGrant(decision.DecidedBy))
+	case permission.Status != 1:
+		decision.Decision = PermissionDecisionDisabled
+		decision.Reason = fmt.Sprintf("权限 %s 已停用", permission.Code)
+	default:
+		decision.Allowed = true
+		decision.Decision = PermissionDecisionAllow
+		decision.Reason = fmt.Sprintf("权限 %s 由%s授予", permission.Code, describeGrant(decision.DecidedBy))
+	}
+	return decision
+}
+
+// describeGrant 规则来源的描述
+func describeGrant(grant *PermissionGrant) string {
+	desc := fmt.Sprintf("角色 %d 的规则", grant.RoleID)
+	if grant.Source == PermissionSourceUser {
+		desc = "用户直接分配的规则"
+	}
+	if grant.Condition != "" {
+		desc += fmt.Sprintf("（条件 %s）", grant.Condition)
+	}
+	return desc
+}
+
+// ExplainUserPermission 判定用户是否拥有指定权限，并说明决定结果的规则。pctx 为请求上下文，用于求值附带条件的规则
+func (s *PermissionService) ExplainUserPermission(userID uint, appID, code string, pctx *PermissionContext) (*PermissionDecision, error) {
+	var permission models.Permission
+	if err := config.DB.Where("app_id = ? AND code = ?", appID, code).First(&permission).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &PermissionDecision{
+				Decision:   PermissionDecisionPermissionNotFound,
+				Reason:     fmt.Sprintf("权限 %s 不存在", code),
+				Permission: code,
+				Grants:     []PermissionGrant{},
+			}, nil
+		}
+		return nil, err
+	}
+
+	grants, err := s.loadUserGrants(userID, appID)
+	if err != nil {
+		return nil, err
+	}
+	if err := evaluateGrantConditions(userID, appID, s.OrgID, grants[permission.ID], pctx); err != nil {
+		return nil, err
+	}
+	return decide(&permission, grants[permission.ID]), nil
+}
+
+// ExplainAPIPermission 判定用户是否可以访问指定API，并说明匹配的规则和决定结果的规则。
+// 最具体的路径模式下有多条规则时，任一规则关联的权限被拒绝即拒绝，否则任一权限被授予即允许
+func (s *PermissionService) ExplainAPIPermission(userID uint, appID, apiPath, apiMethod string, pctx *PermissionContext) (*PermissionDecision, error) {
+	matcher, err := getAPIMatcher(appID)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := matcher.Match(apiPath, apiMethod)
+	if len(rules) == 0 {
+		return &PermissionDecision{
+			Decision: PermissionDecisionNoAPIRule,
+			Reason:   fmt.Sprintf("没有与 %s %s 匹配的API规则", apiMethod, apiPath),
+			Grants:   []PermissionGrant{},
+		}, nil
+	}
+
+	grants, err := s.loadUserGrants(userID, appID)
+	if err != nil {
+		return nil, err
+	}
+	return decideAPIRules(appID, rules, grants, func(ruleGrants []PermissionGrant) error {
+		return evaluateGrantConditions(userID, appID, s.OrgID, ruleGrants, pctx)
+	})
+}
+
+// decideAPIRules 根据匹配到的 API 规则和用户的规则生成判定结果，evaluate 在判定前求值每个权限上附带条件的规则
+func decideAPIRules(appID string, rules []*utils.RouteRule, grants map[uint][]PermissionGrant, evaluate func([]PermissionGrant) error) (*PermissionDecision, error) {
+	permissionIDs := make([]uint, 0, len(rules))
+	for _, rule := range rules {
+		permissionIDs = append(permissionIDs, rule.PermissionID)
+	}
+	var permissions []models.Permission
+	if err := config.DB.Where("app_id = ? AND id IN ?", appID, permissionIDs).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*models.Permission, len(permissions))
+	for i := range permissions {
+		byID[permissions[i].ID] = &permissions[i]
+	}
+
+	var result *PermissionDecision
+	for _, rule := range rules {
+		permission, ok := byID[rule.PermissionID]
+		if !ok {
+			continue
+		}
+		if err := evaluate(grants[rule.PermissionID]); err != nil {
+			return nil, err
+		}
+		decision := decide(permission, grants[rule.PermissionID])
+		decision.APIRule = newPermissionAPIRule(rule)
+		switch {
+		case decision.Decision == PermissionDecisionExplicitDeny:
+			return decision, nil
+		case result == nil || (decision.Allowed && !result.Allowed):
+			result = decision
+		}
+	}
+	if result == nil {
+		return &PermissionDecision{
+			Decision: PermissionDecisionPermissionNotFound,
+			Reason:   "API规则关联的权限不存在",
+			Grants:   []PermissionGrant{},
+			APIRule:  newPermissionAPIRule(rules[0]),
+		}, nil
+	}
+	return result, nil
+}
+
+// UserDirectPermissions 直接分配给用户的授予和拒绝权限，DataScopes 为授予的权限ID到数据范围的映射（仅限定了数据范围的权限）
+type UserDirectPermissions struct {
+	PermissionIDs     []uint             `json:"permission_ids"`
+	DenyPermissionIDs []uint             `json:"deny_permission_ids"`
+	DataScopes        map[uint]DataScope `json:"data_scopes"`
+}
+
+// GetUserDirectPermissions 获取直接分配给用户的权限
+func (s *PermissionService) GetUserDirectPermissions(userID uint, appID string) (*UserDirectPermissions, error) {
+	var rows []models.UserPermission
+	if err := config.DB.Where("user_id = ? AND app_id = ?", userID, appID).Order("permission_id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	result := &UserDirectPermissions{PermissionIDs: []uint{}, DenyPermissionIDs: []uint{}, DataScopes: map[uint]DataScope{}}
+	for _, row := range rows {
+		if row.Effect == PermissionEffectDeny {
+			result.DenyPermissionIDs = append(result.DenyPermissionIDs, row.PermissionID)
+			continue
+		}
+		result.PermissionIDs = append(result.PermissionIDs, row.PermissionID)
+		if row.DataScope == "" {
+			continue
+		}
+		if scope, err := decodeDataScope(row.DataScope); err == nil {
+			result.DataScopes[row.PermissionID] = scope
+		}
+	}
+	return result, nil
+}
+
+// SetUserDirectPermissions 替换直接分配给用户的权限，权限须属于当前应用，同一权限不能同时授予和拒绝。
+// 数据范围只能设置在授予的权限上；未提供 data_scopes 时保留仍然授予的权限现有的数据范围
+func (s *PermissionService) SetUserDirectPermissions(userID uint, appID string, permissions *UserDirectPermissions) error {
+	allowIDs, denyIDs, err := validatePermissionEffects(appID, permissions.PermissionIDs, permissions.DenyPermissionIDs)
+	if err != nil {
+		return err
+	}
+	scopes := permissions.DataScopes
+	if scopes == nil {
+		previous, err := s.GetUserDirectPermissions(userID, appID)
+		if err != nil {
+			return err
+		}
+		scopes = previous.DataScopes
+	} else {
+		scopes, err = ValidateDataScopes(scopes, allowIDs)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND app_id = ?", userID, appID).Delete(&models.UserPermission{}).Error; err != nil {
+			return err
+		}
+		for effect, ids := range map[string][]uint{PermissionEffectAllow: allowIDs, PermissionEffectDeny: denyIDs} {
+			for _, permissionID := range ids {
+				row := models.UserPermission{UserID: userID, PermissionID: permissionID, AppID: appID, Effect: effect}
+				if effect == PermissionEffectAllow {
+					row.DataScope = encodeDataScope(scopes[permissionID])
+				}
+				if err := tx.Create(&row).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 使用户权限缓存失效，变更立即生效
+	cacheService := &PermissionCacheService{}
+	cacheService.InvalidateUser(appID, userID)
+	return nil
+}
+
+// validatePermissionEffects 去重并校验授予和拒绝的权限：须属于当前应用，且不能同时出现在两个列表中
+func validatePermissionEffects(appID string, allowIDs, denyIDs []uint) ([]uint, []uint, error) {
+	allowIDs, denyIDs = uniqueIDs(allowIDs), uniqueIDs(denyIDs)
+
+	allowed := make(map[uint]bool, len(allowIDs))
+	for _, id := range allowIDs {
+		allowed[id] = true
+	}
+	for _, id := range denyIDs {
+		if allowed[id] {
+			return nil, nil, fmt.Errorf("权限 %d 不能同时授予和拒绝", id)
+		}
+	}
+
+	all := append(append([]uint(nil), allowIDs...), denyIDs...)
+	if len(all) > 0 {
+		var count int64
+		if err := config.DB.Model(&models.Permission{}).Where("app_id = ? AND id IN ?", appID, all).Count(&count).Error; err != nil {
+			return nil, nil, err
+		}
+		if count != int64(len(all)) {
+			return nil, nil, errors.New("权限不存在或不属于当前应用")
+		}
+	}
+	return allowIDs, denyIDs, nil
+}
+
+// uniqueIDs 去重并排序
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
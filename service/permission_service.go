@@ -2,8 +2,10 @@ package service
 
 import (
 	"errors"
-	"fmt"
+	"log"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"auth-center/config"
@@ -12,111 +14,340 @@ import (
 )
 
 // PermissionService 权限服务
-type PermissionService struct{}
+type PermissionService struct {
+	OrgID uint // 用户的当前组织（令牌中的 org_id），组织内的角色参与判定；0 表示不在组织内
+}
 
-// CheckUserPermission 检查用户权限
+// CheckUserPermission 检查用户权限，按用户的权限快照判定
 func (s *PermissionService) CheckUserPermission(userID uint, appID, permission string) (bool, error) {
-	// 先尝试从Redis缓存获取
-	cacheKey := fmt.Sprintf("%s%d:%s", utils.UserPermissionPrefix, userID, appID)
-	permissions, err := utils.SMembers(cacheKey)
-	if err != nil || len(permissions) == 0 {
-		// 缓存未命中，从数据库查询
-		permissions, err = s.GetUserPermissionsFromDB(userID, appID)
-		if err != nil {
-			return false, err
+	snapshot, err := s.getPermissionSnapshot(userID, appID)
+	if err != nil {
+		return false, err
+	}
+	return snapshot.HasPermission(permission), nil
+}
+
+// CheckAPIPermission 检查API权限（包括通过角色继承获得的权限）
+// 请求路径和方法按应用的 API 规则匹配到最具体的规则，用户拥有该规则关联的权限且未被明确拒绝时允许访问；没有匹配的规则时拒绝。
+// 不提供请求上下文时，附带条件的授予不生效、附带条件的拒绝生效
+func (s *PermissionService) CheckAPIPermission(userID uint, appID, apiPath, apiMethod string) (bool, error) {
+	return s.CheckAPIPermissionWithContext(userID, appID, apiPath, apiMethod, nil)
+}
+
+// CheckAPIPermissionWithContext 按请求上下文检查API权限，附带条件的规则按上下文求值
+// 优先按用户的权限快照判定，匹配到的规则涉及附带条件的权限且提供了请求上下文时完整求值
+func (s *PermissionService) CheckAPIPermissionWithContext(userID uint, appID, apiPath, apiMethod string, pctx *PermissionContext) (bool, error) {
+	matcher, err := getAPIMatcher(appID)
+	if err != nil {
+		return false, err
+	}
+	rules := matcher.Match(apiPath, apiMethod)
+	if len(rules) == 0 {
+		return false, nil
+	}
+
+	snapshot, err := s.getPermissionSnapshot(userID, appID)
+	if err != nil {
+		return false, err
+	}
+	if allowed, ok := snapshot.DecideAPI(rules, pctx != nil); ok {
+		return allowed, nil
+	}
+
+	decision, err := s.ExplainAPIPermission(userID, appID, apiPath, apiMethod, pctx)
+	if err != nil {
+		return false, err
+	}
+	return decision.Allowed, nil
+}
+
+// GetUserPermissionsFromDB 从数据库获取用户权限（包括通过角色继承获得的权限，不含被明确拒绝的权限）
+// 结果不依赖请求上下文，因此不含附带条件的授予，附带条件的拒绝按生效处理
+func (s *PermissionService) GetUserPermissionsFromDB(userID uint, appID string) ([]string, error) {
+	grants, err := s.loadUserGrants(userID, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 拒绝优先：只保留由授予规则决定的权限
+	var permissionIDs []uint
+	for permissionID, rules := range grants {
+		if decided := ResolvePermissionGrants(rules); decided != nil && decided.Effect == PermissionEffectAllow {
+			permissionIDs = append(permissionIDs, permissionID)
 		}
+	}
+	if len(permissionIDs) == 0 {
+		return nil, nil
+	}
 
-		// 缓存到Redis
-		if len(permissions) > 0 {
-			utils.SAdd(cacheKey, permissions)
-			utils.Expire(cacheKey, 12*time.Hour)
+	var permissions []string
+	if err := config.DB.Model(&models.Permission{}).
+		Where("id IN ? AND app_id = ? AND status = 1", permissionIDs, appID).
+		Order("id").Pluck("code", &permissions).Error; err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// EffectivePermissions 用户在应用内的有效权限，由全部规则按拒绝优先计算一次，供批量检查复用。
+// 与单项检查一致，不提供请求上下文：附带条件的授予不生效、附带条件的拒绝生效
+type EffectivePermissions struct {
+	allowed map[uint]bool   // 由授予规则决定且已启用的权限
+	denied  map[uint]bool   // 由拒绝规则决定的权限
+	codes   map[string]uint // 权限代码 -> 权限ID
+}
+
+// NewEffectivePermissions 根据用户的全部规则和相关权限记录计算有效权限
+func NewEffectivePermissions(grants map[uint][]PermissionGrant, permissions []models.Permission) *EffectivePermissions {
+	effective := &EffectivePermissions{
+		allowed: make(map[uint]bool),
+		denied:  make(map[uint]bool),
+		codes:   make(map[string]uint, len(permissions)),
+	}
+	for _, permission := range permissions {
+		effective.codes[permission.Code] = permission.ID
+		decided := ResolvePermissionGrants(grants[permission.ID])
+		switch {
+		case decided == nil:
+		case decided.Effect == PermissionEffectDeny:
+			effective.denied[permission.ID] = true
+		case permission.Status == 1:
+			effective.allowed[permission.ID] = true
 		}
 	}
+	return effective
+}
 
-	// 检查权限
-	for _, perm := range permissions {
-		if perm == permission {
-			return true, nil
+// HasPermission 是否拥有指定权限代码
+func (e *EffectivePermissions) HasPermission(code string) bool {
+	id, ok := e.codes[code]
+	return ok && e.allowed[id]
+}
+
+// AllowsAPI 按匹配到的 API 规则判定：任一规则关联的权限被拒绝即拒绝，否则任一权限被授予即允许
+func (e *EffectivePermissions) AllowsAPI(rules []*utils.RouteRule) bool {
+	allowed := false
+	for _, rule := range rules {
+		if e.denied[rule.PermissionID] {
+			return false
 		}
+		allowed = allowed || e.allowed[rule.PermissionID]
 	}
+	return allowed
+}
 
-	return false, nil
+// GetEffectivePermissions 获取用户的有效权限（来自权限快照）
+func (s *PermissionService) GetEffectivePermissions(userID uint, appID string) (*EffectivePermissions, error) {
+	snapshot, err := s.getPermissionSnapshot(userID, appID)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.EffectivePermissions, nil
 }
 
-// CheckAPIPermission 检查API权限
-func (s *PermissionService) CheckAPIPermission(userID uint, appID, apiPath, apiMethod string) (bool, error) {
-	// 获取用户角色
-	var userRoles []models.UserRole
-	if err := config.DB.Where("user_id = ? AND app_id = ?", userID, appID).Find(&userRoles).Error; err != nil {
-		return false, err
+// loadGrantedPermissions 读取规则涉及的权限记录
+func loadGrantedPermissions(appID string, grants map[uint][]PermissionGrant) ([]models.Permission, error) {
+	if len(grants) == 0 {
+		return nil, nil
+	}
+	permissionIDs := make([]uint, 0, len(grants))
+	for permissionID := range grants {
+		permissionIDs = append(permissionIDs, permissionID)
+	}
+	var permissions []models.Permission
+	if err := config.DB.Select("id", "code", "status").
+		Where("app_id = ? AND id IN ?", appID, permissionIDs).Find(&permissions).Error; err != nil {
+		return nil, err
 	}
+	return permissions, nil
+}
 
-	if len(userRoles) == 0 {
-		return false, nil
+// APIPermissionQuery 批量检查中的一项 API
+type APIPermissionQuery struct {
+	Path   string `json:"path"`
+	Method string `json:"method"`
+}
+
+// BatchPermissionResult 批量权限检查结果
+type BatchPermissionResult struct {
+	Permissions map[string]bool `json:"permissions"` // 权限代码 -> 是否拥有
+	APIs        map[string]bool `json:"apis"`        // APIPermissionKey -> 是否可以访问
+}
+
+// APIPermissionKey 批量检查结果中 API 的键：大写的方法、空格、路径，如 "GET /api/orders/42"
+func APIPermissionKey(path, method string) string {
+	return strings.ToUpper(strings.TrimSpace(method)) + " " + path
+}
+
+// CheckPermissionsBatch 批量检查权限代码和 API，用户的有效权限和应用的 API 规则只计算一次
+func (s *PermissionService) CheckPermissionsBatch(userID uint, appID string, codes []string, apis []APIPermissionQuery) (*BatchPermissionResult, error) {
+	effective, err := s.GetEffectivePermissions(userID, appID)
+	if err != nil {
+		return nil, err
 	}
 
-	// 检查每个角色的权限
-	for _, userRole := range userRoles {
-		// 获取角色权限
-		rolePermissions, err := s.getRolePermissions(userRole.RoleID, appID)
+	result := &BatchPermissionResult{
+		Permissions: make(map[string]bool, len(codes)),
+		APIs:        make(map[string]bool, len(apis)),
+	}
+	for _, code := range codes {
+		result.Permissions[code] = effective.HasPermission(code)
+	}
+	if len(apis) > 0 {
+		matcher, err := getAPIMatcher(appID)
 		if err != nil {
-			return false, err
+			return nil, err
 		}
-
-		// 检查API权限
-		for _, permissionID := range rolePermissions {
-			hasPermission, err := s.checkAPIPermissionByPermissionID(permissionID, appID, apiPath, apiMethod)
-			if err != nil {
-				return false, err
-			}
-			if hasPermission {
-				return true, nil
-			}
+		for _, api := range apis {
+			result.APIs[APIPermissionKey(api.Path, api.Method)] = effective.AllowsAPI(matcher.Match(api.Path, api.Method))
 		}
 	}
+	return result, nil
+}
+
+// getUserRoleIDs 获取用户当前有效的角色分配、通过分组获得的角色、当前组织内的角色及其继承的全部父角色
+func (s *PermissionService) getUserRoleIDs(userID uint, appID string) ([]uint, error) {
+	roleIDs, err := userAssignedRoleIDs(userID, appID, s.OrgID)
+	if err != nil {
+		return nil, err
+	}
 
-	return false, nil
+	hierarchyService := &RoleHierarchyService{}
+	return hierarchyService.ExpandRoles(appID, roleIDs)
 }
 
-// GetUserPermissionsFromDB 从数据库获取用户权限
-func (s *PermissionService) GetUserPermissionsFromDB(userID uint, appID string) ([]string, error) {
-	var permissions []string
+// RolePermissionSet 角色的直接权限与有效权限
+type RolePermissionSet struct {
+	PermissionIDs              []uint                      `json:"permission_ids"`                // 直接分配的权限
+	DenyPermissionIDs          []uint                      `json:"deny_permission_ids"`           // 直接设置的拒绝权限
+	Conditions                 map[uint]string             `json:"conditions"`                    // 直接分配的权限ID -> 生效条件（仅附带条件的权限）
+	Validity                   map[uint]AssignmentValidity `json:"validity"`                      // 直接分配的权限ID -> 有效期（仅有有效期且当前有效的权限）
+	DataScopes                 map[uint]DataScope          `json:"data_scopes"`                   // 直接授予的权限ID -> 数据范围（仅限定了数据范围的权限）
+	EffectivePermissionIDs     []uint                      `json:"effective_permission_ids"`      // 直接分配与继承的全部权限（已排除被无条件拒绝的权限）
+	EffectiveDenyPermissionIDs []uint                      `json:"effective_deny_permission_ids"` // 直接设置与继承的全部拒绝权限
+	ParentRoleIDs              []uint                      `json:"parent_role_ids"`               // 直接继承的父角色
+	AncestorRoleIDs            []uint                      `json:"ancestor_role_ids"`             // 全部祖先角色
+	InheritedFrom              map[uint][]uint             `json:"inherited_from"`                // 继承获得的权限ID -> 提供该权限的祖先角色
+}
 
-	// 查询用户角色
-	var userRoles []models.UserRole
-	if err := config.DB.Where("user_id = ? AND app_id = ?", userID, appID).Find(&userRoles).Error; err != nil {
+// GetRolePermissionSet 获取角色直接分配的权限和包含继承的有效权限，拒绝规则（含继承的）优先于授予
+func (s *PermissionService) GetRolePermissionSet(roleID uint, appID string) (*RolePermissionSet, error) {
+	graph, err := loadRoleGraph(appID)
+	if err != nil {
 		return nil, err
 	}
+	roleIDs := graph.Ancestors(roleID)
 
-	// 查询每个角色的权限
-	for _, userRole := range userRoles {
-		rolePermissions, err := s.getRolePermissions(userRole.RoleID, appID)
-		if err != nil {
+	// 每个角色授予的权限：缓存中的无条件授予，加上附带条件或有有效期的授予
+	granted := make(map[uint][]uint, len(roleIDs))
+	for _, id := range roleIDs {
+		if granted[id], err = s.getRolePermissions(id, appID); err != nil {
 			return nil, err
 		}
+	}
+	rules, err := getRoleUncachedRules(appID, roleIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &RolePermissionSet{
+		DenyPermissionIDs: []uint{},
+		Conditions:        map[uint]string{},
+		Validity:          map[uint]AssignmentValidity{},
+		DataScopes:        map[uint]DataScope{},
+		ParentRoleIDs:     graph[roleID],
+		AncestorRoleIDs:   []uint{},
+		InheritedFrom:     map[uint][]uint{},
+	}
+	if set.ParentRoleIDs == nil {
+		set.ParentRoleIDs = []uint{}
+	}
+
+	denied := make(map[uint]bool)
+	deniedAny := make(map[uint]bool)
+	for _, rp := range rules {
+		if rp.RoleID == roleID && rp.Condition != "" {
+			set.Conditions[rp.PermissionID] = rp.Condition
+		}
+		if rp.RoleID == roleID && (rp.ValidFrom != nil || rp.ValidUntil != nil) {
+			set.Validity[rp.PermissionID] = AssignmentValidity{ValidFrom: rp.ValidFrom, ValidUntil: rp.ValidUntil}
+		}
+		if rp.Effect != PermissionEffectDeny {
+			granted[rp.RoleID] = append(granted[rp.RoleID], rp.PermissionID)
+			continue
+		}
+		if rp.RoleID == roleID {
+			set.DenyPermissionIDs = append(set.DenyPermissionIDs, rp.PermissionID)
+		}
+		deniedAny[rp.PermissionID] = true
+		if rp.Condition == "" {
+			denied[rp.PermissionID] = true
+		}
+	}
+	set.DenyPermissionIDs = uniqueIDs(set.DenyPermissionIDs)
+	set.PermissionIDs = uniqueIDs(granted[roleID])
+
+	var scoped []models.RolePermission
+	if err := config.DB.Where("role_id = ? AND app_id = ? AND effect <> ? AND data_scope <> ''", roleID, appID, PermissionEffectDeny).
+		Find(&scoped).Error; err != nil {
+		return nil, err
+	}
+	for _, rp := range scoped {
+		if scope, err := decodeDataScope(rp.DataScope); err == nil {
+			set.DataScopes[rp.PermissionID] = scope
+		}
+	}
 
-		// 获取权限代码
-		for _, permissionID := range rolePermissions {
-			var permission models.Permission
-			if err := config.DB.Where("id = ? AND app_id = ? AND status = 1", permissionID, appID).First(&permission).Error; err != nil {
+	isDirect := make(map[uint]bool, len(set.PermissionIDs))
+	for _, id := range set.PermissionIDs {
+		isDirect[id] = true
+	}
+	effective := append([]uint(nil), set.PermissionIDs...)
+	for _, ancestorID := range roleIDs {
+		if ancestorID == roleID {
+			continue
+		}
+		set.AncestorRoleIDs = append(set.AncestorRoleIDs, ancestorID)
+		for _, permissionID := range uniqueIDs(granted[ancestorID]) {
+			if isDirect[permissionID] {
 				continue
 			}
-			permissions = append(permissions, permission.Code)
+			if _, ok := set.InheritedFrom[permissionID]; !ok {
+				effective = append(effective, permissionID)
+			}
+			set.InheritedFrom[permissionID] = append(set.InheritedFrom[permissionID], ancestorID)
 		}
 	}
 
-	return permissions, nil
+	set.EffectivePermissionIDs = make([]uint, 0, len(effective))
+	for _, permissionID := range effective {
+		if !denied[permissionID] {
+			set.EffectivePermissionIDs = append(set.EffectivePermissionIDs, permissionID)
+		}
+	}
+	set.EffectivePermissionIDs = uniqueIDs(set.EffectivePermissionIDs)
+	set.EffectiveDenyPermissionIDs = make([]uint, 0, len(deniedAny))
+	for permissionID := range deniedAny {
+		set.EffectiveDenyPermissionIDs = append(set.EffectiveDenyPermissionIDs, permissionID)
+	}
+	set.EffectiveDenyPermissionIDs = uniqueIDs(set.EffectiveDenyPermissionIDs)
+	return set, nil
 }
 
-// getRolePermissions 获取角色权限
+// getRolePermissions 获取角色无条件且长期有效的授予（不含拒绝规则、附带条件的规则和有有效期的规则）
 func (s *PermissionService) getRolePermissions(roleID uint, appID string) ([]uint, error) {
-	// 先尝试从Redis缓存获取
-	cacheKey := fmt.Sprintf("%s%d:%s", utils.RolePermissionPrefix, roleID, appID)
-	permissions, err := utils.SMembers(cacheKey)
-	if err != nil || len(permissions) == 0 {
+	// 先尝试从Redis缓存获取（缓存键带有版本号，权限变更后自动失效）
+	cacheKey, cacheable := rolePermissionCacheKey(roleID, appID)
+	var permissions []string
+	var err error
+	if cacheable {
+		permissions, err = utils.SMembers(cacheKey)
+	}
+	if !cacheable || err != nil || len(permissions) == 0 {
 		// 缓存未命中，从数据库查询
 		var rolePermissions []models.RolePermission
-		if err := config.DB.Where("role_id = ? AND app_id = ?", roleID, appID).Find(&rolePermissions).Error; err != nil {
+		if err := config.DB.Where("role_id = ? AND app_id = ? AND effect <> ? AND condition_expr = '' AND valid_from IS NULL AND valid_until IS NULL",
+			roleID, appID, PermissionEffectDeny).Find(&rolePermissions).Error; err != nil {
 			return nil, err
 		}
 
@@ -126,13 +357,13 @@ func (s *PermissionService) getRolePermissions(roleID uint, appID string) ([]uin
 		}
 
 		// 缓存到Redis
-		if len(permissionIDs) > 0 {
+		if cacheable && len(permissionIDs) > 0 {
 			var interfaceSlice []interface{}
 			for _, id := range permissionIDs {
 				interfaceSlice = append(interfaceSlice, id)
 			}
 			utils.SAdd(cacheKey, interfaceSlice...)
-			utils.Expire(cacheKey, 12*time.Hour)
+			utils.Expire(cacheKey, permissionCacheTTL)
 		}
 
 		return permissionIDs, nil
@@ -148,68 +379,91 @@ func (s *PermissionService) getRolePermissions(roleID uint, appID string) ([]uin
 	return permissionIDs, nil
 }
 
-// checkAPIPermissionByPermissionID 根据权限ID检查API权限
-func (s *PermissionService) checkAPIPermissionByPermissionID(permissionID uint, appID, apiPath, apiMethod string) (bool, error) {
-	// 先尝试从Redis缓存获取
-	cacheKey := fmt.Sprintf("%s%d:%s", utils.APIPermissionPrefix, permissionID, appID)
-	apis, err := utils.SMembers(cacheKey)
-	if err != nil || len(apis) == 0 {
-		// 缓存未命中，从数据库查询
-		apis, err = s.getAPIsByPermissionID(permissionID, appID)
-		if err != nil {
-			return false, err
-		}
+// apiMatcherTTL 进程内 API 规则匹配器的缓存时间
+const apiMatcherTTL = time.Minute
 
-		// 缓存到Redis
-		if len(apis) > 0 {
-			utils.SAdd(cacheKey, apis)
-			utils.Expire(cacheKey, 24*time.Hour)
-		}
-	}
+// apiMatcher 应用的 API 规则匹配器
+type apiMatcher struct {
+	*utils.RouteMatcher
+	expiresAt time.Time
+}
 
-	// 检查API权限
-	requestKey := apiPath + ":" + apiMethod
-	for _, api := range apis {
-		if api == requestKey {
-			return true, nil
-		}
+var apiMatchers sync.Map // appID -> *apiMatcher
+
+// getAPIMatcher 获取应用的 API 规则匹配器，缓存过期或失效后从数据库重建
+func getAPIMatcher(appID string) (*apiMatcher, error) {
+	if cached, ok := apiMatchers.Load(appID); ok && time.Now().Before(cached.(*apiMatcher).expiresAt) {
+		return cached.(*apiMatcher), nil
 	}
 
-	return false, nil
-}
+	var permissionIDs []uint
+	if err := config.DB.Model(&models.Permission{}).Where("app_id = ?", appID).Pluck("id", &permissionIDs).Error; err != nil {
+		return nil, err
+	}
+	exists := make(map[uint]bool, len(permissionIDs))
+	for _, id := range permissionIDs {
+		exists[id] = true
+	}
 
-// getAPIsByPermissionID 根据权限ID获取API列表
-func (s *PermissionService) getAPIsByPermissionID(permissionID uint, appID string) ([]string, error) {
 	var apis []models.API
-	if err := config.DB.Where("permission_id = ? AND app_id = ? AND status = 1", permissionID, appID).Find(&apis).Error; err != nil {
+	if err := config.DB.Where("app_id = ?", appID).Find(&apis).Error; err != nil {
 		return nil, err
 	}
 
-	var apiList []string
+	matcher := &apiMatcher{
+		RouteMatcher: utils.NewRouteMatcher(),
+		expiresAt:    time.Now().Add(apiMatcherTTL),
+	}
 	for _, api := range apis {
-		apiList = append(apiList, api.Path+":"+api.Method)
+		if !exists[api.PermissionID] {
+			// 关联的权限已删除
+			continue
+		}
+		rule := &utils.RouteRule{ID: api.ID, Path: api.Path, Method: api.Method, PermissionID: api.PermissionID}
+		if err := matcher.Add(rule); err != nil {
+			log.Printf("忽略无效的API规则 %d（%s %s）: %v", api.ID, api.Method, api.Path, err)
+		}
 	}
 
-	return apiList, nil
+	apiMatchers.Store(appID, matcher)
+	return matcher, nil
+}
+
+// APIMatcherCached 本副本是否缓存了应用的 API 规则匹配器且未过期
+func APIMatcherCached(appID string) bool {
+	cached, ok := apiMatchers.Load(appID)
+	return ok && time.Now().Before(cached.(*apiMatcher).expiresAt)
+}
+
+// InvalidateAPIMatcher API 规则或权限变更后使应用的匹配器缓存失效（包括其他副本）
+func InvalidateAPIMatcher(appID string) {
+	cacheService := &PermissionCacheService{}
+	cacheService.InvalidateAPIRules(appID)
 }
 
 // ValidateAppCredentials 验证应用凭据
 func ValidateAppCredentials(appID, appSecret string) (*models.Application, error) {
 	var app models.Application
-	if err := config.DB.Where("app_id = ? AND app_secret = ?", appID, appSecret).First(&app).Error; err != nil {
+	if err := config.DB.Where("app_id = ?", appID).First(&app).Error; err != nil || !VerifyAppSecret(appID, appSecret) {
 		return nil, errors.New("无效的应用凭据")
 	}
 	return &app, nil
 }
 
-// CheckUserPermission 检查用户权限（全局函数）
-func CheckUserPermission(userID uint, appID, permission string) (bool, error) {
-	service := &PermissionService{}
+// CheckUserPermission 检查用户在当前组织 orgID 内的权限（全局函数）
+func CheckUserPermission(userID uint, appID string, orgID uint, permission string) (bool, error) {
+	service := &PermissionService{OrgID: orgID}
 	return service.CheckUserPermission(userID, appID, permission)
 }
 
-// CheckAPIPermission 检查API权限（全局函数）
-func CheckAPIPermission(userID uint, appID, apiPath, apiMethod string) (bool, error) {
-	service := &PermissionService{}
+// CheckAPIPermission 检查用户在当前组织 orgID 内的API权限（全局函数）
+func CheckAPIPermission(userID uint, appID string, orgID uint, apiPath, apiMethod string) (bool, error) {
+	service := &PermissionService{OrgID: orgID}
 	return service.CheckAPIPermission(userID, appID, apiPath, apiMethod)
 }
+
+// CheckAPIPermissionWithContext 按请求上下文检查用户在当前组织 orgID 内的API权限（全局函数）
+func CheckAPIPermissionWithContext(userID uint, appID string, orgID uint, apiPath, apiMethod string, pctx *PermissionContext) (bool, error) {
+	service := &PermissionService{OrgID: orgID}
+	return service.CheckAPIPermissionWithContext(userID, appID, apiPath, apiMethod, pctx)
+}
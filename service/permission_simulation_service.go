@@ -0,0 +1,456 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"auth-center/config"
+	"auth-center/models"
+	"auth-center/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSimulationUserNotFound = errors.New("用户不存在")
+	ErrSimulationRoleNotFound = errors.New("角色不存在或不属于当前应用")
+)
+
+// PermissionSimulationRequest 权限判定模拟请求：按用户实际的角色判定，或按假设的一组角色判定（what-if）
+type PermissionSimulationRequest struct {
+	UserID     uint                   `json:"user_id"`  // 用户，为 0 时只按假设的角色判定
+	OrgID      uint                   `json:"org_id"`   // 用户的当前组织，组织内的角色参与判定；为 0 时只按应用级的角色判定
+	RoleIDs    *[]uint                `json:"role_ids"` // 假设的角色，替代用户实际分配的角色；不提供时使用用户实际的角色
+	Permission string                 `json:"permission"`
+	Path       string                 `json:"path"`
+	Method     string                 `json:"method"`
+	IP         string                 `json:"ip"`       // 求值附带条件的规则时使用的来源IP
+	Time       *time.Time             `json:"time"`     // 求值附带条件的规则时使用的时间，默认为当前时间
+	Resource   map[string]interface{} `json:"resource"` // 求值附带条件的规则时使用的资源属性
+}
+
+// PermissionSimulation 判定结果及完整的判定过程
+type PermissionSimulation struct {
+	*PermissionDecision
+	Trace *PermissionTrace `json:"trace"`
+}
+
+// PermissionTrace 判定过程
+type PermissionTrace struct {
+	User         *TraceUser         `json:"user,omitempty"`
+	Hypothetical bool               `json:"hypothetical"` // 是否按假设的角色判定
+	Roles        []TraceRole        `json:"roles"`        // 参与判定的角色（含继承的父角色）
+	Permissions  []TracePermission  `json:"permissions"`  // 判定涉及的权限及各自的判定结果
+	APIRules     []TraceAPIRule     `json:"api_rules"`    // 与请求方法相符的 API 规则及比较结果（仅 API 判定）
+	Cache        []TraceCacheLookup `json:"cache"`        // 判定前相关缓存的命中情况
+	BlockedBy    []TraceBlock       `json:"blocked_by"`   // 阻止访问的停用或缺失的对象，应用或用户已禁用时判定结果为拒绝
+}
+
+// TraceUser 判定的用户
+type TraceUser struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+	Status   int    `json:"status"`
+}
+
+// TraceRole 参与判定的角色
+type TraceRole struct {
+	ID          uint   `json:"id"`
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Status      int    `json:"status"`
+	Assigned    bool   `json:"assigned"`               // 直接分配、通过分组或在组织内获得（或假设）的角色，否则为继承获得
+	Groups      []uint `json:"groups,omitempty"`       // 通过分组获得时，提供该角色的分组（含上级分组）
+	OrgID       uint   `json:"org_id,omitempty"`       // 在当前组织内分配时为该组织
+	InheritedBy []uint `json:"inherited_by,omitempty"` // 继承获得时，继承该角色的直接分配角色
+}
+
+// TracePermission 判定涉及的权限
+type TracePermission struct {
+	ID       uint              `json:"id"`
+	Code     string            `json:"code"`
+	Name     string            `json:"name"`
+	Status   int               `json:"status"`
+	Decision string            `json:"decision"`
+	Grants   []PermissionGrant `json:"grants"`
+}
+
+// TraceAPIRule 比较过的 API 规则
+type TraceAPIRule struct {
+	ID           uint   `json:"id"`
+	Path         string `json:"path"`
+	Method       string `json:"method"`
+	PermissionID uint   `json:"permission_id"`
+	Matched      bool   `json:"matched"`        // 路径模式和方法与请求相符
+	Selected     bool   `json:"selected"`       // 相符的规则中最具体的，参与判定
+	Note         string `json:"note,omitempty"` // 规则未参与匹配的原因
+}
+
+// TraceCacheLookup 缓存命中情况
+type TraceCacheLookup struct {
+	Cache    string `json:"cache"` // role_permissions、permission_snapshot 或 api_matcher
+	Key      string `json:"key"`
+	Hit      bool   `json:"hit"`
+	Contains *bool  `json:"contains,omitempty"` // permission_snapshot 命中时，快照中是否拥有判定的权限代码（即 /permissions/check 的结果）
+}
+
+// TraceBlock 阻止访问的对象
+type TraceBlock struct {
+	Type   string `json:"type"` // application、user、permission 或 api_rule
+	ID     uint   `json:"id,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// SimulatePermission 判定用户（或假设的一组角色）是否拥有权限或可以访问API，并返回完整的判定过程。
+// 判定与实际请求使用相同的规则；附带条件的规则按请求中的 ip、time 和 resource 求值
+func (s *PermissionService) SimulatePermission(appID string, req *PermissionSimulationRequest) (*PermissionSimulation, error) {
+	trace := &PermissionTrace{
+		Hypothetical: req.RoleIDs != nil,
+		Roles:        []TraceRole{},
+		Permissions:  []TracePermission{},
+		APIRules:     []TraceAPIRule{},
+		Cache:        []TraceCacheLookup{},
+		BlockedBy:    []TraceBlock{},
+	}
+
+	var app models.Application
+	if err := config.DB.Where("app_id = ?", appID).First(&app).Error; err != nil {
+		return nil, err
+	}
+	if app.Status != 1 {
+		trace.BlockedBy = append(trace.BlockedBy, TraceBlock{Type: "application", ID: app.ID, Name: app.AppID, Reason: "应用已禁用，用户无法登录或使用令牌"})
+	}
+
+	user := &models.User{}
+	if req.UserID != 0 {
+		if err := config.DB.Where("id = ? AND app_id = ?", req.UserID, appID).First(user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrSimulationUserNotFound
+			}
+			return nil, err
+		}
+		trace.User = &TraceUser{ID: user.ID, Username: user.Username, Status: user.Status}
+		if user.Status != 1 {
+			trace.BlockedBy = append(trace.BlockedBy, TraceBlock{Type: "user", ID: user.ID, Name: user.Username, Reason: "用户已禁用，无法登录或使用令牌"})
+		}
+	}
+
+	roleIDs, roleCodes, err := s.traceRoles(appID, user, req.RoleIDs, trace)
+	if err != nil {
+		return nil, err
+	}
+	for _, roleID := range roleIDs {
+		key, cacheable := rolePermissionCacheKey(roleID, appID)
+		lookup := TraceCacheLookup{Cache: "role_permissions", Key: key}
+		if cacheable {
+			lookup.Hit, _ = utils.Exists(key)
+		}
+		trace.Cache = append(trace.Cache, lookup)
+	}
+
+	grants, err := s.loadGrants(user.ID, appID, roleIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	pctx := &PermissionContext{IP: req.IP, Time: time.Now(), Path: req.Path, Method: req.Method, Resource: req.Resource}
+	if req.Time != nil {
+		pctx.Time = *req.Time
+	}
+	vars := buildConditionVars(user, s.OrgID, roleCodes, pctx)
+	evaluate := func(ruleGrants []PermissionGrant) error {
+		return evaluateGrantConditionsWith(ruleGrants, func() (map[string]interface{}, error) { return vars, nil })
+	}
+
+	var decision *PermissionDecision
+	if req.Permission != "" {
+		decision, err = s.simulateUserPermission(appID, user, req.Permission, grants, evaluate, trace)
+	} else {
+		decision, err = s.simulateAPIPermission(appID, req.Path, req.Method, grants, evaluate, trace)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, permission := range trace.Permissions {
+		if permission.Decision == PermissionDecisionDisabled {
+			trace.BlockedBy = append(trace.BlockedBy, TraceBlock{Type: "permission", ID: permission.ID, Name: permission.Code, Reason: "权限已授予但已停用"})
+		}
+	}
+	ApplySimulationBlocks(decision, trace.BlockedBy)
+	return &PermissionSimulation{PermissionDecision: decision, Trace: trace}, nil
+}
+
+// ApplySimulationBlocks 应用或用户已禁用时，实际请求在权限判定前即被拒绝，判定结果改为拒绝；
+// 按角色和规则判定的结果仍保留在 trace.permissions 中
+func ApplySimulationBlocks(decision *PermissionDecision, blocks []TraceBlock) {
+	for _, block := range blocks {
+		var result string
+		switch block.Type {
+		case "application":
+			result = PermissionDecisionAppDisabled
+		case "user":
+			result = PermissionDecisionUserDisabled
+		default:
+			continue
+		}
+		decision.Allowed = false
+		decision.Decision = result
+		decision.Reason = block.Reason
+		decision.DecidedBy = nil
+		return
+	}
+}
+
+// TraceRoleSources 参与判定的直接角色及其来源
+type TraceRoleSources struct {
+	Hypothetical bool            // 按假设的角色判定，此时 DirectIDs 中的角色都必须属于当前应用
+	DirectIDs    []uint          // 直接分配、通过分组获得、在当前组织内分配或假设的角色
+	GroupRoles   map[uint][]uint // 通过分组获得的角色 -> 提供该角色的分组（含上级分组）
+	OrgRoles     map[uint]bool   // 在当前组织内分配的角色
+	OrgID        uint
+}
+
+// traceRoles 确定参与判定的角色（直接分配、通过分组获得、在当前组织内分配或假设的角色及其继承的父角色），返回全部角色ID和直接角色的代码
+func (s *PermissionService) traceRoles(appID string, user *models.User, hypothetical *[]uint, trace *PermissionTrace) ([]uint, []string, error) {
+	sources, err := s.traceRoleSources(appID, user, hypothetical)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(sources.DirectIDs) == 0 {
+		return nil, nil, nil
+	}
+
+	graph, err := loadRoleGraph(appID)
+	if err != nil {
+		return nil, nil, err
+	}
+	roleIDs := graph.Ancestors(sources.DirectIDs...)
+	var roles []models.Role
+	if err := config.DB.Where("app_id = ? AND id IN ?", appID, roleIDs).Order("id").Find(&roles).Error; err != nil {
+		return nil, nil, err
+	}
+	traceRoles, roleCodes, err := BuildTraceRoles(sources, graph, roles)
+	if err != nil {
+		return nil, nil, err
+	}
+	trace.Roles = append(trace.Roles, traceRoles...)
+	return roleIDs, roleCodes, nil
+}
+
+// traceRoleSources 读取用户的直接角色及其来源；提供假设的角色时只使用假设的角色
+func (s *PermissionService) traceRoleSources(appID string, user *models.User, hypothetical *[]uint) (*TraceRoleSources, error) {
+	sources := &TraceRoleSources{OrgRoles: map[uint]bool{}}
+	if hypothetical != nil {
+		sources.Hypothetical = true
+		sources.DirectIDs = uniqueIDs(*hypothetical)
+		return sources, nil
+	}
+	if user.ID == 0 {
+		return sources, nil
+	}
+
+	if err := config.DB.Model(&models.UserRole{}).Scopes(activeAssignments("user_roles", time.Now())).
+		Where("user_id = ? AND app_id = ?", user.ID, appID).
+		Pluck("role_id", &sources.DirectIDs).Error; err != nil {
+		return nil, err
+	}
+	groupRoles, err := userGroupRoles(user.ID, appID)
+	if err != nil {
+		return nil, err
+	}
+	sources.GroupRoles = groupRoles
+	for roleID := range groupRoles {
+		sources.DirectIDs = append(sources.DirectIDs, roleID)
+	}
+	if s.OrgID != 0 {
+		orgRoleIDs, err := organizationRoleIDs(user.ID, appID, s.OrgID)
+		if err != nil {
+			return nil, err
+		}
+		for _, roleID := range orgRoleIDs {
+			sources.OrgRoles[roleID] = true
+		}
+		sources.OrgID = s.OrgID
+		sources.DirectIDs = append(sources.DirectIDs, orgRoleIDs...)
+	}
+	sources.DirectIDs = uniqueIDs(sources.DirectIDs)
+	return sources, nil
+}
+
+// BuildTraceRoles 按继承关系标注每个角色的来源。roles 为直接角色及其全部祖先角色（按ID排序），
+// 返回参与判定的角色和直接角色的代码；按假设的角色判定时，假设的角色不在 roles 中返回 ErrSimulationRoleNotFound
+func BuildTraceRoles(sources *TraceRoleSources, graph RoleGraph, roles []models.Role) ([]TraceRole, []string, error) {
+	directIDs := uniqueIDs(sources.DirectIDs)
+	isDirect := make(map[uint]bool, len(directIDs))
+	for _, id := range directIDs {
+		isDirect[id] = true
+	}
+	if sources.Hypothetical {
+		found := 0
+		for _, role := range roles {
+			if isDirect[role.ID] {
+				found++
+			}
+		}
+		if found != len(directIDs) {
+			return nil, nil, ErrSimulationRoleNotFound
+		}
+	}
+
+	inheritedBy := make(map[uint][]uint)
+	for _, directID := range directIDs {
+		for _, ancestorID := range graph.Ancestors(directID) {
+			if !isDirect[ancestorID] {
+				inheritedBy[ancestorID] = append(inheritedBy[ancestorID], directID)
+			}
+		}
+	}
+
+	traceRoles := []TraceRole{}
+	var roleCodes []string
+	for _, role := range roles {
+		traceRole := TraceRole{
+			ID:          role.ID,
+			Code:        role.Code,
+			Name:        role.Name,
+			Status:      role.Status,
+			Assigned:    isDirect[role.ID],
+			Groups:      sources.GroupRoles[role.ID],
+			InheritedBy: inheritedBy[role.ID],
+		}
+		if sources.OrgRoles[role.ID] {
+			traceRole.OrgID = sources.OrgID
+		}
+		traceRoles = append(traceRoles, traceRole)
+		if isDirect[role.ID] {
+			roleCodes = append(roleCodes, role.Code)
+		}
+	}
+	return traceRoles, roleCodes, nil
+}
+
+// simulateUserPermission 按权限代码判定
+func (s *PermissionService) simulateUserPermission(appID string, user *models.User, code string, grants map[uint][]PermissionGrant,
+	evaluate func([]PermissionGrant) error, trace *PermissionTrace) (*PermissionDecision, error) {
+	if user.ID != 0 {
+		key, snapshot := peekPermissionSnapshot(user.ID, appID, s.OrgID)
+		lookup := TraceCacheLookup{Cache: "permission_snapshot", Key: key}
+		if snapshot != nil {
+			contains := snapshot.HasPermission(code)
+			lookup.Hit, lookup.Contains = true, &contains
+		}
+		trace.Cache = append(trace.Cache, lookup)
+	}
+
+	var permission models.Permission
+	if err := config.DB.Where("app_id = ? AND code = ?", appID, code).First(&permission).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			trace.BlockedBy = append(trace.BlockedBy, TraceBlock{Type: "permission", Name: code, Reason: "权限不存在"})
+			return &PermissionDecision{
+				Decision:   PermissionDecisionPermissionNotFound,
+				Reason:     fmt.Sprintf("权限 %s 不存在", code),
+				Permission: code,
+				Grants:     []PermissionGrant{},
+			}, nil
+		}
+		return nil, err
+	}
+
+	if err := evaluate(grants[permission.ID]); err != nil {
+		return nil, err
+	}
+	decision := decide(&permission, grants[permission.ID])
+	trace.Permissions = append(trace.Permissions, tracePermission(&permission, decision))
+	return decision, nil
+}
+
+// simulateAPIPermission 按 API 判定，列出与请求方法相符的全部规则及其比较结果
+func (s *PermissionService) simulateAPIPermission(appID, apiPath, apiMethod string, grants map[uint][]PermissionGrant,
+	evaluate func([]PermissionGrant) error, trace *PermissionTrace) (*PermissionDecision, error) {
+	trace.Cache = append(trace.Cache, TraceCacheLookup{Cache: "api_matcher", Key: appID, Hit: APIMatcherCached(appID)})
+	matcher, err := getAPIMatcher(appID)
+	if err != nil {
+		return nil, err
+	}
+	selected := matcher.Match(apiPath, apiMethod)
+	isSelected := make(map[uint]bool, len(selected))
+	for _, rule := range selected {
+		isSelected[rule.ID] = true
+	}
+
+	var apis []models.API
+	if err := config.DB.Where("app_id = ?", appID).Order("id").Find(&apis).Error; err != nil {
+		return nil, err
+	}
+	method := utils.NormalizeRouteMethod(apiMethod)
+	permissionIDs := make([]uint, 0, len(apis))
+	for _, api := range apis {
+		permissionIDs = append(permissionIDs, api.PermissionID)
+	}
+	permissions := make(map[uint]*models.Permission)
+	if len(permissionIDs) > 0 {
+		var rows []models.Permission
+		if err := config.DB.Where("app_id = ? AND id IN ?", appID, uniqueIDs(permissionIDs)).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for i := range rows {
+			permissions[rows[i].ID] = &rows[i]
+		}
+	}
+
+	for _, api := range apis {
+		ruleMethod := utils.NormalizeRouteMethod(api.Method)
+		if ruleMethod != method && ruleMethod != utils.RouteMethodAny {
+			continue
+		}
+		compared := TraceAPIRule{ID: api.ID, Path: api.Path, Method: api.Method, PermissionID: api.PermissionID, Selected: isSelected[api.ID]}
+		single := utils.NewRouteMatcher()
+		if err := single.Add(&utils.RouteRule{ID: api.ID, Path: api.Path, Method: api.Method, PermissionID: api.PermissionID}); err != nil {
+			compared.Note = "路径模式无效: " + err.Error()
+		} else {
+			compared.Matched = len(single.Match(apiPath, apiMethod)) > 0
+			if _, ok := permissions[api.PermissionID]; !ok {
+				compared.Note = "关联的权限不存在"
+			} else if compared.Matched && !compared.Selected {
+				compared.Note = "存在更具体的规则"
+			}
+		}
+		trace.APIRules = append(trace.APIRules, compared)
+	}
+
+	if len(selected) == 0 {
+		trace.BlockedBy = append(trace.BlockedBy, TraceBlock{Type: "api_rule", Reason: fmt.Sprintf("没有与 %s %s 匹配的API规则", apiMethod, apiPath)})
+		return &PermissionDecision{
+			Decision: PermissionDecisionNoAPIRule,
+			Reason:   fmt.Sprintf("没有与 %s %s 匹配的API规则", apiMethod, apiPath),
+			Grants:   []PermissionGrant{},
+		}, nil
+	}
+
+	for _, rule := range selected {
+		permission, ok := permissions[rule.PermissionID]
+		if !ok {
+			continue
+		}
+		if err := evaluate(grants[permission.ID]); err != nil {
+			return nil, err
+		}
+		trace.Permissions = append(trace.Permissions, tracePermission(permission, decide(permission, grants[permission.ID])))
+	}
+	// 条件已在上面求值
+	return decideAPIRules(appID, selected, grants, func([]PermissionGrant) error { return nil })
+}
+
+func tracePermission(permission *models.Permission, decision *PermissionDecision) TracePermission {
+	return TracePermission{
+		ID:       permission.ID,
+		Code:     permission.Code,
+		Name:     permission.Name,
+		Status:   permission.Status,
+		Decision: decision.Decision,
+		Grants:   decision.Grants,
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"auth-center/config"
+	"auth-center/models"
+	"auth-center/utils"
+)
+
+// 权限快照：用户在应用内的全部规则按拒绝优先预先计算为权限ID和权限代码的哈希集合，
+// 缓存在进程内的定长 LRU 中，权限和 API 检查直接查集合，不再逐个角色读取数据库和 Redis。
+// 快照带有构建时读取的应用和用户版本号，版本号变更（见 permission_cache_service.go）后下次检查时重建；
+// 用户的角色分配或角色的权限分配有有效期时，快照在最早一次生效或失效时过期。
+// API 规则匹配器（前缀树）按应用编译一次，由全部用户的快照共享
+
+// PermissionSnapshot 用户在应用内的权限快照
+type PermissionSnapshot struct {
+	*EffectivePermissions
+	conditional map[uint]bool // 存在附带条件规则的权限，按请求上下文判定时需要完整求值
+	version     string        // 构建时的版本号
+	expiresAt   time.Time     // 有分配生效或失效的时间，为零值表示不会因有效期过期
+}
+
+// valid 快照是否仍然有效
+func (p *PermissionSnapshot) valid(version string, now time.Time) bool {
+	return p.version == version && (p.expiresAt.IsZero() || now.Before(p.expiresAt))
+}
+
+// NewPermissionSnapshot 根据用户的全部规则和相关权限记录构建快照
+func NewPermissionSnapshot(grants map[uint][]PermissionGrant, permissions []models.Permission) *PermissionSnapshot {
+	snapshot := &PermissionSnapshot{
+		EffectivePermissions: NewEffectivePermissions(grants, permissions),
+		conditional:          make(map[uint]bool),
+	}
+	for permissionID, rules := range grants {
+		for _, rule := range rules {
+			if rule.Condition != "" {
+				snapshot.conditional[permissionID] = true
+				break
+			}
+		}
+	}
+	return snapshot
+}
+
+// DecideAPI 按匹配到的 API 规则判定。withContext 为 true（提供了请求上下文）且规则关联的权限上有附带条件的规则时，
+// 快照无法给出结果，ok 为 false，需要按请求上下文完整求值
+func (p *PermissionSnapshot) DecideAPI(rules []*utils.RouteRule, withContext bool) (allowed, ok bool) {
+	if withContext {
+		for _, rule := range rules {
+			if p.conditional[rule.PermissionID] {
+				return false, false
+			}
+		}
+	}
+	return p.AllowsAPI(rules), true
+}
+
+// permissionSnapshotKey 快照缓存键，同一用户在不同组织内的快照分别缓存
+type permissionSnapshotKey struct {
+	appID  string
+	userID uint
+	orgID  uint
+}
+
+func (k permissionSnapshotKey) String() string {
+	if k.orgID != 0 {
+		return fmt.Sprintf("%s:%d@org:%d", k.appID, k.userID, k.orgID)
+	}
+	return fmt.Sprintf("%s:%d", k.appID, k.userID)
+}
+
+var (
+	permissionSnapshotsOnce sync.Once
+	permissionSnapshots     *utils.LRUCache[permissionSnapshotKey, *PermissionSnapshot]
+)
+
+// permissionSnapshotCache 进程内的快照缓存，容量由 [permission] snapshot_cache_size 配置
+func permissionSnapshotCache() *utils.LRUCache[permissionSnapshotKey, *PermissionSnapshot] {
+	permissionSnapshotsOnce.Do(func() {
+		capacity := 10000
+		if config.GlobalConfig != nil {
+			capacity = int(config.GlobalConfig.Permission.SnapshotCacheSize)
+		}
+		permissionSnapshots = utils.NewLRUCache[permissionSnapshotKey, *PermissionSnapshot](capacity)
+	})
+	return permissionSnapshots
+}
+
+func init() {
+	// 版本号不匹配的快照在读取时重建，这里及时释放已失效的快照
+	onPermissionInvalidate(func(invalidation *PermissionInvalidation) {
+		switch invalidation.Scope {
+		case PermissionScopeApp:
+			permissionSnapshotCache().RemoveFunc(func(key permissionSnapshotKey, _ *PermissionSnapshot) bool {
+				return key.appID == invalidation.AppID
+			})
+		case PermissionScopeUser:
+			permissionSnapshotCache().RemoveFunc(func(key permissionSnapshotKey, _ *PermissionSnapshot) bool {
+				return key.appID == invalidation.AppID && key.userID == invalidation.ID
+			})
+		}
+	})
+}
+
+// getPermissionSnapshot 获取用户的权限快照，缓存中没有或版本号已变更时重建
+func (s *PermissionService) getPermissionSnapshot(userID uint, appID string) (*PermissionSnapshot, error) {
+	// 先读取版本号再读取规则，构建期间发生的变更会递增版本号，不会把旧数据缓存在新版本下
+	version, versioned := userPermissionVersion(userID, appID)
+	key := permissionSnapshotKey{appID: appID, userID: userID, orgID: s.OrgID}
+	now := time.Now()
+	if versioned {
+		if snapshot, ok := permissionSnapshotCache().Get(key); ok && snapshot.valid(version, now) {
+			return snapshot, nil
+		}
+	}
+
+	roleIDs, err := s.getUserRoleIDs(userID, appID)
+	if err != nil {
+		return nil, err
+	}
+	grants, err := s.loadGrants(userID, appID, roleIDs)
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := loadGrantedPermissions(appID, grants)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := NewPermissionSnapshot(grants, permissions)
+	if versioned {
+		if snapshot.expiresAt, err = nextAssignmentChange(userID, appID, roleIDs, now); err != nil {
+			return nil, err
+		}
+		snapshot.version = version
+		permissionSnapshotCache().Add(key, snapshot)
+	}
+	return snapshot, nil
+}
+
+// peekPermissionSnapshot 读取缓存中仍然有效的快照，不构建也不影响淘汰顺序
+func peekPermissionSnapshot(userID uint, appID string, orgID uint) (string, *PermissionSnapshot) {
+	key := permissionSnapshotKey{appID: appID, userID: userID, orgID: orgID}
+	version, versioned := userPermissionVersion(userID, appID)
+	if !versioned {
+		return key.String(), nil
+	}
+	name := key.String() + "@" + version
+	if snapshot, ok := permissionSnapshotCache().Peek(key); ok && snapshot.valid(version, time.Now()) {
+		return name, snapshot
+	}
+	return name, nil
+}
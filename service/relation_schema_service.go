@@ -0,0 +1,584 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 关系模式与求值
+//
+// 关系元组写作 object#relation@subject，例如：
+//
+//	document:42#owner@user:alice        alice 是文档 42 的 owner
+//	document:42#viewer@team:eng#member  team:eng 的全部 member 都是文档 42 的 viewer
+//	document:42#parent@folder:7         文档 42 位于目录 7 中
+//
+// 关系模式按命名空间定义每个关系的计算方式（userset rewrite），例如 editor 包含 owner，
+// viewer 包含 editor 以及父目录的 viewer。
+
+// ErrRelationDepthExceeded 关系求值超过最大深度
+var ErrRelationDepthExceeded = errors.New("关系求值超过最大深度")
+
+// ErrRelationInvalid 请求中的对象、主体或关系不合法，或不符合关系模式
+var ErrRelationInvalid = errors.New("无效的关系请求")
+
+// relationMaxDepth 关系求值的最大递归深度
+const relationMaxDepth = 32
+
+// RelationSchema 关系模式：命名空间 -> 关系定义
+type RelationSchema struct {
+	Namespaces map[string]*RelationNamespace `json:"namespaces"`
+}
+
+// RelationNamespace 命名空间（对象类型）的关系定义
+type RelationNamespace struct {
+	Relations map[string]*UsersetRewrite `json:"relations"`
+}
+
+// UsersetRewrite 关系的计算方式，只能设置一项；全部为空时等同于 this
+type UsersetRewrite struct {
+	This            *struct{}             `json:"this,omitempty"`             // 直接写入该关系的元组
+	ComputedUserset string                `json:"computed_userset,omitempty"` // 同一对象的另一关系，如 editor 包含 owner
+	TupleToUserset  *TupleToUserset       `json:"tuple_to_userset,omitempty"` // 经由关联对象的关系，如父目录的 viewer
+	Union           []*UsersetRewrite     `json:"union,omitempty"`
+	Intersection    []*UsersetRewrite     `json:"intersection,omitempty"`
+	Exclusion       *UsersetRewriteExcept `json:"exclusion,omitempty"`
+}
+
+// TupleToUserset 先取对象 Tupleset 关系上的关联对象，再取关联对象的 ComputedUserset 关系
+type TupleToUserset struct {
+	Tupleset        string `json:"tupleset"`
+	ComputedUserset string `json:"computed_userset"`
+}
+
+// UsersetRewriteExcept 属于 Base 但不属于 Subtract
+type UsersetRewriteExcept struct {
+	Base     *UsersetRewrite `json:"base"`
+	Subtract *UsersetRewrite `json:"subtract"`
+}
+
+// isThis 是否为直接关系
+func (r *UsersetRewrite) isThis() bool {
+	return r == nil || r.This != nil ||
+		(r.ComputedUserset == "" && r.TupleToUserset == nil && r.Union == nil && r.Intersection == nil && r.Exclusion == nil)
+}
+
+// allowsDirect 关系是否接受直接写入的元组
+func (r *UsersetRewrite) allowsDirect() bool {
+	if r.isThis() {
+		return true
+	}
+	for _, child := range append(append([]*UsersetRewrite(nil), r.Union...), r.Intersection...) {
+		if child.allowsDirect() {
+			return true
+		}
+	}
+	return r.Exclusion != nil && r.Exclusion.Base != nil && r.Exclusion.Base.allowsDirect()
+}
+
+// relation 查找关系定义
+func (s *RelationSchema) relation(namespace, relation string) (*UsersetRewrite, error) {
+	ns, ok := s.Namespaces[namespace]
+	if !ok {
+		return nil, fmt.Errorf("%w: 命名空间 %s 未定义", ErrRelationInvalid, namespace)
+	}
+	rewrite, ok := ns.Relations[relation]
+	if !ok {
+		return nil, fmt.Errorf("%w: 命名空间 %s 未定义关系 %s", ErrRelationInvalid, namespace, relation)
+	}
+	return rewrite, nil
+}
+
+// Validate 校验关系模式：名称合法，引用的关系均已定义
+func (s *RelationSchema) Validate() error {
+	if len(s.Namespaces) == 0 {
+		return errors.New("关系模式至少需要定义一个命名空间")
+	}
+	for name, ns := range s.Namespaces {
+		if !isRelationName(name) {
+			return fmt.Errorf("命名空间名称不合法: %s", name)
+		}
+		if ns == nil || len(ns.Relations) == 0 {
+			return fmt.Errorf("命名空间 %s 至少需要定义一个关系", name)
+		}
+		for relation, rewrite := range ns.Relations {
+			if !isRelationName(relation) {
+				return fmt.Errorf("命名空间 %s 的关系名称不合法: %s", name, relation)
+			}
+			if err := s.validateRewrite(name, rewrite); err != nil {
+				return fmt.Errorf("%s#%s: %v", name, relation, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *RelationSchema) validateRewrite(namespace string, r *UsersetRewrite) error {
+	if r == nil {
+		return nil
+	}
+	set := 0
+	for _, ok := range []bool{r.This != nil, r.ComputedUserset != "", r.TupleToUserset != nil, r.Union != nil, r.Intersection != nil, r.Exclusion != nil} {
+		if ok {
+			set++
+		}
+	}
+	if set > 1 {
+		return errors.New("每个计算规则只能设置一项")
+	}
+
+	relations := s.Namespaces[namespace].Relations
+	switch {
+	case r.ComputedUserset != "":
+		if _, ok := relations[r.ComputedUserset]; !ok {
+			return fmt.Errorf("引用了未定义的关系 %s", r.ComputedUserset)
+		}
+	case r.TupleToUserset != nil:
+		if _, ok := relations[r.TupleToUserset.Tupleset]; !ok {
+			return fmt.Errorf("引用了未定义的关系 %s", r.TupleToUserset.Tupleset)
+		}
+		if r.TupleToUserset.ComputedUserset == "" {
+			return errors.New("tuple_to_userset 缺少 computed_userset")
+		}
+	case r.Union != nil || r.Intersection != nil:
+		children := append(append([]*UsersetRewrite(nil), r.Union...), r.Intersection...)
+		if len(children) == 0 {
+			return errors.New("union 和 intersection 不能为空")
+		}
+		for _, child := range children {
+			if err := s.validateRewrite(namespace, child); err != nil {
+				return err
+			}
+		}
+	case r.Exclusion != nil:
+		if r.Exclusion.Base == nil || r.Exclusion.Subtract == nil {
+			return errors.New("exclusion 需要 base 和 subtract")
+		}
+		if err := s.validateRewrite(namespace, r.Exclusion.Base); err != nil {
+			return err
+		}
+		return s.validateRewrite(namespace, r.Exclusion.Subtract)
+	}
+	return nil
+}
+
+// isRelationName 命名空间和关系名称：小写字母、数字、下划线，以字母开头
+func isRelationName(name string) bool {
+	if name == "" || len(name) > 64 || name[0] < 'a' || name[0] > 'z' {
+		return false
+	}
+	for _, r := range name {
+		if !(r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+// RelationObject 对象引用 namespace:id
+type RelationObject struct {
+	Namespace string
+	ID        string
+}
+
+func (o RelationObject) String() string {
+	return o.Namespace + ":" + o.ID
+}
+
+// RelationSubject 主体引用：namespace:id，或表示一组主体的 namespace:id#relation
+type RelationSubject struct {
+	Namespace string
+	ID        string
+	Relation  string
+}
+
+func (s RelationSubject) String() string {
+	if s.Relation == "" {
+		return s.Namespace + ":" + s.ID
+	}
+	return s.Namespace + ":" + s.ID + "#" + s.Relation
+}
+
+// object 主体所指的对象
+func (s RelationSubject) object() RelationObject {
+	return RelationObject{Namespace: s.Namespace, ID: s.ID}
+}
+
+// ParseRelationObject 解析 namespace:id
+func ParseRelationObject(value string) (RelationObject, error) {
+	namespace, id, ok := strings.Cut(value, ":")
+	if !ok || !isRelationName(namespace) || id == "" || strings.ContainsAny(id, "#@ ") {
+		return RelationObject{}, fmt.Errorf("%w: 对象格式错误，应为 namespace:id: %s", ErrRelationInvalid, value)
+	}
+	return RelationObject{Namespace: namespace, ID: id}, nil
+}
+
+// ParseRelationSubject 解析 namespace:id 或 namespace:id#relation
+func ParseRelationSubject(value string) (RelationSubject, error) {
+	objectPart, relation, hasRelation := strings.Cut(value, "#")
+	object, err := ParseRelationObject(objectPart)
+	if err != nil || (hasRelation && !isRelationName(relation)) {
+		return RelationSubject{}, fmt.Errorf("%w: 主体格式错误，应为 namespace:id 或 namespace:id#relation: %s", ErrRelationInvalid, value)
+	}
+	return RelationSubject{Namespace: object.Namespace, ID: object.ID, Relation: relation}, nil
+}
+
+// TupleReader 读取关系元组
+type TupleReader interface {
+	// Subjects 返回直接写入 object#relation 的主体
+	Subjects(object RelationObject, relation string) ([]RelationSubject, error)
+	// SubjectTuples 返回主体对象为 subject 的元组（不限主体关系），用于 ListObjects 从主体反向查找
+	SubjectTuples(subject RelationObject) ([]RelationTupleRef, error)
+}
+
+// RelationTupleRef 反向查找得到的元组 object#relation@subject#subject_relation（主体对象由查询条件确定）
+type RelationTupleRef struct {
+	Object          RelationObject
+	Relation        string
+	SubjectRelation string
+}
+
+// RelationEvaluator 按关系模式和元组求值
+type RelationEvaluator struct {
+	Schema *RelationSchema
+	Reader TupleReader
+}
+
+// relationKey 对象与关系，用于检测循环
+func relationKey(object RelationObject, relation string) string {
+	return object.String() + "#" + relation
+}
+
+// Check 判断 subject 是否拥有 object 的 relation 关系
+func (e *RelationEvaluator) Check(object RelationObject, relation string, subject RelationSubject) (bool, error) {
+	return e.check(object, relation, subject, map[string]bool{}, 0)
+}
+
+func (e *RelationEvaluator) check(object RelationObject, relation string, subject RelationSubject, visiting map[string]bool, depth int) (bool, error) {
+	if depth > relationMaxDepth {
+		return false, ErrRelationDepthExceeded
+	}
+	rewrite, err := e.Schema.relation(object.Namespace, relation)
+	if err != nil {
+		return false, err
+	}
+
+	// 主体本身就是该关系（如检查 team:eng#member 是否为 team:eng 的 member）
+	if subject.Relation == relation && subject.object() == object {
+		return true, nil
+	}
+
+	key := relationKey(object, relation)
+	if visiting[key] {
+		return false, nil
+	}
+	visiting[key] = true
+	defer delete(visiting, key)
+
+	return e.checkRewrite(object, relation, rewrite, subject, visiting, depth)
+}
+
+func (e *RelationEvaluator) checkRewrite(object RelationObject, relation string, r *UsersetRewrite, subject RelationSubject, visiting map[string]bool, depth int) (bool, error) {
+	switch {
+	case r.isThis():
+		subjects, err := e.Reader.Subjects(object, relation)
+		if err != nil {
+			return false, err
+		}
+		for _, s := range subjects {
+			if s == subject {
+				return true, nil
+			}
+		}
+		for _, s := range subjects {
+			if s.Relation == "" {
+				continue
+			}
+			ok, err := e.check(s.object(), s.Relation, subject, visiting, depth+1)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+
+	case r.ComputedUserset != "":
+		return e.check(object, r.ComputedUserset, subject, visiting, depth+1)
+
+	case r.TupleToUserset != nil:
+		related, err := e.Reader.Subjects(object, r.TupleToUserset.Tupleset)
+		if err != nil {
+			return false, err
+		}
+		for _, s := range related {
+			if _, err := e.Schema.relation(s.Namespace, r.TupleToUserset.ComputedUserset); err != nil {
+				// 关联对象的类型没有该关系时跳过
+				continue
+			}
+			ok, err := e.check(s.object(), r.TupleToUserset.ComputedUserset, subject, visiting, depth+1)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+
+	case r.Union != nil:
+		for _, child := range r.Union {
+			ok, err := e.checkRewrite(object, relation, child, subject, visiting, depth+1)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+
+	case r.Intersection != nil:
+		for _, child := range r.Intersection {
+			ok, err := e.checkRewrite(object, relation, child, subject, visiting, depth+1)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+
+	default: // Exclusion
+		ok, err := e.checkRewrite(object, relation, r.Exclusion.Base, subject, visiting, depth+1)
+		if err != nil || !ok {
+			return false, err
+		}
+		excluded, err := e.checkRewrite(object, relation, r.Exclusion.Subtract, subject, visiting, depth+1)
+		return !excluded, err
+	}
+}
+
+// UsersetTree 关系展开树
+type UsersetTree struct {
+	Type     string         `json:"type"`               // this、computed_userset、tuple_to_userset、union、intersection、exclusion
+	Object   string         `json:"object,omitempty"`   // 展开的对象（仅关系节点）
+	Relation string         `json:"relation,omitempty"` // 展开的关系（仅关系节点）
+	Subjects []string       `json:"subjects,omitempty"` // 直接写入的主体（this 节点）
+	Children []*UsersetTree `json:"children,omitempty"`
+}
+
+// Expand 展开 object 的 relation 关系，给出全部主体及其来源
+func (e *RelationEvaluator) Expand(object RelationObject, relation string) (*UsersetTree, error) {
+	return e.expand(object, relation, map[string]bool{}, 0)
+}
+
+func (e *RelationEvaluator) expand(object RelationObject, relation string, visiting map[string]bool, depth int) (*UsersetTree, error) {
+	if depth > relationMaxDepth {
+		return nil, ErrRelationDepthExceeded
+	}
+	rewrite, err := e.Schema.relation(object.Namespace, relation)
+	if err != nil {
+		return nil, err
+	}
+
+	key := relationKey(object, relation)
+	if visiting[key] {
+		return &UsersetTree{Type: "this", Object: object.String(), Relation: relation}, nil
+	}
+	visiting[key] = true
+	defer delete(visiting, key)
+
+	tree, err := e.expandRewrite(object, relation, rewrite, visiting, depth)
+	if err != nil {
+		return nil, err
+	}
+	tree.Object, tree.Relation = object.String(), relation
+	return tree, nil
+}
+
+func (e *RelationEvaluator) expandRewrite(object RelationObject, relation string, r *UsersetRewrite, visiting map[string]bool, depth int) (*UsersetTree, error) {
+	switch {
+	case r.isThis():
+		subjects, err := e.Reader.Subjects(object, relation)
+		if err != nil {
+			return nil, err
+		}
+		tree := &UsersetTree{Type: "this", Subjects: []string{}}
+		for _, s := range subjects {
+			tree.Subjects = append(tree.Subjects, s.String())
+			if s.Relation == "" {
+				continue
+			}
+			if _, err := e.Schema.relation(s.Namespace, s.Relation); err != nil {
+				continue
+			}
+			child, err := e.expand(s.object(), s.Relation, visiting, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			tree.Children = append(tree.Children, child)
+		}
+		return tree, nil
+
+	case r.ComputedUserset != "":
+		child, err := e.expand(object, r.ComputedUserset, visiting, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return &UsersetTree{Type: "computed_userset", Children: []*UsersetTree{child}}, nil
+
+	case r.TupleToUserset != nil:
+		related, err := e.Reader.Subjects(object, r.TupleToUserset.Tupleset)
+		if err != nil {
+			return nil, err
+		}
+		tree := &UsersetTree{Type: "tuple_to_userset"}
+		for _, s := range related {
+			if _, err := e.Schema.relation(s.Namespace, r.TupleToUserset.ComputedUserset); err != nil {
+				continue
+			}
+			child, err := e.expand(s.object(), r.TupleToUserset.ComputedUserset, visiting, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			tree.Children = append(tree.Children, child)
+		}
+		return tree, nil
+	}
+
+	var tree *UsersetTree
+	var children []*UsersetRewrite
+	switch {
+	case r.Union != nil:
+		tree, children = &UsersetTree{Type: "union"}, r.Union
+	case r.Intersection != nil:
+		tree, children = &UsersetTree{Type: "intersection"}, r.Intersection
+	default:
+		tree, children = &UsersetTree{Type: "exclusion"}, []*UsersetRewrite{r.Exclusion.Base, r.Exclusion.Subtract}
+	}
+	for _, child := range children {
+		node, err := e.expandRewrite(object, relation, child, visiting, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		tree.Children = append(tree.Children, node)
+	}
+	return tree, nil
+}
+
+// ListObjects 列出 subject 拥有 relation 关系的 namespace 下的对象ID（按ID排序，最多 limit 个）
+//
+// 先从主体出发沿元组和关系模式反向遍历，得到主体可能拥有的对象，再逐个 Check 确认，
+// 读取量取决于与主体相关的元组，而不是命名空间下的对象总数
+func (e *RelationEvaluator) ListObjects(namespace, relation string, subject RelationSubject, limit int) ([]string, error) {
+	if _, err := e.Schema.relation(namespace, relation); err != nil {
+		return nil, err
+	}
+	ids, err := e.reachableObjects(namespace, relation, subject)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+
+	result := []string{}
+	for _, id := range ids {
+		ok, err := e.Check(RelationObject{Namespace: namespace, ID: id}, relation, subject)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result = append(result, id)
+			if limit > 0 && len(result) >= limit {
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// reachableObjects 从主体反向遍历 对象#关系，返回能到达 namespace#relation 的对象ID。
+// 遍历不区分 intersection 和 exclusion 的各分支，结果是最终结果的超集，需要再经 Check 确认
+func (e *RelationEvaluator) reachableObjects(namespace, relation string, subject RelationSubject) ([]string, error) {
+	index := e.Schema.reverseIndex()
+	visited := map[string]bool{}
+	var queue []RelationTupleRef
+	push := func(object RelationObject, relation string) {
+		key := relationKey(object, relation)
+		if !visited[key] {
+			visited[key] = true
+			queue = append(queue, RelationTupleRef{Object: object, Relation: relation})
+		}
+	}
+
+	// 一组主体本身拥有该关系；直接写入该主体的元组
+	if subject.Relation != "" {
+		push(subject.object(), subject.Relation)
+	}
+	refs, err := e.Reader.SubjectTuples(subject.object())
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		if ref.SubjectRelation == subject.Relation {
+			push(ref.Object, ref.Relation)
+		}
+	}
+
+	var ids []string
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if node.Object.Namespace == namespace && node.Relation == relation {
+			ids = append(ids, node.Object.ID)
+		}
+
+		// 同一对象上经 computed_userset 引用该关系的关系
+		for _, r := range index.computed[node.Object.Namespace+"#"+node.Relation] {
+			push(node.Object, r)
+		}
+		refs, err := e.Reader.SubjectTuples(node.Object)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			// 主体为 node 的元组，如 document:1#viewer@team:eng#member
+			if ref.SubjectRelation == node.Relation {
+				push(ref.Object, ref.Relation)
+			}
+			// 经 tuple_to_userset 取 node 对象该关系的关系，如 document:1#parent@folder:7 时文档的 viewer 包含目录的 viewer
+			for _, r := range index.tupleToUserset[ref.Object.Namespace+"#"+ref.Relation+"#"+node.Relation] {
+				push(ref.Object, r)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// relationReverseIndex 关系模式的反向引用
+type relationReverseIndex struct {
+	computed       map[string][]string // namespace#relation -> 经 computed_userset 引用它的关系
+	tupleToUserset map[string][]string // namespace#tupleset#computed_userset -> 经 tuple_to_userset 引用它的关系
+}
+
+// reverseIndex 构造关系模式的反向引用
+func (s *RelationSchema) reverseIndex() *relationReverseIndex {
+	index := &relationReverseIndex{computed: map[string][]string{}, tupleToUserset: map[string][]string{}}
+	for name, ns := range s.Namespaces {
+		for relation, rewrite := range ns.Relations {
+			index.add(name, relation, rewrite)
+		}
+	}
+	return index
+}
+
+func (index *relationReverseIndex) add(namespace, relation string, r *UsersetRewrite) {
+	switch {
+	case r.isThis():
+	case r.ComputedUserset != "":
+		key := namespace + "#" + r.ComputedUserset
+		index.computed[key] = append(index.computed[key], relation)
+	case r.TupleToUserset != nil:
+		key := namespace + "#" + r.TupleToUserset.Tupleset + "#" + r.TupleToUserset.ComputedUserset
+		index.tupleToUserset[key] = append(index.tupleToUserset[key], relation)
+	case r.Exclusion != nil:
+		// 只属于 subtract 的主体不拥有该关系
+		index.add(namespace, relation, r.Exclusion.Base)
+	default:
+		for _, child := range append(append([]*UsersetRewrite(nil), r.Union...), r.Intersection...) {
+			index.add(namespace, relation, child)
+		}
+	}
+}
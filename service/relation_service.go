@@ -0,0 +1,602 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"auth-center/config"
+	"auth-center/models"
+	"auth-center/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RelationService 关系授权服务（关系元组、关系模式和 Check/Expand/ListObjects 查询）
+type RelationService struct{}
+
+// 读取一致性
+const (
+	RelationConsistencyMinimizeLatency = "minimize_latency"  // 默认：使用缓存的版本，可能短暂读不到最新写入
+	RelationConsistencyAtLeastAsFresh  = "at_least_as_fresh" // 至少包含 token 对应版本的写入（读到自己的写入）
+	RelationConsistencyExactSnapshot   = "at_exact_snapshot" // 按 token 对应版本的快照读取
+	RelationConsistencyFull            = "fully_consistent"  // 读取最新版本
+)
+
+// 元组写入操作
+const (
+	RelationWriteCreate = "create" // 写入，已存在时报错
+	RelationWriteTouch  = "touch"  // 写入，已存在时忽略
+	RelationWriteDelete = "delete" // 删除，不存在时忽略
+)
+
+// relationSnapshotTTL 进程内缓存的关系模式和版本的有效期，minimize_latency 读取最多落后这么久
+const relationSnapshotTTL = 2 * time.Second
+
+// relationListObjectsLimit ListObjects 默认和最大返回数量
+const relationListObjectsLimit = 1000
+
+// relationTupleGCLockKey 多副本部署时只有一个副本清理已删除的元组
+const relationTupleGCLockKey = "relation:tuple_gc:lock"
+
+var (
+	// ErrRelationSchemaNotFound 应用未定义关系模式
+	ErrRelationSchemaNotFound = errors.New("应用未定义关系模式")
+	// ErrInvalidConsistencyToken 一致性令牌无效
+	ErrInvalidConsistencyToken = errors.New("无效的一致性令牌")
+)
+
+// RelationConsistency 读取一致性要求
+type RelationConsistency struct {
+	Mode  string `json:"mode"`  // 为空时使用 minimize_latency；传入 token 且未指定 mode 时使用 at_least_as_fresh
+	Token string `json:"token"` // 之前写入或读取返回的一致性令牌
+}
+
+// RelationTupleKey 关系元组 object#relation@subject
+type RelationTupleKey struct {
+	Object   string `json:"object"`   // namespace:id
+	Relation string `json:"relation"` // 关系名称
+	Subject  string `json:"subject"`  // namespace:id 或 namespace:id#relation
+}
+
+func (k RelationTupleKey) String() string {
+	return k.Object + "#" + k.Relation + "@" + k.Subject
+}
+
+// RelationTupleWrite 元组写入
+type RelationTupleWrite struct {
+	Operation string           `json:"operation"` // create、touch 或 delete
+	Tuple     RelationTupleKey `json:"tuple"`
+}
+
+// RelationTupleFilter 元组查询条件，均可为空
+type RelationTupleFilter struct {
+	Namespace string
+	ObjectID  string
+	Relation  string
+	Subject   string // namespace:id 或 namespace:id#relation
+}
+
+// EncodeConsistencyToken 把版本编码为一致性令牌
+func EncodeConsistencyToken(revision uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("rev:" + strconv.FormatUint(revision, 10)))
+}
+
+// DecodeConsistencyToken 从一致性令牌解析版本
+func DecodeConsistencyToken(token string) (uint64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(raw), "rev:") {
+		return 0, ErrInvalidConsistencyToken
+	}
+	revision, err := strconv.ParseUint(strings.TrimPrefix(string(raw), "rev:"), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidConsistencyToken
+	}
+	return revision, nil
+}
+
+// relationSnapshot 应用的关系模式和当前版本
+type relationSnapshot struct {
+	schema     *RelationSchema
+	revision   uint64
+	gcRevision uint64 // 已清理的删除版本，早于它的快照缺少已清理的元组
+	expiresAt  time.Time
+}
+
+var relationSnapshots sync.Map // appID -> *relationSnapshot
+
+// loadRelationSnapshot 获取应用的关系模式和版本；fresh 为 true 或缓存过期时从数据库读取
+func loadRelationSnapshot(appID string, fresh bool) (*relationSnapshot, error) {
+	if !fresh {
+		if cached, ok := relationSnapshots.Load(appID); ok && time.Now().Before(cached.(*relationSnapshot).expiresAt) {
+			return cached.(*relationSnapshot), nil
+		}
+	}
+
+	var row models.RelationSchema
+	if err := config.DB.Where("app_id = ?", appID).First(&row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRelationSchemaNotFound
+		}
+		return nil, err
+	}
+	snapshot, err := newRelationSnapshot(&row)
+	if err != nil {
+		return nil, err
+	}
+	relationSnapshots.Store(appID, snapshot)
+	return snapshot, nil
+}
+
+func newRelationSnapshot(row *models.RelationSchema) (*relationSnapshot, error) {
+	var schema RelationSchema
+	if err := json.Unmarshal([]byte(row.Definition), &schema); err != nil {
+		return nil, fmt.Errorf("解析关系模式失败: %v", err)
+	}
+	return &relationSnapshot{schema: &schema, revision: row.Revision, gcRevision: row.GCRevision, expiresAt: time.Now().Add(relationSnapshotTTL)}, nil
+}
+
+// resolveRelationRevision 按一致性要求确定读取使用的关系模式和版本
+func resolveRelationRevision(appID string, consistency *RelationConsistency) (*RelationSchema, uint64, error) {
+	mode, token := RelationConsistencyMinimizeLatency, ""
+	if consistency != nil {
+		token = consistency.Token
+		switch {
+		case consistency.Mode != "":
+			mode = consistency.Mode
+		case token != "":
+			mode = RelationConsistencyAtLeastAsFresh
+		}
+	}
+
+	var tokenRevision uint64
+	if mode == RelationConsistencyAtLeastAsFresh || mode == RelationConsistencyExactSnapshot {
+		if token == "" {
+			return nil, 0, fmt.Errorf("%w: %s 需要一致性令牌", ErrRelationInvalid, mode)
+		}
+		var err error
+		if tokenRevision, err = DecodeConsistencyToken(token); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	switch mode {
+	case RelationConsistencyMinimizeLatency:
+		snapshot, err := loadRelationSnapshot(appID, false)
+		if err != nil {
+			return nil, 0, err
+		}
+		return snapshot.schema, snapshot.revision, nil
+	case RelationConsistencyAtLeastAsFresh:
+		snapshot, err := loadRelationSnapshot(appID, false)
+		if err == nil && snapshot.revision < tokenRevision {
+			snapshot, err = loadRelationSnapshot(appID, true)
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if snapshot.revision < tokenRevision {
+			return nil, 0, ErrInvalidConsistencyToken
+		}
+		return snapshot.schema, snapshot.revision, nil
+	case RelationConsistencyExactSnapshot:
+		snapshot, err := loadRelationSnapshot(appID, false)
+		if err == nil && snapshot.revision < tokenRevision {
+			snapshot, err = loadRelationSnapshot(appID, true)
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		// 在 token 版本之后删除的元组已被清理时，无法还原该版本的快照
+		if snapshot.revision < tokenRevision || tokenRevision < snapshot.gcRevision {
+			return nil, 0, ErrInvalidConsistencyToken
+		}
+		return snapshot.schema, tokenRevision, nil
+	case RelationConsistencyFull:
+		snapshot, err := loadRelationSnapshot(appID, true)
+		if err != nil {
+			return nil, 0, err
+		}
+		return snapshot.schema, snapshot.revision, nil
+	}
+	return nil, 0, fmt.Errorf("%w: 不支持的一致性模式 %s", ErrRelationInvalid, mode)
+}
+
+// dbTupleReader 从数据库按版本快照读取元组，同一次查询内缓存读取结果
+type dbTupleReader struct {
+	appID         string
+	revision      uint64
+	subjects      map[string][]RelationSubject
+	subjectTuples map[RelationObject][]RelationTupleRef
+}
+
+func newDBTupleReader(appID string, revision uint64) *dbTupleReader {
+	return &dbTupleReader{
+		appID:         appID,
+		revision:      revision,
+		subjects:      map[string][]RelationSubject{},
+		subjectTuples: map[RelationObject][]RelationTupleRef{},
+	}
+}
+
+// snapshot 版本快照中可见的元组
+func (r *dbTupleReader) snapshot() *gorm.DB {
+	return config.DB.Model(&models.RelationTuple{}).
+		Where("app_id = ? AND created_revision <= ? AND (deleted_revision = 0 OR deleted_revision > ?)", r.appID, r.revision, r.revision)
+}
+
+func (r *dbTupleReader) Subjects(object RelationObject, relation string) ([]RelationSubject, error) {
+	key := relationKey(object, relation)
+	if subjects, ok := r.subjects[key]; ok {
+		return subjects, nil
+	}
+
+	var tuples []models.RelationTuple
+	if err := r.snapshot().Where("namespace = ? AND object_id = ? AND relation = ?", object.Namespace, object.ID, relation).
+		Order("id").Find(&tuples).Error; err != nil {
+		return nil, err
+	}
+	subjects := make([]RelationSubject, 0, len(tuples))
+	for _, tuple := range tuples {
+		subjects = append(subjects, RelationSubject{Namespace: tuple.SubjectNamespace, ID: tuple.SubjectID, Relation: tuple.SubjectRelation})
+	}
+	r.subjects[key] = subjects
+	return subjects, nil
+}
+
+func (r *dbTupleReader) SubjectTuples(subject RelationObject) ([]RelationTupleRef, error) {
+	if refs, ok := r.subjectTuples[subject]; ok {
+		return refs, nil
+	}
+
+	var tuples []models.RelationTuple
+	if err := r.snapshot().Where("subject_namespace = ? AND subject_id = ?", subject.Namespace, subject.ID).
+		Order("id").Find(&tuples).Error; err != nil {
+		return nil, err
+	}
+	refs := make([]RelationTupleRef, 0, len(tuples))
+	for _, tuple := range tuples {
+		refs = append(refs, RelationTupleRef{
+			Object:          RelationObject{Namespace: tuple.Namespace, ID: tuple.ObjectID},
+			Relation:        tuple.Relation,
+			SubjectRelation: tuple.SubjectRelation,
+		})
+	}
+	r.subjectTuples[subject] = refs
+	return refs, nil
+}
+
+// relationEvaluator 按一致性要求创建求值器，返回使用的版本
+func relationEvaluator(appID string, consistency *RelationConsistency) (*RelationEvaluator, uint64, error) {
+	schema, revision, err := resolveRelationRevision(appID, consistency)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &RelationEvaluator{Schema: schema, Reader: newDBTupleReader(appID, revision)}, revision, nil
+}
+
+// GetSchema 获取应用的关系模式和当前版本的一致性令牌
+func (s *RelationService) GetSchema(appID string) (*RelationSchema, string, error) {
+	snapshot, err := loadRelationSnapshot(appID, true)
+	if err != nil {
+		return nil, "", err
+	}
+	return snapshot.schema, EncodeConsistencyToken(snapshot.revision), nil
+}
+
+// UpdateSchema 替换应用的关系模式，返回写入后的一致性令牌
+func (s *RelationService) UpdateSchema(appID string, schema *RelationSchema) (string, error) {
+	if err := schema.Validate(); err != nil {
+		if errors.Is(err, ErrRelationInvalid) {
+			return "", err
+		}
+		return "", fmt.Errorf("%w: %v", ErrRelationInvalid, err)
+	}
+	definition, err := json.Marshal(schema)
+	if err != nil {
+		return "", err
+	}
+
+	var row models.RelationSchema
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("app_id = ?", appID).First(&row).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			row = models.RelationSchema{AppID: appID, Definition: string(definition), Revision: 1}
+			return tx.Create(&row).Error
+		}
+		if err != nil {
+			return err
+		}
+		row.Definition = string(definition)
+		row.Revision++
+		return tx.Save(&row).Error
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if snapshot, err := newRelationSnapshot(&row); err == nil {
+		relationSnapshots.Store(appID, snapshot)
+	}
+	return EncodeConsistencyToken(row.Revision), nil
+}
+
+// parseRelationTuple 解析并按关系模式校验元组
+func parseRelationTuple(schema *RelationSchema, key RelationTupleKey) (RelationObject, RelationSubject, error) {
+	object, err := ParseRelationObject(key.Object)
+	if err != nil {
+		return object, RelationSubject{}, err
+	}
+	subject, err := ParseRelationSubject(key.Subject)
+	if err != nil {
+		return object, subject, err
+	}
+	rewrite, err := schema.relation(object.Namespace, key.Relation)
+	if err != nil {
+		return object, subject, err
+	}
+	if !rewrite.allowsDirect() {
+		return object, subject, fmt.Errorf("%w: %s#%s 由其他关系计算得出，不能直接写入", ErrRelationInvalid, object.Namespace, key.Relation)
+	}
+	if subject.Relation != "" {
+		if _, err := schema.relation(subject.Namespace, subject.Relation); err != nil {
+			return object, subject, err
+		}
+	}
+	return object, subject, nil
+}
+
+// WriteTuples 写入或删除关系元组（同一批在一个事务内生效），返回写入后的一致性令牌
+func (s *RelationService) WriteTuples(appID string, writes []RelationTupleWrite) (string, error) {
+	if len(writes) == 0 {
+		return "", fmt.Errorf("%w: 没有要写入的元组", ErrRelationInvalid)
+	}
+
+	var row models.RelationSchema
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定关系模式记录，串行化同一应用的写入并分配版本
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("app_id = ?", appID).First(&row).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRelationSchemaNotFound
+			}
+			return err
+		}
+		snapshot, err := newRelationSnapshot(&row)
+		if err != nil {
+			return err
+		}
+		revision := row.Revision + 1
+
+		for _, write := range writes {
+			object, subject, err := parseRelationTuple(snapshot.schema, write.Tuple)
+			if err != nil {
+				return err
+			}
+			live := tx.Model(&models.RelationTuple{}).Where(
+				"app_id = ? AND namespace = ? AND object_id = ? AND relation = ? AND subject_namespace = ? AND subject_id = ? AND subject_relation = ? AND deleted_revision = 0",
+				appID, object.Namespace, object.ID, write.Tuple.Relation, subject.Namespace, subject.ID, subject.Relation)
+
+			switch write.Operation {
+			case RelationWriteCreate, RelationWriteTouch:
+				var count int64
+				if err := live.Count(&count).Error; err != nil {
+					return err
+				}
+				if count > 0 {
+					if write.Operation == RelationWriteCreate {
+						return fmt.Errorf("%w: 元组已存在: %s", ErrRelationInvalid, write.Tuple)
+					}
+					continue
+				}
+				tuple := models.RelationTuple{
+					AppID:            appID,
+					Namespace:        object.Namespace,
+					ObjectID:         object.ID,
+					Relation:         write.Tuple.Relation,
+					SubjectNamespace: subject.Namespace,
+					SubjectID:        subject.ID,
+					SubjectRelation:  subject.Relation,
+					CreatedRevision:  revision,
+				}
+				if err := tx.Create(&tuple).Error; err != nil {
+					return err
+				}
+			case RelationWriteDelete:
+				if err := live.Updates(map[string]interface{}{"deleted_revision": revision, "removed_at": time.Now()}).Error; err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("%w: 不支持的写入操作 %s", ErrRelationInvalid, write.Operation)
+			}
+		}
+
+		row.Revision = revision
+		return tx.Model(&row).Update("revision", revision).Error
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if snapshot, err := newRelationSnapshot(&row); err == nil {
+		relationSnapshots.Store(appID, snapshot)
+	}
+	return EncodeConsistencyToken(row.Revision), nil
+}
+
+// ReadTuples 按条件查询元组，返回读取使用的版本的一致性令牌
+func (s *RelationService) ReadTuples(appID string, filter *RelationTupleFilter, consistency *RelationConsistency) ([]RelationTupleKey, string, error) {
+	_, revision, err := resolveRelationRevision(appID, consistency)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := newDBTupleReader(appID, revision).snapshot()
+	if filter.Namespace != "" {
+		query = query.Where("namespace = ?", filter.Namespace)
+	}
+	if filter.ObjectID != "" {
+		query = query.Where("object_id = ?", filter.ObjectID)
+	}
+	if filter.Relation != "" {
+		query = query.Where("relation = ?", filter.Relation)
+	}
+	if filter.Subject != "" {
+		subject, err := ParseRelationSubject(filter.Subject)
+		if err != nil {
+			return nil, "", err
+		}
+		query = query.Where("subject_namespace = ? AND subject_id = ? AND subject_relation = ?", subject.Namespace, subject.ID, subject.Relation)
+	}
+
+	var tuples []models.RelationTuple
+	if err := query.Order("id").Limit(relationListObjectsLimit).Find(&tuples).Error; err != nil {
+		return nil, "", err
+	}
+	keys := make([]RelationTupleKey, 0, len(tuples))
+	for _, tuple := range tuples {
+		subject := RelationSubject{Namespace: tuple.SubjectNamespace, ID: tuple.SubjectID, Relation: tuple.SubjectRelation}
+		keys = append(keys, RelationTupleKey{
+			Object:   RelationObject{Namespace: tuple.Namespace, ID: tuple.ObjectID}.String(),
+			Relation: tuple.Relation,
+			Subject:  subject.String(),
+		})
+	}
+	return keys, EncodeConsistencyToken(revision), nil
+}
+
+// Check 判断 subject 是否拥有 object 的 relation 关系，返回判定使用的版本的一致性令牌
+func (s *RelationService) Check(appID, object, relation, subject string, consistency *RelationConsistency) (bool, string, error) {
+	obj, err := ParseRelationObject(object)
+	if err != nil {
+		return false, "", err
+	}
+	sub, err := ParseRelationSubject(subject)
+	if err != nil {
+		return false, "", err
+	}
+	evaluator, revision, err := relationEvaluator(appID, consistency)
+	if err != nil {
+		return false, "", err
+	}
+	allowed, err := evaluator.Check(obj, relation, sub)
+	if err != nil {
+		return false, "", err
+	}
+	return allowed, EncodeConsistencyToken(revision), nil
+}
+
+// Expand 展开 object 的 relation 关系
+func (s *RelationService) Expand(appID, object, relation string, consistency *RelationConsistency) (*UsersetTree, string, error) {
+	obj, err := ParseRelationObject(object)
+	if err != nil {
+		return nil, "", err
+	}
+	evaluator, revision, err := relationEvaluator(appID, consistency)
+	if err != nil {
+		return nil, "", err
+	}
+	tree, err := evaluator.Expand(obj, relation)
+	if err != nil {
+		return nil, "", err
+	}
+	return tree, EncodeConsistencyToken(revision), nil
+}
+
+// ListObjects 列出 subject 拥有 relation 关系的 namespace 下的对象ID
+func (s *RelationService) ListObjects(appID, namespace, relation, subject string, limit int, consistency *RelationConsistency) ([]string, string, error) {
+	sub, err := ParseRelationSubject(subject)
+	if err != nil {
+		return nil, "", err
+	}
+	if limit <= 0 || limit > relationListObjectsLimit {
+		limit = relationListObjectsLimit
+	}
+	evaluator, revision, err := relationEvaluator(appID, consistency)
+	if err != nil {
+		return nil, "", err
+	}
+	ids, err := evaluator.ListObjects(namespace, relation, sub, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	return ids, EncodeConsistencyToken(revision), nil
+}
+
+// StartRelationTupleGC 在后台定期清理超过保留时间的已删除元组
+func StartRelationTupleGC() {
+	interval := config.GetConfig().Relation.TupleGCInterval
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			locked, err := utils.SetNX(relationTupleGCLockKey, permissionCacheInstanceID, time.Duration(interval)*time.Second/2)
+			if err != nil || !locked {
+				continue
+			}
+			window := time.Duration(config.GetConfig().Relation.TupleGCWindow) * time.Second
+			if _, err := (&RelationService{}).GCTuples(time.Now().Add(-window)); err != nil {
+				log.Printf("清理已删除的关系元组失败: %v", err)
+			}
+		}
+	}()
+}
+
+// GCTuples 物理删除在 before 之前删除的元组，并记录各应用已清理的版本，返回删除的数量。
+// 清理后，早于已清理版本的一致性令牌不能再用于 at_exact_snapshot 读取
+func (s *RelationService) GCTuples(before time.Time) (int64, error) {
+	// 删除时间为空的是增加删除时间之前删除的元组，一并清理
+	var horizons []struct {
+		AppID    string
+		Revision uint64
+	}
+	if err := config.DB.Model(&models.RelationTuple{}).
+		Select("app_id, MAX(deleted_revision) AS revision").
+		Where("deleted_revision <> 0 AND (removed_at IS NULL OR removed_at < ?)", before).
+		Group("app_id").Scan(&horizons).Error; err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, horizon := range horizons {
+		var row models.RelationSchema
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			// 锁定关系模式记录，与写入串行；同一应用的版本随时间递增，不超过 horizon 的删除版本都已超过保留时间
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("app_id = ?", horizon.AppID).First(&row).Error; err != nil {
+				return err
+			}
+			result := tx.Where("app_id = ? AND deleted_revision <> 0 AND deleted_revision <= ?", horizon.AppID, horizon.Revision).
+				Delete(&models.RelationTuple{})
+			if result.Error != nil {
+				return result.Error
+			}
+			total += result.RowsAffected
+			if horizon.Revision <= row.GCRevision {
+				return nil
+			}
+			row.GCRevision = horizon.Revision
+			return tx.Model(&row).Update("gc_revision", horizon.Revision).Error
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 没有关系模式的应用不会再读取元组，跳过
+			continue
+		}
+		if err != nil {
+			return total, err
+		}
+		if snapshot, err := newRelationSnapshot(&row); err == nil {
+			relationSnapshots.Store(horizon.AppID, snapshot)
+		}
+	}
+	return total, nil
+}
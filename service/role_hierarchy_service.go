@@ -0,0 +1,180 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"auth-center/config"
+	"auth-center/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RoleHierarchyService 角色继承服务
+type RoleHierarchyService struct{}
+
+// ErrRoleInheritanceCycle 设置父角色后继承关系成环
+var ErrRoleInheritanceCycle = errors.New("角色继承关系不能成环")
+
+// RoleGraph 角色继承关系图：角色ID -> 父角色ID列表
+type RoleGraph map[uint][]uint
+
+// loadRoleGraph 读取应用内全部角色继承关系
+func loadRoleGraph(appID string) (RoleGraph, error) {
+	var edges []models.RoleInheritance
+	if err := config.DB.Where("app_id = ?", appID).Find(&edges).Error; err != nil {
+		return nil, err
+	}
+	graph := make(RoleGraph, len(edges))
+	for _, edge := range edges {
+		graph[edge.RoleID] = append(graph[edge.RoleID], edge.ParentRoleID)
+	}
+	return graph, nil
+}
+
+// Ancestors 返回 roots 及其全部祖先角色（按ID排序，去重）
+func (g RoleGraph) Ancestors(roots ...uint) []uint {
+	seen := make(map[uint]bool, len(roots))
+	queue := append([]uint(nil), roots...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		queue = append(queue, g[id]...)
+	}
+
+	result := make([]uint, 0, len(seen))
+	for id := range seen {
+		result = append(result, id)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// Descendants 返回继承 roleID 的全部角色（直接或间接，按ID排序，不含 roleID 本身）
+func (g RoleGraph) Descendants(roleID uint) []uint {
+	result := []uint{}
+	for childID := range g {
+		if childID == roleID {
+			continue
+		}
+		for _, ancestorID := range g.Ancestors(childID) {
+			if ancestorID == roleID {
+				result = append(result, childID)
+				break
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// CreatesCycle 将 roleID 的父角色设置为 parentIDs 后是否成环：任一父角色（含其祖先）为 roleID 本身
+func (g RoleGraph) CreatesCycle(roleID uint, parentIDs []uint) bool {
+	// 不考虑 roleID 现有的父角色，它们将被 parentIDs 替换
+	next := make(RoleGraph, len(g))
+	for id, parents := range g {
+		if id != roleID {
+			next[id] = parents
+		}
+	}
+	for _, ancestor := range next.Ancestors(parentIDs...) {
+		if ancestor == roleID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRoleParents 获取角色直接继承的父角色
+func (s *RoleHierarchyService) GetRoleParents(appID string, roleID uint) ([]uint, error) {
+	var edges []models.RoleInheritance
+	if err := config.DB.Where("app_id = ? AND role_id = ?", appID, roleID).Order("parent_role_id").Find(&edges).Error; err != nil {
+		return nil, err
+	}
+	parentIDs := make([]uint, 0, len(edges))
+	for _, edge := range edges {
+		parentIDs = append(parentIDs, edge.ParentRoleID)
+	}
+	return parentIDs, nil
+}
+
+// SetRoleParents 替换角色的父角色，父角色须属于同一应用且不能导致成环
+func (s *RoleHierarchyService) SetRoleParents(appID string, roleID uint, parentIDs []uint) error {
+	unique := make([]uint, 0, len(parentIDs))
+	seen := make(map[uint]bool, len(parentIDs))
+	for _, id := range parentIDs {
+		if id == roleID {
+			return errors.New("角色不能继承自身")
+		}
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	if len(unique) > 0 {
+		var count int64
+		if err := config.DB.Model(&models.Role{}).Where("app_id = ? AND id IN ?", appID, unique).Count(&count).Error; err != nil {
+			return err
+		}
+		if count != int64(len(unique)) {
+			return errors.New("父角色不存在或不属于当前应用")
+		}
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定应用记录，串行化同一应用内的继承关系修改，避免并发修改绕过成环检测
+		var app models.Application
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("app_id = ?", appID).First(&app).Error; err != nil {
+			return err
+		}
+		var edges []models.RoleInheritance
+		if err := tx.Where("app_id = ?", appID).Find(&edges).Error; err != nil {
+			return err
+		}
+		graph := make(RoleGraph, len(edges))
+		for _, edge := range edges {
+			graph[edge.RoleID] = append(graph[edge.RoleID], edge.ParentRoleID)
+		}
+		if graph.CreatesCycle(roleID, unique) {
+			return ErrRoleInheritanceCycle
+		}
+
+		if err := tx.Where("app_id = ? AND role_id = ?", appID, roleID).Delete(&models.RoleInheritance{}).Error; err != nil {
+			return err
+		}
+		for _, parentID := range unique {
+			edge := models.RoleInheritance{AppID: appID, RoleID: roleID, ParentRoleID: parentID}
+			if err := tx.Create(&edge).Error; err != nil {
+				return fmt.Errorf("保存角色继承关系失败: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 角色及继承它的角色的用户获得的权限随之变化
+	cacheService := &PermissionCacheService{}
+	cacheService.InvalidateRole(appID, roleID)
+	return nil
+}
+
+// ExpandRoles 返回角色及其通过继承获得的全部祖先角色
+func (s *RoleHierarchyService) ExpandRoles(appID string, roleIDs []uint) ([]uint, error) {
+	if len(roleIDs) == 0 {
+		return nil, nil
+	}
+	graph, err := loadRoleGraph(appID)
+	if err != nil {
+		return nil, err
+	}
+	return graph.Ancestors(roleIDs...), nil
+}
@@ -8,6 +8,7 @@ import (
 	"auth-center/models"
 	"auth-center/utils"
 	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
 )
 
 // SystemAdminService 系统管理员服务
@@ -17,13 +18,20 @@ type SystemAdminService struct{}
 type SystemLoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	// ClientIP、UserAgent 客户端信息，用于审计日志
+	ClientIP  string `json:"-"`
+	UserAgent string `json:"-"`
 }
 
 // SystemLoginResponse 系统登录响应
 type SystemLoginResponse struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
-	ExpiresIn    int    `json:"expires_in"`
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in,omitempty"`
+	// PasswordExpired 密码已超过策略规定的最长使用期限，此时不返回令牌，
+	// 需使用 PasswordChangeToken 调用 /system/password/expired 设置新密码后完成登录
+	PasswordExpired     bool   `json:"password_expired,omitempty"`
+	PasswordChangeToken string `json:"password_change_token,omitempty"`
 	Admin        struct {
 		ID         uint   `json:"id"`
 		Username   string `json:"username"`
@@ -34,6 +42,15 @@ type SystemLoginResponse struct {
 	} `json:"admin"`
 }
 
+// SystemChangeExpiredPasswordRequest 系统管理员密码过期时设置新密码并完成登录的请求
+type SystemChangeExpiredPasswordRequest struct {
+	PasswordChangeToken string `json:"password_change_token" binding:"required"`
+	NewPassword         string `json:"new_password" binding:"required"`
+	// ClientIP、UserAgent 客户端信息，用于审计日志
+	ClientIP  string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
 // SystemRegisterRequest 系统管理员注册请求
 type SystemRegisterRequest struct {
 	Username  string `json:"username" binding:"required"`
@@ -56,6 +73,22 @@ type SystemRegisterResponse struct {
 
 // SystemLogin 系统管理员登录
 func (s *SystemAdminService) SystemLogin(req *SystemLoginRequest) (*SystemLoginResponse, error) {
+	response, err := s.systemLogin(req)
+
+	// 记录登录审计日志（成功与失败）
+	var adminID uint
+	var appID string
+	if response != nil {
+		adminID = response.Admin.ID
+		appID = response.Admin.AppID
+	}
+	recordLoginAudit("system_login", AuditActorSystemAdmin, adminID, req.Username, appID, req.ClientIP, req.UserAgent, err)
+
+	return response, err
+}
+
+// systemLogin 校验系统管理员凭证并签发令牌
+func (s *SystemAdminService) systemLogin(req *SystemLoginRequest) (*SystemLoginResponse, error) {
 	// 查找系统管理员
 	var admin models.SystemAdmin
 	if err := config.DB.Where("username = ? AND is_active = ?", req.Username, true).First(&admin).Error; err != nil {
@@ -68,9 +101,35 @@ func (s *SystemAdminService) SystemLogin(req *SystemLoginRequest) (*SystemLoginR
 		return nil, errors.New("用户名或密码错误")
 	}
 
+	// 哈希算法或参数已过时则自动升级
+	upgradePasswordHash(&admin, req.Password, admin.Password)
+
+	// 密码已过期时不签发令牌，须先设置新密码
+	if (&PasswordPolicyService{}).IsPasswordExpired(AdminPolicyAppID(admin.AdminType, admin.AppID), admin.PasswordChangedAt) {
+		token, err := createPasswordChangeChallenge(&passwordChangeChallenge{
+			AccountType: AccountTypeSystemAdmin,
+			AccountID:   admin.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		response := &SystemLoginResponse{PasswordExpired: true, PasswordChangeToken: token}
+		response.Admin.ID = admin.ID
+		response.Admin.Username = admin.Username
+		response.Admin.AdminType = admin.AdminType
+		response.Admin.AppID = admin.AppID
+		response.Admin.IsActive = admin.IsActive
+		return response, nil
+	}
+
+	return s.issueAdminTokens(&admin)
+}
+
+// issueAdminTokens 为通过认证的系统管理员签发令牌并更新最后登录时间
+func (s *SystemAdminService) issueAdminTokens(admin *models.SystemAdmin) (*SystemLoginResponse, error) {
 	// 更新最后登录时间
 	now := time.Now()
-	config.DB.Model(&admin).Update("last_login_at", now)
+	config.DB.Model(admin).Update("last_login_at", now)
 
 	// 生成JWT令牌
 	accessToken, refreshToken, err := s.generateTokens(admin.ID, admin.Username, admin.AdminType, admin.AppID)
@@ -99,6 +158,33 @@ func (s *SystemAdminService) SystemLogin(req *SystemLoginRequest) (*SystemLoginR
 	return response, nil
 }
 
+// SystemChangeExpiredPassword 使用登录时返回的修改密码令牌设置新密码并签发令牌
+func (s *SystemAdminService) SystemChangeExpiredPassword(req *SystemChangeExpiredPasswordRequest) (*SystemLoginResponse, error) {
+	var admin models.SystemAdmin
+	response, err := s.systemChangeExpiredPassword(req, &admin)
+	recordLoginAudit("system_login_password_change", AuditActorSystemAdmin, admin.ID, admin.Username, admin.AppID, req.ClientIP, req.UserAgent, err)
+	return response, err
+}
+
+// systemChangeExpiredPassword 校验修改密码令牌，设置新密码后签发令牌；令牌仅在密码设置成功后失效
+func (s *SystemAdminService) systemChangeExpiredPassword(req *SystemChangeExpiredPasswordRequest, admin *models.SystemAdmin) (*SystemLoginResponse, error) {
+	challenge, err := loadPasswordChangeChallenge(AccountTypeSystemAdmin, "", req.PasswordChangeToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.DB.Where("id = ? AND is_active = ?", challenge.AccountID, true).First(admin).Error; err != nil {
+		return nil, errors.New("管理员不存在或已禁用")
+	}
+
+	if err := s.SetAdminPassword(admin, req.NewPassword); err != nil {
+		return nil, err
+	}
+	if err := finishPasswordChangeChallenge(req.PasswordChangeToken); err != nil {
+		return nil, err
+	}
+	return s.issueAdminTokens(admin)
+}
+
 // SystemRegister 系统管理员注册
 func (s *SystemAdminService) SystemRegister(req *SystemRegisterRequest) (*SystemRegisterResponse, error) {
 	// 验证应用级管理员必须指定应用ID
@@ -125,6 +211,13 @@ func (s *SystemAdminService) SystemRegister(req *SystemRegisterRequest) (*System
 		return nil, errors.New("邮箱已存在")
 	}
 
+	// 校验密码策略
+	policyAppID := AdminPolicyAppID(req.AdminType, req.AppID)
+	policyService := &PasswordPolicyService{}
+	if err := policyService.CheckPassword(policyAppID, AccountTypeSystemAdmin, 0, req.Username, req.Password); err != nil {
+		return nil, err
+	}
+
 	// 加密密码
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
@@ -132,17 +225,25 @@ func (s *SystemAdminService) SystemRegister(req *SystemRegisterRequest) (*System
 	}
 
 	// 创建系统管理员
+	now := time.Now()
 	admin := &models.SystemAdmin{
-		Username:  req.Username,
-		Email:     req.Email,
-		Phone:     req.Phone,
-		Password:  hashedPassword,
-		AdminType: req.AdminType,
-		AppID:     req.AppID,
-		IsActive:  true,
+		Username:          req.Username,
+		Email:             req.Email,
+		Phone:             req.Phone,
+		Password:          hashedPassword,
+		AdminType:         req.AdminType,
+		AppID:             req.AppID,
+		IsActive:          true,
+		PasswordChangedAt: &now,
 	}
 
-	if err := config.DB.Create(admin).Error; err != nil {
+	// 管理员与密码历史在同一事务中写入
+	if err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(admin).Error; err != nil {
+			return err
+		}
+		return policyService.RecordPasswordHistory(tx, policyAppID, AccountTypeSystemAdmin, admin.ID, hashedPassword)
+	}); err != nil {
 		return nil, errors.New("创建管理员失败")
 	}
 
@@ -172,6 +273,11 @@ func (s *SystemAdminService) SystemRefreshToken(refreshToken string) (*SystemLog
 		return nil, errors.New("管理员不存在或已禁用")
 	}
 
+	// 密码过期后不能再刷新，须重新登录并修改密码
+	if (&PasswordPolicyService{}).IsPasswordExpired(AdminPolicyAppID(admin.AdminType, admin.AppID), admin.PasswordChangedAt) {
+		return nil, ErrPasswordExpired
+	}
+
 	// 生成新的令牌
 	accessToken, newRefreshToken, err := s.generateTokens(admin.ID, admin.Username, admin.AdminType, admin.AppID)
 	if err != nil {
@@ -265,6 +371,36 @@ func (s *SystemAdminService) saveTokens(adminID uint, accessToken, refreshToken
 	return nil
 }
 
+// SetAdminPassword 按密码策略校验并更新系统管理员密码（修改、重置等场景共用）
+func (s *SystemAdminService) SetAdminPassword(admin *models.SystemAdmin, newPassword string) error {
+	policyAppID := AdminPolicyAppID(admin.AdminType, admin.AppID)
+	policyService := &PasswordPolicyService{}
+	if err := policyService.CheckPassword(policyAppID, AccountTypeSystemAdmin, admin.ID, admin.Username, newPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return errors.New("密码加密失败")
+	}
+
+	now := time.Now()
+	if err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(admin).Updates(map[string]interface{}{
+			"password":            hashedPassword,
+			"password_changed_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return policyService.RecordPasswordHistory(tx, policyAppID, AccountTypeSystemAdmin, admin.ID, hashedPassword)
+	}); err != nil {
+		return err
+	}
+	admin.Password = hashedPassword
+	admin.PasswordChangedAt = &now
+	return nil
+}
+
 // GetSystemAdminInfo 获取系统管理员信息
 func (s *SystemAdminService) GetSystemAdminInfo(adminID uint) (*models.SystemAdmin, error) {
 	var admin models.SystemAdmin
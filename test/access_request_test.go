@@ -0,0 +1,76 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"auth-center/service"
+	"auth-center/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestResolveAccessDuration(t *testing.T) {
+	tests := []struct {
+		name        string
+		requested   int64
+		maxDuration int64
+		want        int64
+		wantErr     error
+	}{
+		{"未指定时长取最长时长", 0, 3600, 3600, nil},
+		{"在允许范围内", 600, 3600, 600, nil},
+		{"等于最长时长", 3600, 3600, 3600, nil},
+		{"超过最长时长", 3601, 3600, 0, service.ErrAccessRequestInvalid},
+		{"负数时长", -1, 3600, 0, service.ErrAccessRequestInvalid},
+		{"角色不可申请", 600, 0, 0, service.ErrAccessRequestNotRequestable},
+	}
+	for _, tt := range tests {
+		got, err := service.ResolveAccessDuration(tt.requested, tt.maxDuration)
+		if got != tt.want || !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+			t.Errorf("%s: ResolveAccessDuration(%d, %d) = %d, %v，期望 %d, %v", tt.name, tt.requested, tt.maxDuration, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestApprovedAccessDuration(t *testing.T) {
+	tests := []struct {
+		name                   string
+		requested, maxDuration int64
+		want                   int64
+		err                    error
+	}{
+		{"未超过最长时长", 3600, 7200, 3600, nil},
+		{"最长时长已缩短", 7200, 3600, 3600, nil},
+		{"角色已不可申请", 3600, 0, 0, service.ErrAccessRequestNotRequestable},
+	}
+	for _, tt := range tests {
+		got, err := service.ApprovedAccessDuration(tt.requested, tt.maxDuration)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("%s: ApprovedAccessDuration(%d, %d) = %d, %v，期望 %d, %v", tt.name, tt.requested, tt.maxDuration, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestReviewRequestRechecksRole(t *testing.T) {
+	mr, mock := newMockBackends(t)
+	appID := "test-app-" + utils.GenerateShortCode(8)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM `access_requests`").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "app_id", "user_id", "role_id", "duration", "status"}).
+			AddRow(1, appID, 7, 3, 7200, service.AccessRequestPending))
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM `role_approvers`").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	// 申请后角色已停用或不再可申请
+	mock.ExpectQuery("SELECT \\* FROM `roles` WHERE .*status = 1 AND access_max_duration > 0.* FOR UPDATE").
+		WithArgs(3, appID, 1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	_, err := (&service.AccessRequestService{}).ReviewRequest(appID, 9, 1, true, "")
+	if !errors.Is(err, service.ErrAccessRequestNotRequestable) {
+		t.Errorf("err = %v，期望 ErrAccessRequestNotRequestable", err)
+	}
+	if len(mr.Keys()) != 0 {
+		t.Errorf("未批准时不应使权限缓存失效: %v", mr.Keys())
+	}
+}
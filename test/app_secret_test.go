@@ -0,0 +1,162 @@
+package test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"auth-center/config"
+	"auth-center/models"
+	"auth-center/service"
+	"auth-center/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRotatedSecretExpiries(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	graceEnd := now.Add(time.Hour)
+	soon := now.Add(time.Minute)
+	later := now.Add(2 * time.Hour)
+
+	tests := []struct {
+		name   string
+		active []models.AppSecret // 按 id 倒序
+		want   map[uint]time.Time
+	}{
+		{"没有有效密钥", nil, map[uint]time.Time{}},
+		{"旧密钥在宽限期后失效", []models.AppSecret{{ID: 1}}, map[uint]time.Time{1: graceEnd}},
+		{"已有两个有效密钥时较早的立即失效", []models.AppSecret{{ID: 2}, {ID: 1}}, map[uint]time.Time{2: graceEnd, 1: now}},
+		{"即将失效的密钥不延长", []models.AppSecret{{ID: 2, ExpiresAt: &soon}}, map[uint]time.Time{}},
+		{"宽限期缩短已有的失效时间", []models.AppSecret{{ID: 2, ExpiresAt: &later}}, map[uint]time.Time{2: graceEnd}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := service.RotatedSecretExpiries(tt.active, now, 3600)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RotatedSecretExpiries() = %v，期望 %v", got, tt.want)
+			}
+			// 加上新密钥后同时有效的密钥不超过两个
+			valid := 1
+			for _, secret := range tt.active {
+				expiresAt, updated := got[secret.ID]
+				if !updated && secret.ExpiresAt != nil {
+					expiresAt = *secret.ExpiresAt
+				}
+				if !expiresAt.Equal(now) {
+					valid++
+				}
+			}
+			if valid > 2 {
+				t.Errorf("轮换后有 %d 个有效密钥", valid)
+			}
+		})
+	}
+}
+
+func TestAppSecretUseDue(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-30 * time.Second)
+	stale := now.Add(-2 * time.Minute)
+
+	tests := []struct {
+		name       string
+		lastUsedAt *time.Time
+		want       bool
+	}{
+		{"从未使用", nil, true},
+		{"最小间隔内不重复记录", &recent, false},
+		{"超过最小间隔", &stale, true},
+	}
+	for _, tt := range tests {
+		if got := service.AppSecretUseDue(tt.lastUsedAt, now); got != tt.want {
+			t.Errorf("%s: AppSecretUseDue() = %v，期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyAppSecret(t *testing.T) {
+	_, mock := newMockBackends(t)
+	appID := "test-app-" + utils.GenerateShortCode(8)
+	const plaintext = "app_0123456789abcdef"
+
+	mock.ExpectQuery("SELECT \\* FROM `app_secrets`").WithArgs(appID, sqlmock.AnyArg(), utils.HashAppSecret(plaintext), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "app_id", "secret_hash"}).AddRow(1, appID, utils.HashAppSecret(plaintext)))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `app_secrets` SET `last_used_at`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if !service.VerifyAppSecret(appID, plaintext) {
+		t.Error("有效密钥应验证通过")
+	}
+
+	mock.ExpectQuery("SELECT \\* FROM `app_secrets`").WithArgs(appID, sqlmock.AnyArg(), utils.HashAppSecret("app_wrong"), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	if service.VerifyAppSecret(appID, "app_wrong") {
+		t.Error("无效密钥不应验证通过")
+	}
+	if service.VerifyAppSecret(appID, "") {
+		t.Error("空密钥不应验证通过")
+	}
+}
+
+func TestRevokeSecret(t *testing.T) {
+	tests := []struct {
+		name   string
+		active int
+		err    error
+	}{
+		{"唯一的有效密钥", 1, service.ErrLastActiveAppSecret},
+		{"轮换期间的旧密钥", 2, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mock := newMockBackends(t)
+			appID := "test-app-" + utils.GenerateShortCode(8)
+
+			mock.ExpectQuery("SELECT \\* FROM `app_secrets`").
+				WillReturnRows(sqlmock.NewRows([]string{"id", "app_id"}).AddRow(1, appID))
+			mock.ExpectQuery("SELECT count\\(\\*\\) FROM `app_secrets`").
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.active))
+			if tt.err == nil {
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE `app_secrets` SET `expires_at`").WithArgs(sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			err := (&service.AppSecretService{}).RevokeSecret(appID, 1)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("err = %v，期望 %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestMigrateLegacyAppSecrets(t *testing.T) {
+	original := config.GlobalConfig
+	defer func() { config.GlobalConfig = original }()
+	config.GlobalConfig = &config.Config{Signature: config.SignatureConfig{EncryptionKey: "server-key"}}
+
+	_, mock := newMockBackends(t)
+	appID := "test-app-" + utils.GenerateShortCode(8)
+	const plaintext = "app_0123456789abcdef"
+
+	mock.ExpectQuery("SELECT \\* FROM `applications` WHERE app_secret <> ''").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "app_id", "app_secret"}).AddRow(1, appID, plaintext))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM `app_secrets` WHERE secret_hash = ?").WithArgs(utils.HashAppSecret(plaintext), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec("INSERT INTO `app_secrets`").WithArgs(appID, utils.HashAppSecret(plaintext), "", "app_01234567", nil, nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(5, 1))
+	mock.ExpectExec("UPDATE `app_secrets` SET `signing_key_enc`").WithArgs(sqlmock.AnyArg(), 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// 迁移后清空明文密钥
+	mock.ExpectExec("UPDATE `applications` SET `app_secret`").WithArgs("", sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := service.MigrateLegacyAppSecrets(); err != nil {
+		t.Fatal(err)
+	}
+}
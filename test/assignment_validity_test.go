@@ -0,0 +1,56 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"auth-center/service"
+)
+
+func TestAssignmentValidity(t *testing.T) {
+	now := time.Now()
+	at := func(d time.Duration) *time.Time {
+		v := now.Add(d)
+		return &v
+	}
+
+	tests := []struct {
+		name     string
+		validity service.AssignmentValidity
+		wantErr  bool
+	}{
+		{"不限", service.AssignmentValidity{}, false},
+		{"只有生效时间", service.AssignmentValidity{ValidFrom: at(time.Hour)}, false},
+		{"未来的时间段", service.AssignmentValidity{ValidFrom: at(time.Hour), ValidUntil: at(2 * time.Hour)}, false},
+		{"失效时间早于生效时间", service.AssignmentValidity{ValidFrom: at(2 * time.Hour), ValidUntil: at(time.Hour)}, true},
+		{"已经失效", service.AssignmentValidity{ValidUntil: at(-time.Hour)}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.validity.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v，期望出错 %v", tt.name, err, tt.wantErr)
+		}
+	}
+	if !(service.AssignmentValidity{}).IsZero() || (service.AssignmentValidity{ValidUntil: at(time.Hour)}).IsZero() {
+		t.Error("IsZero 结果错误")
+	}
+}
+
+func TestEarliestAssignmentChange(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		v := now.Add(d)
+		return &v
+	}
+
+	if next := service.EarliestAssignmentChange(nil, now); !next.IsZero() {
+		t.Errorf("没有有效期时应为零值: %v", next)
+	}
+	bounds := []service.AssignmentValidity{
+		{ValidFrom: at(-time.Hour), ValidUntil: at(3 * time.Hour)}, // 已生效，3 小时后失效
+		{ValidFrom: at(2 * time.Hour)},                             // 2 小时后生效
+		{ValidUntil: at(-time.Minute)},                             // 已失效，尚未被删除
+	}
+	if next := service.EarliestAssignmentChange(bounds, now); !next.Equal(*at(2 * time.Hour)) {
+		t.Errorf("EarliestAssignmentChange = %v，期望 %v", next, *at(2 * time.Hour))
+	}
+}
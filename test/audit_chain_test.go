@@ -0,0 +1,171 @@
+package test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"auth-center/models"
+	"auth-center/utils"
+)
+
+// newAuditChain 构造一段连续的审计日志哈希链，并在最后一条记录上生成签名检查点
+func newAuditChain(t *testing.T, key ed25519.PrivateKey, n int) ([]models.AuditLog, *models.AuditCheckpoint) {
+	entries := make([]models.AuditLog, n)
+	prevHash := utils.GenesisAuditHash
+	for i := range entries {
+		entries[i] = models.AuditLog{
+			ID:        uint(i + 1),
+			Seq:       uint64(i + 1),
+			PrevHash:  prevHash,
+			ActorType: "system_admin",
+			ActorID:   1,
+			Action:    "update_user",
+			Diff:      `{"email":{"after":"b@example.com","before":"a@example.com"}}`,
+			Result:    "success",
+			CreatedAt: time.Now().Add(time.Duration(i) * time.Second),
+		}
+		entries[i].Hash = utils.AuditLogHash(&entries[i])
+		prevHash = entries[i].Hash
+	}
+
+	checkpoint := &models.AuditCheckpoint{ID: 1, Seq: entries[n-1].Seq, Hash: entries[n-1].Hash, CreatedAt: time.Now()}
+	utils.SignAuditCheckpoint(key, checkpoint)
+	return entries, checkpoint
+}
+
+// exportAuditChain 按导出格式写出审计日志
+func exportAuditChain(t *testing.T, format string, key ed25519.PrivateKey, entries []models.AuditLog, checkpoint *models.AuditCheckpoint) *bytes.Buffer {
+	var buf bytes.Buffer
+	writer, err := utils.NewAuditExportWriter(&buf, format)
+	if err != nil {
+		t.Fatalf("创建导出写入器失败: %v", err)
+	}
+	pub := key.Public().(ed25519.PublicKey)
+	writer.Write(&utils.AuditExportRecord{Type: utils.AuditRecordKey, KeyID: utils.AuditKeyID(pub), PublicKey: base64.StdEncoding.EncodeToString(pub)})
+	writer.Write(&utils.AuditExportRecord{Type: utils.AuditRecordCheckpoint, Checkpoint: checkpoint})
+	for i := range entries {
+		writer.Write(&utils.AuditExportRecord{Type: utils.AuditRecordEntry, Entry: &entries[i]})
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatalf("写出导出文件失败: %v", err)
+	}
+	return &buf
+}
+
+func TestAuditChainExportVerify(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	pub := key.Public().(ed25519.PublicKey)
+
+	for _, format := range []string{utils.AuditExportJSONL, utils.AuditExportCSV} {
+		entries, checkpoint := newAuditChain(t, key, 5)
+		verifier, keyID, err := utils.VerifyAuditExport(exportAuditChain(t, format, key, entries, checkpoint), format, pub)
+		if err != nil {
+			t.Fatalf("%s 校验失败: %v", format, err)
+		}
+		if verifier.Checked != 5 || len(verifier.Issues) != 0 || keyID != utils.AuditKeyID(pub) {
+			t.Errorf("%s 完整导出应校验通过: checked=%d, issues=%v", format, verifier.Checked, verifier.Issues)
+		}
+	}
+}
+
+func TestAuditChainTamperDetection(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	pub := key.Public().(ed25519.PublicKey)
+
+	hasIssue := func(verifier *utils.AuditChainVerifier, issueType string) bool {
+		for _, issue := range verifier.Issues {
+			if issue.Type == issueType {
+				return true
+			}
+		}
+		return false
+	}
+	verify := func(entries []models.AuditLog, checkpoint *models.AuditCheckpoint, trusted ed25519.PublicKey) *utils.AuditChainVerifier {
+		verifier, _, err := utils.VerifyAuditExport(exportAuditChain(t, utils.AuditExportJSONL, key, entries, checkpoint), utils.AuditExportJSONL, trusted)
+		if err != nil {
+			t.Fatalf("校验失败: %v", err)
+		}
+		return verifier
+	}
+
+	// 修改记录内容
+	entries, checkpoint := newAuditChain(t, key, 5)
+	entries[2].Result = "failure"
+	if !hasIssue(verify(entries, checkpoint, pub), utils.AuditIssueHashMismatch) {
+		t.Error("修改记录内容应被发现")
+	}
+
+	// 修改内容并重算本条哈希，后一条记录的链接断开
+	entries, checkpoint = newAuditChain(t, key, 5)
+	entries[2].Result = "failure"
+	entries[2].Hash = utils.AuditLogHash(&entries[2])
+	if !hasIssue(verify(entries, checkpoint, pub), utils.AuditIssueChainBroken) {
+		t.Error("重算哈希后链接断开应被发现")
+	}
+
+	// 删除中间的记录
+	entries, checkpoint = newAuditChain(t, key, 5)
+	entries = append(entries[:2], entries[3:]...)
+	if !hasIssue(verify(entries, checkpoint, pub), utils.AuditIssueGap) {
+		t.Error("删除记录应被发现")
+	}
+
+	// 截断链尾，检查点对应的记录缺失
+	entries, checkpoint = newAuditChain(t, key, 5)
+	if !hasIssue(verify(entries[:4], checkpoint, pub), utils.AuditIssueMissingRecord) {
+		t.Error("截断链尾应被发现")
+	}
+
+	// 两个副本分配了相同序号，链出现分叉
+	entries, checkpoint = newAuditChain(t, key, 5)
+	fork := entries[2]
+	fork.ID = 100
+	fork.Action = "delete_user"
+	fork.Hash = utils.AuditLogHash(&fork)
+	entries = append(entries[:3], append([]models.AuditLog{fork}, entries[3:]...)...)
+	if !hasIssue(verify(entries, checkpoint, pub), utils.AuditIssueGap) {
+		t.Error("重复序号应被发现")
+	}
+
+	// 使用其他密钥签名的检查点
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	entries, checkpoint = newAuditChain(t, otherKey, 5)
+	if !hasIssue(verify(entries, checkpoint, pub), utils.AuditIssueBadSignature) {
+		t.Error("未知密钥签名的检查点应被发现")
+	}
+}
+
+func TestAuditSigningKeyParse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit_signing.key")
+	if _, err := utils.LoadEd25519Key(path); err == nil {
+		t.Error("密钥文件不存在时应返回错误，不应自动生成")
+	}
+
+	key, err := utils.GenerateEd25519KeyFile(path)
+	if err != nil {
+		t.Fatalf("生成签名密钥失败: %v", err)
+	}
+	if _, err := utils.GenerateEd25519KeyFile(path); err == nil {
+		t.Error("密钥文件已存在时不应覆盖")
+	}
+	loaded, err := utils.LoadEd25519Key(path)
+	if err != nil || !loaded.Equal(key) {
+		t.Fatalf("读取签名密钥失败: %v", err)
+	}
+
+	// 以 DER 的 base64 直接配置的密钥与文件中的密钥相同
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	parsed, err := utils.ParseEd25519Key([]byte(base64.StdEncoding.EncodeToString(der)))
+	if err != nil || !parsed.Equal(key) {
+		t.Errorf("解析 base64 签名密钥失败: %v", err)
+	}
+	if _, err := utils.ParseEd25519Key([]byte("not a key")); err == nil {
+		t.Error("无效的签名密钥应返回错误")
+	}
+}
@@ -0,0 +1,62 @@
+package test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"auth-center/middleware"
+	"auth-center/models"
+	"auth-center/service"
+)
+
+func TestAuditDiff(t *testing.T) {
+	before := models.User{ID: 1, Username: "alice", Email: "alice@example.com", Password: "hash", Status: 1}
+
+	// after 只包含变更字段
+	diff := service.AuditDiff(before, map[string]interface{}{
+		"email":    "alice@new.example.com",
+		"status":   1,
+		"password": "new-password",
+	})
+
+	var changes map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(diff), &changes); err != nil {
+		t.Fatalf("解析差异失败: %v, diff=%s", err, diff)
+	}
+	if changes["email"]["before"] != "alice@example.com" || changes["email"]["after"] != "alice@new.example.com" {
+		t.Errorf("email 差异错误: %v", changes["email"])
+	}
+	if _, ok := changes["status"]; ok {
+		t.Error("未变化的字段不应出现在差异中")
+	}
+	if changes["password"]["after"] != "***" {
+		t.Errorf("密码应被脱敏: %v", changes["password"])
+	}
+	if strings.Contains(diff, "new-password") {
+		t.Error("差异中不应包含密码明文")
+	}
+
+	if service.AuditDiff(nil, before) != "" || service.AuditDiff(before, before) != "" {
+		t.Error("创建或无变化时差异应为空")
+	}
+
+	snapshot := service.AuditSnapshot(map[string]string{"app_id": "demo", "app_secret": "plain"})
+	if strings.Contains(snapshot, "plain") || !strings.Contains(snapshot, "demo") {
+		t.Errorf("快照脱敏错误: %s", snapshot)
+	}
+}
+
+func TestAuditActionName(t *testing.T) {
+	cases := map[string]string{
+		"auth-center/controllers.(*AppResourceController).CreateUser-fm":                         "create_user",
+		"auth-center/controllers.(*SystemAdminManagementController).ResetSystemAdminPassword-fm": "reset_system_admin_password",
+		"auth-center/controllers.(*AppManagementController).AddAppCertificate-fm":                "add_app_certificate",
+		"main.CheckAPIPermission": "check_api_permission",
+	}
+	for handler, want := range cases {
+		if got := middleware.AuditActionName(handler); got != want {
+			t.Errorf("AuditActionName(%s) = %s, 期望 %s", handler, got, want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"testing"
+
+	"auth-center/utils"
+)
+
+func TestBloomFilter(t *testing.T) {
+	breached := []string{"123456", "password", "qwerty", "iloveyou"}
+
+	filter := utils.NewBloomFilter(uint64(len(breached)), 0.001)
+	for _, password := range breached[:3] {
+		filter.Add(password)
+	}
+	// 以 SHA-1 摘要形式加入，与明文加入等价
+	filter.AddSHA1(sha1.Sum([]byte(breached[3])))
+
+	for _, password := range breached {
+		if !filter.Test(password) {
+			t.Errorf("泄露密码 %s 应该命中", password)
+		}
+	}
+	if filter.Test("Tr0ub4dor&3-correct-horse") {
+		t.Error("未泄露的密码不应命中")
+	}
+
+	// 写入后重新读取
+	var buf bytes.Buffer
+	if _, err := filter.WriteTo(&buf); err != nil {
+		t.Fatalf("写入布隆过滤器失败: %v", err)
+	}
+	loaded, err := utils.ReadBloomFilter(&buf)
+	if err != nil {
+		t.Fatalf("读取布隆过滤器失败: %v", err)
+	}
+	if loaded.Count() != filter.Count() {
+		t.Errorf("期望记录数 %d，实际得到 %d", filter.Count(), loaded.Count())
+	}
+	for _, password := range breached {
+		if !loaded.Test(password) {
+			t.Errorf("读取后泄露密码 %s 应该命中", password)
+		}
+	}
+
+	if _, err := utils.ReadBloomFilter(bytes.NewReader([]byte("not a bloom filter file"))); err == nil {
+		t.Error("无效文件应该返回错误")
+	}
+
+	// 文件头声明超大位数组时应在分配内存前拒绝
+	var oversized bytes.Buffer
+	if _, err := filter.WriteTo(&oversized); err != nil {
+		t.Fatalf("写入布隆过滤器失败: %v", err)
+	}
+	data := oversized.Bytes()
+	binary.LittleEndian.PutUint64(data[9:17], 1<<62)
+	if _, err := utils.ReadBloomFilter(bytes.NewReader(data)); err == nil {
+		t.Error("位数组长度超出上限时应该返回错误")
+	}
+}
@@ -0,0 +1,73 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"auth-center/service"
+)
+
+func TestValidateDataScope(t *testing.T) {
+	scope := service.DataScope{Type: service.DataScopeCustom, Values: []string{" north", "east", "north", ""}}
+	if err := service.ValidateDataScope(&scope); err != nil {
+		t.Fatalf("自定义数据范围应该有效: %v", err)
+	}
+	if !reflect.DeepEqual(scope.Values, []string{"east", "north"}) {
+		t.Errorf("取值应去除空白、去重并排序: %v", scope.Values)
+	}
+
+	invalid := []service.DataScope{
+		{Type: "region"},
+		{Type: service.DataScopeCustom},
+		{Type: service.DataScopeCustom, Values: []string{" "}},
+		{Type: service.DataScopeOwn, Values: []string{"east"}},
+	}
+	for _, scope := range invalid {
+		if err := service.ValidateDataScope(&scope); err == nil {
+			t.Errorf("数据范围 %+v 应该无效", scope)
+		}
+	}
+
+	if _, err := service.ValidateDataScopes(map[uint]service.DataScope{2: {Type: service.DataScopeOwn}}, []uint{1}); err == nil {
+		t.Error("未授予的权限不能设置数据范围")
+	}
+	scopes, err := service.ValidateDataScopes(map[uint]service.DataScope{
+		1: {Type: service.DataScopeAll},
+		2: {Type: service.DataScopeDepartment},
+	}, []uint{1, 2})
+	if err != nil || len(scopes) != 1 || scopes[2].Type != service.DataScopeDepartment {
+		t.Errorf("all 应视为未设置: %v, %v", scopes, err)
+	}
+}
+
+func TestMergeDataScopes(t *testing.T) {
+	// 没有生效的授予规则时不能访问任何数据
+	none := service.MergeDataScopes(nil)
+	if none.Allowed || none.All || none.Own || none.Department || len(none.CustomValues) != 0 {
+		t.Errorf("没有规则时不应有数据范围: %+v", none)
+	}
+
+	// 多条规则取并集
+	merged := service.MergeDataScopes([]service.DataScope{
+		{Type: service.DataScopeOwn},
+		{Type: service.DataScopeCustom, Values: []string{"north", "east"}},
+		{Type: service.DataScopeCustom, Values: []string{"east", "west"}},
+		{Type: service.DataScopeDepartment},
+	})
+	if !merged.Allowed || merged.All || !merged.Own || !merged.Department {
+		t.Errorf("合并结果错误: %+v", merged)
+	}
+	if !reflect.DeepEqual(merged.CustomValues, []string{"east", "north", "west"}) {
+		t.Errorf("自定义取值应合并去重: %v", merged.CustomValues)
+	}
+
+	// 任一规则为 all 时不限制
+	all := service.MergeDataScopes([]service.DataScope{
+		{Type: service.DataScopeOwn},
+		{Type: service.DataScopeAll},
+		{Type: service.DataScopeCustom, Values: []string{"east"}},
+	})
+	if !all.All || all.Own || all.Department || len(all.CustomValues) != 0 {
+		t.Errorf("包含 all 时应为全部数据: %+v", all)
+	}
+}
@@ -0,0 +1,189 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"auth-center/config"
+	"auth-center/middleware"
+	"auth-center/service"
+	"auth-center/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// newDPoPProof 使用 ES256 密钥生成 DPoP 证明
+func newDPoPProof(t *testing.T, key *ecdsa.PrivateKey, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["typ"] = "dpop+jwt"
+	token.Header["jwk"] = map[string]string{
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(key.PublicKey.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(key.PublicKey.Y.FillBytes(make([]byte, 32))),
+	}
+	proof, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("生成 DPoP 证明失败: %v", err)
+	}
+	return proof
+}
+
+func TestDPoPProof(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("生成密钥失败: %v", err)
+	}
+
+	accessToken := "eyJhbGciOiJIUzI1NiJ9.test.token"
+	ath := sha256.Sum256([]byte(accessToken))
+	claims := jwt.MapClaims{
+		"jti": "proof-1",
+		"htm": "GET",
+		"htu": "https://auth.example.com/api/v1/auth/user",
+		"iat": time.Now().Unix(),
+		"ath": base64.RawURLEncoding.EncodeToString(ath[:]),
+	}
+	proof := newDPoPProof(t, key, claims)
+
+	parsed, err := utils.ParseDPoPProof(proof, "GET", "https://AUTH.example.com:443/api/v1/auth/user?x=1", accessToken)
+	if err != nil {
+		t.Fatalf("DPoP 证明校验失败: %v", err)
+	}
+	if parsed.JTI != "proof-1" || parsed.Thumbprint == "" {
+		t.Errorf("DPoP 证明解析错误: %+v", parsed)
+	}
+
+	// 同一公钥的不同证明指纹一致
+	claims["jti"] = "proof-2"
+	again, err := utils.ParseDPoPProof(newDPoPProof(t, key, claims), "GET", "https://auth.example.com/api/v1/auth/user", accessToken)
+	if err != nil || again.Thumbprint != parsed.Thumbprint {
+		t.Errorf("同一公钥的指纹应一致: %v", err)
+	}
+
+	if _, err := utils.ParseDPoPProof(proof, "POST", "https://auth.example.com/api/v1/auth/user", accessToken); err == nil {
+		t.Error("htm 不一致应该校验失败")
+	}
+	if _, err := utils.ParseDPoPProof(proof, "GET", "https://auth.example.com/api/v1/auth/logout", accessToken); err == nil {
+		t.Error("htu 不一致应该校验失败")
+	}
+	if _, err := utils.ParseDPoPProof(proof, "GET", "https://auth.example.com/api/v1/auth/user", "other-token"); err == nil {
+		t.Error("ath 不一致应该校验失败")
+	}
+
+	// 对称算法不能用于 DPoP
+	hmacToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	hmacToken.Header["typ"] = "dpop+jwt"
+	hmacProof, _ := hmacToken.SignedString([]byte("secret"))
+	if _, err := utils.ParseDPoPProof(hmacProof, "GET", "https://auth.example.com/api/v1/auth/user", ""); err == nil {
+		t.Error("HS256 证明应该校验失败")
+	}
+}
+
+func TestJWKThumbprint(t *testing.T) {
+	// RFC 7638 第 3.1 节示例
+	jwk := utils.JWK{
+		Kty: "RSA",
+		N:   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:   "AQAB",
+	}
+	thumbprint, err := jwk.Thumbprint()
+	if err != nil {
+		t.Fatalf("计算指纹失败: %v", err)
+	}
+	if thumbprint != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Errorf("JWK 指纹错误: %s", thumbprint)
+	}
+}
+
+func TestDPoPNonceRequired(t *testing.T) {
+	original := config.GlobalConfig
+	defer func() { config.GlobalConfig = original }()
+	config.GlobalConfig = &config.Config{
+		JWT:  config.JWTConfig{SecretKey: "test-secret"},
+		DPoP: config.DPoPConfig{MaxAge: 300, RequireNonce: true, NonceTTL: 300},
+	}
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	claims := jwt.MapClaims{
+		"jti":   "proof-nonce",
+		"htm":   "POST",
+		"htu":   "https://auth.example.com/api/v1/auth/login",
+		"iat":   time.Now().Unix(),
+		"nonce": "forged-nonce",
+	}
+
+	_, err := service.VerifyDPoPProof(newDPoPProof(t, key, claims), "POST", "https://auth.example.com/api/v1/auth/login", "")
+	var dpopErr *service.DPoPError
+	if !errors.As(err, &dpopErr) || dpopErr.Code != service.DPoPErrorUseNonce {
+		t.Errorf("伪造的 nonce 应返回 use_dpop_nonce: %v", err)
+	}
+
+	// 过期的证明
+	claims["nonce"] = service.NewDPoPNonce()
+	claims["iat"] = time.Now().Add(-time.Hour).Unix()
+	_, err = service.VerifyDPoPProof(newDPoPProof(t, key, claims), "POST", "https://auth.example.com/api/v1/auth/login", "")
+	if !errors.As(err, &dpopErr) || dpopErr.Code != service.DPoPErrorInvalidProof {
+		t.Errorf("过期的证明应返回 invalid_dpop_proof: %v", err)
+	}
+}
+
+func TestRequestHTUTrustedProxies(t *testing.T) {
+	original := config.GlobalConfig
+	defer func() { config.GlobalConfig = original }()
+	config.GlobalConfig = &config.Config{
+		DPoP: config.DPoPConfig{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1", "2001:db8::1"}},
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       string
+	}{
+		{"可信网段", "10.1.2.3:5000", "https://auth.example.com/api/v1/auth/user"},
+		{"可信地址", "192.0.2.1:5000", "https://auth.example.com/api/v1/auth/user"},
+		{"可信 IPv6 地址", "[2001:db8::1]:5000", "https://auth.example.com/api/v1/auth/user"},
+		{"非可信地址忽略转发头", "198.51.100.7:5000", "http://internal:8080/api/v1/auth/user"},
+		{"相邻地址不可信", "192.0.2.2:5000", "http://internal:8080/api/v1/auth/user"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "http://internal:8080/api/v1/auth/user?x=1", nil)
+			c.Request.RemoteAddr = tt.remoteAddr
+			c.Request.Header.Set("X-Forwarded-Proto", "https, http")
+			c.Request.Header.Set("X-Forwarded-Host", "auth.example.com")
+			if got := middleware.RequestHTU(c); got != tt.want {
+				t.Errorf("RequestHTU = %s，期望 %s", got, tt.want)
+			}
+		})
+	}
+
+	// 未配置可信代理时忽略转发头
+	config.GlobalConfig = &config.Config{}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "http://internal:8080/api/v1/auth/user", nil)
+	c.Request.RemoteAddr = "10.1.2.3:5000"
+	c.Request.Header.Set("X-Forwarded-Host", "attacker.example.com")
+	if got := middleware.RequestHTU(c); got != "http://internal:8080/api/v1/auth/user" {
+		t.Errorf("未配置可信代理时 RequestHTU = %s", got)
+	}
+}
+
+func TestTrustedProxyNetworks(t *testing.T) {
+	if _, err := (config.DPoPConfig{TrustedProxies: []string{"10.0.0.0/8", "::1"}}).TrustedProxyNetworks(); err != nil {
+		t.Errorf("有效的可信代理应解析成功: %v", err)
+	}
+	for _, proxy := range []string{"10.0.0.0/33", "proxy.local", "10.0.0"} {
+		if _, err := (config.DPoPConfig{TrustedProxies: []string{proxy}}).TrustedProxyNetworks(); err == nil {
+			t.Errorf("%q 应为无效的可信代理", proxy)
+		}
+	}
+}
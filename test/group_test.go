@@ -0,0 +1,37 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"auth-center/service"
+)
+
+func TestResolveGroupRoles(t *testing.T) {
+	// 分组嵌套：backend(3) -> engineering(2) -> company(1)，oncall(4) 独立
+	graph := service.RoleGraph{
+		3: {2},
+		2: {1},
+	}
+	groupRoles := map[uint][]uint{
+		1: {10},     // company: employee
+		2: {20, 10}, // engineering: developer, employee
+		4: {40},     // oncall: operator
+	}
+
+	tests := []struct {
+		name   string
+		groups []uint
+		want   map[uint][]uint
+	}{
+		{"下级分组的成员获得上级分组的角色", []uint{3}, map[uint][]uint{10: {1, 2}, 20: {2}}},
+		{"多个分组", []uint{1, 4}, map[uint][]uint{10: {1}, 40: {4}}},
+		{"分组没有角色", []uint{5}, map[uint][]uint{}},
+		{"不在任何分组", nil, map[uint][]uint{}},
+	}
+	for _, tt := range tests {
+		if got := service.ResolveGroupRoles(graph, tt.groups, groupRoles); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ResolveGroupRoles(%v) = %v，期望 %v", tt.name, tt.groups, got, tt.want)
+		}
+	}
+}
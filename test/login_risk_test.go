@@ -0,0 +1,227 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"auth-center/models"
+	"auth-center/service"
+	"auth-center/utils"
+)
+
+// mmdbValue 按 MaxMind DB 格式编码测试数据（仅支持字符串、double、uint32 和映射）
+func mmdbValue(buf *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case string:
+		buf.WriteByte(2<<5 | byte(len(v)))
+		buf.WriteString(v)
+	case float64:
+		buf.WriteByte(3<<5 | 8)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+	case uint32:
+		buf.WriteByte(6<<5 | 4)
+		binary.Write(buf, binary.BigEndian, v)
+	case map[string]interface{}:
+		buf.WriteByte(7<<5 | byte(len(v)))
+		for key, value := range v {
+			mmdbValue(buf, key)
+			mmdbValue(buf, value)
+		}
+	}
+}
+
+// newTestGeoIP 构造只包含 1.0.0.0/8 一条记录的 IPv4 数据库（记录长度 24）
+func newTestGeoIP(t *testing.T) *utils.GeoIPReader {
+	const nodeCount = 8
+	const dataRecord = nodeCount + 16
+
+	var db bytes.Buffer
+	// 1 的二进制为 00000001：前 7 位走左子树，第 8 位走右子树指向数据，其余分支指向“未找到”
+	for node := 0; node < nodeCount; node++ {
+		left, right := node+1, nodeCount
+		if node == nodeCount-1 {
+			left, right = nodeCount, dataRecord
+		}
+		db.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left), byte(right >> 16), byte(right >> 8), byte(right)})
+	}
+	db.Write(make([]byte, 16))
+	mmdbValue(&db, map[string]interface{}{
+		"country":  map[string]interface{}{"iso_code": "CN"},
+		"city":     map[string]interface{}{"names": map[string]interface{}{"en": "Beijing"}},
+		"location": map[string]interface{}{"latitude": 39.9042, "longitude": 116.4074},
+	})
+	db.WriteString("\xAB\xCD\xEFMaxMind.com")
+	mmdbValue(&db, map[string]interface{}{
+		"node_count":  uint32(nodeCount),
+		"record_size": uint32(24),
+		"ip_version":  uint32(4),
+	})
+
+	reader, err := utils.NewGeoIPReader(db.Bytes())
+	if err != nil {
+		t.Fatalf("解析 GeoIP 数据库失败: %v", err)
+	}
+	return reader
+}
+
+func TestGeoIPLookup(t *testing.T) {
+	reader := newTestGeoIP(t)
+
+	loc, err := reader.Lookup(net.ParseIP("1.2.3.4"))
+	if err != nil || loc == nil {
+		t.Fatalf("应命中 1.0.0.0/8: loc=%v, err=%v", loc, err)
+	}
+	if loc.Country != "CN" || loc.City != "Beijing" || loc.Latitude != 39.9042 || loc.Longitude != 116.4074 {
+		t.Errorf("位置解析错误: %+v", loc)
+	}
+
+	if loc, err := reader.Lookup(net.ParseIP("8.8.8.8")); err != nil || loc != nil {
+		t.Errorf("未收录的地址应返回 nil: loc=%v, err=%v", loc, err)
+	}
+}
+
+func TestGeoDistance(t *testing.T) {
+	// 北京到纽约约 11000 公里
+	distance := utils.GeoDistanceKm(39.9042, 116.4074, 40.7128, -74.0060)
+	if distance < 10900 || distance > 11100 {
+		t.Errorf("北京到纽约距离计算错误: %.0f", distance)
+	}
+	if utils.GeoDistanceKm(39.9, 116.4, 39.9, 116.4) != 0 {
+		t.Error("相同位置距离应为 0")
+	}
+}
+
+func TestEvaluateLoginRisk(t *testing.T) {
+	now := time.Now()
+	beijing := &models.LoginHistory{Latitude: 39.9042, Longitude: 116.4074, CreatedAt: now.Add(-time.Hour)}
+	newYork := &models.LoginHistory{Latitude: 40.7128, Longitude: -74.0060, CreatedAt: now}
+
+	policy := service.DefaultLoginRiskPolicy("app")
+	policy.Enabled = true
+
+	tests := []struct {
+		name       string
+		rc         *service.LoginRiskContext
+		policy     func(p *models.LoginRiskPolicy)
+		wantFlags  []string
+		wantAction string
+	}{
+		{
+			name:       "首次登录",
+			rc:         &service.LoginRiskContext{Current: newYork},
+			wantAction: service.LoginRiskActionNone,
+		},
+		{
+			name:       "已知设备、位置未变化",
+			rc:         &service.LoginRiskContext{Current: newYork, LastSuccess: &models.LoginHistory{Latitude: 40.7128, Longitude: -74.0060, CreatedAt: now.Add(-time.Hour)}, KnownDevice: true},
+			wantAction: service.LoginRiskActionNone,
+		},
+		{
+			name:       "新设备",
+			rc:         &service.LoginRiskContext{Current: &models.LoginHistory{CreatedAt: now}, LastSuccess: beijing},
+			wantFlags:  []string{service.LoginRiskNewDevice},
+			wantAction: service.LoginRiskActionNotify,
+		},
+		{
+			name:       "一小时内从北京到纽约",
+			rc:         &service.LoginRiskContext{Current: newYork, LastSuccess: beijing, KnownDevice: true},
+			wantFlags:  []string{service.LoginRiskImpossibleTravel},
+			wantAction: service.LoginRiskActionMFA,
+		},
+		{
+			name: "一天后从北京到纽约",
+			rc: &service.LoginRiskContext{Current: newYork, KnownDevice: true,
+				LastSuccess: &models.LoginHistory{Latitude: 39.9042, Longitude: 116.4074, CreatedAt: now.Add(-24 * time.Hour)}},
+			wantAction: service.LoginRiskActionNone,
+		},
+		{
+			name:       "失败次数达到阈值，按最严重的处置",
+			rc:         &service.LoginRiskContext{Current: newYork, LastSuccess: beijing, RecentFailures: 5},
+			policy:     func(p *models.LoginRiskPolicy) { p.FailureBurstAction = service.LoginRiskActionRevoke },
+			wantFlags:  []string{service.LoginRiskNewDevice, service.LoginRiskImpossibleTravel, service.LoginRiskFailureBurst},
+			wantAction: service.LoginRiskActionRevoke,
+		},
+		{
+			name:       "策略未启用只记录不处置",
+			rc:         &service.LoginRiskContext{Current: newYork, LastSuccess: beijing},
+			policy:     func(p *models.LoginRiskPolicy) { p.Enabled = false },
+			wantFlags:  []string{service.LoginRiskNewDevice, service.LoginRiskImpossibleTravel},
+			wantAction: service.LoginRiskActionNone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := *policy
+			if tt.policy != nil {
+				tt.policy(&p)
+			}
+			flags, action := service.EvaluateLoginRisk(&p, tt.rc)
+			if !reflect.DeepEqual(flags, tt.wantFlags) || action != tt.wantAction {
+				t.Errorf("flags=%v action=%s，期望 flags=%v action=%s", flags, action, tt.wantFlags, tt.wantAction)
+			}
+		})
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{"https://8.8.8.8/hooks/login", true},
+		{"http://8.8.8.8/hooks/login", false},
+		{"ftp://8.8.8.8/", false},
+		{"https:///no-host", false},
+		{"https://127.0.0.1/", false},
+		{"https://localhost:8443/", false},
+		{"https://10.0.0.8/", false},
+		{"https://192.168.1.1/", false},
+		{"https://169.254.169.254/latest/meta-data/", false},
+		{"https://100.100.100.200/latest/meta-data/", false},
+		{"https://0.0.0.0/", false},
+		{"https://[::1]/", false},
+		{"https://[fe80::1]/", false},
+		{"https://[fd00::1]/", false},
+		{"https://[::ffff:127.0.0.1]/", false},
+	}
+	for _, tt := range tests {
+		if err := utils.ValidateWebhookURL(tt.url); (err == nil) != tt.valid {
+			t.Errorf("ValidateWebhookURL(%q) = %v，期望有效 %v", tt.url, err, tt.valid)
+		}
+	}
+}
+
+func TestWebhookClientRejectsPrivateAddress(t *testing.T) {
+	var received atomic.Bool
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Store(true)
+	}))
+	defer server.Close()
+
+	// 地址在保存后被解析到本机（DNS 重绑定）时，连接阶段仍会拒绝
+	err := utils.PostWebhook(utils.NewWebhookClient(time.Second), server.URL, []byte(`{}`))
+	if !errors.Is(err, utils.ErrWebhookAddress) {
+		t.Errorf("连接本机地址期望 ErrWebhookAddress，实际 %v", err)
+	}
+	if received.Load() {
+		t.Error("不应向本机地址发送回调")
+	}
+
+	// 同一服务使用不限制地址的客户端可以送达，说明拒绝来自地址校验
+	if err := utils.PostWebhook(server.Client(), server.URL, []byte(`{}`)); err != nil || !received.Load() {
+		t.Errorf("期望送达测试服务，实际 %v", err)
+	}
+	if err := utils.PostWebhook(server.Client(), "http"+server.URL[len("https"):], []byte(`{}`)); !errors.Is(err, utils.ErrWebhookAddress) {
+		t.Errorf("非 https 地址期望 ErrWebhookAddress，实际 %v", err)
+	}
+}
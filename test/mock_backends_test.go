@@ -0,0 +1,42 @@
+package test
+
+import (
+	"testing"
+
+	"auth-center/config"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newMockBackends 使用内存 Redis 和模拟的数据库（go-sqlmock）替换全局连接
+func newMockBackends(t *testing.T) (*miniredis.Miniredis, sqlmock.Sqlmock) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	originalRedis, originalDB := config.RedisClient, config.DB
+	config.RedisClient, config.DB = client, db
+	t.Cleanup(func() {
+		config.RedisClient, config.DB = originalRedis, originalDB
+		client.Close()
+		sqlDB.Close()
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("数据库查询与预期不符: %v", err)
+		}
+	})
+	return mr, mock
+}
@@ -0,0 +1,105 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"auth-center/config"
+	"auth-center/service"
+	"auth-center/utils"
+)
+
+// newTestCertificate 生成自签名测试证书
+func newTestCertificate(t *testing.T, commonName string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("生成密钥失败: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"auth-center"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("生成证书失败: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("解析证书失败: %v", err)
+	}
+	return cert
+}
+
+func TestCertificateBoundToken(t *testing.T) {
+	original := config.GlobalConfig
+	defer func() { config.GlobalConfig = original }()
+	config.GlobalConfig = &config.Config{JWT: config.JWTConfig{SecretKey: "test-secret", TTL: 3600}}
+
+	cert := newTestCertificate(t, "backend-a")
+	other := newTestCertificate(t, "backend-b")
+
+	// 指纹为 DER 的 SHA-256（base64url 无填充）
+	sum := sha256.Sum256(cert.Raw)
+	if got := utils.CertThumbprint(cert); got != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		t.Errorf("证书指纹错误: %s", got)
+	}
+
+	// PEM 解析后指纹一致
+	parsed, err := utils.ParseCertificatePEM(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})))
+	if err != nil || utils.CertThumbprint(parsed) != utils.CertThumbprint(cert) {
+		t.Errorf("PEM 证书解析失败: %v", err)
+	}
+
+	// 从 TLS 连接读取客户端证书
+	req := httptest.NewRequest("GET", "/api/v1/auth/user", nil)
+	if utils.ClientCertFromRequest(req) != nil {
+		t.Error("非 TLS 请求不应返回客户端证书")
+	}
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	clientCert := utils.ClientCertFromRequest(req)
+	if clientCert == nil || clientCert.Thumbprint != utils.CertThumbprint(cert) || clientCert.Verified {
+		t.Fatalf("客户端证书信息错误: %+v", clientCert)
+	}
+
+	// 签发证书绑定令牌
+	token, err := utils.GenerateBoundAccessToken(1, "test-app", []uint{1}, service.CertificateConfirmation(clientCert))
+	if err != nil {
+		t.Fatalf("生成令牌失败: %v", err)
+	}
+	claims, err := utils.ParseAccessToken(token)
+	if err != nil {
+		t.Fatalf("解析令牌失败: %v", err)
+	}
+	if claims.Cnf == nil || claims.Cnf.X5tS256 != clientCert.Thumbprint {
+		t.Fatalf("令牌缺少 cnf.x5t#S256: %+v", claims.Cnf)
+	}
+
+	otherReq := httptest.NewRequest("GET", "/api/v1/auth/user", nil)
+	otherReq.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{other}}
+
+	if !service.ConfirmationMatches(claims.Cnf, clientCert) {
+		t.Error("相同证书应该通过校验")
+	}
+	if service.ConfirmationMatches(claims.Cnf, utils.ClientCertFromRequest(otherReq)) {
+		t.Error("不同证书不应通过校验")
+	}
+	if service.ConfirmationMatches(claims.Cnf, nil) {
+		t.Error("未出示证书不应通过校验")
+	}
+	if !service.ConfirmationMatches(nil, nil) {
+		t.Error("未绑定的令牌应该始终通过校验")
+	}
+}
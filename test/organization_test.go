@@ -0,0 +1,64 @@
+package test
+
+import (
+	"testing"
+
+	"auth-center/config"
+	"auth-center/utils"
+)
+
+func TestOrganizationToken(t *testing.T) {
+	original := config.GlobalConfig
+	defer func() { config.GlobalConfig = original }()
+	config.GlobalConfig = &config.Config{JWT: config.JWTConfig{SecretKey: "test-secret", RefreshSecretKey: "test-refresh-secret", TTL: 3600, RefreshTTL: 7200}}
+
+	// 访问令牌带有当前组织
+	token, err := utils.GenerateOrgAccessToken(1, "test-app", 3, []uint{2}, nil)
+	if err != nil {
+		t.Fatalf("生成令牌失败: %v", err)
+	}
+	claims, err := utils.ParseAccessToken(token)
+	if err != nil {
+		t.Fatalf("解析令牌失败: %v", err)
+	}
+	if claims.OrgID != 3 || claims.UserID != 1 || claims.AppID != "test-app" {
+		t.Errorf("令牌声明错误: %+v", claims)
+	}
+
+	// 刷新令牌保持当前组织和绑定信息
+	cnf := &utils.Confirmation{Jkt: "thumbprint"}
+	refreshToken, err := utils.GenerateOrgRefreshToken(1, "test-app", 3, cnf)
+	if err != nil {
+		t.Fatalf("生成刷新令牌失败: %v", err)
+	}
+	claims, err = utils.ParseRefreshToken(refreshToken)
+	if err != nil {
+		t.Fatalf("解析刷新令牌失败: %v", err)
+	}
+	if claims.OrgID != 3 || claims.Cnf == nil || claims.Cnf.Jkt != "thumbprint" {
+		t.Errorf("刷新令牌声明错误: %+v", claims)
+	}
+
+	// 切换组织时替换的刷新令牌沿用原过期时间，不延长会话
+	replaced, err := utils.GenerateOrgRefreshTokenUntil(1, "test-app", 4, cnf, claims.ExpiresAt.Time)
+	if err != nil {
+		t.Fatalf("生成刷新令牌失败: %v", err)
+	}
+	replacedClaims, err := utils.ParseRefreshToken(replaced)
+	if err != nil {
+		t.Fatalf("解析刷新令牌失败: %v", err)
+	}
+	if replacedClaims.OrgID != 4 || !replacedClaims.ExpiresAt.Equal(claims.ExpiresAt.Time) || replacedClaims.JTI == claims.JTI {
+		t.Errorf("替换的刷新令牌声明错误: %+v", replacedClaims)
+	}
+
+	// 不在组织内的令牌不带 org_id
+	token, err = utils.GenerateBoundAccessToken(1, "test-app", []uint{2}, nil)
+	if err != nil {
+		t.Fatalf("生成令牌失败: %v", err)
+	}
+	claims, err = utils.ParseAccessToken(token)
+	if err != nil || claims.OrgID != 0 {
+		t.Errorf("普通令牌不应带有组织: %+v, %v", claims, err)
+	}
+}
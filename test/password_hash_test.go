@@ -0,0 +1,158 @@
+package test
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"auth-center/config"
+	"auth-center/utils"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+func TestPasswordHashUpgrade(t *testing.T) {
+	password := "Correct-Horse-42"
+
+	original := config.GlobalConfig
+	defer func() { config.GlobalConfig = original }()
+
+	// bcrypt cost 10 -> 12
+	config.GlobalConfig = &config.Config{Password: config.PasswordHashConfig{Algorithm: "bcrypt", BcryptCost: 10}}
+	bcryptHash, err := utils.HashPassword(password)
+	if err != nil {
+		t.Fatalf("密码哈希失败: %v", err)
+	}
+	if utils.PasswordNeedsRehash(bcryptHash) {
+		t.Error("参数一致的哈希不应需要升级")
+	}
+	config.GlobalConfig.Password.BcryptCost = 12
+	if !utils.PasswordNeedsRehash(bcryptHash) {
+		t.Error("bcrypt 成本变化后应需要升级")
+	}
+
+	// bcrypt -> argon2id
+	config.GlobalConfig.Password = config.PasswordHashConfig{
+		Algorithm:         "argon2id",
+		Argon2Memory:      8 * 1024,
+		Argon2Iterations:  1,
+		Argon2Parallelism: 1,
+	}
+	if !utils.PasswordNeedsRehash(bcryptHash) {
+		t.Error("算法变化后应需要升级")
+	}
+	if ok, err := utils.VerifyPassword(password, bcryptHash); err != nil || !ok {
+		t.Error("切换算法后旧的 bcrypt 哈希仍应可以验证")
+	}
+
+	argonHash, err := utils.HashPassword(password)
+	if err != nil {
+		t.Fatalf("argon2id 哈希失败: %v", err)
+	}
+	if !strings.HasPrefix(argonHash, "$argon2id$v=19$m=8192,t=1,p=1$") {
+		t.Errorf("argon2id 编码格式错误: %s", argonHash)
+	}
+	if ok, _ := utils.VerifyPassword(password, argonHash); !ok {
+		t.Error("argon2id 密码验证失败")
+	}
+	if ok, _ := utils.VerifyPassword("wrong-password", argonHash); ok {
+		t.Error("错误密码应该验证失败")
+	}
+	if utils.PasswordNeedsRehash(argonHash) {
+		t.Error("参数一致的哈希不应需要升级")
+	}
+	config.GlobalConfig.Password.Argon2Iterations = 2
+	if !utils.PasswordNeedsRehash(argonHash) {
+		t.Error("argon2id 参数变化后应需要升级")
+	}
+}
+
+func TestLegacyPasswordHash(t *testing.T) {
+	password := "legacy-secret"
+
+	t.Run("PBKDF2", func(t *testing.T) {
+		salt := []byte("0123456789abcdef")
+		key := pbkdf2.Key([]byte(password), salt, 1000, 32, sha256.New)
+
+		encoded, err := utils.EncodeLegacyHash(&utils.LegacyHash{
+			Format:       "pbkdf2-sha256",
+			Hash:         hex.EncodeToString(key),
+			Salt:         hex.EncodeToString(salt),
+			SaltEncoding: "hex",
+			Iterations:   1000,
+		})
+		if err != nil {
+			t.Fatalf("转换旧式哈希失败: %v", err)
+		}
+		if ok, err := utils.VerifyPassword(password, encoded); err != nil || !ok {
+			t.Errorf("PBKDF2 密码验证失败: %v", err)
+		}
+		if ok, _ := utils.VerifyPassword("wrong-password", encoded); ok {
+			t.Error("错误密码应该验证失败")
+		}
+		if !utils.PasswordNeedsRehash(encoded) {
+			t.Error("旧式哈希应需要升级")
+		}
+	})
+
+	t.Run("加盐SHA", func(t *testing.T) {
+		salt := "s@lt"
+		digest := sha1.Sum([]byte(password + salt))
+
+		encoded, err := utils.EncodeLegacyHash(&utils.LegacyHash{
+			Format:       "salted-sha1",
+			Hash:         hex.EncodeToString(digest[:]),
+			Salt:         salt,
+			SaltPosition: "suffix",
+		})
+		if err != nil {
+			t.Fatalf("转换旧式哈希失败: %v", err)
+		}
+		if ok, err := utils.VerifyPassword(password, encoded); err != nil || !ok {
+			t.Errorf("加盐 SHA 密码验证失败: %v", err)
+		}
+		if ok, _ := utils.VerifyPassword("wrong-password", encoded); ok {
+			t.Error("错误密码应该验证失败")
+		}
+	})
+
+	t.Run("非法参数", func(t *testing.T) {
+		salt := base64.RawStdEncoding.EncodeToString([]byte("0123456789abcdef"))
+		key := base64.RawStdEncoding.EncodeToString(make([]byte, 32))
+		for _, params := range []string{"m=8,t=0,p=0", "m=8,t=1,p=2", "m=65536,t=1,p=0", "m=1073741824,t=1,p=1", "m=65536,t=100000,p=1"} {
+			hash := "$argon2id$v=19$" + params + "$" + salt + "$" + key
+			if _, err := utils.EncodeLegacyHash(&utils.LegacyHash{Format: "argon2id", Hash: hash}); err == nil {
+				t.Errorf("参数 %s 的 argon2id 哈希应拒绝导入", params)
+			}
+			if _, err := utils.VerifyPassword(password, hash); err == nil {
+				t.Errorf("参数 %s 的 argon2id 哈希应验证失败", params)
+			}
+		}
+		if _, err := utils.VerifyPassword(password, "$argon2id$v=19$m=8192,t=1,p=1$"+salt+"$"); err == nil {
+			t.Error("空哈希值应验证失败")
+		}
+
+		if _, err := utils.EncodeLegacyHash(&utils.LegacyHash{
+			Format:     "pbkdf2-sha256",
+			Hash:       hex.EncodeToString(make([]byte, 32)),
+			Salt:       "salt",
+			Iterations: 1 << 30,
+		}); err == nil {
+			t.Error("迭代次数过大的 PBKDF2 哈希应拒绝导入")
+		}
+		if _, err := utils.VerifyPassword(password, "$pbkdf2-sha256$i=1073741824$"+salt+"$"+key); err == nil {
+			t.Error("迭代次数过大的 PBKDF2 哈希应验证失败")
+		}
+	})
+
+	t.Run("不支持的格式", func(t *testing.T) {
+		if _, err := utils.EncodeLegacyHash(&utils.LegacyHash{Format: "md5", Hash: "abc"}); err == nil {
+			t.Error("不支持的格式应返回错误")
+		}
+		if _, err := utils.VerifyPassword(password, "plain-text"); err == nil {
+			t.Error("无法识别的哈希应返回错误")
+		}
+	})
+}
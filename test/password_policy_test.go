@@ -0,0 +1,91 @@
+package test
+
+import (
+	"testing"
+
+	"auth-center/models"
+	"auth-center/service"
+)
+
+func TestValidatePassword(t *testing.T) {
+	policy := &models.PasswordPolicy{
+		MinLength:      8,
+		MaxLength:      64,
+		RequireUpper:   true,
+		RequireDigit:   true,
+		ForbidUsername: true,
+	}
+
+	codes := func(violations []service.PasswordViolation) map[string]bool {
+		result := make(map[string]bool)
+		for _, v := range violations {
+			result[v.Code] = true
+		}
+		return result
+	}
+
+	t.Run("符合策略", func(t *testing.T) {
+		if violations := service.ValidatePassword(policy, "alice", "Secur3Pass"); len(violations) != 0 {
+			t.Errorf("期望无违规项，实际得到 %v", violations)
+		}
+	})
+
+	t.Run("长度与字符类别不足", func(t *testing.T) {
+		got := codes(service.ValidatePassword(policy, "alice", "abc"))
+		for _, code := range []string{"min_length", "require_upper", "require_digit"} {
+			if !got[code] {
+				t.Errorf("期望包含违规项 %s，实际得到 %v", code, got)
+			}
+		}
+	})
+
+	t.Run("包含用户名", func(t *testing.T) {
+		got := codes(service.ValidatePassword(policy, "alice", "Alice2024xyz"))
+		if !got["contains_username"] {
+			t.Errorf("期望包含违规项 contains_username，实际得到 %v", got)
+		}
+	})
+
+	t.Run("最少字符类别", func(t *testing.T) {
+		classPolicy := &models.PasswordPolicy{MinLength: 1, MinCharClasses: 3}
+		if got := codes(service.ValidatePassword(classPolicy, "", "abcDEF")); !got["min_char_classes"] {
+			t.Errorf("期望包含违规项 min_char_classes，实际得到 %v", got)
+		}
+		if violations := service.ValidatePassword(classPolicy, "", "abcDEF!"); len(violations) != 0 {
+			t.Errorf("期望无违规项，实际得到 %v", violations)
+		}
+	})
+}
+
+func TestDefaultPasswordPolicy(t *testing.T) {
+	policy := service.DefaultPasswordPolicy("app")
+	// 默认策略只限制长度，未配置策略的应用行为不变
+	if violations := service.ValidatePassword(policy, "alice", "alice123"); len(violations) != 0 {
+		t.Errorf("默认策略期望无违规项，实际得到 %v", violations)
+	}
+	if policy.MaxAgeDays != 0 || policy.HistoryCount != 0 {
+		t.Errorf("默认策略不应限制密码有效期和历史，实际得到 %+v", policy)
+	}
+}
+
+func TestAdminPolicyAppID(t *testing.T) {
+	tests := []struct {
+		adminType string
+		appID     string
+		want      string
+	}{
+		{"app", "app_1", "app_1"},
+		{"system", "", service.SystemAdminPolicyAppID},
+		{"system", "system-admin", service.SystemAdminPolicyAppID},
+		{"app", "", service.SystemAdminPolicyAppID},
+	}
+	for _, tt := range tests {
+		if got := service.AdminPolicyAppID(tt.adminType, tt.appID); got != tt.want {
+			t.Errorf("AdminPolicyAppID(%q, %q) = %q，期望 %q", tt.adminType, tt.appID, got, tt.want)
+		}
+	}
+	// 系统级管理员策略不能与任何应用的策略冲突
+	if service.SystemAdminPolicyAppID != "" {
+		t.Errorf("系统级管理员策略的 app_id 应为空，实际为 %q", service.SystemAdminPolicyAppID)
+	}
+}
@@ -0,0 +1,72 @@
+package test
+
+import (
+	"testing"
+
+	"auth-center/models"
+	"auth-center/service"
+	"auth-center/utils"
+)
+
+func TestEffectivePermissions(t *testing.T) {
+	allow := func(permissionID, roleID uint) service.PermissionGrant {
+		return service.PermissionGrant{PermissionID: permissionID, Effect: service.PermissionEffectAllow, Source: service.PermissionSourceRole, RoleID: roleID}
+	}
+	deny := func(permissionID uint) service.PermissionGrant {
+		return service.PermissionGrant{PermissionID: permissionID, Effect: service.PermissionEffectDeny, Source: service.PermissionSourceUser}
+	}
+
+	grants := map[uint][]service.PermissionGrant{
+		1: {allow(1, 1)},
+		2: {allow(2, 1), deny(2)},
+		3: {allow(3, 2)},
+		4: {{PermissionID: 4, Effect: service.PermissionEffectAllow, Source: service.PermissionSourceRole, RoleID: 1, Condition: "x"}},
+	}
+	permissions := []models.Permission{
+		{ID: 1, Code: "order:read", Status: 1},
+		{ID: 2, Code: "order:delete", Status: 1},
+		{ID: 3, Code: "order:export", Status: 0},
+		{ID: 4, Code: "order:approve", Status: 1},
+		{ID: 5, Code: "order:edit", Status: 1},
+	}
+	effective := service.NewEffectivePermissions(grants, permissions)
+
+	for code, want := range map[string]bool{
+		"order:read":    true,
+		"order:delete":  false, // 明确拒绝
+		"order:export":  false, // 已停用
+		"order:approve": false, // 条件未求值
+		"order:edit":    false, // 未授予
+		"order:unknown": false, // 不存在
+	} {
+		if got := effective.HasPermission(code); got != want {
+			t.Errorf("HasPermission(%s) = %v，期望 %v", code, got, want)
+		}
+	}
+
+	rule := func(permissionID uint) *utils.RouteRule {
+		return &utils.RouteRule{Path: "/api/orders/:id", Method: "GET", PermissionID: permissionID}
+	}
+	tests := []struct {
+		name  string
+		rules []*utils.RouteRule
+		want  bool
+	}{
+		{"没有匹配的规则", nil, false},
+		{"权限已授予", []*utils.RouteRule{rule(1)}, true},
+		{"任一权限被授予", []*utils.RouteRule{rule(5), rule(1)}, true},
+		{"任一权限被拒绝", []*utils.RouteRule{rule(1), rule(2)}, false},
+		{"权限已停用", []*utils.RouteRule{rule(3)}, false},
+	}
+	for _, tt := range tests {
+		if got := effective.AllowsAPI(tt.rules); got != tt.want {
+			t.Errorf("%s: AllowsAPI = %v，期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAPIPermissionKey(t *testing.T) {
+	if key := service.APIPermissionKey("/api/orders/42", " delete "); key != "DELETE /api/orders/42" {
+		t.Errorf("APIPermissionKey = %q", key)
+	}
+}
@@ -0,0 +1,206 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"auth-center/models"
+	"auth-center/service"
+	"auth-center/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// permissionVersionValues Redis 中全部权限版本号
+func permissionVersionValues(mr *miniredis.Miniredis) map[string]string {
+	values := map[string]string{}
+	for _, key := range mr.Keys() {
+		if value, err := mr.Get(key); err == nil {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+func TestPermissionCacheInvalidation(t *testing.T) {
+	const roleID = 3
+	tests := []struct {
+		name       string
+		invalidate func(appID string)
+		expect     func(mock sqlmock.Sqlmock, appID string)
+		want       func(appID string) []string // 递增为 1 的版本号键（不含前缀和应用ID）
+	}{
+		{
+			name:       "应用",
+			invalidate: func(appID string) { (&service.PermissionCacheService{}).InvalidateApp(appID) },
+			want:       func(appID string) []string { return []string{""} },
+		},
+		{
+			name:       "用户",
+			invalidate: func(appID string) { (&service.PermissionCacheService{}).InvalidateUser(appID, 7) },
+			want:       func(appID string) []string { return []string{":user:7"} },
+		},
+		{
+			name:       "角色经继承、组织和嵌套分组影响的用户",
+			invalidate: func(appID string) { (&service.PermissionCacheService{}).InvalidateRole(appID, roleID) },
+			expect: func(mock sqlmock.Sqlmock, appID string) {
+				// 角色 5 继承角色 3
+				mock.ExpectQuery("SELECT \\* FROM `role_inheritances`").WithArgs(appID).
+					WillReturnRows(sqlmock.NewRows([]string{"role_id", "parent_role_id"}).AddRow(5, roleID))
+				mock.ExpectQuery("SELECT DISTINCT `user_id` FROM `user_roles`").WithArgs(appID, 5, roleID).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1).AddRow(2))
+				mock.ExpectQuery("SELECT DISTINCT `user_id` FROM `organization_member_roles`").WithArgs(appID, 5, roleID).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(2).AddRow(4))
+				mock.ExpectQuery("SELECT DISTINCT `group_id` FROM `group_roles`").WithArgs(appID, 5, roleID).
+					WillReturnRows(sqlmock.NewRows([]string{"group_id"}).AddRow(10))
+				// 分组 11 是分组 10 的下级分组
+				mock.ExpectQuery("SELECT \\* FROM `group_inheritances`").WithArgs(appID).
+					WillReturnRows(sqlmock.NewRows([]string{"group_id", "parent_group_id"}).AddRow(11, 10))
+				mock.ExpectQuery("SELECT DISTINCT `user_id` FROM `group_members`").WithArgs(appID, 10, 11).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(6))
+			},
+			want: func(appID string) []string {
+				return []string{":role:3", ":user:1", ":user:2", ":user:4", ":user:6"}
+			},
+		},
+		{
+			name:       "查询角色的用户失败时整个应用失效",
+			invalidate: func(appID string) { (&service.PermissionCacheService{}).InvalidateRole(appID, roleID) },
+			expect: func(mock sqlmock.Sqlmock, appID string) {
+				mock.ExpectQuery("SELECT \\* FROM `role_inheritances`").WithArgs(appID).WillReturnError(errors.New("连接已断开"))
+			},
+			want: func(appID string) []string { return []string{"", ":role:3"} },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr, mock := newMockBackends(t)
+			appID := "test-app-" + utils.GenerateShortCode(8)
+			if tt.expect != nil {
+				tt.expect(mock, appID)
+			}
+
+			tt.invalidate(appID)
+
+			want := map[string]string{}
+			for _, suffix := range tt.want(appID) {
+				want[utils.PermissionVersionPrefix+appID+suffix] = "1"
+			}
+			if got := permissionVersionValues(mr); !reflect.DeepEqual(got, want) {
+				t.Errorf("版本号 = %v，期望 %v", got, want)
+			}
+			// 递增后的版本号在本副本立即生效
+			for key := range want {
+				if value, ok := service.LocalPermissionVersion(key); !ok || value != "1" {
+					t.Errorf("本副本缓存的 %s = %q, %v，期望 1", key, value, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestRemotePermissionInvalidation(t *testing.T) {
+	mr, mock := newMockBackends(t)
+	appID := "test-app-" + utils.GenerateShortCode(8)
+	userKey := utils.PermissionVersionPrefix + appID + ":user:7"
+	sentinelKey := utils.PermissionVersionPrefix + appID + ":user:8"
+
+	service.StartPermissionCacheSubscriber()
+	deadline := time.Now().Add(2 * time.Second)
+	for mr.PubSubNumSub(utils.PermissionInvalidateChannel)[utils.PermissionInvalidateChannel] == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("未订阅权限缓存失效通知")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// cacheAPIMatcher 从数据库构建并缓存 API 规则匹配器
+	cacheAPIMatcher := func() {
+		mock.ExpectQuery("SELECT `id` FROM `permissions`").WithArgs(appID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT \\* FROM `apis`").WithArgs(appID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		if _, err := (&service.PermissionService{}).CheckAPIPermission(7, appID, "/unmatched", "GET"); err != nil {
+			t.Fatal(err)
+		}
+		if !service.APIMatcherCached(appID) {
+			t.Fatal("API 规则匹配器应已缓存")
+		}
+	}
+	// receive 模拟其他副本递增版本号并发出通知，随后发出一条使 sentinelKey 失效的通知，收到它即表示前一条已处理
+	receive := func(invalidation service.PermissionInvalidation) {
+		(&service.PermissionCacheService{}).InvalidateUser(appID, 8)
+		for _, key := range invalidation.Keys {
+			mr.Incr(key, 1)
+		}
+		invalidation.AppID, invalidation.Origin = appID, "other-replica"
+		sentinel := service.PermissionInvalidation{AppID: appID, Scope: service.PermissionScopeUser, ID: 8, Keys: []string{sentinelKey}, Origin: "other-replica"}
+		for _, message := range []service.PermissionInvalidation{invalidation, sentinel} {
+			payload, _ := json.Marshal(message)
+			if err := utils.Publish(utils.PermissionInvalidateChannel, payload); err != nil {
+				t.Fatal(err)
+			}
+		}
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			if _, cached := service.LocalPermissionVersion(sentinelKey); !cached {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("未收到失效通知")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		invalidation  service.PermissionInvalidation
+		evictVersion  bool
+		evictMatchers bool
+	}{
+		{"用户", service.PermissionInvalidation{Scope: service.PermissionScopeUser, ID: 7, Keys: []string{userKey}}, true, false},
+		{"其他用户", service.PermissionInvalidation{Scope: service.PermissionScopeUser, ID: 9, Keys: []string{utils.PermissionVersionPrefix + appID + ":user:9"}}, false, false},
+		{"API 规则", service.PermissionInvalidation{Scope: service.PermissionScopeAPI}, false, true},
+		{"应用", service.PermissionInvalidation{Scope: service.PermissionScopeApp, Keys: []string{utils.PermissionVersionPrefix + appID}}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			(&service.PermissionCacheService{}).InvalidateUser(appID, 7)
+			if !service.APIMatcherCached(appID) {
+				cacheAPIMatcher()
+			}
+
+			receive(tt.invalidation)
+
+			if _, cached := service.LocalPermissionVersion(userKey); cached == tt.evictVersion {
+				t.Errorf("用户版本号仍缓存 = %v，期望 %v", cached, !tt.evictVersion)
+			}
+			if cached := service.APIMatcherCached(appID); cached == tt.evictMatchers {
+				t.Errorf("API 规则匹配器仍缓存 = %v，期望 %v", cached, !tt.evictMatchers)
+			}
+		})
+	}
+}
+
+func TestDeleteUserRollsBackOnCleanupFailure(t *testing.T) {
+	mr, mock := newMockBackends(t)
+	appID := "test-app-" + utils.GenerateShortCode(8)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("`users`").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM `user_roles`").WillReturnError(errors.New("连接已断开"))
+	mock.ExpectRollback()
+
+	err := (&service.PermissionService{}).DeleteUser(&models.User{ID: 7, AppID: appID})
+	if err == nil {
+		t.Fatal("清理分配失败时应返回错误")
+	}
+	// 删除用户和清理分配一起回滚，也不递增版本号
+	if got := permissionVersionValues(mr); len(got) != 0 {
+		t.Errorf("回滚后不应递增版本号: %v", got)
+	}
+}
@@ -0,0 +1,92 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"auth-center/service"
+)
+
+func TestEvaluatePermissionCondition(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	workday := time.Date(2024, 6, 3, 10, 30, 0, 0, shanghai) // 周一
+	night := time.Date(2024, 6, 3, 22, 0, 0, 0, shanghai)
+
+	vars := func(ip string, at time.Time, resource map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"user":     map[string]interface{}{"id": int64(7), "username": "alice", "roles": []string{"editor"}},
+			"request":  map[string]interface{}{"ip": ip, "time": at},
+			"resource": resource,
+		}
+	}
+
+	businessHours := `request.time.getHours("Asia/Shanghai") >= 9 && request.time.getHours("Asia/Shanghai") < 18`
+	tests := []struct {
+		name string
+		expr string
+		vars map[string]interface{}
+		want bool
+	}{
+		{"无条件", "", nil, true},
+		{"工作时间内", businessHours, vars("", workday, nil), true},
+		{"工作时间外", businessHours, vars("", night, nil), false},
+		{"公司网段内", `ip_in_cidr(request.ip, "10.0.0.0/8")`, vars("10.1.2.3", workday, nil), true},
+		{"公司网段外", `ip_in_cidr(request.ip, "10.0.0.0/8")`, vars("8.8.8.8", workday, nil), false},
+		{"无效IP", `ip_in_cidr(request.ip, "10.0.0.0/8")`, vars("", workday, nil), false},
+		{"资源所有者（JSON 数字）", `resource.owner_id == user.id`, vars("", workday, map[string]interface{}{"owner_id": float64(7)}), true},
+		{"非资源所有者", `resource.owner_id == user.id`, vars("", workday, map[string]interface{}{"owner_id": float64(8)}), false},
+		{"角色代码", `"editor" in user.roles && resource.amount < 1000`, vars("", workday, map[string]interface{}{"amount": float64(500)}), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := service.EvaluatePermissionCondition(tt.expr, tt.vars)
+			if err != nil {
+				t.Fatalf("求值失败: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("%s = %v，期望 %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+
+	// 缺少的资源属性求值出错，由调用方按拒绝处理
+	if _, err := service.EvaluatePermissionCondition(`resource.owner_id == user.id`, vars("", workday, nil)); err == nil {
+		t.Error("缺少资源属性时应返回错误")
+	}
+}
+
+func TestValidatePermissionCondition(t *testing.T) {
+	valid := []string{"", `ip_in_cidr(request.ip, "192.168.0.0/16")`, `resource.owner_id == user.id`}
+	for _, expr := range valid {
+		if err := service.ValidatePermissionCondition(expr); err != nil {
+			t.Errorf("%s 应为有效条件: %v", expr, err)
+		}
+	}
+
+	invalid := []string{`request.ip +`, `"not a bool"`, `unknown.field == 1`, `ip_in_cidr(1, 2)`}
+	for _, expr := range invalid {
+		if err := service.ValidatePermissionCondition(expr); err == nil {
+			t.Errorf("%s 应为无效条件", expr)
+		}
+	}
+}
+
+func TestConditionalGrantApplies(t *testing.T) {
+	met, notMet := true, false
+	allow := service.PermissionGrant{PermissionID: 1, Effect: service.PermissionEffectAllow, Source: service.PermissionSourceRole, RoleID: 1, Condition: "x"}
+	deny := service.PermissionGrant{PermissionID: 1, Effect: service.PermissionEffectDeny, Source: service.PermissionSourceRole, RoleID: 2, Condition: "y"}
+
+	// 条件未求值：授予不生效，拒绝生效
+	if decided := service.ResolvePermissionGrants([]service.PermissionGrant{allow}); decided != nil {
+		t.Errorf("未求值的条件授予不应生效: %+v", decided)
+	}
+	if decided := service.ResolvePermissionGrants([]service.PermissionGrant{deny}); decided == nil || decided.Effect != service.PermissionEffectDeny {
+		t.Errorf("未求值的条件拒绝应生效: %+v", decided)
+	}
+
+	// 条件成立的授予生效，条件不成立的拒绝不生效
+	allow.ConditionMet, deny.ConditionMet = &met, &notMet
+	if decided := service.ResolvePermissionGrants([]service.PermissionGrant{allow, deny}); decided == nil || decided.Effect != service.PermissionEffectAllow {
+		t.Errorf("应由条件成立的授予决定: %+v", decided)
+	}
+}
@@ -0,0 +1,39 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"auth-center/service"
+)
+
+func TestResolvePermissionGrants(t *testing.T) {
+	roleAllow := func(roleID uint) service.PermissionGrant {
+		return service.PermissionGrant{PermissionID: 1, Effect: service.PermissionEffectAllow, Source: service.PermissionSourceRole, RoleID: roleID}
+	}
+	roleDeny := func(roleID uint) service.PermissionGrant {
+		return service.PermissionGrant{PermissionID: 1, Effect: service.PermissionEffectDeny, Source: service.PermissionSourceRole, RoleID: roleID}
+	}
+	userAllow := service.PermissionGrant{PermissionID: 1, Effect: service.PermissionEffectAllow, Source: service.PermissionSourceUser}
+	userDeny := service.PermissionGrant{PermissionID: 1, Effect: service.PermissionEffectDeny, Source: service.PermissionSourceUser}
+
+	tests := []struct {
+		name   string
+		grants []service.PermissionGrant
+		want   *service.PermissionGrant
+	}{
+		{"没有规则", nil, nil},
+		{"角色授予", []service.PermissionGrant{roleAllow(2)}, &service.PermissionGrant{PermissionID: 1, Effect: "allow", Source: "role", RoleID: 2}},
+		{"多个角色授予取角色ID最小的", []service.PermissionGrant{roleAllow(5), roleAllow(2)}, &service.PermissionGrant{PermissionID: 1, Effect: "allow", Source: "role", RoleID: 2}},
+		{"直接授予优先于角色授予", []service.PermissionGrant{roleAllow(2), userAllow}, &userAllow},
+		{"角色拒绝优先于授予", []service.PermissionGrant{roleAllow(1), userAllow, roleDeny(3)}, &service.PermissionGrant{PermissionID: 1, Effect: "deny", Source: "role", RoleID: 3}},
+		{"直接拒绝优先于角色拒绝", []service.PermissionGrant{roleDeny(3), userDeny, roleAllow(1)}, &userDeny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := service.ResolvePermissionGrants(tt.grants); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResolvePermissionGrants() = %+v，期望 %+v", got, tt.want)
+			}
+		})
+	}
+}
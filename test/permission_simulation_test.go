@@ -0,0 +1,151 @@
+package test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"auth-center/models"
+	"auth-center/service"
+)
+
+func TestBuildTraceRoles(t *testing.T) {
+	// admin(3) -> editor(2) -> viewer(1)，auditor(4) -> viewer(1)
+	graph := service.RoleGraph{
+		3: {2},
+		2: {1},
+		4: {1},
+	}
+	allRoles := map[uint]models.Role{
+		1: {ID: 1, Code: "viewer", Status: 1},
+		2: {ID: 2, Code: "editor", Status: 1},
+		3: {ID: 3, Code: "admin", Status: 1},
+		4: {ID: 4, Code: "auditor", Status: 0},
+	}
+	// rolesOf 模拟按继承关系展开后从数据库读取的角色（只读到当前应用存在的角色）
+	rolesOf := func(directIDs []uint) []models.Role {
+		var roles []models.Role
+		for _, id := range graph.Ancestors(directIDs...) {
+			if role, ok := allRoles[id]; ok {
+				roles = append(roles, role)
+			}
+		}
+		return roles
+	}
+
+	tests := []struct {
+		name    string
+		sources service.TraceRoleSources
+		want    []service.TraceRole
+		codes   []string
+		err     error
+	}{
+		{
+			name:    "直接分配及继承",
+			sources: service.TraceRoleSources{DirectIDs: []uint{3}},
+			want: []service.TraceRole{
+				{ID: 1, Code: "viewer", Status: 1, InheritedBy: []uint{3}},
+				{ID: 2, Code: "editor", Status: 1, InheritedBy: []uint{3}},
+				{ID: 3, Code: "admin", Status: 1, Assigned: true},
+			},
+			codes: []string{"admin"},
+		},
+		{
+			name:    "多个直接角色继承同一父角色",
+			sources: service.TraceRoleSources{DirectIDs: []uint{4, 2, 2}},
+			want: []service.TraceRole{
+				{ID: 1, Code: "viewer", Status: 1, InheritedBy: []uint{2, 4}},
+				{ID: 2, Code: "editor", Status: 1, Assigned: true},
+				{ID: 4, Code: "auditor", Status: 0, Assigned: true},
+			},
+			codes: []string{"editor", "auditor"},
+		},
+		{
+			name: "通过分组和组织获得",
+			sources: service.TraceRoleSources{
+				DirectIDs:  []uint{1, 2},
+				GroupRoles: map[uint][]uint{2: {5, 6}},
+				OrgRoles:   map[uint]bool{1: true},
+				OrgID:      9,
+			},
+			want: []service.TraceRole{
+				{ID: 1, Code: "viewer", Status: 1, Assigned: true, OrgID: 9},
+				{ID: 2, Code: "editor", Status: 1, Assigned: true, Groups: []uint{5, 6}},
+			},
+			codes: []string{"viewer", "editor"},
+		},
+		{
+			name:    "假设的角色",
+			sources: service.TraceRoleSources{Hypothetical: true, DirectIDs: []uint{2, 4}},
+			want: []service.TraceRole{
+				{ID: 1, Code: "viewer", Status: 1, InheritedBy: []uint{2, 4}},
+				{ID: 2, Code: "editor", Status: 1, Assigned: true},
+				{ID: 4, Code: "auditor", Status: 0, Assigned: true},
+			},
+			codes: []string{"editor", "auditor"},
+		},
+		{
+			name:    "假设的角色不属于当前应用",
+			sources: service.TraceRoleSources{Hypothetical: true, DirectIDs: []uint{2, 8}},
+			err:     service.ErrSimulationRoleNotFound,
+		},
+		{
+			name:    "实际分配的角色已不存在时忽略",
+			sources: service.TraceRoleSources{DirectIDs: []uint{2, 8}},
+			want: []service.TraceRole{
+				{ID: 1, Code: "viewer", Status: 1, InheritedBy: []uint{2}},
+				{ID: 2, Code: "editor", Status: 1, Assigned: true},
+			},
+			codes: []string{"editor"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roles, codes, err := service.BuildTraceRoles(&tt.sources, graph, rolesOf(tt.sources.DirectIDs))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v，期望 %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if !reflect.DeepEqual(roles, tt.want) {
+				t.Errorf("roles = %+v，期望 %+v", roles, tt.want)
+			}
+			if !reflect.DeepEqual(codes, tt.codes) {
+				t.Errorf("codes = %v，期望 %v", codes, tt.codes)
+			}
+		})
+	}
+}
+
+func TestApplySimulationBlocks(t *testing.T) {
+	grant := &service.PermissionGrant{PermissionID: 2, Effect: service.PermissionEffectAllow, Source: service.PermissionSourceRole, RoleID: 3}
+	allowed := func() *service.PermissionDecision {
+		return &service.PermissionDecision{Allowed: true, Decision: service.PermissionDecisionAllow, DecidedBy: grant}
+	}
+
+	tests := []struct {
+		name     string
+		decision *service.PermissionDecision
+		blocks   []service.TraceBlock
+		allowed  bool
+		want     string
+	}{
+		{"没有阻止", allowed(), nil, true, service.PermissionDecisionAllow},
+		{"停用的权限不改变判定", allowed(), []service.TraceBlock{{Type: "permission", ID: 2}}, true, service.PermissionDecisionAllow},
+		{"用户已禁用", allowed(), []service.TraceBlock{{Type: "user", ID: 5, Reason: "用户已禁用"}}, false, service.PermissionDecisionUserDisabled},
+		{"应用已禁用", allowed(), []service.TraceBlock{{Type: "application", Reason: "应用已禁用"}, {Type: "user", ID: 5}}, false, service.PermissionDecisionAppDisabled},
+		{"拒绝时同样标明禁用", &service.PermissionDecision{Decision: service.PermissionDecisionNotGranted}, []service.TraceBlock{{Type: "user", ID: 5}}, false, service.PermissionDecisionUserDisabled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service.ApplySimulationBlocks(tt.decision, tt.blocks)
+			if tt.decision.Allowed != tt.allowed || tt.decision.Decision != tt.want {
+				t.Errorf("判定 = %v/%s，期望 %v/%s", tt.decision.Allowed, tt.decision.Decision, tt.allowed, tt.want)
+			}
+			if !tt.allowed && tt.decision.DecidedBy != nil {
+				t.Errorf("禁用时不应给出 decided_by: %+v", tt.decision.DecidedBy)
+			}
+		})
+	}
+}
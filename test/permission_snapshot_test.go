@@ -0,0 +1,334 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+
+	"auth-center/config"
+	"auth-center/models"
+	"auth-center/service"
+	"auth-center/utils"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestLRUCache(t *testing.T) {
+	cache := utils.NewLRUCache[string, int](2)
+	cache.Add("a", 1)
+	cache.Add("b", 2)
+	cache.Get("a") // a 变为最近使用
+	cache.Add("c", 3)
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("最久未使用的 b 应被淘汰")
+	}
+	if v, ok := cache.Get("a"); !ok || v != 1 {
+		t.Errorf("Get(a) = %d, %v", v, ok)
+	}
+	cache.Add("c", 4)
+	if v, _ := cache.Peek("c"); v != 4 || cache.Len() != 2 {
+		t.Errorf("覆盖写入后 c = %d，元素个数 %d", v, cache.Len())
+	}
+
+	cache.RemoveFunc(func(key string, value int) bool { return value > 3 })
+	if _, ok := cache.Get("c"); ok || cache.Len() != 1 {
+		t.Error("RemoveFunc 应删除满足条件的元素")
+	}
+
+	disabled := utils.NewLRUCache[string, int](0)
+	disabled.Add("a", 1)
+	if _, ok := disabled.Get("a"); ok {
+		t.Error("容量为 0 时不应缓存")
+	}
+}
+
+func TestPermissionSnapshotDecideAPI(t *testing.T) {
+	grants := map[uint][]service.PermissionGrant{
+		1: {{PermissionID: 1, Effect: service.PermissionEffectAllow, Source: service.PermissionSourceRole, RoleID: 1}},
+		2: {{PermissionID: 2, Effect: service.PermissionEffectAllow, Source: service.PermissionSourceRole, RoleID: 1, Condition: "x"}},
+		3: {{PermissionID: 3, Effect: service.PermissionEffectDeny, Source: service.PermissionSourceRole, RoleID: 1, Condition: "y"}},
+	}
+	permissions := []models.Permission{{ID: 1, Code: "order:read", Status: 1}, {ID: 2, Code: "order:edit", Status: 1}, {ID: 3, Code: "order:delete", Status: 1}}
+	snapshot := service.NewPermissionSnapshot(grants, permissions)
+	rule := func(permissionID uint) []*utils.RouteRule {
+		return []*utils.RouteRule{{Path: "/api/orders/:id", Method: "GET", PermissionID: permissionID}}
+	}
+
+	tests := []struct {
+		name                string
+		rules               []*utils.RouteRule
+		withContext         bool
+		wantAllowed, wantOK bool
+	}{
+		{"无条件授予", rule(1), true, true, true},
+		{"附带条件的授予，无请求上下文", rule(2), false, false, true},
+		{"附带条件的授予，有请求上下文", rule(2), true, false, false},
+		{"附带条件的拒绝，无请求上下文", rule(3), false, false, true},
+		{"附带条件的拒绝，有请求上下文", rule(3), true, false, false},
+	}
+	for _, tt := range tests {
+		allowed, ok := snapshot.DecideAPI(tt.rules, tt.withContext)
+		if allowed != tt.wantAllowed || ok != tt.wantOK {
+			t.Errorf("%s: DecideAPI = %v, %v，期望 %v, %v", tt.name, allowed, ok, tt.wantAllowed, tt.wantOK)
+		}
+	}
+	if !snapshot.HasPermission("order:read") || snapshot.HasPermission("order:edit") {
+		t.Error("HasPermission 结果错误")
+	}
+}
+
+// 基准测试的数据：20 个角色，每个角色 50 个权限，共 1000 个权限，每个权限对应一条 API 规则
+const (
+	benchRoles           = 20
+	benchRolePermissions = 50
+)
+
+func benchPermissionData(b *testing.B) (map[uint][]uint, []models.Permission, *utils.RouteMatcher) {
+	rolePermissions := make(map[uint][]uint, benchRoles)
+	var permissions []models.Permission
+	matcher := utils.NewRouteMatcher()
+	for roleID := uint(1); roleID <= benchRoles; roleID++ {
+		for i := uint(0); i < benchRolePermissions; i++ {
+			id := (roleID-1)*benchRolePermissions + i + 1
+			rolePermissions[roleID] = append(rolePermissions[roleID], id)
+			permissions = append(permissions, models.Permission{ID: id, Code: fmt.Sprintf("resource%d:read", id), Status: 1})
+			rule := &utils.RouteRule{ID: id, Path: fmt.Sprintf("/api/resource%d/:id", id), Method: "GET", PermissionID: id}
+			if err := matcher.Add(rule); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	return rolePermissions, permissions, matcher
+}
+
+// benchPermissionStore 原有检查方式每次请求读取的存储：查询用户的角色，再为每个角色读取一次角色权限集合（Redis SMEMBERS）
+type benchPermissionStore interface {
+	UserRoles(userID uint) ([]uint, error)
+	RolePermissions(roleID uint) ([]string, error)
+}
+
+// benchStubStore 内存中的存储桩，记录往返次数；不含网络开销，结果是原有方式耗时的下限
+type benchStubStore struct {
+	roles      []uint
+	members    map[uint][]string
+	roundTrips int
+}
+
+func newBenchStubStore(rolePermissions map[uint][]uint) *benchStubStore {
+	store := &benchStubStore{members: make(map[uint][]string, len(rolePermissions))}
+	for roleID, ids := range rolePermissions {
+		store.roles = append(store.roles, roleID)
+		for _, id := range ids {
+			store.members[roleID] = append(store.members[roleID], strconv.FormatUint(uint64(id), 10))
+		}
+	}
+	return store
+}
+
+func (s *benchStubStore) UserRoles(userID uint) ([]uint, error) {
+	s.roundTrips++
+	return s.roles, nil
+}
+
+func (s *benchStubStore) RolePermissions(roleID uint) ([]string, error) {
+	s.roundTrips++
+	return s.members[roleID], nil
+}
+
+// benchRedisStore 使用 TEST_REDIS_ADDR 的 Redis，角色权限集合与原有实现相同存放在 Redis 集合中；
+// 用户角色原为数据库查询，这里以一次 Redis 往返代替，结果偏乐观
+type benchRedisStore struct {
+	prefix string
+}
+
+func newBenchRedisStore(b *testing.B, rolePermissions map[uint][]uint) *benchRedisStore {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		b.Skip("未设置 TEST_REDIS_ADDR，跳过依赖 Redis 的基准测试")
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		b.Skipf("Redis 不可用: %v", err)
+	}
+	original := config.RedisClient
+	config.RedisClient = client
+
+	store := &benchRedisStore{prefix: utils.RolePermissionPrefix + "bench:" + utils.GenerateShortCode(8) + ":"}
+	var keys []string
+	for roleID, ids := range rolePermissions {
+		members := make([]interface{}, len(ids))
+		for i, id := range ids {
+			members[i] = id
+		}
+		if err := utils.SAdd(store.roleKey(roleID), members...); err != nil {
+			b.Fatal(err)
+		}
+		if err := utils.SAdd(store.prefix+"user", roleID); err != nil {
+			b.Fatal(err)
+		}
+		keys = append(keys, store.roleKey(roleID))
+	}
+	b.Cleanup(func() {
+		for _, key := range append(keys, store.prefix+"user") {
+			utils.Del(key)
+		}
+		config.RedisClient = original
+		client.Close()
+	})
+	return store
+}
+
+func (s *benchRedisStore) roleKey(roleID uint) string {
+	return s.prefix + strconv.FormatUint(uint64(roleID), 10)
+}
+
+func (s *benchRedisStore) UserRoles(userID uint) ([]uint, error) {
+	members, err := utils.SMembers(s.prefix + "user")
+	if err != nil {
+		return nil, err
+	}
+	return benchParseIDs(members), nil
+}
+
+func (s *benchRedisStore) RolePermissions(roleID uint) ([]string, error) {
+	return utils.SMembers(s.roleKey(roleID))
+}
+
+func benchParseIDs(members []string) []uint {
+	ids := make([]uint, 0, len(members))
+	for _, member := range members {
+		if id, err := strconv.ParseUint(member, 10, 32); err == nil {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
+}
+
+// benchLoopGrants 原有检查方式每次请求的读取：查询用户角色、逐个角色读取权限集合并汇总为规则
+func benchLoopGrants(b *testing.B, store benchPermissionStore) map[uint][]service.PermissionGrant {
+	roleIDs, err := store.UserRoles(1)
+	if err != nil {
+		b.Fatal(err)
+	}
+	grants := make(map[uint][]service.PermissionGrant)
+	for _, roleID := range roleIDs {
+		members, err := store.RolePermissions(roleID)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, id := range benchParseIDs(members) {
+			grants[id] = append(grants[id], service.PermissionGrant{
+				PermissionID: id, Effect: service.PermissionEffectAllow, Source: service.PermissionSourceRole, RoleID: roleID,
+			})
+		}
+	}
+	return grants
+}
+
+// benchLoopCheck 原有权限检查：读取规则后逐个权限按拒绝优先判定，再在结果中逐个比较权限代码
+func benchLoopCheck(b *testing.B, store benchPermissionStore, permissions []models.Permission, code string) bool {
+	grants := benchLoopGrants(b, store)
+	var codes []string
+	for _, permission := range permissions {
+		if decided := service.ResolvePermissionGrants(grants[permission.ID]); decided != nil && decided.Effect == service.PermissionEffectAllow {
+			codes = append(codes, permission.Code)
+		}
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+func benchPermissionCheckLoop(b *testing.B, store benchPermissionStore, permissions []models.Permission) {
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !benchLoopCheck(b, store, permissions, "resource999:read") {
+			b.Fatal("应拥有权限")
+		}
+	}
+}
+
+func BenchmarkPermissionCheckLoop(b *testing.B) {
+	rolePermissions, permissions, _ := benchPermissionData(b)
+	store := newBenchStubStore(rolePermissions)
+	benchPermissionCheckLoop(b, store, permissions)
+	b.ReportMetric(float64(store.roundTrips)/float64(b.N), "roundtrips/op")
+}
+
+func BenchmarkPermissionCheckLoopRedis(b *testing.B) {
+	rolePermissions, permissions, _ := benchPermissionData(b)
+	benchPermissionCheckLoop(b, newBenchRedisStore(b, rolePermissions), permissions)
+}
+
+func BenchmarkPermissionCheckSnapshot(b *testing.B) {
+	rolePermissions, permissions, _ := benchPermissionData(b)
+	cache := utils.NewLRUCache[uint, *service.PermissionSnapshot](10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		snapshot, ok := cache.Get(1)
+		if !ok {
+			snapshot = benchSnapshot(rolePermissions, permissions)
+			cache.Add(1, snapshot)
+		}
+		if !snapshot.HasPermission("resource999:read") {
+			b.Fatal("应拥有权限")
+		}
+	}
+}
+
+func benchAPIPermissionLoop(b *testing.B, store benchPermissionStore, permissions []models.Permission, matcher *utils.RouteMatcher) {
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rules := matcher.Match("/api/resource999/42", "GET")
+		if !service.NewEffectivePermissions(benchLoopGrants(b, store), permissions).AllowsAPI(rules) {
+			b.Fatal("应允许访问")
+		}
+	}
+}
+
+func BenchmarkAPIPermissionLoop(b *testing.B) {
+	rolePermissions, permissions, matcher := benchPermissionData(b)
+	store := newBenchStubStore(rolePermissions)
+	benchAPIPermissionLoop(b, store, permissions, matcher)
+	b.ReportMetric(float64(store.roundTrips)/float64(b.N), "roundtrips/op")
+}
+
+func BenchmarkAPIPermissionLoopRedis(b *testing.B) {
+	rolePermissions, permissions, matcher := benchPermissionData(b)
+	benchAPIPermissionLoop(b, newBenchRedisStore(b, rolePermissions), permissions, matcher)
+}
+
+func BenchmarkAPIPermissionSnapshot(b *testing.B) {
+	rolePermissions, permissions, matcher := benchPermissionData(b)
+	cache := utils.NewLRUCache[uint, *service.PermissionSnapshot](10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rules := matcher.Match("/api/resource999/42", "GET")
+		snapshot, ok := cache.Get(1)
+		if !ok {
+			snapshot = benchSnapshot(rolePermissions, permissions)
+			cache.Add(1, snapshot)
+		}
+		if allowed, _ := snapshot.DecideAPI(rules, true); !allowed {
+			b.Fatal("应允许访问")
+		}
+	}
+}
+
+func benchSnapshot(rolePermissions map[uint][]uint, permissions []models.Permission) *service.PermissionSnapshot {
+	grants := make(map[uint][]service.PermissionGrant)
+	for roleID, ids := range rolePermissions {
+		for _, id := range ids {
+			grants[id] = append(grants[id], service.PermissionGrant{
+				PermissionID: id, Effect: service.PermissionEffectAllow, Source: service.PermissionSourceRole, RoleID: roleID,
+			})
+		}
+	}
+	return service.NewPermissionSnapshot(grants, permissions)
+}
@@ -0,0 +1,286 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"auth-center/config"
+	"auth-center/middleware"
+	"auth-center/models"
+	"auth-center/service"
+	"auth-center/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// rateLimitTestConfig 测试用限流配置
+func rateLimitTestConfig(t *testing.T, groups map[string]config.RateLimitGroupConfig) {
+	original := config.GlobalConfig
+	t.Cleanup(func() { config.GlobalConfig = original })
+	config.GlobalConfig = &config.Config{RateLimit: config.RateLimitConfig{Enabled: true, Groups: groups}}
+}
+
+// rateLimitTestRedis 连接 TEST_REDIS_ADDR 指定的 Redis，未配置或不可用时跳过
+func rateLimitTestRedis(t *testing.T) {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("未设置 TEST_REDIS_ADDR，跳过依赖 Redis 的测试")
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Skipf("Redis 不可用: %v", err)
+	}
+	original := config.RedisClient
+	config.RedisClient = client
+	t.Cleanup(func() {
+		config.RedisClient = original
+		client.Close()
+	})
+}
+
+// rateLimitTestKey 生成不与其他测试冲突的限流键
+func rateLimitTestKey(t *testing.T) string {
+	key := utils.RateLimitPrefix + "test:" + t.Name() + ":" + utils.GenerateShortCode(8)
+	t.Cleanup(func() { utils.Del(key) })
+	return key
+}
+
+func TestResolveRule(t *testing.T) {
+	rateLimitTestConfig(t, map[string]config.RateLimitGroupConfig{
+		"auth": {Window: 60, IP: 30, App: 600, User: 0},
+	})
+	rateLimitService := &service.RateLimitService{}
+
+	rule := rateLimitService.ResolveRule("", "auth", service.RateLimitDimensionIP)
+	if rule == nil || rule.Limit != 30 || rule.Window != time.Minute {
+		t.Errorf("IP 维度期望使用默认配额 30/60s，实际得到 %+v", rule)
+	}
+	if rule := rateLimitService.ResolveRule("", "auth", service.RateLimitDimensionApp); rule == nil || rule.Limit != 600 {
+		t.Errorf("应用维度期望使用默认配额 600，实际得到 %+v", rule)
+	}
+	if rule := rateLimitService.ResolveRule("", "auth", service.RateLimitDimensionUser); rule != nil {
+		t.Errorf("配额为 0 的维度不应限流，实际得到 %+v", rule)
+	}
+	if rule := rateLimitService.ResolveRule("", "unknown", service.RateLimitDimensionIP); rule != nil {
+		t.Errorf("未配置的路由组不应限流，实际得到 %+v", rule)
+	}
+}
+
+func TestResolveRuleQuotaOverride(t *testing.T) {
+	rateLimitTestConfig(t, map[string]config.RateLimitGroupConfig{
+		"auth": {Window: 60, IP: 30, App: 600},
+	})
+	rateLimitTestRedis(t)
+
+	// 配额覆盖从缓存读取，不访问数据库
+	appID := "test-app-" + utils.GenerateShortCode(8)
+	cacheKey := utils.AppConfigPrefix + "ratelimit:" + appID
+	overrides, _ := json.Marshal(map[string]models.RateLimitQuota{
+		"auth:app": {AppID: appID, Group: "auth", Dimension: service.RateLimitDimensionApp, Limit: 5, Window: 10},
+		"auth:ip":  {AppID: appID, Group: "auth", Dimension: service.RateLimitDimensionIP, Limit: 0, Window: 10},
+	})
+	if err := utils.Set(cacheKey, overrides, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { utils.Del(cacheKey) })
+
+	rateLimitService := &service.RateLimitService{}
+	if rule := rateLimitService.ResolveRule(appID, "auth", service.RateLimitDimensionApp); rule == nil || rule.Limit != 5 || rule.Window != 10*time.Second {
+		t.Errorf("期望使用应用配额覆盖 5/10s，实际得到 %+v", rule)
+	}
+	if rule := rateLimitService.ResolveRule(appID, "auth", service.RateLimitDimensionIP); rule != nil {
+		t.Errorf("覆盖为 0 的维度不应限流，实际得到 %+v", rule)
+	}
+}
+
+func TestSlidingWindowAllow(t *testing.T) {
+	rateLimitTestRedis(t)
+	key := rateLimitTestKey(t)
+	window := 500 * time.Millisecond
+
+	for i := int64(1); i <= 3; i++ {
+		result, err := utils.SlidingWindowAllow(key, 3, window)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed || result.Remaining != 3-i {
+			t.Fatalf("第 %d 次请求期望放行且剩余 %d，实际得到 %+v", i, 3-i, result)
+		}
+	}
+
+	result, err := utils.SlidingWindowAllow(key, 3, window)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed || result.Remaining != 0 {
+		t.Fatalf("超出限额期望拒绝，实际得到 %+v", result)
+	}
+	if result.ResetIn <= 0 || result.ResetIn > window {
+		t.Errorf("重置时间应在 (0, %v] 内，实际为 %v", window, result.ResetIn)
+	}
+
+	// 被拒绝的请求不计入窗口，最早的请求移出窗口后恢复放行
+	time.Sleep(window + 50*time.Millisecond)
+	result, err = utils.SlidingWindowAllow(key, 3, window)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed || result.Remaining != 2 {
+		t.Errorf("窗口滑过后期望放行且剩余 2，实际得到 %+v", result)
+	}
+}
+
+// rateLimitTestRouter 构造挂载限流中间件的路由，appID 非空时模拟已认证的应用
+func rateLimitTestRouter(appID string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RateLimitMiddleware("auth"))
+	if appID != "" {
+		r.Use(func(c *gin.Context) { c.Set("app_id", appID) }, middleware.RateLimitMiddleware("auth"))
+	}
+	r.POST("/login", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+// rateLimitTestRequest 发送带客户端自报 app_id 的请求
+func rateLimitTestRequest(r *gin.Engine, ip, claimedAppID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/login?app_id="+claimedAppID, strings.NewReader(`{"app_id":"`+claimedAppID+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-App-Id", claimedAppID)
+	req.RemoteAddr = ip + ":1234"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitMiddlewareIgnoresClaimedAppID(t *testing.T) {
+	rateLimitTestConfig(t, map[string]config.RateLimitGroupConfig{
+		"auth": {Window: 60, IP: 0, App: 1},
+	})
+	// 未连接 Redis 和数据库：若按自报的 app_id 查询配额覆盖会访问空的数据库连接
+	originalRedis, originalDB := config.RedisClient, config.DB
+	config.RedisClient, config.DB = nil, nil
+	defer func() { config.RedisClient, config.DB = originalRedis, originalDB }()
+
+	r := rateLimitTestRouter("")
+	for i := 0; i < 3; i++ {
+		if w := rateLimitTestRequest(r, "192.0.2.1", "fake-app"); w.Code != http.StatusOK {
+			t.Fatalf("未认证请求不应按自报的 app_id 限流，实际状态码 %d", w.Code)
+		}
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	rateLimitTestConfig(t, map[string]config.RateLimitGroupConfig{
+		"auth": {Window: 60, IP: 2, App: 3},
+	})
+	rateLimitTestRedis(t)
+
+	suffix := utils.GenerateShortCode(8)
+	ipA, ipB, ipC := "198.51.100.1", "198.51.100.2", "198.51.100.3"
+	appID := "test-app-" + suffix
+	t.Cleanup(func() {
+		for _, key := range []string{
+			utils.RateLimitPrefix + "auth:ip:" + ipA,
+			utils.RateLimitPrefix + "auth:ip:" + ipB,
+			utils.RateLimitPrefix + "auth:ip:" + ipC,
+			utils.RateLimitPrefix + "auth:app:" + appID,
+			utils.AppConfigPrefix + "ratelimit:" + appID,
+		} {
+			utils.Del(key)
+		}
+	})
+	// 空的配额覆盖缓存，避免访问数据库
+	if err := utils.Set(utils.AppConfigPrefix+"ratelimit:"+appID, "{}", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("IP 维度", func(t *testing.T) {
+		r := rateLimitTestRouter("")
+		for i := 0; i < 2; i++ {
+			w := rateLimitTestRequest(r, ipA, "fake-"+suffix)
+			if w.Code != http.StatusOK {
+				t.Fatalf("第 %d 次请求期望放行，实际状态码 %d", i+1, w.Code)
+			}
+			if w.Header().Get("X-RateLimit-Limit") != "2" {
+				t.Errorf("期望输出 IP 维度的限流响应头，实际为 %q", w.Header().Get("X-RateLimit-Limit"))
+			}
+		}
+		w := rateLimitTestRequest(r, ipA, "fake-"+suffix)
+		if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+			t.Errorf("超出 IP 限额期望 429 并携带 Retry-After，实际状态码 %d", w.Code)
+		}
+		if exists, _ := utils.Exists(utils.RateLimitPrefix + "auth:app:fake-" + suffix); exists {
+			t.Error("自报的 app_id 不应产生应用维度的限流记录")
+		}
+		if exists, _ := utils.Exists(utils.AppConfigPrefix + "ratelimit:fake-" + suffix); exists {
+			t.Error("自报的 app_id 不应查询和缓存配额覆盖")
+		}
+	})
+
+	t.Run("已认证应用维度", func(t *testing.T) {
+		r := rateLimitTestRouter(appID)
+		// 应用配额跨 IP 共享，同一请求经两次挂载只计数一次
+		for i, ip := range []string{ipB, ipB, ipC} {
+			if w := rateLimitTestRequest(r, ip, ""); w.Code != http.StatusOK {
+				t.Fatalf("第 %d 次请求期望放行，实际状态码 %d", i+1, w.Code)
+			}
+		}
+		if w := rateLimitTestRequest(r, ipC, ""); w.Code != http.StatusTooManyRequests {
+			t.Errorf("超出应用限额期望 429，实际状态码 %d", w.Code)
+		}
+	})
+}
+
+func TestAllowVerifiedApp(t *testing.T) {
+	rateLimitTestConfig(t, map[string]config.RateLimitGroupConfig{
+		"auth": {Window: 60, IP: 0, App: 2},
+	})
+	mr, _ := newMockBackends(t)
+
+	appID := "test-app-" + utils.GenerateShortCode(8)
+	// 空的配额覆盖缓存，避免访问数据库
+	mr.Set(utils.AppConfigPrefix+"ratelimit:"+appID, "{}")
+
+	// 模拟登录：中间件只按 IP 限流，业务层校验应用密钥后补充应用维度
+	r := rateLimitTestRouter("")
+	r.POST("/verified", func(c *gin.Context) {
+		if !middleware.AllowVerifiedApp(c, c.Query("app_id")) {
+			return
+		}
+		// 同一请求再次校验不重复计数
+		if !middleware.AllowVerifiedApp(c, c.Query("app_id")) {
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	request := func(ip, claimedAppID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/verified?app_id="+claimedAppID, nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// 应用配额跨 IP 共享
+	for i, ip := range []string{"198.51.100.1", "198.51.100.2"} {
+		if w := request(ip, appID); w.Code != http.StatusOK {
+			t.Fatalf("第 %d 次请求期望放行，实际状态码 %d", i+1, w.Code)
+		}
+	}
+	w := request("198.51.100.3", appID)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("超出应用限额期望 429 并携带 Retry-After，实际状态码 %d", w.Code)
+	}
+	// 未校验应用密钥、只自报 app_id 的请求仍不按应用限流
+	if w := rateLimitTestRequest(r, "198.51.100.3", appID); w.Code != http.StatusOK {
+		t.Errorf("未校验应用的请求不应按应用限流，实际状态码 %d", w.Code)
+	}
+}
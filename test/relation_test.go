@@ -0,0 +1,297 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"auth-center/service"
+)
+
+// memoryTuples 内存中的关系元组，键为 namespace:id#relation
+type memoryTuples map[string][]service.RelationSubject
+
+func newMemoryTuples(t *testing.T, tuples ...string) memoryTuples {
+	m := memoryTuples{}
+	for _, tuple := range tuples {
+		objectRelation, subject, _ := strings.Cut(tuple, "@")
+		sub, err := service.ParseRelationSubject(subject)
+		if err != nil {
+			t.Fatalf("解析元组 %s 失败: %v", tuple, err)
+		}
+		m[objectRelation] = append(m[objectRelation], sub)
+	}
+	return m
+}
+
+func (m memoryTuples) Subjects(object service.RelationObject, relation string) ([]service.RelationSubject, error) {
+	return m[object.String()+"#"+relation], nil
+}
+
+func (m memoryTuples) SubjectTuples(subject service.RelationObject) ([]service.RelationTupleRef, error) {
+	var refs []service.RelationTupleRef
+	for key, subjects := range m {
+		objectPart, relation, _ := strings.Cut(key, "#")
+		object, _ := service.ParseRelationObject(objectPart)
+		for _, s := range subjects {
+			if s.Namespace == subject.Namespace && s.ID == subject.ID {
+				refs = append(refs, service.RelationTupleRef{Object: object, Relation: relation, SubjectRelation: s.Relation})
+			}
+		}
+	}
+	return refs, nil
+}
+
+// countingTuples 统计读取元组的次数
+type countingTuples struct {
+	memoryTuples
+	reads int
+}
+
+func (c *countingTuples) Subjects(object service.RelationObject, relation string) ([]service.RelationSubject, error) {
+	c.reads++
+	return c.memoryTuples.Subjects(object, relation)
+}
+
+func (c *countingTuples) SubjectTuples(subject service.RelationObject) ([]service.RelationTupleRef, error) {
+	c.reads++
+	return c.memoryTuples.SubjectTuples(subject)
+}
+
+const testRelationSchema = `{
+  "namespaces": {
+    "user": {"relations": {"self": {}}},
+    "team": {"relations": {"member": {}}},
+    "folder": {"relations": {
+      "owner": {},
+      "viewer": {"union": [{"this": {}}, {"computed_userset": "owner"}]}
+    }},
+    "document": {"relations": {
+      "parent": {},
+      "owner": {},
+      "banned": {},
+      "editor": {"union": [{"this": {}}, {"computed_userset": "owner"}]},
+      "viewer": {"exclusion": {
+        "base": {"union": [
+          {"this": {}},
+          {"computed_userset": "editor"},
+          {"tuple_to_userset": {"tupleset": "parent", "computed_userset": "viewer"}}
+        ]},
+        "subtract": {"computed_userset": "banned"}
+      }}
+    }}
+  }
+}`
+
+func newTestRelationEvaluator(t *testing.T, tuples ...string) *service.RelationEvaluator {
+	var schema service.RelationSchema
+	if err := json.Unmarshal([]byte(testRelationSchema), &schema); err != nil {
+		t.Fatalf("解析关系模式失败: %v", err)
+	}
+	if err := schema.Validate(); err != nil {
+		t.Fatalf("关系模式应有效: %v", err)
+	}
+	return &service.RelationEvaluator{Schema: &schema, Reader: newMemoryTuples(t, tuples...)}
+}
+
+func TestRelationCheck(t *testing.T) {
+	evaluator := newTestRelationEvaluator(t,
+		"document:42#owner@team:eng#member",
+		"team:eng#member@user:alice",
+		"team:eng#member@team:sre#member", // 嵌套团队
+		"team:sre#member@user:bob",
+		"document:42#parent@folder:7",
+		"folder:7#owner@user:carol",
+		"document:42#viewer@user:dave",
+		"document:42#banned@user:bob",
+	)
+
+	tests := []struct {
+		object, relation, subject string
+		want                      bool
+	}{
+		{"document:42", "owner", "user:alice", true},      // 团队成员
+		{"document:42", "editor", "user:alice", true},     // editor 包含 owner
+		{"document:42", "viewer", "user:alice", true},     // viewer 包含 editor
+		{"document:42", "editor", "user:bob", true},       // 嵌套团队成员
+		{"document:42", "viewer", "user:bob", false},      // 被排除
+		{"document:42", "viewer", "user:carol", true},     // 父目录的 viewer
+		{"document:42", "editor", "user:carol", false},    // 父目录的权限不继承 editor
+		{"document:42", "viewer", "user:dave", true},      // 直接写入
+		{"document:42", "editor", "user:dave", false},     // viewer 不包含 editor
+		{"document:42", "viewer", "user:erin", false},     // 无关用户
+		{"document:42", "owner", "team:eng#member", true}, // 主体为一组用户
+		{"document:42", "owner", "team:sre#member", true}, // 嵌套的一组用户
+	}
+	for _, tt := range tests {
+		object, _ := service.ParseRelationObject(tt.object)
+		subject, _ := service.ParseRelationSubject(tt.subject)
+		got, err := evaluator.Check(object, tt.relation, subject)
+		if err != nil {
+			t.Fatalf("Check(%s#%s@%s) 失败: %v", tt.object, tt.relation, tt.subject, err)
+		}
+		if got != tt.want {
+			t.Errorf("Check(%s#%s@%s) = %v，期望 %v", tt.object, tt.relation, tt.subject, got, tt.want)
+		}
+	}
+
+	object, _ := service.ParseRelationObject("document:42")
+	subject, _ := service.ParseRelationSubject("user:alice")
+	if _, err := evaluator.Check(object, "unknown", subject); !errors.Is(err, service.ErrRelationInvalid) {
+		t.Errorf("未定义的关系应返回 ErrRelationInvalid: %v", err)
+	}
+}
+
+func TestRelationCheckCycle(t *testing.T) {
+	// 团队互相包含时不会无限递归
+	evaluator := newTestRelationEvaluator(t,
+		"team:a#member@team:b#member",
+		"team:b#member@team:a#member",
+		"team:b#member@user:alice",
+	)
+	object, _ := service.ParseRelationObject("team:a")
+	for subject, want := range map[string]bool{"user:alice": true, "user:bob": false} {
+		sub, _ := service.ParseRelationSubject(subject)
+		got, err := evaluator.Check(object, "member", sub)
+		if err != nil || got != want {
+			t.Errorf("Check(team:a#member@%s) = %v, %v，期望 %v", subject, got, err, want)
+		}
+	}
+}
+
+func TestRelationExpandAndListObjects(t *testing.T) {
+	evaluator := newTestRelationEvaluator(t,
+		"document:1#owner@user:alice",
+		"document:2#parent@folder:7",
+		"folder:7#viewer@user:alice",
+		"document:3#owner@user:bob",
+		"document:4#owner@user:alice",
+		"document:4#banned@user:alice",
+	)
+
+	subject, _ := service.ParseRelationSubject("user:alice")
+	ids, err := evaluator.ListObjects("document", "viewer", subject, 0)
+	if err != nil {
+		t.Fatalf("ListObjects 失败: %v", err)
+	}
+	if want := []string{"1", "2"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ListObjects = %v，期望 %v", ids, want)
+	}
+
+	object, _ := service.ParseRelationObject("document:1")
+	tree, err := evaluator.Expand(object, "editor")
+	if err != nil {
+		t.Fatalf("Expand 失败: %v", err)
+	}
+	if tree.Type != "union" || tree.Object != "document:1" || tree.Relation != "editor" || len(tree.Children) != 2 {
+		t.Fatalf("展开结果错误: %+v", tree)
+	}
+	owner := tree.Children[1].Children[0]
+	if owner.Relation != "owner" || !reflect.DeepEqual(owner.Subjects, []string{"user:alice"}) {
+		t.Errorf("owner 展开错误: %+v", owner)
+	}
+}
+
+func TestRelationListObjectsReverse(t *testing.T) {
+	evaluator := newTestRelationEvaluator(t,
+		"team:eng#member@team:sre#member",
+		"team:sre#member@user:bob",
+		"document:10#editor@team:eng#member", // 嵌套团队
+		"folder:7#owner@team:sre#member",
+		"document:11#parent@folder:7", // 父目录 owner 包含 viewer
+		"document:12#viewer@user:bob",
+		"document:12#banned@team:eng#member", // 被排除
+		"document:13#owner@user:alice",       // 与 bob 无关
+	)
+
+	tests := []struct {
+		relation, subject string
+		limit             int
+		want              []string
+	}{
+		{"viewer", "user:bob", 0, []string{"10", "11"}},
+		{"editor", "user:bob", 0, []string{"10"}},
+		{"viewer", "user:bob", 1, []string{"10"}},
+		{"viewer", "team:sre#member", 0, []string{"10", "11"}},
+		{"viewer", "user:alice", 0, []string{"13"}},
+		{"viewer", "user:erin", 0, []string{}},
+	}
+	for _, tt := range tests {
+		subject, _ := service.ParseRelationSubject(tt.subject)
+		ids, err := evaluator.ListObjects("document", tt.relation, subject, tt.limit)
+		if err != nil {
+			t.Fatalf("ListObjects(%s, %s) 失败: %v", tt.relation, tt.subject, err)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("ListObjects(%s, %s, %d) = %v，期望 %v", tt.relation, tt.subject, tt.limit, ids, tt.want)
+		}
+	}
+}
+
+func TestRelationListObjectsSkipsUnrelatedObjects(t *testing.T) {
+	tuples := []string{"document:1#owner@user:alice"}
+	for i := 0; i < 1000; i++ {
+		tuples = append(tuples, fmt.Sprintf("document:x%d#owner@user:u%d", i, i))
+	}
+	evaluator := newTestRelationEvaluator(t, tuples...)
+	reader := &countingTuples{memoryTuples: evaluator.Reader.(memoryTuples)}
+	evaluator.Reader = reader
+
+	subject, _ := service.ParseRelationSubject("user:alice")
+	ids, err := evaluator.ListObjects("document", "viewer", subject, 0)
+	if err != nil {
+		t.Fatalf("ListObjects 失败: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []string{"1"}) {
+		t.Errorf("ListObjects = %v，期望 [1]", ids)
+	}
+	// 只读取与 alice 相关的元组，不随命名空间下的对象数增长
+	if reader.reads > 20 {
+		t.Errorf("读取元组 %d 次，不应逐个检查无关对象", reader.reads)
+	}
+}
+
+func TestRelationSchemaValidate(t *testing.T) {
+	invalid := []string{
+		`{"namespaces": {}}`,
+		`{"namespaces": {"Doc": {"relations": {"owner": {}}}}}`,
+		`{"namespaces": {"doc": {"relations": {"editor": {"computed_userset": "owner"}}}}}`,
+		`{"namespaces": {"doc": {"relations": {"viewer": {"tuple_to_userset": {"tupleset": "parent", "computed_userset": "viewer"}}}}}}`,
+		`{"namespaces": {"doc": {"relations": {"owner": {}, "editor": {"this": {}, "computed_userset": "owner"}}}}}`,
+	}
+	for _, definition := range invalid {
+		var schema service.RelationSchema
+		if err := json.Unmarshal([]byte(definition), &schema); err != nil {
+			t.Fatalf("解析 %s 失败: %v", definition, err)
+		}
+		if err := schema.Validate(); err == nil {
+			t.Errorf("%s 应为无效模式", definition)
+		}
+	}
+}
+
+func TestConsistencyToken(t *testing.T) {
+	token := service.EncodeConsistencyToken(42)
+	revision, err := service.DecodeConsistencyToken(token)
+	if err != nil || revision != 42 {
+		t.Errorf("DecodeConsistencyToken(%s) = %d, %v", token, revision, err)
+	}
+	if _, err := service.DecodeConsistencyToken("not-a-token"); !errors.Is(err, service.ErrInvalidConsistencyToken) {
+		t.Errorf("无效令牌应返回 ErrInvalidConsistencyToken: %v", err)
+	}
+}
+
+func TestParseRelationSubject(t *testing.T) {
+	subject, err := service.ParseRelationSubject("team:eng#member")
+	if err != nil || subject.Namespace != "team" || subject.ID != "eng" || subject.Relation != "member" {
+		t.Errorf("解析错误: %+v, %v", subject, err)
+	}
+	for _, value := range []string{"alice", ":alice", "user:", "user:a#", "User:alice", "user:a b"} {
+		if _, err := service.ParseRelationSubject(value); err == nil {
+			t.Errorf("%q 应为无效主体", value)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"auth-center/service"
+)
+
+func TestRoleGraphAncestors(t *testing.T) {
+	// admin(3) -> editor(2) -> viewer(1)，auditor(4) -> viewer(1)
+	graph := service.RoleGraph{
+		3: {2},
+		2: {1},
+		4: {1},
+	}
+
+	tests := []struct {
+		roots []uint
+		want  []uint
+	}{
+		{[]uint{1}, []uint{1}},
+		{[]uint{2}, []uint{1, 2}},
+		{[]uint{3}, []uint{1, 2, 3}},
+		{[]uint{3, 4}, []uint{1, 2, 3, 4}},
+		{nil, []uint{}},
+	}
+	for _, tt := range tests {
+		if got := graph.Ancestors(tt.roots...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Ancestors(%v) = %v，期望 %v", tt.roots, got, tt.want)
+		}
+	}
+}
+
+func TestRoleGraphCreatesCycle(t *testing.T) {
+	graph := service.RoleGraph{
+		3: {2},
+		2: {1},
+	}
+
+	if !graph.CreatesCycle(1, []uint{3}) {
+		t.Error("viewer 继承 admin 会成环")
+	}
+	if !graph.CreatesCycle(1, []uint{2}) {
+		t.Error("viewer 继承 editor 会成环")
+	}
+	if graph.CreatesCycle(4, []uint{3, 1}) {
+		t.Error("新角色继承 admin 和 viewer 不会成环")
+	}
+	// 替换 editor 的父角色时不考虑它原有的父角色
+	if graph.CreatesCycle(2, []uint{4}) {
+		t.Error("editor 改为继承其他角色不会成环")
+	}
+	if !graph.CreatesCycle(2, []uint{3}) {
+		t.Error("editor 继承 admin 会成环")
+	}
+}
+
+func TestRoleGraphDescendants(t *testing.T) {
+	// admin(3) -> editor(2) -> viewer(1)，auditor(4) -> viewer(1)
+	graph := service.RoleGraph{
+		3: {2},
+		2: {1},
+		4: {1},
+	}
+
+	tests := []struct {
+		role uint
+		want []uint
+	}{
+		{1, []uint{2, 3, 4}},
+		{2, []uint{3}},
+		{3, []uint{}},
+		{5, []uint{}},
+	}
+	for _, tt := range tests {
+		if got := graph.Descendants(tt.role); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Descendants(%d) = %v，期望 %v", tt.role, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"testing"
+
+	"auth-center/utils"
+)
+
+func TestRouteMatcher(t *testing.T) {
+	matcher := utils.NewRouteMatcher()
+	rules := []*utils.RouteRule{
+		{ID: 1, Path: "/orders", Method: "GET"},
+		{ID: 2, Path: "/orders/:id", Method: "GET"},
+		{ID: 3, Path: "/orders/{id}", Method: "ANY"},
+		{ID: 4, Path: "/orders/export", Method: "GET"},
+		{ID: 5, Path: "/reports/**", Method: "GET"},
+		{ID: 6, Path: "/reports/*.csv", Method: "GET"},
+		{ID: 7, Path: "/reports/secret", Method: "*"},
+		{ID: 8, Path: "/static/*filepath", Method: "GET"},
+		{ID: 9, Path: "/users/*/profile", Method: "PUT"},
+	}
+	for _, rule := range rules {
+		if err := matcher.Add(rule); err != nil {
+			t.Fatalf("添加规则 %s 失败: %v", rule.Path, err)
+		}
+	}
+
+	tests := []struct {
+		path   string
+		method string
+		want   uint // 0 表示没有匹配
+	}{
+		{"/orders", "GET", 1},
+		{"/orders/", "get", 1},
+		{"/orders", "POST", 0},
+		{"/orders/42", "GET", 2},
+		{"/orders/:id", "GET", 2}, // 调用方传入路由模板
+		{"/orders/42", "DELETE", 3},
+		{"/orders/export", "GET", 4},
+		{"/orders/export", "POST", 3}, // 静态路径没有该方法时回退到参数路径
+		{"/orders/42/items", "GET", 0},
+		{"/reports", "GET", 5},
+		{"/reports/2024/q1.pdf", "GET", 5},
+		{"/reports/q1.csv", "GET", 6},
+		{"/reports/secret", "GET", 7},
+		{"/reports/secret", "DELETE", 7},
+		{"/reports/q1.csv", "POST", 0},
+		{"/static/js/app.js", "GET", 8},
+		{"/users/7/profile", "PUT", 9},
+		{"/users/7/settings", "PUT", 0},
+	}
+	for _, tt := range tests {
+		matched := matcher.Match(tt.path, tt.method)
+		var got uint
+		if len(matched) > 0 {
+			got = matched[0].ID
+		}
+		if got != tt.want {
+			t.Errorf("%s %s 匹配到规则 %d，期望 %d", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRoutePattern(t *testing.T) {
+	valid := []string{"/", "/orders/:id", "/orders/{id}/items", "/reports/**", "/static/*filepath", "/files/*.csv", "/v?/users"}
+	for _, pattern := range valid {
+		if err := utils.ValidateRoutePattern(pattern); err != nil {
+			t.Errorf("%s 应为有效模式: %v", pattern, err)
+		}
+	}
+
+	invalid := []string{"orders", "/reports/**/list", "/static/*filepath/more", "/files/[a-"}
+	for _, pattern := range invalid {
+		if err := utils.ValidateRoutePattern(pattern); err == nil {
+			t.Errorf("%s 应为无效模式", pattern)
+		}
+	}
+}
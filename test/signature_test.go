@@ -0,0 +1,79 @@
+package test
+
+import (
+	"testing"
+
+	"auth-center/config"
+	"auth-center/utils"
+)
+
+func TestRequestSignature(t *testing.T) {
+	signingKey := utils.DeriveSigningKey("app_test-secret")
+	body := []byte(`{"username":"alice","password":"secret"}`)
+
+	signature := utils.SignRequest(signingKey, "POST", "/api/v1/auth/login", "1700000000", "nonce-1", body)
+	if len(signature) != 64 {
+		t.Fatalf("签名长度错误: %s", signature)
+	}
+
+	if !utils.VerifyRequestSignature(signingKey, signature, "post", "/api/v1/auth/login", "1700000000", "nonce-1", body) {
+		t.Error("合法签名应该校验通过")
+	}
+
+	cases := []struct {
+		name       string
+		method     string
+		requestURI string
+		timestamp  string
+		nonce      string
+		body       []byte
+	}{
+		{"篡改方法", "PUT", "/api/v1/auth/login", "1700000000", "nonce-1", body},
+		{"篡改路径", "POST", "/api/v1/auth/register", "1700000000", "nonce-1", body},
+		{"篡改时间戳", "POST", "/api/v1/auth/login", "1700000001", "nonce-1", body},
+		{"篡改nonce", "POST", "/api/v1/auth/login", "1700000000", "nonce-2", body},
+		{"篡改请求体", "POST", "/api/v1/auth/login", "1700000000", "nonce-1", []byte(`{"username":"bob","password":"secret"}`)},
+	}
+	for _, tc := range cases {
+		if utils.VerifyRequestSignature(signingKey, signature, tc.method, tc.requestURI, tc.timestamp, tc.nonce, tc.body) {
+			t.Errorf("%s后签名应该校验失败", tc.name)
+		}
+	}
+
+	if signingKey == utils.HashAppSecret("app_test-secret") {
+		t.Error("签名密钥不能与存储的密钥哈希相同")
+	}
+	otherKey := utils.DeriveSigningKey("app_other-secret")
+	if utils.VerifyRequestSignature(otherKey, signature, "POST", "/api/v1/auth/login", "1700000000", "nonce-1", body) {
+		t.Error("其他应用密钥不应校验通过")
+	}
+}
+
+func TestSigningKeyEncryption(t *testing.T) {
+	original := config.GlobalConfig
+	defer func() { config.GlobalConfig = original }()
+	config.GlobalConfig = &config.Config{Signature: config.SignatureConfig{EncryptionKey: "server-key"}}
+
+	signingKey := utils.DeriveSigningKey("app_test-secret")
+	binding := utils.HashAppSecret("app_test-secret")
+	encrypted, err := utils.EncryptSigningKey(signingKey, binding)
+	if err != nil {
+		t.Fatalf("加密签名密钥失败: %v", err)
+	}
+	if encrypted == signingKey || encrypted == binding {
+		t.Error("签名密钥不能明文保存")
+	}
+
+	decrypted, err := utils.DecryptSigningKey(encrypted, binding)
+	if err != nil || decrypted != signingKey {
+		t.Fatalf("解密签名密钥失败: %v", err)
+	}
+	if _, err := utils.DecryptSigningKey(encrypted, utils.HashAppSecret("app_other-secret")); err == nil {
+		t.Error("绑定到其他密钥记录时应解密失败")
+	}
+
+	config.GlobalConfig.Signature.EncryptionKey = "other-server-key"
+	if _, err := utils.DecryptSigningKey(encrypted, binding); err == nil {
+		t.Error("服务端密钥不同时应解密失败")
+	}
+}
@@ -0,0 +1,253 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"auth-center/models"
+)
+
+// GenesisAuditHash 第一条审计日志的 PrevHash
+const GenesisAuditHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// 审计链校验问题类型
+const (
+	AuditIssueGap           = "gap"            // 序号不连续（记录缺失或重复）
+	AuditIssueHashMismatch  = "hash_mismatch"  // 记录内容与哈希不一致（被修改）
+	AuditIssueChainBroken   = "chain_broken"   // PrevHash 与上一条记录的哈希不一致
+	AuditIssueBadSignature  = "bad_signature"  // 检查点签名无效或签名公钥未知
+	AuditIssueCheckpoint    = "checkpoint"     // 检查点与对应记录的哈希不一致
+	AuditIssueMissingRecord = "missing_record" // 检查点覆盖的记录不存在（尾部被截断）
+)
+
+// AuditIssue 审计链校验发现的问题
+type AuditIssue struct {
+	Seq     uint64 `json:"seq"`
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// AuditTime 参与哈希计算的时间表示：UTC，毫秒精度（与数据库 datetime(3) 一致）
+func AuditTime(t time.Time) string {
+	return t.UTC().Truncate(time.Millisecond).Format("2006-01-02T15:04:05.000Z")
+}
+
+// AuditLogHash 计算审计日志的链哈希
+// 将序号、PrevHash 和全部业务字段按固定顺序编码为 JSON 数组后取 SHA-256（十六进制），不包含数据库自增ID
+func AuditLogHash(entry *models.AuditLog) string {
+	fields := []interface{}{
+		entry.Seq, entry.PrevHash, AuditTime(entry.CreatedAt),
+		entry.ActorType, entry.ActorID, entry.ActorName, entry.AppID,
+		entry.Action, entry.TargetType, entry.TargetID,
+		entry.Before, entry.After, entry.Diff,
+		entry.Method, entry.Path, entry.StatusCode,
+		entry.Result, entry.Error, entry.IP, entry.UserAgent,
+	}
+	data, _ := json.Marshal(fields)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditCheckpointPayload 检查点签名内容
+func AuditCheckpointPayload(seq uint64, hash string, createdAt time.Time) []byte {
+	return []byte("auth-center-audit-checkpoint\n" + strconv.FormatUint(seq, 10) + "\n" + hash + "\n" + AuditTime(createdAt))
+}
+
+// SignAuditCheckpoint 使用 Ed25519 私钥签名检查点
+func SignAuditCheckpoint(key ed25519.PrivateKey, checkpoint *models.AuditCheckpoint) {
+	checkpoint.KeyID = AuditKeyID(key.Public().(ed25519.PublicKey))
+	signature := ed25519.Sign(key, AuditCheckpointPayload(checkpoint.Seq, checkpoint.Hash, checkpoint.CreatedAt))
+	checkpoint.Signature = base64.StdEncoding.EncodeToString(signature)
+}
+
+// VerifyAuditCheckpoint 校验检查点签名
+func VerifyAuditCheckpoint(pub ed25519.PublicKey, checkpoint *models.AuditCheckpoint) bool {
+	if checkpoint.KeyID != AuditKeyID(pub) {
+		return false
+	}
+	signature, err := base64.StdEncoding.DecodeString(checkpoint.Signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(pub, AuditCheckpointPayload(checkpoint.Seq, checkpoint.Hash, checkpoint.CreatedAt), signature)
+}
+
+// AuditKeyID 公钥ID：公钥 SHA-256 的前 16 位十六进制
+func AuditKeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// GenerateEd25519KeyFile 生成 Ed25519 私钥并以 PKCS#8 PEM 格式写入 path（文件已存在时返回错误）
+func GenerateEd25519KeyFile(path string) (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if err := pem.Encode(file, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// LoadEd25519Key 从 PKCS#8 PEM 文件读取 Ed25519 私钥，文件不存在时返回错误（不自动生成，避免多副本各自生成不同的密钥）
+func LoadEd25519Key(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("签名密钥文件 %s 不存在，请使用 go run ./cmd/auditverify -genkey %s 生成，并在所有副本上使用同一密钥", path, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseEd25519Key(data)
+}
+
+// ParseEd25519Key 解析 Ed25519 私钥：PKCS#8 PEM，或 PKCS#8 DER 的 base64
+func ParseEd25519Key(data []byte) (ed25519.PrivateKey, error) {
+	der := data
+	if block, _ := pem.Decode(data); block != nil {
+		der = block.Bytes
+	} else if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data))); err == nil {
+		der = decoded
+	} else {
+		return nil, errors.New("签名密钥不是有效的 PEM 或 base64 格式")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("解析签名密钥失败: %v", err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("签名密钥必须为 Ed25519 私钥")
+	}
+	return key, nil
+}
+
+// AuditChainVerifier 按序号顺序逐条校验审计日志的哈希链和检查点签名
+type AuditChainVerifier struct {
+	publicKey   ed25519.PublicKey
+	checkpoints map[uint64][]*models.AuditCheckpoint
+	started     bool
+	firstSeq    uint64
+	lastSeq     uint64
+	lastHash    string
+	Checked     int
+	Issues      []AuditIssue
+}
+
+// NewAuditChainVerifier 创建校验器，publicKey 为 nil 时不校验检查点签名
+func NewAuditChainVerifier(publicKey ed25519.PublicKey) *AuditChainVerifier {
+	return &AuditChainVerifier{
+		publicKey:   publicKey,
+		checkpoints: make(map[uint64][]*models.AuditCheckpoint),
+	}
+}
+
+// Anchor 以校验范围之前的最后一条记录作为起点，用于校验范围内第一条记录的 PrevHash
+func (v *AuditChainVerifier) Anchor(seq uint64, hash string) {
+	v.started = true
+	v.lastSeq = seq
+	v.lastHash = hash
+}
+
+// AddCheckpoint 登记检查点：立即校验签名，对应序号的记录到达时再校验哈希
+func (v *AuditChainVerifier) AddCheckpoint(checkpoint *models.AuditCheckpoint) {
+	if v.publicKey != nil && !VerifyAuditCheckpoint(v.publicKey, checkpoint) {
+		v.issue(checkpoint.Seq, AuditIssueBadSignature, fmt.Sprintf("检查点 %d 签名无效（key_id=%s）", checkpoint.ID, checkpoint.KeyID))
+		return
+	}
+	v.checkpoints[checkpoint.Seq] = append(v.checkpoints[checkpoint.Seq], checkpoint)
+}
+
+// Add 校验下一条审计日志
+func (v *AuditChainVerifier) Add(entry *models.AuditLog) {
+	v.Checked++
+
+	if AuditLogHash(entry) != entry.Hash {
+		v.issue(entry.Seq, AuditIssueHashMismatch, "记录内容与哈希不一致")
+	}
+
+	if v.Checked == 1 {
+		v.firstSeq = entry.Seq
+	}
+
+	switch {
+	case !v.started:
+		v.started = true
+		if entry.Seq == 1 && entry.PrevHash != GenesisAuditHash {
+			v.issue(entry.Seq, AuditIssueChainBroken, "第一条记录的 PrevHash 不是初始值")
+		}
+	case entry.Seq == v.lastSeq:
+		v.issue(entry.Seq, AuditIssueGap, "序号重复：哈希链出现分叉")
+	case entry.Seq != v.lastSeq+1:
+		v.issue(entry.Seq, AuditIssueGap, fmt.Sprintf("序号不连续：上一条为 %d", v.lastSeq))
+	case entry.PrevHash != v.lastHash:
+		v.issue(entry.Seq, AuditIssueChainBroken, "PrevHash 与上一条记录的哈希不一致")
+	}
+
+	for _, checkpoint := range v.checkpoints[entry.Seq] {
+		if checkpoint.Hash != entry.Hash {
+			v.issue(entry.Seq, AuditIssueCheckpoint, fmt.Sprintf("检查点 %d 记录的哈希与审计日志不一致", checkpoint.ID))
+		}
+	}
+	delete(v.checkpoints, entry.Seq)
+
+	v.lastSeq = entry.Seq
+	v.lastHash = entry.Hash
+}
+
+// Finish 结束校验：已登记但未遇到对应记录的检查点视为记录缺失
+// 仅检查序号不大于 maxSeq 的检查点（maxSeq 为 0 时检查全部）
+func (v *AuditChainVerifier) Finish(maxSeq uint64) []AuditIssue {
+	seqs := make([]uint64, 0, len(v.checkpoints))
+	for seq := range v.checkpoints {
+		if (maxSeq != 0 && seq > maxSeq) || (v.firstSeq != 0 && seq < v.firstSeq) {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+
+	for _, seq := range seqs {
+		for _, checkpoint := range v.checkpoints[seq] {
+			v.issue(seq, AuditIssueMissingRecord, fmt.Sprintf("检查点 %d 对应的记录不存在", checkpoint.ID))
+		}
+	}
+	v.checkpoints = make(map[uint64][]*models.AuditCheckpoint)
+	return v.Issues
+}
+
+// LastSeq 最后一条已校验记录的序号
+func (v *AuditChainVerifier) LastSeq() uint64 {
+	return v.lastSeq
+}
+
+func (v *AuditChainVerifier) issue(seq uint64, issueType, message string) {
+	v.Issues = append(v.Issues, AuditIssue{Seq: seq, Type: issueType, Message: message})
+}
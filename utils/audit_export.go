@@ -0,0 +1,267 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"auth-center/models"
+)
+
+// 审计日志导出格式
+const (
+	AuditExportJSONL = "jsonl"
+	AuditExportCSV   = "csv"
+)
+
+// 导出记录类型：依次为签名公钥、检查点、审计日志，校验时可单遍流式处理
+const (
+	AuditRecordKey        = "key"
+	AuditRecordCheckpoint = "checkpoint"
+	AuditRecordEntry      = "entry"
+)
+
+// AuditExportRecord 导出文件中的一条记录（JSON Lines 每行一条）
+type AuditExportRecord struct {
+	Type       string                  `json:"type"`
+	KeyID      string                  `json:"key_id,omitempty"`
+	PublicKey  string                  `json:"public_key,omitempty"` // Ed25519 公钥（base64）
+	Checkpoint *models.AuditCheckpoint `json:"checkpoint,omitempty"`
+	Entry      *models.AuditLog        `json:"entry,omitempty"`
+}
+
+// AuditExportCSVHeader CSV 导出的列
+var AuditExportCSVHeader = []string{
+	"record_type", "id", "seq", "prev_hash", "hash", "created_at",
+	"actor_type", "actor_id", "actor_name", "app_id", "action", "target_type", "target_id",
+	"before", "after", "diff", "method", "path", "status_code", "result", "error", "ip", "user_agent",
+	"key_id", "signature", "public_key",
+}
+
+// CSVRow 将记录转换为 CSV 行（列顺序同 AuditExportCSVHeader）
+func (r *AuditExportRecord) CSVRow() []string {
+	row := make([]string, len(AuditExportCSVHeader))
+	row[0] = r.Type
+	switch r.Type {
+	case AuditRecordKey:
+		row[23] = r.KeyID
+		row[25] = r.PublicKey
+	case AuditRecordCheckpoint:
+		c := r.Checkpoint
+		row[1] = strconv.FormatUint(uint64(c.ID), 10)
+		row[2] = strconv.FormatUint(c.Seq, 10)
+		row[4] = c.Hash
+		row[5] = AuditTime(c.CreatedAt)
+		row[23] = c.KeyID
+		row[24] = c.Signature
+	case AuditRecordEntry:
+		e := r.Entry
+		copy(row[1:23], []string{
+			strconv.FormatUint(uint64(e.ID), 10), strconv.FormatUint(e.Seq, 10), e.PrevHash, e.Hash, AuditTime(e.CreatedAt),
+			e.ActorType, strconv.FormatUint(uint64(e.ActorID), 10), e.ActorName, e.AppID, e.Action, e.TargetType, e.TargetID,
+			e.Before, e.After, e.Diff, e.Method, e.Path, strconv.Itoa(e.StatusCode), e.Result, e.Error, e.IP, e.UserAgent,
+		})
+	}
+	return row
+}
+
+// parseAuditCSVRow 解析 CSV 行
+func parseAuditCSVRow(row []string) (*AuditExportRecord, error) {
+	if len(row) != len(AuditExportCSVHeader) {
+		return nil, fmt.Errorf("列数错误：%d", len(row))
+	}
+
+	record := &AuditExportRecord{Type: row[0]}
+	var err error
+	parseUint := func(s string) uint64 {
+		if err != nil || s == "" {
+			return 0
+		}
+		var v uint64
+		v, err = strconv.ParseUint(s, 10, 64)
+		return v
+	}
+	parseTime := func(s string) time.Time {
+		if err != nil {
+			return time.Time{}
+		}
+		var t time.Time
+		t, err = time.Parse(time.RFC3339Nano, s)
+		return t
+	}
+
+	switch record.Type {
+	case AuditRecordKey:
+		record.KeyID = row[23]
+		record.PublicKey = row[25]
+	case AuditRecordCheckpoint:
+		record.Checkpoint = &models.AuditCheckpoint{
+			ID:        uint(parseUint(row[1])),
+			Seq:       parseUint(row[2]),
+			Hash:      row[4],
+			CreatedAt: parseTime(row[5]),
+			KeyID:     row[23],
+			Signature: row[24],
+		}
+	case AuditRecordEntry:
+		record.Entry = &models.AuditLog{
+			ID:         uint(parseUint(row[1])),
+			Seq:        parseUint(row[2]),
+			PrevHash:   row[3],
+			Hash:       row[4],
+			CreatedAt:  parseTime(row[5]),
+			ActorType:  row[6],
+			ActorID:    uint(parseUint(row[7])),
+			ActorName:  row[8],
+			AppID:      row[9],
+			Action:     row[10],
+			TargetType: row[11],
+			TargetID:   row[12],
+			Before:     row[13],
+			After:      row[14],
+			Diff:       row[15],
+			Method:     row[16],
+			Path:       row[17],
+			StatusCode: int(parseUint(row[18])),
+			Result:     row[19],
+			Error:      row[20],
+			IP:         row[21],
+			UserAgent:  row[22],
+		}
+	default:
+		return nil, fmt.Errorf("未知的记录类型：%s", record.Type)
+	}
+	return record, err
+}
+
+// AuditExportWriter 按指定格式写出导出记录
+type AuditExportWriter struct {
+	format string
+	json   *json.Encoder
+	csv    *csv.Writer
+}
+
+// NewAuditExportWriter 创建导出写入器，CSV 格式会先写出表头
+func NewAuditExportWriter(w io.Writer, format string) (*AuditExportWriter, error) {
+	switch format {
+	case AuditExportJSONL:
+		return &AuditExportWriter{format: format, json: json.NewEncoder(w)}, nil
+	case AuditExportCSV:
+		writer := &AuditExportWriter{format: format, csv: csv.NewWriter(w)}
+		return writer, writer.csv.Write(AuditExportCSVHeader)
+	}
+	return nil, errors.New("不支持的导出格式：" + format)
+}
+
+// Write 写出一条记录
+func (w *AuditExportWriter) Write(record *AuditExportRecord) error {
+	if w.format == AuditExportCSV {
+		return w.csv.Write(record.CSVRow())
+	}
+	return w.json.Encode(record)
+}
+
+// Flush 刷新缓冲区
+func (w *AuditExportWriter) Flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		return w.csv.Error()
+	}
+	return nil
+}
+
+// VerifyAuditExport 校验导出文件
+// trustedKey 为 nil 时使用文件中携带的公钥（只能证明文件内部一致，应通过 key_id 与服务端公钥比对）
+// 返回校验器（包含校验条数和发现的问题）以及文件中的公钥ID
+func VerifyAuditExport(r io.Reader, format string, trustedKey ed25519.PublicKey) (*AuditChainVerifier, string, error) {
+	var verifier *AuditChainVerifier
+	var keyID string
+	if trustedKey != nil {
+		verifier = NewAuditChainVerifier(trustedKey)
+	}
+
+	handle := func(record *AuditExportRecord) error {
+		switch record.Type {
+		case AuditRecordKey:
+			keyID = record.KeyID
+			if verifier != nil {
+				return nil
+			}
+			raw, err := base64.StdEncoding.DecodeString(record.PublicKey)
+			if err != nil || len(raw) != ed25519.PublicKeySize {
+				return errors.New("导出文件中的公钥无效")
+			}
+			verifier = NewAuditChainVerifier(ed25519.PublicKey(raw))
+		case AuditRecordCheckpoint, AuditRecordEntry:
+			if verifier == nil {
+				return errors.New("导出文件缺少签名公钥")
+			}
+			if record.Checkpoint != nil {
+				verifier.AddCheckpoint(record.Checkpoint)
+			}
+			if record.Entry != nil {
+				verifier.Add(record.Entry)
+			}
+		default:
+			return fmt.Errorf("未知的记录类型：%s", record.Type)
+		}
+		return nil
+	}
+
+	switch format {
+	case AuditExportJSONL:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+			var record AuditExportRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				return nil, keyID, fmt.Errorf("第 %d 行解析失败: %v", line, err)
+			}
+			if err := handle(&record); err != nil {
+				return nil, keyID, fmt.Errorf("第 %d 行: %v", line, err)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, keyID, err
+		}
+	case AuditExportCSV:
+		reader := csv.NewReader(r)
+		if _, err := reader.Read(); err != nil {
+			return nil, keyID, fmt.Errorf("读取表头失败: %v", err)
+		}
+		for line := 2; ; line++ {
+			row, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, keyID, err
+			}
+			record, err := parseAuditCSVRow(row)
+			if err != nil {
+				return nil, keyID, fmt.Errorf("第 %d 行解析失败: %v", line, err)
+			}
+			if err := handle(record); err != nil {
+				return nil, keyID, fmt.Errorf("第 %d 行: %v", line, err)
+			}
+		}
+	default:
+		return nil, keyID, errors.New("不支持的导出格式：" + format)
+	}
+
+	if verifier == nil {
+		return nil, keyID, errors.New("导出文件缺少签名公钥")
+	}
+	verifier.Finish(0)
+	return verifier, keyID, nil
+}
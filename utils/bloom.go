@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+)
+
+// bloomMagic 布隆过滤器文件头标识
+var bloomMagic = [4]byte{'A', 'C', 'B', 'F'}
+
+const bloomVersion = 1
+
+// 文件头中参数的上限，避免损坏或恶意的文件头导致分配超大内存或每次检查耗时过长
+const (
+	bloomMaxBits   = 1 << 36 // 8 GiB 位数组，足以容纳十亿级泄露密码（误判率 0.1%）
+	bloomMaxHashes = 64
+	bloomHeaderLen = 25
+)
+
+// BloomFilter 布隆过滤器
+// 元素以 SHA-1 摘要表示，位置由摘要前 16 字节做双重哈希得到，
+// 因此既可以直接加入明文密码，也可以加入已有的 SHA-1 泄露密码哈希列表
+type BloomFilter struct {
+	m     uint64 // 位数组长度
+	k     uint32 // 哈希函数个数
+	count uint64 // 已加入的元素个数
+	bits  []uint64
+}
+
+// NewBloomFilter 按预计元素个数 n 和期望误判率 p 创建布隆过滤器
+func NewBloomFilter(n uint64, p float64) *BloomFilter {
+	if n == 0 {
+		n = 1
+	}
+	if p <= 0 || p >= 1 {
+		p = 0.001
+	}
+
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k := uint32(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	return &BloomFilter{
+		m:    m,
+		k:    k,
+		bits: make([]uint64, (m+63)/64),
+	}
+}
+
+// Add 加入明文
+func (f *BloomFilter) Add(value string) {
+	f.AddSHA1(sha1.Sum([]byte(value)))
+}
+
+// AddSHA1 加入 SHA-1 摘要
+func (f *BloomFilter) AddSHA1(digest [sha1.Size]byte) {
+	h1, h2 := bloomHashes(digest)
+	for i := uint32(0); i < f.k; i++ {
+		pos := (h1 + uint64(i)*h2) % f.m
+		f.bits[pos/64] |= 1 << (pos % 64)
+	}
+	f.count++
+}
+
+// Test 判断明文是否可能存在（存在误判，不存在漏判）
+func (f *BloomFilter) Test(value string) bool {
+	return f.TestSHA1(sha1.Sum([]byte(value)))
+}
+
+// TestSHA1 判断 SHA-1 摘要是否可能存在
+func (f *BloomFilter) TestSHA1(digest [sha1.Size]byte) bool {
+	h1, h2 := bloomHashes(digest)
+	for i := uint32(0); i < f.k; i++ {
+		pos := (h1 + uint64(i)*h2) % f.m
+		if f.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Count 已加入的元素个数
+func (f *BloomFilter) Count() uint64 {
+	return f.count
+}
+
+// WriteTo 将布隆过滤器写入 w
+func (f *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	header := make([]byte, 0, 25)
+	header = append(header, bloomMagic[:]...)
+	header = append(header, bloomVersion)
+	header = binary.LittleEndian.AppendUint32(header, f.k)
+	header = binary.LittleEndian.AppendUint64(header, f.m)
+	header = binary.LittleEndian.AppendUint64(header, f.count)
+	if _, err := bw.Write(header); err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, 8)
+	for _, word := range f.bits {
+		binary.LittleEndian.PutUint64(buf, word)
+		if _, err := bw.Write(buf); err != nil {
+			return 0, err
+		}
+	}
+
+	return int64(len(header) + 8*len(f.bits)), bw.Flush()
+}
+
+// ReadBloomFilter 从 r 读取布隆过滤器
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, bloomHeaderLen)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, err
+	}
+	if [4]byte(header[:4]) != bloomMagic || header[4] != bloomVersion {
+		return nil, errors.New("不是有效的布隆过滤器文件")
+	}
+
+	f := &BloomFilter{
+		k:     binary.LittleEndian.Uint32(header[5:9]),
+		m:     binary.LittleEndian.Uint64(header[9:17]),
+		count: binary.LittleEndian.Uint64(header[17:25]),
+	}
+	if f.k == 0 || f.m == 0 || f.k > bloomMaxHashes || f.m > bloomMaxBits {
+		return nil, errors.New("布隆过滤器参数无效")
+	}
+
+	f.bits = make([]uint64, (f.m+63)/64)
+	buf := make([]byte, 8)
+	for i := range f.bits {
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, err
+		}
+		f.bits[i] = binary.LittleEndian.Uint64(buf)
+	}
+
+	return f, nil
+}
+
+// LoadBloomFilter 从文件加载布隆过滤器
+func LoadBloomFilter(path string) (*BloomFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// 文件长度须与文件头声明的位数组长度一致，截断的文件在分配内存前即被拒绝
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, bloomHeaderLen)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return nil, err
+	}
+	if m := binary.LittleEndian.Uint64(header[9:17]); m <= bloomMaxBits && info.Size() != bloomHeaderLen+int64((m+63)/64)*8 {
+		return nil, errors.New("布隆过滤器文件长度与文件头不一致")
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return ReadBloomFilter(file)
+}
+
+// bloomHashes 由 SHA-1 摘要得到双重哈希的两个基值
+func bloomHashes(digest [sha1.Size]byte) (uint64, uint64) {
+	h1 := binary.BigEndian.Uint64(digest[0:8])
+	h2 := binary.BigEndian.Uint64(digest[8:16]) | 1 // 保证为奇数，避免步长为0
+	return h1, h2
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net/http"
+)
+
+// ClientCertInfo TLS 连接中客户端出示的证书
+type ClientCertInfo struct {
+	Thumbprint string // 证书 SHA-256 指纹（base64url，无填充），即 RFC 8705 的 x5t#S256
+	Subject    string // 证书主题（RFC 2253 格式）
+	Verified   bool   // 证书链是否已由配置的客户端 CA 验证
+}
+
+// CertThumbprint 计算证书的 SHA-256 指纹（base64url，无填充）
+func CertThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// ClientCertFromRequest 获取请求所在 TLS 连接的客户端证书，非 TLS 或未出示证书时返回 nil
+func ClientCertFromRequest(r *http.Request) *ClientCertInfo {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+
+	leaf := r.TLS.PeerCertificates[0]
+	return &ClientCertInfo{
+		Thumbprint: CertThumbprint(leaf),
+		Subject:    leaf.Subject.String(),
+		Verified:   len(r.TLS.VerifiedChains) > 0,
+	}
+}
+
+// ParseCertificatePEM 解析 PEM 格式的证书
+func ParseCertificatePEM(data string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("无效的 PEM 证书")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DPoPHeader DPoP 证明请求头
+const DPoPHeader = "DPoP"
+
+// DPoPNonceHeader 服务端下发 DPoP nonce 的响应头
+const DPoPNonceHeader = "DPoP-Nonce"
+
+// dpopAlgorithms 支持的 DPoP 证明签名算法（仅非对称算法）
+var dpopAlgorithms = []string{"ES256", "ES384", "RS256", "PS256", "EdDSA"}
+
+// DPoPProof 校验通过的 DPoP 证明
+type DPoPProof struct {
+	Thumbprint string    // 公钥 JWK 指纹（RFC 7638），即令牌 cnf.jkt
+	JTI        string    // 证明唯一ID，用于防重放
+	IssuedAt   time.Time // 证明签发时间
+	Nonce      string    // 服务端下发的 nonce
+}
+
+// dpopClaims DPoP 证明声明（RFC 9449 第 4.2 节）
+type dpopClaims struct {
+	Htm   string `json:"htm"`
+	Htu   string `json:"htu"`
+	Ath   string `json:"ath,omitempty"`
+	Nonce string `json:"nonce,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// JWK 公钥（仅包含计算指纹和验签所需的字段）
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	D   string `json:"d,omitempty"` // 私钥参数，出现即拒绝
+}
+
+// ParseDPoPProof 校验 DPoP 证明的格式、签名以及 htm、htu、ath 声明
+// 时间窗口、jti 重放和 nonce 由调用方校验；accessToken 不为空时要求 ath 与令牌哈希一致
+func ParseDPoPProof(proof, method, htu, accessToken string) (*DPoPProof, error) {
+	var jwk JWK
+	claims := &dpopClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods(dpopAlgorithms))
+	_, err := parser.ParseWithClaims(proof, claims, func(token *jwt.Token) (interface{}, error) {
+		if typ, _ := token.Header["typ"].(string); typ != "dpop+jwt" {
+			return nil, errors.New("DPoP 证明 typ 必须为 dpop+jwt")
+		}
+		raw, err := json.Marshal(token.Header["jwk"])
+		if err != nil || token.Header["jwk"] == nil {
+			return nil, errors.New("DPoP 证明缺少 jwk")
+		}
+		if err := json.Unmarshal(raw, &jwk); err != nil {
+			return nil, errors.New("DPoP 证明 jwk 无效")
+		}
+		if jwk.D != "" {
+			return nil, errors.New("DPoP 证明 jwk 不能包含私钥")
+		}
+		return jwk.PublicKey()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.ID == "" || claims.IssuedAt == nil {
+		return nil, errors.New("DPoP 证明缺少 jti 或 iat")
+	}
+	if !strings.EqualFold(claims.Htm, method) {
+		return nil, errors.New("DPoP 证明 htm 与请求方法不一致")
+	}
+	if normalizeHTU(claims.Htu) != normalizeHTU(htu) {
+		return nil, errors.New("DPoP 证明 htu 与请求地址不一致")
+	}
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		if claims.Ath != base64.RawURLEncoding.EncodeToString(sum[:]) {
+			return nil, errors.New("DPoP 证明 ath 与访问令牌不一致")
+		}
+	}
+
+	thumbprint, err := jwk.Thumbprint()
+	if err != nil {
+		return nil, err
+	}
+
+	return &DPoPProof{
+		Thumbprint: thumbprint,
+		JTI:        claims.ID,
+		IssuedAt:   claims.IssuedAt.Time,
+		Nonce:      claims.Nonce,
+	}, nil
+}
+
+// PublicKey 将 JWK 转换为公钥
+func (k *JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, errors.New("不支持的 EC 曲线: " + k.Crv)
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil {
+			return nil, errors.New("EC 公钥参数无效")
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("EC 公钥不在曲线上")
+		}
+		return pub, nil
+
+	case "RSA":
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("RSA 公钥参数无效")
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if pub.N.BitLen() < 2048 {
+			return nil, errors.New("RSA 公钥长度不能少于 2048 位")
+		}
+		return pub, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, errors.New("不支持的 OKP 曲线: " + k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("Ed25519 公钥参数无效")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, errors.New("不支持的密钥类型: " + k.Kty)
+}
+
+// Thumbprint 计算 JWK 指纹（RFC 7638）：按字典序仅保留必需成员的 JSON 的 SHA-256，base64url 无填充
+func (k *JWK) Thumbprint() (string, error) {
+	var canonical string
+	switch k.Kty {
+	case "EC":
+		canonical = `{"crv":` + jsonString(k.Crv) + `,"kty":"EC","x":` + jsonString(k.X) + `,"y":` + jsonString(k.Y) + `}`
+	case "RSA":
+		canonical = `{"e":` + jsonString(k.E) + `,"kty":"RSA","n":` + jsonString(k.N) + `}`
+	case "OKP":
+		canonical = `{"crv":` + jsonString(k.Crv) + `,"kty":"OKP","x":` + jsonString(k.X) + `}`
+	default:
+		return "", errors.New("不支持的密钥类型: " + k.Kty)
+	}
+
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// jsonString 将字符串编码为 JSON 字符串字面量
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// normalizeHTU 规范化 htu：去掉查询参数和片段，协议与主机名小写，省略默认端口
+func normalizeHTU(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Host)
+	if (scheme == "https" && strings.HasSuffix(host, ":443")) || (scheme == "http" && strings.HasSuffix(host, ":80")) {
+		host = host[:strings.LastIndex(host, ":")]
+	}
+	return scheme + "://" + host + u.EscapedPath()
+}
@@ -0,0 +1,356 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// GeoLocation IP 地理位置
+type GeoLocation struct {
+	Country   string  `json:"country"` // ISO 3166-1 国家代码
+	City      string  `json:"city"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// GeoIPReader MaxMind DB（.mmdb）格式的本地 GeoIP 数据库，兼容 GeoLite2-City / GeoIP2-City
+type GeoIPReader struct {
+	buf        []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	dataStart  uint
+	ipv4Start  uint
+}
+
+// mmdbMetadataMarker 元数据段起始标记
+var mmdbMetadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// mmdbDataSeparator 搜索树与数据段之间的 16 字节分隔
+const mmdbDataSeparator = 16
+
+// OpenGeoIP 读取 GeoIP 数据库文件
+func OpenGeoIP(path string) (*GeoIPReader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewGeoIPReader(buf)
+}
+
+// NewGeoIPReader 从内存中的数据库内容创建读取器
+func NewGeoIPReader(buf []byte) (*GeoIPReader, error) {
+	idx := bytes.LastIndex(buf, mmdbMetadataMarker)
+	if idx < 0 {
+		return nil, errors.New("不是有效的 MaxMind DB 文件")
+	}
+	metaStart := uint(idx + len(mmdbMetadataMarker))
+	meta, _, err := (&mmdbDecoder{buf: buf[metaStart:]}).decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("解析元数据失败: %v", err)
+	}
+	metadata, ok := meta.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("元数据格式错误")
+	}
+
+	r := &GeoIPReader{
+		buf:        buf,
+		nodeCount:  uint(mmdbUint(metadata["node_count"])),
+		recordSize: uint(mmdbUint(metadata["record_size"])),
+		ipVersion:  uint(mmdbUint(metadata["ip_version"])),
+	}
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("不支持的记录长度：%d", r.recordSize)
+	}
+	treeSize := r.recordSize * 2 / 8 * r.nodeCount
+	r.dataStart = treeSize + mmdbDataSeparator
+	if r.dataStart > metaStart {
+		return nil, errors.New("搜索树长度超出文件范围")
+	}
+
+	// IPv6 数据库中的 IPv4 地址位于 ::/96 子树下
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// Lookup 查询 IP 对应的地理位置，数据库中没有该地址时返回 nil
+func (r *GeoIPReader) Lookup(ip net.IP) (*GeoLocation, error) {
+	record, err := r.LookupRecord(ip)
+	if err != nil || record == nil {
+		return nil, err
+	}
+
+	loc := &GeoLocation{}
+	if country, ok := record["country"].(map[string]interface{}); ok {
+		loc.Country, _ = country["iso_code"].(string)
+	}
+	if city, ok := record["city"].(map[string]interface{}); ok {
+		if names, ok := city["names"].(map[string]interface{}); ok {
+			loc.City, _ = names["en"].(string)
+		}
+	}
+	if location, ok := record["location"].(map[string]interface{}); ok {
+		loc.Latitude, _ = location["latitude"].(float64)
+		loc.Longitude, _ = location["longitude"].(float64)
+	}
+	return loc, nil
+}
+
+// LookupRecord 查询 IP 对应的原始数据记录
+func (r *GeoIPReader) LookupRecord(ip net.IP) (map[string]interface{}, error) {
+	node := uint(0)
+	bitCount := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		bitCount = 32
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if ip = ip.To16(); ip == nil {
+		return nil, errors.New("无效的IP地址")
+	} else if r.ipVersion == 4 {
+		return nil, nil
+	}
+
+	for i := 0; i < bitCount && node < r.nodeCount; i++ {
+		bit := uint(ip[i/8]>>(7-uint(i%8))) & 1
+		node = r.record(node, bit)
+	}
+	if node <= r.nodeCount {
+		return nil, nil
+	}
+
+	offset := node - r.nodeCount - mmdbDataSeparator
+	value, _, err := (&mmdbDecoder{buf: r.buf[r.dataStart:]}).decode(offset)
+	if err != nil {
+		return nil, err
+	}
+	record, _ := value.(map[string]interface{})
+	return record, nil
+}
+
+// record 读取搜索树节点的左（bit=0）或右（bit=1）记录
+func (r *GeoIPReader) record(node, bit uint) uint {
+	b := r.buf
+	switch r.recordSize {
+	case 24:
+		off := node*6 + bit*3
+		return uint(b[off])<<16 | uint(b[off+1])<<8 | uint(b[off+2])
+	case 28:
+		off := node * 7
+		if bit == 0 {
+			return uint(b[off+3]&0xF0)<<20 | uint(b[off])<<16 | uint(b[off+1])<<8 | uint(b[off+2])
+		}
+		return uint(b[off+3]&0x0F)<<24 | uint(b[off+4])<<16 | uint(b[off+5])<<8 | uint(b[off+6])
+	default:
+		off := node*8 + bit*4
+		return uint(binary.BigEndian.Uint32(b[off : off+4]))
+	}
+}
+
+// mmdbDecoder MaxMind DB 数据段解码器，指针相对于 buf 起始位置
+type mmdbDecoder struct {
+	buf []byte
+}
+
+// MaxMind DB 数据类型
+const (
+	mmdbExtended = iota
+	mmdbPointer
+	mmdbString
+	mmdbDouble
+	mmdbBytes
+	mmdbUint16
+	mmdbUint32
+	mmdbMap
+	mmdbInt32
+	mmdbUint64
+	mmdbUint128
+	mmdbArray
+	mmdbContainer
+	mmdbEndMarker
+	mmdbBool
+	mmdbFloat
+)
+
+var errMMDBTruncated = errors.New("数据段不完整")
+
+// decode 解码 offset 处的值，返回值和下一个字段的偏移
+func (d *mmdbDecoder) decode(offset uint) (interface{}, uint, error) {
+	if offset >= uint(len(d.buf)) {
+		return nil, 0, errMMDBTruncated
+	}
+	ctrl := d.buf[offset]
+	offset++
+	typ := uint(ctrl >> 5)
+
+	if typ == mmdbPointer {
+		pointer, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(pointer)
+		return value, next, err
+	}
+
+	if typ == mmdbExtended {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, errMMDBTruncated
+		}
+		typ = 7 + uint(d.buf[offset])
+		offset++
+	}
+
+	size, offset, err := d.size(ctrl, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch typ {
+	case mmdbMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			value, next, err := d.decode(next)
+			if err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.New("映射的键不是字符串")
+			}
+			m[k] = value
+			offset = next
+		}
+		return m, offset, nil
+	case mmdbArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			value, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+			offset = next
+		}
+		return a, offset, nil
+	case mmdbBool:
+		return size != 0, offset, nil
+	case mmdbEndMarker, mmdbContainer:
+		return nil, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, errMMDBTruncated
+	}
+	raw := d.buf[offset : offset+size]
+	next := offset + size
+
+	switch typ {
+	case mmdbString:
+		return string(raw), next, nil
+	case mmdbBytes, mmdbUint128:
+		return append([]byte(nil), raw...), next, nil
+	case mmdbDouble:
+		if size != 8 {
+			return nil, 0, errors.New("double 长度错误")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(raw)), next, nil
+	case mmdbFloat:
+		if size != 4 {
+			return nil, 0, errors.New("float 长度错误")
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(raw))), next, nil
+	case mmdbUint16, mmdbUint32, mmdbUint64:
+		var v uint64
+		for _, b := range raw {
+			v = v<<8 | uint64(b)
+		}
+		return v, next, nil
+	case mmdbInt32:
+		var v uint32
+		for _, b := range raw {
+			v = v<<8 | uint32(b)
+		}
+		return int64(int32(v)), next, nil
+	}
+	return nil, 0, fmt.Errorf("未知的数据类型：%d", typ)
+}
+
+// size 解析控制字节中的长度字段
+func (d *mmdbDecoder) size(ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl & 0x1F)
+	if size < 29 {
+		return size, offset, nil
+	}
+	n := size - 28
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errMMDBTruncated
+	}
+	var v uint
+	for _, b := range d.buf[offset : offset+n] {
+		v = v<<8 | uint(b)
+	}
+	switch size {
+	case 29:
+		return 29 + v, offset + n, nil
+	case 30:
+		return 285 + v, offset + n, nil
+	default:
+		return 65821 + v, offset + n, nil
+	}
+}
+
+// pointer 解析指针，返回指向的偏移和指针之后的偏移
+func (d *mmdbDecoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	n := uint(ctrl>>3)&0x3 + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errMMDBTruncated
+	}
+	var v uint
+	if n < 4 {
+		v = uint(ctrl & 0x7)
+	}
+	for _, b := range d.buf[offset : offset+n] {
+		v = v<<8 | uint(b)
+	}
+	switch n {
+	case 2:
+		v += 2048
+	case 3:
+		v += 526336
+	}
+	return v, offset + n, nil
+}
+
+// mmdbUint 将元数据中的无符号整数转换为 uint64
+func mmdbUint(v interface{}) uint64 {
+	n, _ := v.(uint64)
+	return n
+}
+
+// GeoDistanceKm 计算两个经纬度之间的大圆距离（公里）
+func GeoDistanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371.0
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := rad(lat2 - lat1)
+	dLon := rad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
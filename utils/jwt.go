@@ -14,16 +14,36 @@ type JWTClaims struct {
 	AppID   string   `json:"app_id"`
 	Roles   []uint   `json:"roles"`
 	JTI     string   `json:"jti"` // JWT ID
+	OrgID   uint     `json:"org_id,omitempty"` // 当前组织，0 表示不在组织内
+	Cnf     *Confirmation `json:"cnf,omitempty"` // 令牌绑定信息，为空表示普通 Bearer 令牌
 	jwt.RegisteredClaims
 }
 
+// Confirmation 令牌持有者证明（RFC 7800 cnf 声明）
+type Confirmation struct {
+	X5tS256 string `json:"x5t#S256,omitempty"` // 客户端证书 SHA-256 指纹（RFC 8705）
+	Jkt     string `json:"jkt,omitempty"`      // DPoP 公钥 JWK 指纹（RFC 9449）
+}
+
 // GenerateAccessToken 生成访问令牌
 func GenerateAccessToken(userID uint, appID string, roles []uint) (string, error) {
+	return GenerateBoundAccessToken(userID, appID, roles, nil)
+}
+
+// GenerateBoundAccessToken 生成绑定到持有者证明的访问令牌，cnf 为空时等同于 GenerateAccessToken
+func GenerateBoundAccessToken(userID uint, appID string, roles []uint, cnf *Confirmation) (string, error) {
+	return GenerateOrgAccessToken(userID, appID, 0, roles, cnf)
+}
+
+// GenerateOrgAccessToken 生成以 orgID 为当前组织的访问令牌，orgID 为 0 时等同于 GenerateBoundAccessToken
+func GenerateOrgAccessToken(userID uint, appID string, orgID uint, roles []uint, cnf *Confirmation) (string, error) {
 	claims := JWTClaims{
 		UserID: userID,
 		AppID:  appID,
 		Roles:  roles,
 		JTI:    generateJTI(),
+		OrgID:  orgID,
+		Cnf:    cnf,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(config.GetConfig().JWT.TTL) * time.Second)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -39,12 +59,29 @@ func GenerateAccessToken(userID uint, appID string, roles []uint) (string, error
 
 // GenerateRefreshToken 生成刷新令牌
 func GenerateRefreshToken(userID uint, appID string) (string, error) {
+	return GenerateBoundRefreshToken(userID, appID, nil)
+}
+
+// GenerateBoundRefreshToken 生成绑定到持有者证明的刷新令牌，刷新时须出示相同的证明
+func GenerateBoundRefreshToken(userID uint, appID string, cnf *Confirmation) (string, error) {
+	return GenerateOrgRefreshToken(userID, appID, 0, cnf)
+}
+
+// GenerateOrgRefreshToken 生成以 orgID 为当前组织的刷新令牌，刷新后的令牌保持该组织
+func GenerateOrgRefreshToken(userID uint, appID string, orgID uint, cnf *Confirmation) (string, error) {
+	return GenerateOrgRefreshTokenUntil(userID, appID, orgID, cnf, time.Now().Add(time.Duration(config.GetConfig().JWT.RefreshTTL)*time.Second))
+}
+
+// GenerateOrgRefreshTokenUntil 生成在 expiresAt 过期的刷新令牌，用于替换原刷新令牌而不延长会话
+func GenerateOrgRefreshTokenUntil(userID uint, appID string, orgID uint, cnf *Confirmation, expiresAt time.Time) (string, error) {
 	claims := JWTClaims{
 		UserID: userID,
 		AppID:  appID,
 		JTI:    generateJTI(),
+		OrgID:  orgID,
+		Cnf:    cnf,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(config.GetConfig().JWT.RefreshTTL) * time.Second)),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "auth-center",
@@ -1,41 +1,137 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"auth-center/config"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
 )
 
 // DefaultCost bcrypt 默认成本因子（范围 4-31，值越大计算越慢，安全性越高）
 const DefaultCost = 10
 
-// HashPassword 使用 bcrypt 哈希密码
+// 密码哈希算法标识
+const (
+	HashAlgorithmBcrypt   = "bcrypt"
+	HashAlgorithmArgon2id = "argon2id"
+)
+
+// ErrUnknownHashFormat 无法识别的密码哈希格式
+var ErrUnknownHashFormat = errors.New("无法识别的密码哈希格式")
+
+// PasswordHasher 密码哈希算法
+// 每种算法的编码结果都以 "$算法标识$" 开头，验证时据此选择算法
+type PasswordHasher interface {
+	// Matches 判断编码后的哈希是否由该算法生成
+	Matches(encodedHash string) bool
+	// Hash 生成编码后的哈希
+	Hash(password string) (string, error)
+	// Verify 验证明文密码与编码后的哈希是否匹配
+	Verify(password, encodedHash string) (bool, error)
+	// NeedsRehash 判断编码后的哈希参数是否落后于当前配置
+	NeedsRehash(encodedHash string) bool
+}
+
+// HashPassword 使用当前配置的算法哈希密码
 // 参数：password 原始明文密码
-// 返回：加密后的哈希字符串 / 错误信息
+// 返回：编码后的哈希字符串（包含算法标识、参数和盐值） / 错误信息
 func HashPassword(password string) (string, error) {
-	// 1. 生成 bcrypt 哈希（自动生成 16 字节随机盐）
-	// GenerateFromPassword 会自动处理盐值和成本因子
-	hashBytes, err := bcrypt.GenerateFromPassword(
-		[]byte(password), // 原始密码字节
-		DefaultCost,      // 成本因子（推荐 10-12，平衡安全与性能）
-	)
+	return currentHasher().Hash(password)
+}
+
+// VerifyPassword 根据哈希中的算法标识验证明文密码是否匹配
+// 参数：password 明文密码 / encodedHash 存储的哈希
+// 返回：是否匹配 / 错误信息
+func VerifyPassword(password, encodedHash string) (bool, error) {
+	hasher := hasherFor(encodedHash)
+	if hasher == nil {
+		return false, ErrUnknownHashFormat
+	}
+	return hasher.Verify(password, encodedHash)
+}
+
+// PasswordNeedsRehash 判断存储的哈希是否需要升级（算法不同、参数落后或为导入的旧式哈希）
+func PasswordNeedsRehash(encodedHash string) bool {
+	current := currentHasher()
+	if !current.Matches(encodedHash) {
+		return true
+	}
+	return current.NeedsRehash(encodedHash)
+}
+
+// currentHasher 根据配置返回新密码使用的哈希算法
+func currentHasher() PasswordHasher {
+	cfg := config.GetConfig()
+	if cfg == nil {
+		return &bcryptHasher{cost: DefaultCost}
+	}
+
+	if cfg.Password.Algorithm == HashAlgorithmArgon2id &&
+		cfg.Password.Argon2Memory > 0 && cfg.Password.Argon2Iterations > 0 && cfg.Password.Argon2Parallelism > 0 {
+		return &argon2idHasher{
+			memory:      cfg.Password.Argon2Memory,
+			iterations:  cfg.Password.Argon2Iterations,
+			parallelism: cfg.Password.Argon2Parallelism,
+		}
+	}
+
+	cost := cfg.Password.BcryptCost
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = DefaultCost
+	}
+	return &bcryptHasher{cost: cost}
+}
+
+// hasherFor 根据哈希前缀选择验证算法，参数以哈希中记录的为准
+func hasherFor(encodedHash string) PasswordHasher {
+	for _, hasher := range []PasswordHasher{
+		&bcryptHasher{},
+		&argon2idHasher{},
+		&pbkdf2Hasher{},
+		&saltedSHAHasher{},
+	} {
+		if hasher.Matches(encodedHash) {
+			return hasher
+		}
+	}
+	return nil
+}
+
+// bcryptHasher bcrypt 算法，编码格式为 "$2a$10$盐值哈希"
+type bcryptHasher struct {
+	cost int
+}
+
+func (h *bcryptHasher) Matches(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$2a$") ||
+		strings.HasPrefix(encodedHash, "$2b$") ||
+		strings.HasPrefix(encodedHash, "$2y$")
+}
+
+func (h *bcryptHasher) Hash(password string) (string, error) {
+	// GenerateFromPassword 会自动生成 16 字节随机盐
+	hashBytes, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
 	if err != nil {
 		return "", err
 	}
-
-	// 2. 直接返回哈希字符串（bcrypt 哈希已包含盐值和成本因子，格式为 "$2a$10$盐值$哈希"）
 	return string(hashBytes), nil
 }
 
-// VerifyPassword 验证 bcrypt 哈希与明文密码是否匹配
-// 参数：password 明文密码 / encodedHash 存储的 bcrypt 哈希
-// 返回：是否匹配 / 错误信息
-func VerifyPassword(password, encodedHash string) (bool, error) {
-	// 1. 调用 CompareHashAndPassword 验证（自动解析哈希中的盐值和成本因子）
-	err := bcrypt.CompareHashAndPassword(
-		[]byte(encodedHash), // 存储的哈希字节
-		[]byte(password),    // 待验证的明文密码字节
-	)
-
-	// 2. 处理结果（err 为 nil 表示验证成功）
+func (h *bcryptHasher) Verify(password, encodedHash string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password))
 	if err == bcrypt.ErrMismatchedHashAndPassword {
 		// 密码不匹配
 		return false, nil
@@ -43,3 +139,336 @@ func VerifyPassword(password, encodedHash string) (bool, error) {
 	// 其他错误（如哈希格式无效）
 	return err == nil, err
 }
+
+func (h *bcryptHasher) NeedsRehash(encodedHash string) bool {
+	cost, err := bcrypt.Cost([]byte(encodedHash))
+	return err != nil || cost != h.cost
+}
+
+// argon2idHasher Argon2id 算法，编码格式遵循 PHC 规范：
+// "$argon2id$v=19$m=65536,t=3,p=2$盐值$哈希"（盐值和哈希为无填充 base64）
+type argon2idHasher struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// 可接受的 Argon2id 参数范围。哈希中的参数来自导入的数据，超出范围时拒绝验证，
+// 避免非法参数使 argon2.IDKey panic，或过大的参数在登录时耗尽内存和 CPU
+const (
+	argon2MaxMemory      = 4 * 1024 * 1024 // KiB，即 4 GiB
+	argon2MaxIterations  = 64
+	argon2MaxParallelism = 64
+	argon2MinSaltLength  = 8
+	argon2MinKeyLength   = 16
+	argon2MaxKeyLength   = 128
+)
+
+func (h *argon2idHasher) Matches(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$argon2id$")
+}
+
+func (h *argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.iterations, h.memory, h.parallelism, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.memory, h.iterations, h.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *argon2idHasher) Verify(password, encodedHash string) (bool, error) {
+	params, salt, key, err := h.decode(encodedHash)
+	if err != nil {
+		return false, err
+	}
+
+	actual := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(actual, key) == 1, nil
+}
+
+func (h *argon2idHasher) NeedsRehash(encodedHash string) bool {
+	params, _, _, err := h.decode(encodedHash)
+	return err != nil || params.memory != h.memory || params.iterations != h.iterations || params.parallelism != h.parallelism
+}
+
+// decode 解析 PHC 格式的 Argon2id 哈希
+func (h *argon2idHasher) decode(encodedHash string) (*argon2idHasher, []byte, []byte, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 {
+		return nil, nil, nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, errors.New("不支持的 argon2 版本")
+	}
+
+	params := &argon2idHasher{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return nil, nil, nil, ErrUnknownHashFormat
+	}
+	if err := params.validate(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(salt) < argon2MinSaltLength {
+		return nil, nil, nil, ErrUnknownHashFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) < argon2MinKeyLength || len(key) > argon2MaxKeyLength {
+		return nil, nil, nil, ErrUnknownHashFormat
+	}
+
+	return params, salt, key, nil
+}
+
+// validate 校验 Argon2id 参数：t≥1、p≥1、m≥8·p（RFC 9106 的要求），且不超过上限
+func (h *argon2idHasher) validate() error {
+	if h.iterations < 1 || h.parallelism < 1 || h.memory < 8*uint32(h.parallelism) {
+		return errors.New("argon2id 参数无效")
+	}
+	if h.memory > argon2MaxMemory || h.iterations > argon2MaxIterations || h.parallelism > argon2MaxParallelism {
+		return errors.New("argon2id 参数超出允许范围")
+	}
+	return nil
+}
+
+// pbkdf2Hasher PBKDF2 旧式哈希（仅用于验证导入的用户，登录后自动升级）
+// 编码格式："$pbkdf2-sha256$i=迭代次数$盐值$哈希"（盐值和哈希为无填充 base64）
+type pbkdf2Hasher struct{}
+
+// 可接受的 PBKDF2 参数范围，迭代次数和哈希长度过大时每次登录都会消耗大量 CPU
+const (
+	pbkdf2MaxIterations = 10_000_000
+	pbkdf2MinKeyLength  = 16
+	pbkdf2MaxKeyLength  = 64
+)
+
+// validatePBKDF2Params 校验 PBKDF2 迭代次数和哈希长度
+func validatePBKDF2Params(iterations, keyLength int) error {
+	if iterations <= 0 || iterations > pbkdf2MaxIterations {
+		return fmt.Errorf("PBKDF2 迭代次数应在 1-%d 之间", pbkdf2MaxIterations)
+	}
+	if keyLength < pbkdf2MinKeyLength || keyLength > pbkdf2MaxKeyLength {
+		return fmt.Errorf("PBKDF2 哈希长度应在 %d-%d 字节之间", pbkdf2MinKeyLength, pbkdf2MaxKeyLength)
+	}
+	return nil
+}
+
+func (h *pbkdf2Hasher) Matches(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$pbkdf2-")
+}
+
+func (h *pbkdf2Hasher) Hash(password string) (string, error) {
+	return "", errors.New("PBKDF2 仅用于验证导入的旧式哈希")
+}
+
+func (h *pbkdf2Hasher) Verify(password, encodedHash string) (bool, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 5 {
+		return false, ErrUnknownHashFormat
+	}
+
+	newHash := shaByName(strings.TrimPrefix(parts[1], "pbkdf2-"))
+	iterations, err := strconv.Atoi(strings.TrimPrefix(parts[2], "i="))
+	if newHash == nil || err != nil || iterations <= 0 {
+		return false, ErrUnknownHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false, ErrUnknownHashFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, ErrUnknownHashFormat
+	}
+	if err := validatePBKDF2Params(iterations, len(key)); err != nil {
+		return false, err
+	}
+
+	actual := pbkdf2.Key([]byte(password), salt, iterations, len(key), newHash)
+	return subtle.ConstantTimeCompare(actual, key) == 1, nil
+}
+
+func (h *pbkdf2Hasher) NeedsRehash(encodedHash string) bool {
+	return true
+}
+
+// saltedSHAHasher 加盐 SHA 旧式哈希（仅用于验证导入的用户，登录后自动升级）
+// 编码格式："$salted-sha256$pos=prefix$盐值$哈希"，pos 表示盐值拼接在密码之前（prefix）或之后（suffix）
+type saltedSHAHasher struct{}
+
+func (h *saltedSHAHasher) Matches(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$salted-")
+}
+
+func (h *saltedSHAHasher) Hash(password string) (string, error) {
+	return "", errors.New("加盐 SHA 仅用于验证导入的旧式哈希")
+}
+
+func (h *saltedSHAHasher) Verify(password, encodedHash string) (bool, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 5 {
+		return false, ErrUnknownHashFormat
+	}
+
+	newHash := shaByName(strings.TrimPrefix(parts[1], "salted-"))
+	position := strings.TrimPrefix(parts[2], "pos=")
+	if newHash == nil || (position != "prefix" && position != "suffix") {
+		return false, ErrUnknownHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false, ErrUnknownHashFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, ErrUnknownHashFormat
+	}
+
+	digest := newHash()
+	if position == "prefix" {
+		digest.Write(salt)
+		digest.Write([]byte(password))
+	} else {
+		digest.Write([]byte(password))
+		digest.Write(salt)
+	}
+	return subtle.ConstantTimeCompare(digest.Sum(nil), key) == 1, nil
+}
+
+func (h *saltedSHAHasher) NeedsRehash(encodedHash string) bool {
+	return true
+}
+
+// LegacyHash 从其他系统迁移用户时提供的旧式密码哈希
+type LegacyHash struct {
+	Format       string `json:"format"`        // bcrypt, argon2id, pbkdf2-sha1, pbkdf2-sha256, pbkdf2-sha512, salted-sha1, salted-sha256, salted-sha512
+	Hash         string `json:"hash"`          // 哈希值（bcrypt/argon2id 为完整编码，其余为十六进制或 base64）
+	Salt         string `json:"salt"`          // 盐值
+	SaltEncoding string `json:"salt_encoding"` // 盐值编码：raw（默认）、hex、base64
+	SaltPosition string `json:"salt_position"` // 加盐 SHA 的盐值位置：prefix（默认）、suffix
+	Iterations   int    `json:"iterations"`    // PBKDF2 迭代次数
+}
+
+// EncodeLegacyHash 将旧式哈希转换为本系统可验证的编码格式
+func EncodeLegacyHash(legacy *LegacyHash) (string, error) {
+	switch {
+	case legacy.Format == HashAlgorithmBcrypt:
+		if _, err := bcrypt.Cost([]byte(legacy.Hash)); err != nil || !(&bcryptHasher{}).Matches(legacy.Hash) {
+			return "", ErrUnknownHashFormat
+		}
+		return legacy.Hash, nil
+
+	case legacy.Format == HashAlgorithmArgon2id:
+		hasher := &argon2idHasher{}
+		if !hasher.Matches(legacy.Hash) {
+			return "", ErrUnknownHashFormat
+		}
+		if _, _, _, err := hasher.decode(legacy.Hash); err != nil {
+			return "", err
+		}
+		return legacy.Hash, nil
+
+	case strings.HasPrefix(legacy.Format, "pbkdf2-"):
+		if shaByName(strings.TrimPrefix(legacy.Format, "pbkdf2-")) == nil {
+			return "", fmt.Errorf("不支持的哈希格式: %s", legacy.Format)
+		}
+		if legacy.Iterations <= 0 {
+			return "", errors.New("PBKDF2 哈希必须指定迭代次数")
+		}
+		salt, key, err := decodeLegacySaltAndHash(legacy)
+		if err != nil {
+			return "", err
+		}
+		if err := validatePBKDF2Params(legacy.Iterations, len(key)); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("$%s$i=%d$%s$%s", legacy.Format, legacy.Iterations,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+
+	case strings.HasPrefix(legacy.Format, "salted-"):
+		newHash := shaByName(strings.TrimPrefix(legacy.Format, "salted-"))
+		if newHash == nil {
+			return "", fmt.Errorf("不支持的哈希格式: %s", legacy.Format)
+		}
+		position := legacy.SaltPosition
+		if position == "" {
+			position = "prefix"
+		}
+		if position != "prefix" && position != "suffix" {
+			return "", errors.New("盐值位置只能为 prefix 或 suffix")
+		}
+		salt, key, err := decodeLegacySaltAndHash(legacy)
+		if err != nil {
+			return "", err
+		}
+		if len(key) != newHash().Size() {
+			return "", errors.New("哈希长度与算法不匹配")
+		}
+		return fmt.Sprintf("$%s$pos=%s$%s$%s", legacy.Format, position,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+
+	return "", fmt.Errorf("不支持的哈希格式: %s", legacy.Format)
+}
+
+// decodeLegacySaltAndHash 解码旧式哈希的盐值（按 SaltEncoding）和哈希值（十六进制或 base64）
+func decodeLegacySaltAndHash(legacy *LegacyHash) ([]byte, []byte, error) {
+	var salt []byte
+	var err error
+	switch legacy.SaltEncoding {
+	case "", "raw":
+		salt = []byte(legacy.Salt)
+	case "hex":
+		salt, err = hex.DecodeString(legacy.Salt)
+	case "base64":
+		salt, err = decodeBase64(legacy.Salt)
+	default:
+		return nil, nil, errors.New("盐值编码只能为 raw、hex 或 base64")
+	}
+	if err != nil {
+		return nil, nil, errors.New("盐值解码失败")
+	}
+
+	key, err := hex.DecodeString(legacy.Hash)
+	if err != nil {
+		key, err = decodeBase64(legacy.Hash)
+	}
+	if err != nil || len(key) == 0 {
+		return nil, nil, errors.New("哈希值解码失败")
+	}
+
+	return salt, key, nil
+}
+
+// decodeBase64 兼容有无填充的标准 base64
+func decodeBase64(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// shaByName 根据名称返回 SHA 哈希构造函数
+func shaByName(name string) func() hash.Hash {
+	switch name {
+	case "sha1":
+		return sha1.New
+	case "sha256":
+		return sha256.New
+	case "sha512":
+		return sha512.New
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"auth-center/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimitResult 限流检查结果
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int64
+	Remaining int64
+	ResetIn   time.Duration // 窗口内最早一次请求移出窗口的剩余时间
+}
+
+// slidingWindowScript 基于有序集合的滑动窗口限流脚本
+// KEYS[1]: 限流键
+// ARGV[1]: 当前时间（毫秒） ARGV[2]: 窗口长度（毫秒） ARGV[3]: 限额 ARGV[4]: 本次请求的唯一成员
+// 返回 {是否放行, 剩余次数, 距离重置的毫秒数}
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	redis.call('PEXPIRE', key, window)
+	count = count + 1
+	allowed = 1
+end
+
+local reset = window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+
+return {allowed, limit - count, reset}
+`)
+
+// SlidingWindowAllow 滑动窗口限流：判断 key 在 window 内的请求数是否超过 limit，未超过则记录本次请求
+func SlidingWindowAllow(key string, limit int64, window time.Duration) (*RateLimitResult, error) {
+	if config.RedisClient == nil {
+		return nil, errors.New("redis client is nil (not initialized)")
+	}
+
+	now := time.Now().UnixMilli()
+	member := strconv.FormatInt(now, 10) + "-" + GenerateShortCode(8)
+	values, err := slidingWindowScript.Run(context.Background(), config.RedisClient,
+		[]string{key}, now, window.Milliseconds(), limit, member).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := values[1]
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return &RateLimitResult{
+		Allowed:   values[0] == 1,
+		Limit:     limit,
+		Remaining: remaining,
+		ResetIn:   time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"auth-center/config"
+
+	"github.com/redis/go-redis/v9"
 )
 
 // Set 设置键值对
@@ -32,6 +34,14 @@ func Del(key string) error {
 	return config.RedisClient.Del(context.Background(), key).Err()
 }
 
+// GetDel 获取值并删除键（原子操作），并发调用时只有一个调用方能取到值
+func GetDel(key string) (string, error) {
+	if config.RedisClient == nil {
+		return "", errors.New("redis client is nil (not initialized)")
+	}
+	return config.RedisClient.GetDel(context.Background(), key).Result()
+}
+
 // Exists 检查键是否存在
 func Exists(key string) (bool, error) {
 	if config.RedisClient == nil {
@@ -49,6 +59,14 @@ func Expire(key string, expiration time.Duration) error {
 	return config.RedisClient.Expire(context.Background(), key, expiration).Err()
 }
 
+// TTL 获取键的剩余过期时间
+func TTL(key string) (time.Duration, error) {
+	if config.RedisClient == nil {
+		return 0, errors.New("redis client is nil (not initialized)")
+	}
+	return config.RedisClient.TTL(context.Background(), key).Result()
+}
+
 // SAdd 向集合添加成员
 func SAdd(key string, members ...interface{}) error {
 	if config.RedisClient == nil {
@@ -137,11 +155,73 @@ func LLen(key string) (int64, error) {
 	return config.RedisClient.LLen(context.Background(), key).Result()
 }
 
+// SetNX 键不存在时设置值，返回是否设置成功
+func SetNX(key string, value interface{}, expiration time.Duration) (bool, error) {
+	if config.RedisClient == nil {
+		return false, errors.New("redis client is nil (not initialized)")
+	}
+	return config.RedisClient.SetNX(context.Background(), key, value, expiration).Result()
+}
+
+// MGet 批量获取值，不存在的键对应 nil
+func MGet(keys ...string) ([]interface{}, error) {
+	if config.RedisClient == nil {
+		return nil, errors.New("redis client is nil (not initialized)")
+	}
+	return config.RedisClient.MGet(context.Background(), keys...).Result()
+}
+
+// IncrAll 在一个管道中将多个键的值加 1，返回各键递增后的值
+func IncrAll(keys ...string) ([]int64, error) {
+	if config.RedisClient == nil {
+		return nil, errors.New("redis client is nil (not initialized)")
+	}
+	pipe := config.RedisClient.TxPipeline()
+	cmds := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Incr(context.Background(), key)
+	}
+	if _, err := pipe.Exec(context.Background()); err != nil {
+		return nil, err
+	}
+	values := make([]int64, len(keys))
+	for i, cmd := range cmds {
+		values[i] = cmd.Val()
+	}
+	return values, nil
+}
+
+// Publish 向频道发布消息
+func Publish(channel string, message interface{}) error {
+	if config.RedisClient == nil {
+		return errors.New("redis client is nil (not initialized)")
+	}
+	return config.RedisClient.Publish(context.Background(), channel, message).Err()
+}
+
+// Subscribe 订阅频道，断线后自动重新订阅
+func Subscribe(channels ...string) (*redis.PubSub, error) {
+	if config.RedisClient == nil {
+		return nil, errors.New("redis client is nil (not initialized)")
+	}
+	return config.RedisClient.Subscribe(context.Background(), channels...), nil
+}
+
 // 缓存键前缀常量
 const (
 	TokenBlacklistPrefix = "token:blacklist:"
-	UserPermissionPrefix = "user:permission:"
 	RolePermissionPrefix = "role:permission:"
 	APIPermissionPrefix  = "api:permission:"
 	AppConfigPrefix      = "app:config:"
+	RateLimitPrefix      = "ratelimit:"
+	SignatureNoncePrefix = "signature:nonce:"
+	DPoPJTIPrefix        = "dpop:jti:"
+
+	PermissionVersionPrefix = "permission:version:" // 权限缓存版本号
 )
+
+// PermissionInvalidateChannel 权限缓存失效通知频道
+const PermissionInvalidateChannel = "permission:invalidate"
+
+// AssignmentEventChannel 角色分配和角色权限分配生效、到期事件的频道
+const AssignmentEventChannel = "assignment:events"
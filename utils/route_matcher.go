@@ -0,0 +1,237 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// RouteMethodAny 匹配任意 HTTP 方法（规则中写作 ANY 或 *）
+const RouteMethodAny = "*"
+
+// RouteRule API 访问规则
+type RouteRule struct {
+	ID           uint
+	Path         string
+	Method       string
+	PermissionID uint
+}
+
+// RouteMatcher 按路径段组织的前缀树，用于把请求路径和方法匹配到最具体的 API 规则
+//
+// 路径模式按 / 分段，每段可以是：
+//   - 静态段：/orders
+//   - 参数段：/orders/:id、/orders/{id} 或 /orders/*，匹配任意一段
+//   - 通配段：/reports/*.csv、/v?/users，按 path.Match 规则匹配一段
+//   - 前缀通配（只能位于末尾）：/reports/** 或 /static/*filepath，匹配剩余的零段或多段
+//
+// 匹配时逐段优先选择静态段，其次通配段、参数段、前缀通配；同一路径下精确方法优先于 ANY。
+// 最具体的路径没有对应方法的规则时，继续尝试次具体的路径
+type RouteMatcher struct {
+	root *routeNode
+}
+
+// routeSegmentKind 路径段类型，按匹配优先级排列
+type routeSegmentKind int
+
+const (
+	routeStatic routeSegmentKind = iota
+	routeGlob
+	routeParam
+	routeCatchAll
+)
+
+type routeNode struct {
+	static   map[string]*routeNode
+	globs    []*routeNode
+	glob     string // 通配段节点的模式
+	param    *routeNode
+	catchAll *routeNode
+	rules    map[string][]*RouteRule // 方法 -> 规则
+}
+
+func newRouteNode() *routeNode {
+	return &routeNode{static: map[string]*routeNode{}}
+}
+
+// NewRouteMatcher 创建空的路由匹配器
+func NewRouteMatcher() *RouteMatcher {
+	return &RouteMatcher{root: newRouteNode()}
+}
+
+// splitRoutePath 按 / 分段，忽略空段（首尾和重复的 /）
+func splitRoutePath(p string) []string {
+	parts := strings.Split(p, "/")
+	segments := parts[:0]
+	for _, part := range parts {
+		if part != "" {
+			segments = append(segments, part)
+		}
+	}
+	return segments
+}
+
+// classifyRouteSegment 判断模式中一段的类型
+func classifyRouteSegment(segment string) (routeSegmentKind, error) {
+	switch {
+	case segment == "**" || (strings.HasPrefix(segment, "*") && isRouteParamName(segment[1:])):
+		return routeCatchAll, nil
+	case segment == "*" || strings.HasPrefix(segment, ":") ||
+		(strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")):
+		return routeParam, nil
+	case strings.ContainsAny(segment, "*?["):
+		if _, err := path.Match(segment, ""); err != nil {
+			return 0, fmt.Errorf("通配符格式错误：%s", segment)
+		}
+		return routeGlob, nil
+	}
+	return routeStatic, nil
+}
+
+// isRouteParamName 是否为参数名（字母、数字、下划线）
+func isRouteParamName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizeRouteMethod 规范化规则中的 HTTP 方法，ANY 和 * 表示任意方法
+func NormalizeRouteMethod(method string) string {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if method == "ANY" {
+		return RouteMethodAny
+	}
+	return method
+}
+
+// ValidateRoutePattern 校验路径模式
+func ValidateRoutePattern(pattern string) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return errors.New("路径必须以 / 开头")
+	}
+	segments := splitRoutePath(pattern)
+	for i, segment := range segments {
+		kind, err := classifyRouteSegment(segment)
+		if err != nil {
+			return err
+		}
+		if kind == routeCatchAll && i != len(segments)-1 {
+			return errors.New("前缀通配只能位于路径末尾")
+		}
+	}
+	return nil
+}
+
+// Add 添加规则
+func (m *RouteMatcher) Add(rule *RouteRule) error {
+	if err := ValidateRoutePattern(rule.Path); err != nil {
+		return err
+	}
+	method := NormalizeRouteMethod(rule.Method)
+	if method == "" {
+		return errors.New("HTTP方法不能为空")
+	}
+
+	node := m.root
+	for _, segment := range splitRoutePath(rule.Path) {
+		kind, _ := classifyRouteSegment(segment)
+		switch kind {
+		case routeStatic:
+			child, ok := node.static[segment]
+			if !ok {
+				child = newRouteNode()
+				node.static[segment] = child
+			}
+			node = child
+		case routeGlob:
+			var child *routeNode
+			for _, g := range node.globs {
+				if g.glob == segment {
+					child = g
+					break
+				}
+			}
+			if child == nil {
+				child = newRouteNode()
+				child.glob = segment
+				node.globs = append(node.globs, child)
+			}
+			node = child
+		case routeParam:
+			if node.param == nil {
+				node.param = newRouteNode()
+			}
+			node = node.param
+		case routeCatchAll:
+			if node.catchAll == nil {
+				node.catchAll = newRouteNode()
+			}
+			node = node.catchAll
+		}
+	}
+
+	if node.rules == nil {
+		node.rules = map[string][]*RouteRule{}
+	}
+	node.rules[method] = append(node.rules[method], rule)
+	return nil
+}
+
+// Match 返回与请求路径和方法匹配的最具体规则（同一模式下可能有多条等价规则），没有匹配时返回 nil
+func (m *RouteMatcher) Match(requestPath, method string) []*RouteRule {
+	return m.root.match(splitRoutePath(requestPath), NormalizeRouteMethod(method))
+}
+
+func (n *routeNode) match(segments []string, method string) []*RouteRule {
+	if len(segments) == 0 {
+		if rules := n.methodRules(method); rules != nil {
+			return rules
+		}
+		// 前缀通配可以匹配零段
+		if n.catchAll != nil {
+			return n.catchAll.methodRules(method)
+		}
+		return nil
+	}
+
+	segment, rest := segments[0], segments[1:]
+	if child, ok := n.static[segment]; ok {
+		if rules := child.match(rest, method); rules != nil {
+			return rules
+		}
+	}
+	for _, child := range n.globs {
+		if ok, _ := path.Match(child.glob, segment); ok {
+			if rules := child.match(rest, method); rules != nil {
+				return rules
+			}
+		}
+	}
+	if n.param != nil {
+		if rules := n.param.match(rest, method); rules != nil {
+			return rules
+		}
+	}
+	if n.catchAll != nil {
+		return n.catchAll.methodRules(method)
+	}
+	return nil
+}
+
+// methodRules 节点上与方法匹配的规则，精确方法优先于 ANY
+func (n *routeNode) methodRules(method string) []*RouteRule {
+	if rules := n.rules[method]; len(rules) > 0 {
+		return rules
+	}
+	if rules := n.rules[RouteMethodAny]; len(rules) > 0 {
+		return rules
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+
+	"auth-center/config"
+)
+
+// signingKeyLabel 由应用密钥派生请求签名密钥时使用的标签，SDK 使用相同的派生方式
+const signingKeyLabel = "auth-center request signing"
+
+// HashAppSecret 计算应用密钥的 SHA-256 哈希（十六进制）
+// 应用密钥为 32 字节随机数，无需加盐或慢哈希
+func HashAppSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// DeriveSigningKey 由应用密钥派生请求签名密钥：hex(HMAC-SHA256(app_secret, "auth-center request signing"))
+// 签名密钥与存储的密钥哈希无关，读取 app_secrets 表不能伪造签名
+func DeriveSigningKey(secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signingKeyLabel))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// EncryptSigningKey 使用服务端密钥（AES-256-GCM）加密签名密钥，binding 作为附加数据将密文绑定到对应的密钥记录
+// 返回：base64(nonce+密文)
+func EncryptSigningKey(signingKey, binding string) (string, error) {
+	aead, err := signingKeyCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(signingKey), []byte(binding))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSigningKey 解密 EncryptSigningKey 加密的签名密钥，服务端密钥或 binding 不一致时返回错误
+func DecryptSigningKey(encrypted, binding string) (string, error) {
+	aead, err := signingKeyCipher()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("签名密钥格式无效")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(binding))
+	if err != nil {
+		return "", errors.New("签名密钥解密失败")
+	}
+	return string(plain), nil
+}
+
+// signingKeyCipher 根据服务端密钥构造 AES-256-GCM，未配置 [signature] encryption_key 时由 JWT 密钥派生
+func signingKeyCipher() (cipher.AEAD, error) {
+	cfg := config.GetConfig()
+	if cfg == nil {
+		return nil, errors.New("配置未加载")
+	}
+	var key [sha256.Size]byte
+	if cfg.Signature.EncryptionKey != "" {
+		key = sha256.Sum256([]byte(cfg.Signature.EncryptionKey))
+	} else {
+		mac := hmac.New(sha256.New, []byte(cfg.JWT.SecretKey))
+		mac.Write([]byte("auth-center signing key encryption"))
+		copy(key[:], mac.Sum(nil))
+	}
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
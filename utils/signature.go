@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// 请求签名头
+const (
+	SignatureHeaderAppID     = "X-App-Id"
+	SignatureHeaderTimestamp = "X-Timestamp"
+	SignatureHeaderNonce     = "X-Nonce"
+	SignatureHeaderSignature = "X-Signature"
+)
+
+// CanonicalRequest 构造待签名字符串：
+// 请求方法\n请求URI（路径+查询参数）\n时间戳\nnonce\n请求体 SHA-256（十六进制）
+func CanonicalRequest(method, requestURI, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		requestURI,
+		timestamp,
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+}
+
+// SignRequest 使用签名密钥计算请求签名（HMAC-SHA256，十六进制）
+// 签名密钥由应用密钥派生（即 DeriveSigningKey 的结果），服务端加密保存签名密钥，无需保存明文密钥
+func SignRequest(signingKey, method, requestURI, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(CanonicalRequest(method, requestURI, timestamp, nonce, body)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyRequestSignature 常量时间比较请求签名
+func VerifyRequestSignature(signingKey, signature, method, requestURI, timestamp, nonce string, body []byte) bool {
+	expected := SignRequest(signingKey, method, requestURI, timestamp, nonce, body)
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(signature)))
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrWebhookAddress 回调地址不是 https 或指向内网、本机、链路本地等非公网地址
+var ErrWebhookAddress = errors.New("回调地址须为 https，且不能指向内网、本机或链路本地地址")
+
+// sharedAddressSpace 运营商级 NAT 地址段（100.64.0.0/10），部分云厂商的元数据服务位于其中
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// IsPublicIP 是否为可对外回调的公网地址
+func IsPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip))
+}
+
+// ValidateWebhookURL 校验回调地址：须为 https，主机名解析出的地址均须为公网地址
+// 保存时校验只能拦截明显的内网地址，发送时还需使用 NewWebhookClient 防止 DNS 重绑定
+func ValidateWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return ErrWebhookAddress
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("解析回调地址失败: %v", err)
+	}
+	for _, addr := range addrs {
+		if !IsPublicIP(addr.IP) {
+			return ErrWebhookAddress
+		}
+	}
+	return nil
+}
+
+// NewWebhookClient 发送回调的 HTTP 客户端
+// 建立连接时校验实际连接的地址，拒绝非公网地址；不使用环境代理，不跟随重定向
+func NewWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+				return ErrWebhookAddress
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: timeout},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// PostWebhook 以 JSON POST 到回调地址，地址不合法或响应状态码不是 2xx 时返回错误
+func PostWebhook(client *http.Client, rawURL string, payload []byte) error {
+	if u, err := url.Parse(rawURL); err != nil || u.Scheme != "https" {
+		return ErrWebhookAddress
+	}
+	resp, err := client.Post(rawURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}